@@ -0,0 +1,41 @@
+//Package httpmw contains HTTP middleware that can be shared
+//by the servers in this repo. Each middleware is an Adapter,
+//which wraps an http.Handler, and Adapters can be combined
+//using Chain or New. See ../main.go for an example.
+package httpmw
+
+import (
+	"net/http"
+)
+
+//Adapter is the type for adapter functions.
+//An adapter function accepts an http.Handler
+//and returns a new http.Handler that wraps the
+//input handler, providing some pre- and/or
+//post-processing.
+type Adapter func(http.Handler) http.Handler
+
+//Chain wraps `handler` with each of the adapters,
+//so that the first adapter listed runs outermost:
+//  Chain(mux, a, b, c)
+//is the same as
+//  a(b(c(mux)))
+//It does this by iterating the adapters in reverse
+//order, passing the handler to each, and resetting
+//the handler to the one returned from the adapter.
+func Chain(handler http.Handler, adapters ...Adapter) http.Handler {
+	for idx := len(adapters) - 1; idx >= 0; idx-- {
+		handler = adapters[idx](handler)
+	}
+	return handler
+}
+
+//New combines the adapters into a single Adapter
+//that can be reused to wrap several handlers or muxes
+//in the same way. The adapters run in the same order
+//as they would with Chain.
+func New(adapters ...Adapter) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return Chain(handler, adapters...)
+	}
+}
@@ -0,0 +1,48 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//marker returns an Adapter that appends `name` to
+//`markers` before and after calling the wrapped handler
+func marker(markers *[]string, name string) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*markers = append(*markers, name+" before")
+			handler.ServeHTTP(w, r)
+			*markers = append(*markers, name+" after")
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	markers := []string{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		markers = append(markers, "handler")
+	})
+
+	expected := "a before,b before,c before,handler,c after,b after,a after"
+
+	Chain(handler, marker(&markers, "a"), marker(&markers, "b"), marker(&markers, "c")).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+	if actual := strings.Join(markers, ","); actual != expected {
+		t.Errorf("Chain: expected %s but got %s", expected, actual)
+	}
+
+	markers = []string{}
+	adapter := New(marker(&markers, "a"), marker(&markers, "b"), marker(&markers, "c"))
+	adapter(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+	if actual := strings.Join(markers, ","); actual != expected {
+		t.Errorf("New: expected %s but got %s", expected, actual)
+	}
+
+	markers = []string{}
+	Chain(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+	if actual := strings.Join(markers, ","); actual != "handler" {
+		t.Errorf("Chain with no adapters: expected handler but got %s", actual)
+	}
+}
@@ -0,0 +1,164 @@
+package httpmw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const headerAPIKey = "X-API-Key"
+
+//paramAPIKey is the query string parameter
+//checked when there's no X-API-Key header
+const paramAPIKey = "api_key"
+
+//APIKeyOwnerKey is the request context key under
+//which the owner of the request's API key is stored
+const APIKeyOwnerKey contextKey = "apiKeyOwner"
+
+//ErrKeyNotFound is returned by a KeyStore
+//when there's no such API key
+var ErrKeyNotFound = errors.New("API key not found")
+
+//APIKey describes an API key handed out to a partner
+type APIKey struct {
+	//Owner identifies who the key belongs to
+	Owner string `json:"owner"`
+	//Enabled is false if the key has been turned off
+	Enabled bool `json:"enabled"`
+}
+
+//KeyStore looks up API keys
+type KeyStore interface {
+	//GetKey returns the APIKey for `key`,
+	//or ErrKeyNotFound if there's no such key
+	GetKey(key string) (*APIKey, error)
+}
+
+//StaticKeys is an in-memory KeyStore,
+//mapping each key to its APIKey
+type StaticKeys map[string]*APIKey
+
+//GetKey returns the APIKey for `key`
+func (sk StaticKeys) GetKey(key string) (*APIKey, error) {
+	apiKey, found := sk[key]
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+	return apiKey, nil
+}
+
+//KeyFile is a KeyStore loaded from a JSON file like
+//  {"3f9a...": {"owner": "partner1", "enabled": true}}
+//Call Reload to pick up changes to the file without
+//restarting the server.
+type KeyFile struct {
+	mx       sync.RWMutex
+	filePath string
+	keys     StaticKeys
+}
+
+//LoadKeyFile loads the API keys in `filePath`
+func LoadKeyFile(filePath string) (*KeyFile, error) {
+	kf := &KeyFile{filePath: filePath}
+	if err := kf.Reload(); err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
+
+//Reload reloads the keys from the file. If the
+//file can't be loaded, the current keys are kept.
+func (kf *KeyFile) Reload() error {
+	f, err := os.Open(kf.filePath)
+	if err != nil {
+		return fmt.Errorf("error opening API keys file: %v", err)
+	}
+	defer f.Close()
+
+	keys := StaticKeys{}
+	if err := json.NewDecoder(f).Decode(&keys); err != nil {
+		return fmt.Errorf("error decoding API keys file: %v", err)
+	}
+	kf.mx.Lock()
+	kf.keys = keys
+	kf.mx.Unlock()
+	return nil
+}
+
+//GetKey returns the APIKey for `key`
+func (kf *KeyFile) GetKey(key string) (*APIKey, error) {
+	kf.mx.RLock()
+	defer kf.mx.RUnlock()
+	return kf.keys.GetKey(key)
+}
+
+//APIKeyOwnerFromContext returns the owner of the API key
+//stored in `ctx`, or an empty string if there isn't one
+func APIKeyOwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(APIKeyOwnerKey).(string)
+	return owner
+}
+
+//requestAPIKey returns the API key from the X-API-Key
+//header, or from the api_key query string parameter
+//if there's no header
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(headerAPIKey); len(key) > 0 {
+		return key
+	}
+	return r.URL.Query().Get(paramAPIKey)
+}
+
+//RequireAPIKey returns an Adapter that requires a valid API key
+//on every request, except for those whose path is in `exempt`.
+//The key is read from the X-API-Key header, or the api_key query
+//string parameter if there's no header. Clients should prefer
+//the header, as query strings end up in logs. Requests with a
+//missing or unknown key get a 401, and those with a disabled key
+//get a 403. Otherwise the key's owner is stored in the request
+//context, and recorded as the user by LogRequests. To rate limit
+//each key separately, install RateLimit inside of this, with a
+//KeyFunc that returns APIKeyOwnerFromContext(r.Context()).
+func RequireAPIKey(store KeyStore, exempt ...string) Adapter {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			key := requestAPIKey(r)
+			if len(key) == 0 {
+				respondError(w, http.StatusUnauthorized, "missing API key")
+				return
+			}
+			apiKey, err := store.GetKey(key)
+			if err == ErrKeyNotFound {
+				respondError(w, http.StatusUnauthorized, "invalid API key")
+				return
+			}
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "error checking API key")
+				return
+			}
+			if !apiKey.Enabled {
+				respondError(w, http.StatusForbidden, "API key is disabled")
+				return
+			}
+
+			setLogUser(r.Context(), apiKey.Owner)
+			ctx := context.WithValue(r.Context(), APIKeyOwnerKey, apiKey.Owner)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
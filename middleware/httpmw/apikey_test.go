@@ -0,0 +1,172 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKeys = StaticKeys{
+	"key-1":   {Owner: "partner1", Enabled: true},
+	"key-2":   {Owner: "partner2", Enabled: true},
+	"key-off": {Owner: "partner3", Enabled: false},
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	cases := []struct {
+		name           string
+		header         string
+		query          string
+		expectedStatus int
+		expectedOwner  string
+	}{
+		{"missing key", "", "", http.StatusUnauthorized, ""},
+		{"unknown key", "nope", "", http.StatusUnauthorized, ""},
+		{"disabled key", "key-off", "", http.StatusForbidden, ""},
+		{"header", "key-1", "", http.StatusOK, "partner1"},
+		{"query", "", "key-2", http.StatusOK, "partner2"},
+		{"header wins over query", "key-1", "key-2", http.StatusOK, "partner1"},
+		{"header wins even if unknown", "nope", "key-2", http.StatusUnauthorized, ""},
+	}
+
+	for _, c := range cases {
+		owner := ""
+		handler := RequireAPIKey(testKeys, "/health")(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				owner = APIKeyOwnerFromContext(r.Context())
+			}))
+
+		w := httptest.NewRecorder()
+		path := "/zips/city/seattle"
+		if len(c.query) > 0 {
+			path += "?api_key=" + c.query
+		}
+		r := httptest.NewRequest("GET", path, nil)
+		if len(c.header) > 0 {
+			r.Header.Set(headerAPIKey, c.header)
+		}
+		handler.ServeHTTP(w, r)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		if owner != c.expectedOwner {
+			t.Errorf("%s: expected owner %q but got %q", c.name, c.expectedOwner, owner)
+		}
+		if w.Code != http.StatusOK {
+			errResp := &errorResponse{}
+			if err := json.NewDecoder(w.Body).Decode(errResp); err != nil || len(errResp.Error) == 0 {
+				t.Errorf("%s: expected a JSON error but got %q", c.name, w.Body.String())
+			}
+		}
+	}
+
+	//exempt paths don't need a key
+	w := httptest.NewRecorder()
+	RequireAPIKey(testKeys, "/health")(http.HandlerFunc(helloHandler)).ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d for exempt path but got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRequireAPIKeyLogsOwner(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := Chain(http.HandlerFunc(helloHandler),
+		LogRequestsWithOptions(logger, &LogOptions{Format: LogFormatJSON}),
+		RequireAPIKey(testKeys))
+
+	r := httptest.NewRequest("GET", "/zips/city/seattle", nil)
+	r.Header.Set(headerAPIKey, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	rec := &logRecord{}
+	if err := json.Unmarshal(buf.Bytes(), rec); err != nil {
+		t.Fatalf("error parsing log line %q: %v", buf.String(), err)
+	}
+	if rec.User != "partner1" {
+		t.Errorf("expected the key owner in the log line, got %q", buf.String())
+	}
+}
+
+func TestRequireAPIKeyRateLimit(t *testing.T) {
+	handler := Chain(http.HandlerFunc(helloHandler),
+		RequireAPIKey(testKeys),
+		RateLimit(&RateLimitConfig{
+			RequestsPerSecond: 1,
+			Burst:             1,
+			KeyFunc: func(r *http.Request) string {
+				return APIKeyOwnerFromContext(r.Context())
+			},
+		}))
+
+	request := func(key string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/zips/city/seattle", nil)
+		r.Header.Set(headerAPIKey, key)
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	//both keys come from the same IP, but get their own buckets
+	if status := request("key-1"); status != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, status)
+	}
+	if status := request("key-1"); status != http.StatusTooManyRequests {
+		t.Errorf("expected status %d but got %d", http.StatusTooManyRequests, status)
+	}
+	if status := request("key-2"); status != http.StatusOK {
+		t.Errorf("expected status %d for another key but got %d", http.StatusOK, status)
+	}
+}
+
+func TestKeyFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "keys.json")
+
+	write := func(contents string) {
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatalf("error writing keys file: %v", err)
+		}
+	}
+	write(`{"key-1": {"owner": "partner1", "enabled": true}}`)
+	kf, err := LoadKeyFile(filePath)
+	if err != nil {
+		t.Fatalf("error loading keys file: %v", err)
+	}
+	if key, err := kf.GetKey("key-1"); err != nil || key.Owner != "partner1" || !key.Enabled {
+		t.Errorf("unexpected key %+v, %v", key, err)
+	}
+
+	write(`{"key-1": {"owner": "partner1", "enabled": false}, "key-2": {"owner": "partner2", "enabled": true}}`)
+	if err := kf.Reload(); err != nil {
+		t.Fatalf("error reloading keys file: %v", err)
+	}
+	if key, err := kf.GetKey("key-1"); err != nil || key.Enabled {
+		t.Errorf("expected key-1 to be disabled after reload, got %+v, %v", key, err)
+	}
+	if _, err := kf.GetKey("key-2"); err != nil {
+		t.Errorf("expected key-2 to be added after reload: %v", err)
+	}
+
+	//a broken file leaves the current keys in place
+	write(`{"key-1": `)
+	if err := kf.Reload(); err == nil || !strings.Contains(err.Error(), "decoding") {
+		t.Errorf("expected a decoding error but got %v", err)
+	}
+	if _, err := kf.GetKey("key-2"); err != nil {
+		t.Errorf("expected the previous keys to be kept: %v", err)
+	}
+	if _, err := kf.GetKey("nope"); err != ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound but got %v", err)
+	}
+}
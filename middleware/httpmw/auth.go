@@ -0,0 +1,103 @@
+package httpmw
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+)
+
+const schemeBearer = "Bearer"
+
+//PrincipalKey is the request context key under
+//which the authenticated principal is stored
+const PrincipalKey contextKey = "principal"
+
+//ErrInvalidToken is returned by a TokenValidator
+//when the token isn't valid
+var ErrInvalidToken = errors.New("invalid token")
+
+//TokenValidator validates a bearer token, returning the
+//authenticated principal, or an error if the token is invalid
+type TokenValidator func(token string) (string, error)
+
+//StaticToken returns a TokenValidator that accepts only
+//`token`, authenticating the caller as `principal`
+func StaticToken(token string, principal string) TokenValidator {
+	return func(candidate string) (string, error) {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) != 1 {
+			return "", ErrInvalidToken
+		}
+		return principal, nil
+	}
+}
+
+//PrincipalFromContext returns the authenticated principal
+//stored in `ctx`, or an empty string if there isn't one
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(PrincipalKey).(string)
+	return principal
+}
+
+//bearerToken returns the token from an
+//`Authorization: Bearer <token>` request header
+func bearerToken(r *http.Request) (string, error) {
+	auth := r.Header.Get(headerAuthorization)
+	if len(auth) == 0 {
+		return "", errors.New("missing Authorization header")
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], schemeBearer) {
+		return "", errors.New("Authorization header must use the Bearer scheme")
+	}
+	token := strings.TrimSpace(parts[1])
+	if len(token) == 0 {
+		return "", errors.New("missing bearer token")
+	}
+	return token, nil
+}
+
+//BearerAuth returns an Adapter that requires a valid bearer
+//token on every request, except for those whose path is in
+//`exempt` (e.g., /health). Requests without a valid token
+//get a 401 response. Otherwise the principal returned from
+//`validate` is stored in the request context, and recorded
+//as the user by LogRequests, before calling the wrapped handler.
+func BearerAuth(validate TokenValidator, exempt ...string) Adapter {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			token, err := bearerToken(r)
+			if err != nil {
+				w.Header().Set(headerWWWAuthenticate, schemeBearer)
+				respondError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			principal, err := validate(token)
+			if err != nil {
+				w.Header().Set(headerWWWAuthenticate, schemeBearer+` error="invalid_token"`)
+				respondError(w, http.StatusUnauthorized, "invalid bearer token")
+				return
+			}
+
+			setLogUser(r.Context(), principal)
+			ctx := context.WithValue(r.Context(), PrincipalKey, principal)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBearerAuth(t *testing.T) {
+	cases := []struct {
+		name              string
+		path              string
+		auth              string
+		expectedStatus    int
+		expectedPrincipal string
+	}{
+		{"missing header", "/v1/tasks", "", http.StatusUnauthorized, ""},
+		{"wrong scheme", "/v1/tasks", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+		{"empty token", "/v1/tasks", "Bearer ", http.StatusUnauthorized, ""},
+		{"bad token", "/v1/tasks", "Bearer not-the-token", http.StatusUnauthorized, ""},
+		{"success", "/v1/tasks", "Bearer s3cret", http.StatusOK, "admin"},
+		{"lower-case scheme", "/v1/tasks", "bearer s3cret", http.StatusOK, "admin"},
+		{"exempt path", "/health", "", http.StatusOK, ""},
+	}
+
+	for _, c := range cases {
+		principal := ""
+		handlerCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerCalled = true
+			principal = PrincipalFromContext(r.Context())
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", c.path, nil)
+		if len(c.auth) > 0 {
+			r.Header.Set(headerAuthorization, c.auth)
+		}
+		BearerAuth(StaticToken("s3cret", "admin"), "/health")(handler).ServeHTTP(w, r)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		if principal != c.expectedPrincipal {
+			t.Errorf("%s: expected principal %q but got %q", c.name, c.expectedPrincipal, principal)
+		}
+		if c.expectedStatus == http.StatusUnauthorized {
+			if handlerCalled {
+				t.Errorf("%s: handler should not be called", c.name)
+			}
+			if !strings.HasPrefix(w.Header().Get(headerWWWAuthenticate), schemeBearer) {
+				t.Errorf("%s: expected WWW-Authenticate header but got %q", c.name, w.Header().Get(headerWWWAuthenticate))
+			}
+			if !strings.HasPrefix(w.Body.String(), `{"error":`) {
+				t.Errorf("%s: expected JSON error but got %q", c.name, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package httpmw
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const schemeBasic = "Basic"
+
+//CredentialChecker returns true if `username`
+//and `password` are valid credentials
+type CredentialChecker func(username string, password string) bool
+
+//StaticCredentials returns a CredentialChecker that accepts
+//only `username` and `password`. Both are compared in constant
+//time, and both comparisons always run, so the time taken
+//doesn't reveal which one was wrong. If either is empty,
+//no credentials are accepted.
+func StaticCredentials(username string, password string) CredentialChecker {
+	return func(u string, p string) bool {
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username))
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password))
+		return len(username) > 0 && len(password) > 0 && userOK&passOK == 1
+	}
+}
+
+//basicCredentials returns the username and password from an
+//`Authorization: Basic <base64(username:password)>` request header
+func basicCredentials(r *http.Request) (string, string, error) {
+	auth := r.Header.Get(headerAuthorization)
+	if len(auth) == 0 {
+		return "", "", errors.New("missing Authorization header")
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], schemeBasic) {
+		return "", "", errors.New("Authorization header must use the Basic scheme")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return "", "", errors.New("malformed Basic credentials")
+	}
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return "", "", errors.New("malformed Basic credentials")
+	}
+	return credentials[0], credentials[1], nil
+}
+
+//BasicAuth returns an Adapter that requires HTTP Basic
+//authentication, for simple admin and debug routes. If `check`
+//is nil, the credentials are compared against the ADMINUSER
+//and ADMINPASS environment variables, and if those aren't set,
+//every request is rejected. Requests without valid credentials
+//get a 401 response with a WWW-Authenticate header naming `realm`.
+//Otherwise the username is stored in the request context as the
+//principal, and recorded as the user by LogRequests.
+func BasicAuth(realm string, check CredentialChecker) Adapter {
+	if check == nil {
+		check = StaticCredentials(os.Getenv("ADMINUSER"), os.Getenv("ADMINPASS"))
+	}
+	challenge := schemeBasic + " realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, err := basicCredentials(r)
+			if err == nil && !check(username, password) {
+				err = errors.New("invalid username or password")
+			}
+			if err != nil {
+				w.Header().Set(headerWWWAuthenticate, challenge)
+				respondError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			setLogUser(r.Context(), username)
+			ctx := context.WithValue(r.Context(), PrincipalKey, username)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
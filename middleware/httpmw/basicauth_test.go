@@ -0,0 +1,118 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func basicHeader(credentials string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+func TestBasicAuth(t *testing.T) {
+	cases := []struct {
+		name           string
+		auth           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"missing header", "", http.StatusUnauthorized, "missing Authorization header"},
+		{"wrong scheme", "Bearer s3cret", http.StatusUnauthorized, "Basic scheme"},
+		{"malformed base64", "Basic not*base64!", http.StatusUnauthorized, "malformed"},
+		{"missing colon", basicHeader("admin"), http.StatusUnauthorized, "malformed"},
+		{"wrong password", basicHeader("admin:wrong"), http.StatusUnauthorized, "invalid username or password"},
+		{"wrong username", basicHeader("root:s3cret"), http.StatusUnauthorized, "invalid username or password"},
+		{"success", basicHeader("admin:s3cret"), http.StatusOK, ""},
+		{"colon in password", basicHeader("admin:s3:cret"), http.StatusUnauthorized, "invalid username or password"},
+	}
+
+	for _, c := range cases {
+		principal := ""
+		handler := BasicAuth("admin", StaticCredentials("admin", "s3cret"))(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFromContext(r.Context())
+			}))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/admin/reload", nil)
+		if len(c.auth) > 0 {
+			r.Header.Set(headerAuthorization, c.auth)
+		}
+		handler.ServeHTTP(w, r)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		if c.expectedStatus == http.StatusOK {
+			if principal != "admin" {
+				t.Errorf("%s: expected principal admin but got %q", c.name, principal)
+			}
+			continue
+		}
+		if challenge := w.Header().Get(headerWWWAuthenticate); challenge != `Basic realm="admin", charset="UTF-8"` {
+			t.Errorf("%s: unexpected WWW-Authenticate %q", c.name, challenge)
+		}
+		if !strings.Contains(w.Body.String(), c.expectedError) {
+			t.Errorf("%s: expected error containing %q but got %q", c.name, c.expectedError, w.Body.String())
+		}
+	}
+}
+
+func TestStaticCredentials(t *testing.T) {
+	check := StaticCredentials("admin", "s3cret")
+	if !check("admin", "s3cret") {
+		t.Error("expected valid credentials to be accepted")
+	}
+	for _, creds := range [][2]string{{"admin", ""}, {"", "s3cret"}, {"admin", "s3cre"}, {"admin", "s3cret!"}} {
+		if check(creds[0], creds[1]) {
+			t.Errorf("expected %v to be rejected", creds)
+		}
+	}
+	if StaticCredentials("", "")("", "") {
+		t.Error("expected empty configured credentials to reject everything")
+	}
+}
+
+func TestBasicAuthFromEnv(t *testing.T) {
+	os.Setenv("ADMINUSER", "envadmin")
+	os.Setenv("ADMINPASS", "envpass")
+	defer os.Unsetenv("ADMINUSER")
+	defer os.Unsetenv("ADMINPASS")
+
+	handler := BasicAuth("admin", nil)(http.HandlerFunc(helloHandler))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/admin/reload", nil)
+	r.Header.Set(headerAuthorization, basicHeader("envadmin:envpass"))
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected env credentials to be accepted but got %d", w.Code)
+	}
+}
+
+func TestBasicAuthLogsUser(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := Chain(http.HandlerFunc(helloHandler),
+		LogRequestsWithOptions(logger, &LogOptions{Format: LogFormatCombined}),
+		BasicAuth("admin", StaticCredentials("frank", "s3cret")))
+
+	r := httptest.NewRequest("GET", "/admin/reload", nil)
+	r.Header.Set(headerAuthorization, basicHeader("frank:s3cret"))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.HasPrefix(buf.String(), "192.0.2.1 - frank [") {
+		t.Errorf("expected the user in the CLF line, got %q", buf.String())
+	}
+
+	buf.Reset()
+	r = httptest.NewRequest("GET", "/admin/reload", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.HasPrefix(buf.String(), "192.0.2.1 - - [") {
+		t.Errorf("expected no user for a rejected request, got %q", buf.String())
+	}
+}
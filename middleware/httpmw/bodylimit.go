@@ -0,0 +1,133 @@
+package httpmw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//BodyLimitConfig configures the LimitBody middleware
+type BodyLimitConfig struct {
+	//MaxBytes is the largest request body accepted
+	//by routes that don't have their own limit
+	MaxBytes int64
+	//Routes overrides MaxBytes for particular paths.
+	//Like http.ServeMux patterns, keys ending in a slash
+	//match the whole subtree, and the longest match wins.
+	Routes map[string]int64
+}
+
+//limitFor returns the body size limit for `path`
+func (c *BodyLimitConfig) limitFor(path string) int64 {
+	limit := c.MaxBytes
+	matched := -1
+	for pattern, max := range c.Routes {
+		if len(pattern) <= matched {
+			continue
+		}
+		if pattern == path || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern)) {
+			limit = max
+			matched = len(pattern)
+		}
+	}
+	return limit
+}
+
+//limitedBody wraps an http.MaxBytesReader and
+//remembers whether the client went over the limit
+type limitedBody struct {
+	io.ReadCloser
+	tooLarge bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		lb.tooLarge = true
+	}
+	return n, err
+}
+
+//bodyLimitWriter replaces whatever response the handler
+//writes with a 413 if the handler read past the limit.
+//Handlers typically report a failed json.Decode as a
+//400, which would be misleading to the client.
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	msg         string
+	wroteHeader bool
+	rejected    bool
+}
+
+func (bw *bodyLimitWriter) WriteHeader(status int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	if bw.body.tooLarge {
+		bw.rejected = true
+		bw.Header().Del(headerContentLength)
+		respondError(bw.ResponseWriter, http.StatusRequestEntityTooLarge, bw.msg)
+		return
+	}
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+func (bw *bodyLimitWriter) Write(p []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	if bw.rejected {
+		//pretend it worked so the handler carries on
+		return len(p), nil
+	}
+	return bw.ResponseWriter.Write(p)
+}
+
+//Flush passes through to the real ResponseWriter,
+//for handlers that stream, unless the response was
+//replaced with a 413
+func (bw *bodyLimitWriter) Flush() {
+	if bw.rejected {
+		return
+	}
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//LimitBody returns an Adapter that stops clients from
+//sending request bodies larger than the configured limit.
+//Requests that declare a larger Content-Length are rejected
+//with a 413 before the handler is called. Bodies of unknown
+//length are cut off by http.MaxBytesReader once they go over
+//the limit, and the handler's response is replaced by a 413.
+func LimitBody(config *BodyLimitConfig) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := config.limitFor(r.URL.Path)
+			if limit <= 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			msg := fmt.Sprintf("request body must be no larger than %d bytes", limit)
+			if r.ContentLength > limit {
+				respondError(w, http.StatusRequestEntityTooLarge, msg)
+				return
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+			r.Body = body
+			bw := &bodyLimitWriter{ResponseWriter: w, body: body, msg: msg}
+			handler.ServeHTTP(bw, r)
+			if !bw.wroteHeader && body.tooLarge {
+				bw.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//decodeHandler mimics a handler that decodes a JSON
+//request body and reports failures as a 400
+func decodeHandler(called *bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*called = true
+		v := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			http.Error(w, "error decoding JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+//oversizedJSON returns a JSON body of at least n bytes
+func oversizedJSON(n int) string {
+	return `{"title":"` + strings.Repeat("x", n) + `"}`
+}
+
+func assert413(t *testing.T, w *httptest.ResponseRecorder) {
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d but got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+	errResp := &errorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(errResp); err != nil {
+		t.Fatalf("error decoding error body: %v", err)
+	}
+	if !strings.Contains(errResp.Error, "no larger than") {
+		t.Errorf("unexpected error message %q", errResp.Error)
+	}
+}
+
+func TestLimitBodyContentLength(t *testing.T) {
+	called := false
+	handler := LimitBody(&BodyLimitConfig{MaxBytes: 1024})(decodeHandler(&called))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(oversizedJSON(2048)))
+	handler.ServeHTTP(w, r)
+	assert413(t, w)
+	if called {
+		t.Error("handler should not have been called")
+	}
+}
+
+func TestLimitBodyStreamed(t *testing.T) {
+	called := false
+	handler := LimitBody(&BodyLimitConfig{MaxBytes: 1024})(decodeHandler(&called))
+
+	//an io.Pipe has no known length, so the
+	//body is sent without a Content-Length
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, oversizedJSON(1<<20))
+		pw.Close()
+	}()
+	r := httptest.NewRequest("POST", "/v1/tasks", pr)
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert413(t, w)
+	if !called {
+		t.Error("handler should have been called for a body of unknown length")
+	}
+	//unblock the writer
+	io.Copy(ioutil.Discard, pr)
+}
+
+func TestLimitBodyRoutes(t *testing.T) {
+	config := &BodyLimitConfig{
+		MaxBytes: 1024,
+		Routes: map[string]int64{
+			"/v1/uploads/":      1 << 20,
+			"/v1/uploads/small": 16,
+			"/v1/unlimited":     0,
+		},
+	}
+
+	cases := []struct {
+		path           string
+		size           int
+		expectedStatus int
+	}{
+		{"/v1/tasks", 100, http.StatusCreated},
+		{"/v1/tasks", 2048, http.StatusRequestEntityTooLarge},
+		{"/v1/uploads/photo", 2048, http.StatusCreated},
+		{"/v1/uploads/small", 100, http.StatusRequestEntityTooLarge},
+		{"/v1/unlimited", 1 << 20, http.StatusCreated},
+	}
+
+	for _, c := range cases {
+		called := false
+		handler := LimitBody(config)(decodeHandler(&called))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", c.path, strings.NewReader(oversizedJSON(c.size))))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s with %d bytes: expected status %d but got %d", c.path, c.size, c.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestLimitBodyFlush(t *testing.T) {
+	handler := LimitBody(&BodyLimitConfig{MaxBytes: 10})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: hello\n\n"))
+			w.(http.Flusher).Flush()
+		}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/events", nil))
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
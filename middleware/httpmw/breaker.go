@@ -0,0 +1,202 @@
+package httpmw
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//breakerState is the state of a circuit breaker
+type breakerState int
+
+const (
+	//breakerClosed lets all requests through
+	breakerClosed breakerState = iota
+	//breakerOpen fails all requests fast
+	breakerOpen
+	//breakerHalfOpen lets one probe request through
+	//to see if the handler has recovered
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+//BreakerConfig configures a circuit breaker
+type BreakerConfig struct {
+	//Name identifies the breaker in log messages and metrics
+	Name string
+	//Threshold is the number of consecutive failures
+	//that opens the breaker. Defaults to 5.
+	Threshold int
+	//CoolDown is how long the breaker stays open before
+	//letting a probe request through. Defaults to 30 seconds.
+	CoolDown time.Duration
+	//Statsd, if set, is sent a "breaker_transitions" counter
+	//tagged with the breaker name and its new state
+	Statsd *StatsdClient
+}
+
+//CircuitBreaker stops sending requests to a handler that keeps
+//failing, such as one whose database is down, so that clients
+//get a quick 503 instead of waiting for every request to time
+//out. After Threshold consecutive 5xx responses (or panics) the
+//breaker opens, and all requests fail fast. Once CoolDown has
+//passed it lets a single probe request through: if that one
+//succeeds the breaker closes again, and if it fails the breaker
+//stays open for another CoolDown.
+type CircuitBreaker struct {
+	mx          sync.Mutex
+	name        string
+	threshold   int
+	coolDown    time.Duration
+	statsd      *StatsdClient
+	logger      *log.Logger
+	state       breakerState
+	failures    int
+	openedAt    time.Time
+	transitions int64
+	rejected    int64
+	now         func() time.Time
+}
+
+//NewCircuitBreaker creates a new CircuitBreaker that logs
+//its state transitions to `logger`
+func NewCircuitBreaker(config *BreakerConfig, logger *log.Logger) *CircuitBreaker {
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	coolDown := config.CoolDown
+	if coolDown <= 0 {
+		coolDown = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		name:      config.Name,
+		threshold: threshold,
+		coolDown:  coolDown,
+		statsd:    config.Statsd,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+//State returns the breaker's current state:
+//"closed", "open", or "half-open"
+func (cb *CircuitBreaker) State() string {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+	return cb.state.String()
+}
+
+//Stats returns the breaker's state and counters
+//in a form suitable for publishing with expvar.Func
+func (cb *CircuitBreaker) Stats() interface{} {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+	return map[string]interface{}{
+		"state":       cb.state.String(),
+		"failures":    cb.failures,
+		"transitions": cb.transitions,
+		"rejected":    cb.rejected,
+	}
+}
+
+//setState moves the breaker to `state`, logging the transition.
+//The caller must hold the lock.
+func (cb *CircuitBreaker) setState(state breakerState) {
+	if state == cb.state {
+		return
+	}
+	cb.logger.Printf("circuit breaker %s: %s -> %s", cb.name, cb.state, state)
+	cb.state = state
+	cb.transitions++
+	if state == breakerOpen {
+		cb.openedAt = cb.now()
+	}
+	if cb.statsd != nil {
+		cb.statsd.Count("breaker_transitions", 1, "breaker:"+cb.name, "state:"+state.String())
+	}
+}
+
+//allow returns true if a request may go through to the
+//handler, and whether it's the half-open probe. If not, it
+//returns how long until the breaker will let a probe through.
+func (cb *CircuitBreaker) allow() (bool, bool, time.Duration) {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+
+	switch cb.state {
+	case breakerClosed:
+		return true, false, 0
+	case breakerOpen:
+		wait := cb.openedAt.Add(cb.coolDown).Sub(cb.now())
+		if wait <= 0 {
+			cb.setState(breakerHalfOpen)
+			return true, true, 0
+		}
+		cb.rejected++
+		return false, false, wait
+	default:
+		//a probe is already running, so wait for
+		//it to decide which way the breaker goes
+		cb.rejected++
+		return false, false, cb.coolDown
+	}
+}
+
+//record updates the breaker with the result of a request
+func (cb *CircuitBreaker) record(probe bool, failed bool) {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+
+	if !failed {
+		cb.failures = 0
+		if probe {
+			cb.setState(breakerClosed)
+		}
+		return
+	}
+	cb.failures++
+	if probe || (cb.state == breakerClosed && cb.failures >= cb.threshold) {
+		cb.setState(breakerOpen)
+	}
+}
+
+//Protect is an Adapter that wraps `handler` with the breaker.
+//Wrap each route that depends on the same failing resource with
+//the same breaker, and install it inside of Recover, as panics
+//are counted as failures and then passed along.
+func (cb *CircuitBreaker) Protect(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, probe, wait := cb.allow()
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set(headerRetryAfter, strconv.Itoa(retryAfter))
+			respondError(w, http.StatusServiceUnavailable, "service is temporarily unavailable, please try again later")
+			return
+		}
+
+		rw := newResponseRecorder(w)
+		completed := false
+		defer func() {
+			cb.record(probe, !completed || rw.status >= 500)
+		}()
+		handler.ServeHTTP(rw, r)
+		completed = true
+	})
+}
@@ -0,0 +1,147 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cb := NewCircuitBreaker(&BreakerConfig{
+		Name:      "tasks",
+		Threshold: 3,
+		CoolDown:  10 * time.Second,
+	}, log.New(buf, "", 0))
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+
+	failing := true
+	calls := 0
+	handler := cb.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			http.Error(w, "mongo is down", http.StatusInternalServerError)
+			return
+		}
+		helloHandler(w, r)
+	}))
+
+	request := func(expectedStatus int) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+		if w.Code != expectedStatus {
+			t.Fatalf("expected status %d but got %d", expectedStatus, w.Code)
+		}
+		return w
+	}
+
+	//a success resets the count of consecutive failures
+	request(http.StatusInternalServerError)
+	request(http.StatusInternalServerError)
+	failing = false
+	request(http.StatusOK)
+	failing = true
+	request(http.StatusInternalServerError)
+	request(http.StatusInternalServerError)
+	if state := cb.State(); state != "closed" {
+		t.Fatalf("expected breaker to be closed but it was %s", state)
+	}
+
+	//the third consecutive failure opens it
+	request(http.StatusInternalServerError)
+	if state := cb.State(); state != "open" {
+		t.Fatalf("expected breaker to be open but it was %s", state)
+	}
+
+	//while open, requests fail fast without calling the handler
+	calls = 0
+	now = now.Add(4 * time.Second)
+	w := request(http.StatusServiceUnavailable)
+	if retryAfter := w.Header().Get(headerRetryAfter); retryAfter != "6" {
+		t.Errorf("expected Retry-After of 6 but got %q", retryAfter)
+	}
+	if calls != 0 {
+		t.Errorf("expected the handler not to be called, but it was called %d times", calls)
+	}
+
+	//after the cool-down a failed probe opens it again
+	now = now.Add(6 * time.Second)
+	request(http.StatusInternalServerError)
+	if calls != 1 {
+		t.Errorf("expected one probe request, but the handler was called %d times", calls)
+	}
+	if state := cb.State(); state != "open" {
+		t.Fatalf("expected breaker to be open after a failed probe but it was %s", state)
+	}
+	w = request(http.StatusServiceUnavailable)
+	if retryAfter := w.Header().Get(headerRetryAfter); retryAfter != "10" {
+		t.Errorf("expected Retry-After of 10 but got %q", retryAfter)
+	}
+
+	//and a successful probe closes it
+	now = now.Add(10 * time.Second)
+	failing = false
+	request(http.StatusOK)
+	if state := cb.State(); state != "closed" {
+		t.Fatalf("expected breaker to be closed after a successful probe but it was %s", state)
+	}
+	request(http.StatusOK)
+
+	output := buf.String()
+	for _, transition := range []string{
+		"circuit breaker tasks: closed -> open",
+		"circuit breaker tasks: open -> half-open",
+		"circuit breaker tasks: half-open -> open",
+		"circuit breaker tasks: half-open -> closed",
+	} {
+		if !strings.Contains(output, transition) {
+			t.Errorf("expected %q to be logged, got %q", transition, output)
+		}
+	}
+	stats := cb.Stats().(map[string]interface{})
+	if stats["transitions"] != int64(5) || stats["rejected"] != int64(2) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker(&BreakerConfig{Threshold: 1}, log.New(&bytes.Buffer{}, "", 0))
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	cb.record(false, true)
+
+	//only one probe is let through at a time
+	now = now.Add(30 * time.Second)
+	if ok, probe, _ := cb.allow(); !ok || !probe {
+		t.Fatal("expected a probe to be let through after the cool-down")
+	}
+	if ok, _, _ := cb.allow(); ok {
+		t.Error("expected requests to fail fast while the probe is running")
+	}
+	cb.record(true, false)
+	if ok, probe, _ := cb.allow(); !ok || probe {
+		t.Error("expected requests to go through once the probe succeeded")
+	}
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	cb := NewCircuitBreaker(&BreakerConfig{Threshold: 1}, log.New(&bytes.Buffer{}, "", 0))
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	}), Recover(log.New(&bytes.Buffer{}, "", 0)), cb.Protect)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	if state := cb.State(); state != "open" {
+		t.Errorf("expected a panic to open the breaker, but it was %s", state)
+	}
+}
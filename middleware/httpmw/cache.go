@@ -0,0 +1,269 @@
+package httpmw
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerXCache = "X-Cache"
+	headerAccept = "Accept"
+)
+
+const (
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+//CacheConfig configures the response cache
+type CacheConfig struct {
+	//TTL is how long a response is served from the cache
+	TTL time.Duration
+	//MaxEntries is the number of responses kept; when the
+	//cache is full, the least recently used is evicted.
+	//If it's 0 or less, there's no limit, and entries are
+	//only removed when they expire or the cache is purged.
+	MaxEntries int
+}
+
+//cacheEntry is one cached response
+type cacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+//cacheCall is a cache miss that's being handled. Concurrent
+//requests for the same key wait for it instead of all
+//calling the handler at once.
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry
+}
+
+//ResponseCache caches whole responses to GET requests
+//in memory. The entries are kept in a linked list in order
+//of use, with the map pointing to their list elements,
+//so the least recently used entry is always at the back.
+type ResponseCache struct {
+	mx         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	calls      map[string]*cacheCall
+	generation int
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+//NewResponseCache creates an empty ResponseCache
+//configured by `config`
+func NewResponseCache(config *CacheConfig) *ResponseCache {
+	return &ResponseCache{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		calls:      make(map[string]*cacheCall),
+		ttl:        config.TTL,
+		maxEntries: config.MaxEntries,
+		now:        time.Now,
+	}
+}
+
+//cacheKeyHeaders are the request headers that handlers
+//use to choose between formats and encodings, so requests
+//that differ in them may not get the same response
+var cacheKeyHeaders = []string{headerAccept, headerAcceptEncoding}
+
+//cacheKey returns the key for requests like `r`
+func cacheKey(r *http.Request) string {
+	key := r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+	for _, name := range cacheKeyHeaders {
+		key += "\n" + name + ": " + r.Header.Get(name)
+	}
+	return key
+}
+
+//get returns the unexpired entry for `key`, if any
+func (rc *ResponseCache) get(key string) *cacheEntry {
+	elem, found := rc.entries[key]
+	if !found {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if rc.now().After(entry.expires) {
+		rc.lru.Remove(elem)
+		delete(rc.entries, key)
+		return nil
+	}
+	rc.lru.MoveToFront(elem)
+	return entry
+}
+
+//add stores `entry`, evicting the least recently
+//used entry if the cache is full, if it has a limit
+func (rc *ResponseCache) add(entry *cacheEntry) {
+	if elem, found := rc.entries[entry.key]; found {
+		rc.lru.Remove(elem)
+	}
+	rc.entries[entry.key] = rc.lru.PushFront(entry)
+	for rc.maxEntries > 0 && rc.lru.Len() > rc.maxEntries {
+		oldest := rc.lru.Back()
+		rc.lru.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+//Purge removes every entry from the cache. Responses that
+//are being generated while Purge is called aren't cached,
+//as they may have been built from data that's now stale.
+func (rc *ResponseCache) Purge() {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	rc.entries = make(map[string]*list.Element)
+	rc.lru.Init()
+	rc.generation++
+}
+
+//Len returns the number of entries in the cache
+func (rc *ResponseCache) Len() int {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	return rc.lru.Len()
+}
+
+//cacheWriter passes the response through to the client,
+//and keeps a copy of it so that it can be cached
+type cacheWriter struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	buf         bytes.Buffer
+	wroteHeader bool
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+	cw.header = cloneHeader(cw.Header())
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.buf.Write(p)
+	return cw.ResponseWriter.Write(p)
+}
+
+//cloneHeader returns a deep copy of `h`
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for name, values := range h {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+//serveEntry writes a cached response to `w`
+func serveEntry(w http.ResponseWriter, entry *cacheEntry) {
+	h := w.Header()
+	for name, values := range entry.header {
+		h[name] = append([]string(nil), values...)
+	}
+	h.Set(headerXCache, cacheHit)
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+//fill calls the handler for a cache miss, and caches the
+//response if it was successful and the cache wasn't purged
+//in the meantime. It returns the entry, or nil if the
+//response couldn't be cached.
+func (rc *ResponseCache) fill(handler http.Handler, w http.ResponseWriter, r *http.Request, key string, generation int) *cacheEntry {
+	w.Header().Set(headerXCache, cacheMiss)
+	cw := &cacheWriter{ResponseWriter: w}
+	handler.ServeHTTP(cw, r)
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.status != http.StatusOK || strings.Contains(cw.header.Get(headerCacheControl), "no-store") {
+		return nil
+	}
+	entry := &cacheEntry{
+		key:     key,
+		status:  cw.status,
+		header:  cw.header,
+		body:    cw.buf.Bytes(),
+		expires: rc.now().Add(rc.ttl),
+	}
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	if rc.generation != generation {
+		return nil
+	}
+	rc.add(entry)
+	return entry
+}
+
+//Cache is an Adapter that serves successful GET responses
+//from the cache for up to the configured TTL, without calling
+//`handler`. Responses are keyed on the method, path, query
+//string, and the Accept and Accept-Encoding headers, so a
+//client isn't sent XML or gzip it didn't ask for. Each
+//response has an X-Cache header set to HIT or MISS.
+//Only 200 responses without Cache-Control: no-store are cached.
+//
+//If several requests miss on the same key at once, only the
+//first calls the handler; the rest wait and share its response.
+func (rc *ResponseCache) Cache(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+		rc.mx.Lock()
+		if entry := rc.get(key); entry != nil {
+			rc.mx.Unlock()
+			serveEntry(w, entry)
+			return
+		}
+		if call, found := rc.calls[key]; found {
+			rc.mx.Unlock()
+			<-call.done
+			if call.entry != nil {
+				serveEntry(w, call.entry)
+				return
+			}
+			//the response wasn't cacheable, so this request
+			//needs its own; no generation matches -1, so
+			//it won't be cached either
+			rc.fill(handler, w, r, key, -1)
+			return
+		}
+		call := &cacheCall{done: make(chan struct{})}
+		rc.calls[key] = call
+		generation := rc.generation
+		rc.mx.Unlock()
+
+		defer func() {
+			rc.mx.Lock()
+			delete(rc.calls, key)
+			rc.mx.Unlock()
+			close(call.done)
+		}()
+		call.entry = rc.fill(handler, w, r, key, generation)
+	})
+}
@@ -0,0 +1,243 @@
+package httpmw
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//countingHandler writes the request path and
+//counts how many times it has been called
+func countingHandler(calls *int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(calls, 1)
+		w.Header().Set(headerContentType, contentTypeText)
+		fmt.Fprintf(w, "%s #%d", r.URL.Path, n)
+	}
+}
+
+//cacheGet sends a GET request for `path` to `handler`
+func cacheGet(handler http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestResponseCacheHit(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	handler := rc.Cache(countingHandler(&calls))
+
+	w := cacheGet(handler, "/zips/code/98103")
+	if w.Header().Get(headerXCache) != cacheMiss {
+		t.Errorf("expected first request to miss but got %q", w.Header().Get(headerXCache))
+	}
+
+	w = cacheGet(handler, "/zips/code/98103")
+	if w.Header().Get(headerXCache) != cacheHit {
+		t.Errorf("expected second request to hit but got %q", w.Header().Get(headerXCache))
+	}
+	if w.Body.String() != "/zips/code/98103 #1" || w.Header().Get(headerContentType) != contentTypeText {
+		t.Errorf("expected cached response but got %q (%s)", w.Body.String(), w.Header().Get(headerContentType))
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to be called once but got %d", calls)
+	}
+
+	//different query strings and methods aren't cached together
+	cacheGet(handler, "/zips/code/98103?format=xml")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/zips/code/98103", nil))
+	if calls != 3 {
+		t.Errorf("expected handler to be called 3 times but got %d", calls)
+	}
+}
+
+func TestResponseCacheKeyHeaders(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	handler := rc.Cache(countingHandler(&calls))
+
+	get := func(accept string, encoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/zips/code/98103", nil)
+		r.Header.Set(headerAccept, accept)
+		r.Header.Set(headerAcceptEncoding, encoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		accept   string
+		encoding string
+		xcache   string
+	}{
+		{"application/json", "", cacheMiss},
+		{"application/json", "", cacheHit},
+		{"application/xml", "", cacheMiss},
+		{"application/json", "gzip", cacheMiss},
+		{"application/xml", "", cacheHit},
+		{"application/json", "gzip", cacheHit},
+	}
+	for _, c := range cases {
+		if w := get(c.accept, c.encoding); w.Header().Get(headerXCache) != c.xcache {
+			t.Errorf("Accept %q, Accept-Encoding %q: expected %s but got %q", c.accept, c.encoding, c.xcache, w.Header().Get(headerXCache))
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected handler to be called 3 times but got %d", calls)
+	}
+}
+
+func TestResponseCacheNotCached(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	handler := rc.Cache(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set(headerCacheControl, "no-store")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+
+	for _, path := range []string{"/missing", "/missing", "/private", "/private"} {
+		if w := cacheGet(handler, path); w.Header().Get(headerXCache) != cacheMiss {
+			t.Errorf("%s: expected a miss but got %q", path, w.Header().Get(headerXCache))
+		}
+	}
+	if calls != 4 || rc.Len() != 0 {
+		t.Errorf("expected nothing to be cached, but handler was called %d times and %d were cached", calls, rc.Len())
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	var calls int64
+	now := time.Now()
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	rc.now = func() time.Time { return now }
+	handler := rc.Cache(countingHandler(&calls))
+
+	cacheGet(handler, "/zips")
+	now = now.Add(59 * time.Second)
+	if w := cacheGet(handler, "/zips"); w.Header().Get(headerXCache) != cacheHit {
+		t.Error("expected a hit before the TTL")
+	}
+	now = now.Add(2 * time.Second)
+	if w := cacheGet(handler, "/zips"); w.Header().Get(headerXCache) != cacheMiss {
+		t.Error("expected a miss after the TTL")
+	}
+	if calls != 2 {
+		t.Errorf("expected handler to be called twice but got %d", calls)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 2})
+	handler := rc.Cache(countingHandler(&calls))
+
+	cacheGet(handler, "/a")
+	cacheGet(handler, "/b")
+	//use /a so that /b is the least recently used
+	cacheGet(handler, "/a")
+	cacheGet(handler, "/c")
+
+	if rc.Len() != 2 {
+		t.Errorf("expected 2 entries but got %d", rc.Len())
+	}
+	expected := map[string]string{"/a": cacheHit, "/c": cacheHit, "/b": cacheMiss}
+	for _, path := range []string{"/a", "/c", "/b"} {
+		if w := cacheGet(handler, path); w.Header().Get(headerXCache) != expected[path] {
+			t.Errorf("%s: expected %s but got %s", path, expected[path], w.Header().Get(headerXCache))
+		}
+	}
+}
+
+func TestResponseCacheNoLimit(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute})
+	handler := rc.Cache(countingHandler(&calls))
+
+	paths := []string{"/a", "/b", "/c"}
+	for _, path := range paths {
+		cacheGet(handler, path)
+	}
+	if rc.Len() != len(paths) {
+		t.Errorf("expected %d entries but got %d", len(paths), rc.Len())
+	}
+	for _, path := range paths {
+		if w := cacheGet(handler, path); w.Header().Get(headerXCache) != cacheHit {
+			t.Errorf("%s: expected %s but got %s", path, cacheHit, w.Header().Get(headerXCache))
+		}
+	}
+}
+
+func TestResponseCachePurge(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	handler := rc.Cache(countingHandler(&calls))
+
+	cacheGet(handler, "/a")
+	cacheGet(handler, "/b")
+	rc.Purge()
+	if rc.Len() != 0 {
+		t.Errorf("expected empty cache after purge but got %d entries", rc.Len())
+	}
+	if w := cacheGet(handler, "/a"); w.Header().Get(headerXCache) != cacheMiss || w.Body.String() != "/a #3" {
+		t.Errorf("expected a fresh response after purge but got %q", w.Body.String())
+	}
+
+	//responses generated during a purge aren't cached
+	purging := rc.Cache(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc.Purge()
+		w.Write([]byte("stale"))
+	}))
+	cacheGet(purging, "/stale")
+	if rc.Len() != 0 {
+		t.Errorf("expected response generated during purge not to be cached")
+	}
+}
+
+func TestResponseCacheCollapsesMisses(t *testing.T) {
+	const requests = 10
+	var calls int64
+	rc := NewResponseCache(&CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	release := make(chan struct{})
+	handler := rc.Cache(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		countingHandler(&calls)(w, r)
+	}))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = cacheGet(handler, "/zips").Body.String()
+		}(i)
+	}
+	//wait until the first request is in the
+	//handler, then give the rest time to queue up
+	waitFor(t, "the first request", func() bool {
+		rc.mx.Lock()
+		defer rc.mx.Unlock()
+		return len(rc.calls) == 1
+	})
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected concurrent misses to call the handler once but got %d", calls)
+	}
+	for i, body := range bodies {
+		if body != "/zips #1" {
+			t.Errorf("request %d: expected shared response but got %q", i, body)
+		}
+	}
+}
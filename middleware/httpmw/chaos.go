@@ -0,0 +1,193 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ChaosRule describes how badly requests should misbehave
+type ChaosRule struct {
+	//LatencyRate is the fraction (0.0-1.0) of requests
+	//that are delayed before being handled
+	LatencyRate float64 `json:"latencyRate"`
+	//MinLatencyMS and MaxLatencyMS are the range, in
+	//milliseconds, of the delay added to those requests
+	MinLatencyMS int `json:"minLatencyMS"`
+	MaxLatencyMS int `json:"maxLatencyMS"`
+	//ErrorRate is the fraction (0.0-1.0) of requests that
+	//get an error response instead of being handled
+	ErrorRate float64 `json:"errorRate"`
+	//ErrorStatuses are the status codes returned for those
+	//requests, chosen at random. Defaults to 500 and 503.
+	ErrorStatuses []int `json:"errorStatuses,omitempty"`
+}
+
+//ChaosConfig configures the Chaos middleware
+type ChaosConfig struct {
+	//Enabled must be true for anything to happen
+	Enabled bool `json:"enabled"`
+	//Default is the rule for requests that
+	//don't match any of the Prefixes
+	Default ChaosRule `json:"default"`
+	//Prefixes maps path prefixes to the rule for requests
+	//starting with them. If several prefixes match,
+	//the longest one wins.
+	Prefixes map[string]ChaosRule `json:"prefixes,omitempty"`
+	//Exempt lists paths that are never touched.
+	//Defaults to /health.
+	Exempt []string `json:"exempt,omitempty"`
+}
+
+//rule returns the rule for `path`, and false
+//if the path is exempt
+func (config *ChaosConfig) rule(path string) (*ChaosRule, bool) {
+	exempt := config.Exempt
+	if exempt == nil {
+		exempt = []string{"/health"}
+	}
+	for _, p := range exempt {
+		if p == path {
+			return nil, false
+		}
+	}
+	rule := &config.Default
+	longest := ""
+	for prefix := range config.Prefixes {
+		if len(prefix) > len(longest) && strings.HasPrefix(path, prefix) {
+			longest = prefix
+		}
+	}
+	if len(longest) > 0 {
+		r := config.Prefixes[longest]
+		rule = &r
+	}
+	return rule, true
+}
+
+//Chaos makes requests misbehave on purpose, by adding latency
+//and returning errors, for testing how clients cope with a slow
+//or failing server. Its settings can be read and changed while
+//the server is running, using its ServeHTTP method as an admin
+//handler. Never enable it in production.
+type Chaos struct {
+	mx     sync.RWMutex
+	config ChaosConfig
+	//rand returns a random number in [0.0,1.0);
+	//it must be safe to call concurrently
+	rand func() float64
+	//sleep waits for `d`, or until the request is canceled
+	sleep func(r *http.Request, d time.Duration)
+}
+
+//NewChaos creates a new Chaos with the settings in `config`
+func NewChaos(config *ChaosConfig) *Chaos {
+	return &Chaos{
+		config: *config,
+		rand:   rand.Float64,
+		sleep:  sleepContext,
+	}
+}
+
+//sleepContext waits for `d`, or until the client goes away
+func sleepContext(r *http.Request, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
+//Settings returns the current settings
+func (c *Chaos) Settings() ChaosConfig {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.config
+}
+
+//Update replaces the current settings with `config`
+func (c *Chaos) Update(config *ChaosConfig) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.config = *config
+}
+
+//roll returns true with a probability of `rate`, along with
+//a random number in [0.0,1.0) for choosing what to inject.
+//A random number below the rate is spread evenly between zero
+//and the rate, so dividing it by the rate gives another one.
+func (c *Chaos) roll(rate float64) (float64, bool) {
+	if rate <= 0 {
+		return 0, false
+	}
+	random := c.rand()
+	if random >= rate {
+		return 0, false
+	}
+	return random / rate, true
+}
+
+//Inject is an Adapter that delays some requests, and answers
+//some with an error instead of calling `handler`, according
+//to the current settings. Latency is added before errors,
+//so a request may get both.
+func (c *Chaos) Inject(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := c.Settings()
+		if !config.Enabled {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rule, ok := config.rule(r.URL.Path)
+		if !ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if random, ok := c.roll(rule.LatencyRate); ok {
+			latency := time.Duration(rule.MinLatencyMS) * time.Millisecond
+			if spread := rule.MaxLatencyMS - rule.MinLatencyMS; spread > 0 {
+				latency += time.Duration(random * float64(spread) * float64(time.Millisecond))
+			}
+			c.sleep(r, latency)
+		}
+		if random, ok := c.roll(rule.ErrorRate); ok {
+			statuses := rule.ErrorStatuses
+			if len(statuses) == 0 {
+				statuses = []int{http.StatusInternalServerError, http.StatusServiceUnavailable}
+			}
+			respondError(w, statuses[int(random*float64(len(statuses)))], "chaos: injected error")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+//ServeHTTP is an admin handler for the settings. GET returns
+//them as JSON, and PUT or POST replaces them with the JSON in
+//the request body. Protect it with BasicAuth, and register it
+//outside of the handlers wrapped by Inject, so that it can
+//always be used to turn the chaos off again.
+func (c *Chaos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		config := &ChaosConfig{}
+		if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+			respondError(w, http.StatusBadRequest, "error decoding chaos settings: "+err.Error())
+			return
+		}
+		c.Update(config)
+	default:
+		w.Header().Set(headerAllow, "GET, PUT, POST")
+		respondError(w, http.StatusMethodNotAllowed, "method "+r.Method+" is not allowed")
+		return
+	}
+	settings := c.Settings()
+	w.Header().Set(headerContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(&settings)
+}
@@ -0,0 +1,155 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//sequence returns a source of "random" numbers that
+//cycles through 0.00, 0.01, ... 0.99
+func sequence() func() float64 {
+	mx := sync.Mutex{}
+	i := 0
+	return func() float64 {
+		mx.Lock()
+		defer mx.Unlock()
+		f := float64(i%100) / 100
+		i++
+		return f
+	}
+}
+
+//newTestChaos creates a Chaos with a fixed source of random
+//numbers, which records the delays instead of sleeping
+func newTestChaos(config *ChaosConfig) (*Chaos, *[]time.Duration) {
+	c := NewChaos(config)
+	c.rand = sequence()
+	delays := []time.Duration{}
+	c.sleep = func(r *http.Request, d time.Duration) {
+		delays = append(delays, d)
+	}
+	return c, &delays
+}
+
+//chaosStatuses sends `n` requests for `path`
+//and counts the responses by status code
+func chaosStatuses(handler http.Handler, path string, n int) map[int]int {
+	statuses := map[int]int{}
+	for i := 0; i < n; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		statuses[w.Code]++
+	}
+	return statuses
+}
+
+func TestChaosErrorRate(t *testing.T) {
+	c, _ := newTestChaos(&ChaosConfig{
+		Enabled: true,
+		Default: ChaosRule{ErrorRate: 0.25, ErrorStatuses: []int{http.StatusServiceUnavailable}},
+		Prefixes: map[string]ChaosRule{
+			"/v1/tasks/": {ErrorRate: 0.5, ErrorStatuses: []int{http.StatusInternalServerError}},
+		},
+	})
+	handler := c.Inject(http.HandlerFunc(helloHandler))
+
+	if statuses := chaosStatuses(handler, "/v1/hello1", 100); statuses[http.StatusServiceUnavailable] != 25 || statuses[http.StatusOK] != 75 {
+		t.Errorf("expected 25 errors out of 100 requests, got %v", statuses)
+	}
+	if statuses := chaosStatuses(handler, "/v1/tasks/1234", 100); statuses[http.StatusInternalServerError] != 50 || statuses[http.StatusOK] != 50 {
+		t.Errorf("expected 50 errors out of 100 requests for the prefix, got %v", statuses)
+	}
+	//errors are spread across the statuses
+	c.Update(&ChaosConfig{Enabled: true, Default: ChaosRule{ErrorRate: 1}})
+	if statuses := chaosStatuses(handler, "/v1/hello1", 100); statuses[http.StatusInternalServerError] != 50 || statuses[http.StatusServiceUnavailable] != 50 {
+		t.Errorf("expected errors to be split between 500 and 503, got %v", statuses)
+	}
+
+	//exempt paths are untouched
+	if statuses := chaosStatuses(handler, "/health", 100); statuses[http.StatusOK] != 100 {
+		t.Errorf("expected /health to be exempt, got %v", statuses)
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	c, delays := newTestChaos(&ChaosConfig{
+		Enabled: true,
+		Default: ChaosRule{LatencyRate: 0.5, MinLatencyMS: 100, MaxLatencyMS: 200},
+		Exempt:  []string{"/health", "/v1/ping"},
+	})
+	handler := c.Inject(http.HandlerFunc(helloHandler))
+
+	if statuses := chaosStatuses(handler, "/v1/hello1", 100); statuses[http.StatusOK] != 100 {
+		t.Errorf("expected latency only, got %v", statuses)
+	}
+	if len(*delays) != 50 {
+		t.Errorf("expected 50 delayed requests but got %d", len(*delays))
+	}
+	for _, d := range *delays {
+		if d < 100*time.Millisecond || d >= 200*time.Millisecond {
+			t.Errorf("expected a delay between 100ms and 200ms but got %s", d)
+		}
+	}
+
+	*delays = nil
+	chaosStatuses(handler, "/health", 10)
+	chaosStatuses(handler, "/v1/ping", 10)
+	if len(*delays) > 0 {
+		t.Errorf("expected exempt paths not to be delayed, got %v", *delays)
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	c, delays := newTestChaos(&ChaosConfig{
+		Default: ChaosRule{LatencyRate: 1, ErrorRate: 1},
+	})
+	handler := c.Inject(http.HandlerFunc(helloHandler))
+	if statuses := chaosStatuses(handler, "/v1/hello1", 10); statuses[http.StatusOK] != 10 || len(*delays) > 0 {
+		t.Errorf("expected nothing to happen unless enabled, got %v and %v", statuses, *delays)
+	}
+}
+
+func TestChaosAdmin(t *testing.T) {
+	c, _ := newTestChaos(&ChaosConfig{})
+	handler := c.Inject(http.HandlerFunc(helloHandler))
+
+	w := httptest.NewRecorder()
+	body := `{"enabled": true, "default": {"errorRate": 1, "errorStatuses": [503]}}`
+	c.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/chaos", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if statuses := chaosStatuses(handler, "/v1/hello1", 10); statuses[http.StatusServiceUnavailable] != 10 {
+		t.Errorf("expected the new settings to apply, got %v", statuses)
+	}
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/admin/chaos", nil))
+	settings := &ChaosConfig{}
+	if err := json.NewDecoder(w.Body).Decode(settings); err != nil {
+		t.Fatalf("error decoding settings: %v", err)
+	}
+	if !settings.Enabled || settings.Default.ErrorRate != 1 {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/chaos", strings.NewReader(`{"enabled": `)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid JSON but got %d", http.StatusBadRequest, w.Code)
+	}
+	if !c.Settings().Enabled {
+		t.Error("expected invalid settings to be ignored")
+	}
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/chaos", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
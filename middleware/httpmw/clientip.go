@@ -0,0 +1,130 @@
+package httpmw
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerXForwardedFor = "X-Forwarded-For"
+	headerXRealIP       = "X-Real-IP"
+)
+
+//ClientIPKey is the request context key under
+//which the resolved client IP address is stored
+const ClientIPKey contextKey = "clientIP"
+
+//ClientIPResolver works out the real client IP address for
+//requests that may have come through reverse proxies like nginx
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+//NewClientIPResolver creates a ClientIPResolver that trusts
+//the X-Forwarded-For and X-Real-IP headers only when they were
+//set by a proxy within one of the `trustedCIDRs`, such as
+//"127.0.0.1/32" or "10.0.0.0/8".
+func NewClientIPResolver(trustedCIDRs ...string) (*ClientIPResolver, error) {
+	cr := &ClientIPResolver{}
+	for _, cidr := range trustedCIDRs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %v", cidr, err)
+		}
+		cr.trusted = append(cr.trusted, ipnet)
+	}
+	return cr, nil
+}
+
+//parseIP parses an address that may or may not include a port,
+//such as "10.0.0.1", "10.0.0.1:5555", or "[::1]:5555"
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.ParseIP(host)
+	}
+	return nil
+}
+
+//isTrusted returns true if `ip` is one of the trusted proxies
+func (cr *ClientIPResolver) isTrusted(ip net.IP) bool {
+	for _, ipnet := range cr.trusted {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//Resolve returns the IP address of the client that made `r`.
+//If the request came directly from a trusted proxy, the
+//X-Forwarded-For entries are walked from right to left,
+//skipping trusted proxies, and the first untrusted address
+//is the client. Entries to the left of that were supplied by
+//the client itself, so they can't be trusted. If there's no
+//X-Forwarded-For header, X-Real-IP is used instead.
+func (cr *ClientIPResolver) Resolve(r *http.Request) string {
+	peer := parseIP(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !cr.isTrusted(peer) {
+		return peer.String()
+	}
+
+	if xff := r.Header.Get(headerXForwardedFor); len(xff) > 0 {
+		hops := strings.Split(xff, ",")
+		var leftmost net.IP
+		for idx := len(hops) - 1; idx >= 0; idx-- {
+			ip := parseIP(hops[idx])
+			if ip == nil {
+				//a malformed entry means we can't trust
+				//anything further to the left
+				break
+			}
+			if !cr.isTrusted(ip) {
+				return ip.String()
+			}
+			leftmost = ip
+		}
+		if leftmost != nil {
+			return leftmost.String()
+		}
+	}
+	if ip := parseIP(r.Header.Get(headerXRealIP)); ip != nil {
+		return ip.String()
+	}
+	return peer.String()
+}
+
+//ResolveClientIPs returns an Adapter that resolves the client IP
+//of each request using `cr`, and stores it in the request context
+//for the logging, rate limiting, and other middleware to use
+func ResolveClientIPs(cr *ClientIPResolver) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ClientIPKey, cr.Resolve(r))
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+//ClientIP returns the IP address of the client that made the
+//request. This is the address resolved by ResolveClientIPs if
+//that middleware is installed, or the host part of RemoteAddr
+//if it isn't.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	if ip := parseIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,77 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	cr, err := NewClientIPResolver("127.0.0.1/32", "10.0.0.0/8", "::1/128")
+	if err != nil {
+		t.Fatalf("error creating resolver: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		expected   string
+	}{
+		{"direct client", "203.0.113.9:5555", "", "", "203.0.113.9"},
+		{"untrusted peer spoofing XFF", "203.0.113.9:5555", "1.2.3.4", "", "203.0.113.9"},
+		{"untrusted peer spoofing X-Real-IP", "203.0.113.9:5555", "", "1.2.3.4", "203.0.113.9"},
+		{"trusted proxy", "127.0.0.1:5555", "198.51.100.7", "", "198.51.100.7"},
+		{"multiple proxies", "127.0.0.1:5555", "198.51.100.7, 10.0.0.5, 10.0.0.6", "", "198.51.100.7"},
+		{"client-supplied XFF entry", "127.0.0.1:5555", "6.6.6.6, 198.51.100.7, 10.0.0.5", "", "198.51.100.7"},
+		{"all hops trusted", "127.0.0.1:5555", "10.0.0.4, 10.0.0.5", "", "10.0.0.4"},
+		{"XFF entry with port", "127.0.0.1:5555", "198.51.100.7:443", "", "198.51.100.7"},
+		{"malformed XFF entry", "127.0.0.1:5555", "198.51.100.7, garbage", "", "127.0.0.1"},
+		{"X-Real-IP", "127.0.0.1:5555", "", "198.51.100.7", "198.51.100.7"},
+		{"IPv6 peer", "[2001:db8::1]:5555", "", "", "2001:db8::1"},
+		{"IPv6 trusted proxy", "[::1]:5555", "2001:db8::2", "", "2001:db8::2"},
+		{"IPv6 XFF entry with port", "[::1]:5555", "[2001:db8::3]:443", "", "2001:db8::3"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/hello1", nil)
+		r.RemoteAddr = c.remoteAddr
+		if len(c.xff) > 0 {
+			r.Header.Set(headerXForwardedFor, c.xff)
+		}
+		if len(c.xRealIP) > 0 {
+			r.Header.Set(headerXRealIP, c.xRealIP)
+		}
+		if actual := cr.Resolve(r); actual != c.expected {
+			t.Errorf("%s: expected %s but got %s", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestNewClientIPResolverInvalidCIDR(t *testing.T) {
+	if _, err := NewClientIPResolver("10.0.0.0/99"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestResolveClientIPsContext(t *testing.T) {
+	cr, _ := NewClientIPResolver("127.0.0.1/32")
+	var ip string
+	handler := ResolveClientIPs(cr)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r)
+	}))
+
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.RemoteAddr = "127.0.0.1:5555"
+	r.Header.Set(headerXForwardedFor, "198.51.100.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if ip != "198.51.100.7" {
+		t.Errorf("expected resolved IP in context but got %s", ip)
+	}
+
+	//without the middleware, ClientIP falls back to RemoteAddr
+	if ip := ClientIP(r); ip != "127.0.0.1" {
+		t.Errorf("expected fallback to RemoteAddr but got %s", ip)
+	}
+}
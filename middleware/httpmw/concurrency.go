@@ -0,0 +1,129 @@
+package httpmw
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+//ConcurrencyConfig configures the concurrency limiter
+type ConcurrencyConfig struct {
+	//MaxInFlight is the number of requests that
+	//may be handled at the same time
+	MaxInFlight int
+	//MaxQueued is the number of requests that may wait
+	//for one of the in-flight requests to finish
+	MaxQueued int
+	//MaxWait is how long a queued request waits
+	//before it's rejected
+	MaxWait time.Duration
+	//RetryAfter is sent to rejected clients in the
+	//Retry-After header. Defaults to one second.
+	RetryAfter time.Duration
+}
+
+//ConcurrencyLimiter caps the number of requests being handled
+//at once. Buffered channels act as semaphores: a request
+//holds a slot for as long as its handler runs, and a spot in
+//the queue while it waits for a slot.
+type ConcurrencyLimiter struct {
+	slots      chan struct{}
+	queue      chan struct{}
+	maxWait    time.Duration
+	retryAfter string
+	inFlight   int64
+	rejected   int64
+}
+
+//NewConcurrencyLimiter creates a ConcurrencyLimiter with
+//the slots and queue spots described by `config`; use its
+//Limit method as an Adapter
+func NewConcurrencyLimiter(config *ConcurrencyConfig) *ConcurrencyLimiter {
+	retryAfter := config.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	return &ConcurrencyLimiter{
+		slots:      make(chan struct{}, config.MaxInFlight),
+		queue:      make(chan struct{}, config.MaxQueued),
+		maxWait:    config.MaxWait,
+		retryAfter: strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))),
+	}
+}
+
+//InFlight returns the number of requests currently being handled
+func (cl *ConcurrencyLimiter) InFlight() int64 {
+	return atomic.LoadInt64(&cl.inFlight)
+}
+
+//Rejected returns the number of requests rejected so far
+func (cl *ConcurrencyLimiter) Rejected() int64 {
+	return atomic.LoadInt64(&cl.rejected)
+}
+
+//Stats returns the counters in a form
+//suitable for publishing with expvar.Func
+func (cl *ConcurrencyLimiter) Stats() interface{} {
+	return map[string]int64{
+		"inFlight": cl.InFlight(),
+		"rejected": cl.Rejected(),
+	}
+}
+
+//acquire takes a slot, waiting in the queue if all the slots
+//are taken. It returns false if the queue is full, the wait
+//times out, or the client goes away while waiting.
+func (cl *ConcurrencyLimiter) acquire(r *http.Request) bool {
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case cl.queue <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-cl.queue }()
+
+	timer := time.NewTimer(cl.maxWait)
+	defer timer.Stop()
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+//Limit is an Adapter that lets at most MaxInFlight requests
+//through to `handler` at once. Up to MaxQueued more wait for
+//up to MaxWait, and the rest get a 503 with a Retry-After header.
+func (cl *ConcurrencyLimiter) Limit(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cl.acquire(r) {
+			atomic.AddInt64(&cl.rejected, 1)
+			w.Header().Set(headerRetryAfter, cl.retryAfter)
+			respondError(w, http.StatusServiceUnavailable, "server is too busy, please try again later")
+			return
+		}
+		atomic.AddInt64(&cl.inFlight, 1)
+		defer func() {
+			atomic.AddInt64(&cl.inFlight, -1)
+			<-cl.slots
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+//LimitConcurrency returns an Adapter that caps the number
+//of requests handled at once. Use NewConcurrencyLimiter
+//directly if you need to read its counters.
+func LimitConcurrency(config *ConcurrencyConfig) Adapter {
+	return NewConcurrencyLimiter(config).Limit
+}
@@ -0,0 +1,104 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//waitFor polls `cond` until it returns true,
+//failing the test if that takes too long
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	const (
+		maxInFlight = 2
+		maxQueued   = 1
+		requests    = 6
+	)
+	cl := NewConcurrencyLimiter(&ConcurrencyConfig{
+		MaxInFlight: maxInFlight,
+		MaxQueued:   maxQueued,
+		MaxWait:     5 * time.Second,
+	})
+
+	//the handler blocks until released, so
+	//every request arrives while it's busy
+	release := make(chan struct{})
+	handler := cl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("done"))
+	}))
+
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	statuses := map[int]int{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/slow", nil))
+			if w.Code == http.StatusServiceUnavailable && w.Header().Get(headerRetryAfter) != "1" {
+				t.Errorf("expected Retry-After of 1 but got %q", w.Header().Get(headerRetryAfter))
+			}
+			mx.Lock()
+			statuses[w.Code]++
+			mx.Unlock()
+		}()
+	}
+
+	rejected := requests - maxInFlight - maxQueued
+	waitFor(t, "requests to be rejected", func() bool {
+		return cl.Rejected() == int64(rejected) && cl.InFlight() == maxInFlight
+	})
+	close(release)
+	wg.Wait()
+
+	if statuses[http.StatusOK] != maxInFlight+maxQueued {
+		t.Errorf("expected %d 200s but got %d", maxInFlight+maxQueued, statuses[http.StatusOK])
+	}
+	if statuses[http.StatusServiceUnavailable] != rejected {
+		t.Errorf("expected %d 503s but got %d", rejected, statuses[http.StatusServiceUnavailable])
+	}
+	if cl.InFlight() != 0 {
+		t.Errorf("expected no requests in flight but got %d", cl.InFlight())
+	}
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	cl := NewConcurrencyLimiter(&ConcurrencyConfig{
+		MaxInFlight: 1,
+		MaxQueued:   1,
+		MaxWait:     10 * time.Millisecond,
+	})
+	release := make(chan struct{})
+	handler := cl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/slow", nil))
+		close(done)
+	}()
+	waitFor(t, "the first request to start", func() bool { return cl.InFlight() == 1 })
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected queued request to time out with %d but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,182 @@
+package httpmw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerOrigin                        = "Origin"
+	headerVary                          = "Vary"
+	headerAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	headerAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAccessControlMaxAge           = "Access-Control-Max-Age"
+	headerAccessControlRequestMethod    = "Access-Control-Request-Method"
+	headerAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+)
+
+//CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	//AllowedOrigins is the list of origins allowed to make
+	//cross-origin requests, or "*" to allow any origin
+	AllowedOrigins []string
+	//AllowedMethods is the list of methods allowed in
+	//cross-origin requests. Defaults to GET, HEAD, and POST.
+	AllowedMethods []string
+	//AllowedHeaders is the list of non-simple request headers
+	//clients may send, or "*" to allow any header
+	AllowedHeaders []string
+	//ExposedHeaders is the list of response headers
+	//that client scripts are allowed to read
+	ExposedHeaders []string
+	//AllowCredentials allows clients to send cookies
+	//and authorization headers with their requests
+	AllowCredentials bool
+	//MaxAge is how long clients may cache preflight results
+	MaxAge time.Duration
+	//RouteMethods, if set, returns the methods allowed for a
+	//path, and false if there's no such route. Preflights then
+	//allow each route's own methods instead of AllowedMethods,
+	//and preflights for unknown paths get a 404.
+	//Use Routes.Allowed to fill this in.
+	RouteMethods func(path string) ([]string, bool)
+}
+
+//corsPolicy is a CORSConfig compiled into the
+//lookups needed to handle each request
+type corsPolicy struct {
+	config         *CORSConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	methods        map[string]bool
+	anyHeader      bool
+	headers        map[string]bool
+	allowedMethods string
+	exposedHeaders string
+}
+
+func newCORSPolicy(config *CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		config:  config,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[origin] = true
+	}
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST"}
+	}
+	for _, method := range methods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range config.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	p.allowedMethods = strings.Join(methods, ", ")
+	p.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+	return p
+}
+
+//headersAllowed returns true if every header in the comma-separated
+//Access-Control-Request-Headers value is allowed
+func (p *corsPolicy) headersAllowed(requested string) bool {
+	if p.anyHeader || len(requested) == 0 {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if len(header) > 0 && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+//setOrigin sets the Access-Control-Allow-Origin header and
+//related headers that apply to preflight and actual requests
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	//browsers reject "*" when credentials are allowed,
+	//so we have to echo the specific origin instead
+	if p.anyOrigin && !p.config.AllowCredentials {
+		h.Set(headerAccessControlAllowOrigin, "*")
+	} else {
+		h.Set(headerAccessControlAllowOrigin, origin)
+		h.Add(headerVary, headerOrigin)
+	}
+	if p.config.AllowCredentials {
+		h.Set(headerAccessControlAllowCredentials, "true")
+	}
+}
+
+//preflight responds to a CORS preflight request
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	method := r.Header.Get(headerAccessControlRequestMethod)
+	requestedHeaders := r.Header.Get(headerAccessControlRequestHeaders)
+	methods, allow := p.methods, p.allowedMethods
+	if p.config.RouteMethods != nil {
+		routeMethods, found := p.config.RouteMethods(r.URL.Path)
+		if !found {
+			respondError(w, http.StatusNotFound, "no resource at "+r.URL.Path)
+			return
+		}
+		methods, allow = allowedMethods(routeMethods)
+	}
+	//if the method or headers aren't allowed, we just
+	//leave out the CORS headers and the browser will
+	//refuse to send the actual request
+	if methods[method] && p.headersAllowed(requestedHeaders) {
+		h := w.Header()
+		p.setOrigin(h, origin)
+		h.Set(headerAccessControlAllowMethods, allow)
+		if len(requestedHeaders) > 0 {
+			h.Set(headerAccessControlAllowHeaders, requestedHeaders)
+		}
+		if p.config.MaxAge > 0 {
+			h.Set(headerAccessControlMaxAge, strconv.Itoa(int(p.config.MaxAge.Seconds())))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//CORS returns an Adapter that adds CORS headers according to
+//`config`. It answers preflight requests itself, and adds
+//the appropriate headers to actual requests before calling
+//the wrapped handler. Requests from origins that aren't
+//allowed are passed through without any CORS headers.
+func CORS(config *CORSConfig) Adapter {
+	p := newCORSPolicy(config)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(headerOrigin)
+			if len(origin) == 0 || !(p.anyOrigin || p.origins[origin]) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Method == "OPTIONS" && len(r.Header.Get(headerAccessControlRequestMethod)) > 0 {
+				p.preflight(w, r, origin)
+				return
+			}
+
+			p.setOrigin(w.Header(), origin)
+			if len(p.exposedHeaders) > 0 {
+				w.Header().Set(headerAccessControlExposeHeaders, p.exposedHeaders)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
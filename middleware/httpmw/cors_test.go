@@ -0,0 +1,137 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//corsRequest sends a request through the CORS middleware
+//and returns the response and whether the handler ran
+func corsRequest(config *CORSConfig, method string, origin string, headers map[string]string) (*httptest.ResponseRecorder, bool) {
+	handlerCalled := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/v1/hello1", nil)
+	if len(origin) > 0 {
+		r.Header.Set(headerOrigin, origin)
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	CORS(config)(handler).ServeHTTP(w, r)
+	return w, handlerCalled
+}
+
+func TestCORSWildcard(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"X-Total-Count"},
+	}
+
+	w, called := corsRequest(config, "GET", "https://example.com", nil)
+	if !called {
+		t.Error("expected handler to be called")
+	}
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); acao != "*" {
+		t.Errorf("expected Access-Control-Allow-Origin of * but got %q", acao)
+	}
+	if exposed := w.Header().Get(headerAccessControlExposeHeaders); exposed != "X-Total-Count" {
+		t.Errorf("expected exposed headers but got %q", exposed)
+	}
+
+	w, called = corsRequest(config, "GET", "", nil)
+	if !called || len(w.Header().Get(headerAccessControlAllowOrigin)) > 0 {
+		t.Error("expected same-origin requests to pass through without CORS headers")
+	}
+}
+
+func TestCORSExactOrigin(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins: []string{"https://info344.example.com"},
+	}
+
+	w, called := corsRequest(config, "GET", "https://info344.example.com", nil)
+	if !called {
+		t.Error("expected handler to be called")
+	}
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); acao != "https://info344.example.com" {
+		t.Errorf("expected origin to be echoed but got %q", acao)
+	}
+	if vary := w.Header().Get(headerVary); vary != headerOrigin {
+		t.Errorf("expected Vary: Origin but got %q", vary)
+	}
+
+	w, called = corsRequest(config, "GET", "https://evil.example.com", nil)
+	if !called {
+		t.Error("expected requests from disallowed origins to pass through")
+	}
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); len(acao) > 0 {
+		t.Errorf("expected no CORS headers for disallowed origin but got %q", acao)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins: []string{"https://info344.example.com"},
+		AllowedMethods: []string{"GET", "POST", "PATCH"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	w, called := corsRequest(config, "OPTIONS", "https://info344.example.com", map[string]string{
+		headerAccessControlRequestMethod:  "PATCH",
+		headerAccessControlRequestHeaders: "content-type",
+	})
+	if called {
+		t.Error("expected preflight to be answered without calling the handler")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d but got %d", http.StatusNoContent, w.Code)
+	}
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); acao != "https://info344.example.com" {
+		t.Errorf("expected origin to be echoed but got %q", acao)
+	}
+	if methods := w.Header().Get(headerAccessControlAllowMethods); methods != "GET, POST, PATCH" {
+		t.Errorf("unexpected allowed methods %q", methods)
+	}
+	if headers := w.Header().Get(headerAccessControlAllowHeaders); headers != "content-type" {
+		t.Errorf("unexpected allowed headers %q", headers)
+	}
+	if maxAge := w.Header().Get(headerAccessControlMaxAge); maxAge != "600" {
+		t.Errorf("expected max age of 600 but got %q", maxAge)
+	}
+
+	disallowed := []map[string]string{
+		{headerAccessControlRequestMethod: "DELETE"},
+		{headerAccessControlRequestMethod: "POST", headerAccessControlRequestHeaders: "X-Secret"},
+	}
+	for _, headers := range disallowed {
+		w, called = corsRequest(config, "OPTIONS", "https://info344.example.com", headers)
+		if called {
+			t.Errorf("%v: expected preflight to be answered without calling the handler", headers)
+		}
+		if acao := w.Header().Get(headerAccessControlAllowOrigin); len(acao) > 0 {
+			t.Errorf("%v: expected no CORS headers for disallowed preflight, got %q", headers, acao)
+		}
+	}
+}
+
+func TestCORSCredentialsWithWildcard(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	}
+
+	w, _ := corsRequest(config, "GET", "https://example.com", nil)
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); acao != "https://example.com" {
+		t.Errorf("expected specific origin with credentials but got %q", acao)
+	}
+	if creds := w.Header().Get(headerAccessControlAllowCredentials); creds != "true" {
+		t.Errorf("expected Access-Control-Allow-Credentials: true but got %q", creds)
+	}
+}
@@ -0,0 +1,167 @@
+package httpmw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	headerCookie             = "Cookie"
+	headerSetCookie          = "Set-Cookie"
+	headerProxyAuthorization = "Proxy-Authorization"
+)
+
+const redacted = "[REDACTED]"
+
+//defaultDumpBytes is how much of each body is
+//dumped if DumpConfig.MaxBodyBytes isn't set
+const defaultDumpBytes = 4096
+
+//redactedHeaders are never written to the dump,
+//as they contain credentials
+var redactedHeaders = []string{
+	headerAuthorization,
+	headerProxyAuthorization,
+	headerCookie,
+	headerSetCookie,
+}
+
+//DumpConfig configures the DumpBodies middleware
+type DumpConfig struct {
+	//Enabled turns dumping on. When false, DumpBodies
+	//returns handlers unchanged.
+	Enabled bool
+	//MaxBodyBytes is how much of each body is dumped;
+	//the rest is replaced by a note with the full length
+	MaxBodyBytes int
+}
+
+//cappedBuffer keeps the first `max` bytes written to
+//it, and counts the total number of bytes written
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+func (cb *cappedBuffer) Write(p []byte) (int, error) {
+	cb.total += len(p)
+	if remaining := cb.max - cb.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			cb.buf.Write(p[:remaining])
+		} else {
+			cb.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+//teeBody copies everything read from the request
+//body to a cappedBuffer, while still letting
+//the handler read and close the original
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
+//isTextual returns true if bodies of `contentType`
+//are safe to write to the log as-is
+func isTextual(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return isCompressible(mediaType) || mediaType == "application/x-www-form-urlencoded"
+}
+
+//writeHeaders writes `header` to `buf` in sorted order,
+//redacting the values of headers that carry credentials
+func writeHeaders(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			for _, r := range redactedHeaders {
+				if strings.EqualFold(name, r) {
+					value = redacted
+				}
+			}
+			fmt.Fprintf(buf, "%s: %s\n", name, value)
+		}
+	}
+}
+
+//writeBody writes the captured body to `buf`, or a placeholder
+//if it's binary, noting how much of it was truncated
+func writeBody(buf *bytes.Buffer, contentType string, cb *cappedBuffer) {
+	if cb.total == 0 {
+		return
+	}
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(cb.buf.Bytes())
+	}
+	buf.WriteString("\n")
+	if !isTextual(contentType) {
+		fmt.Fprintf(buf, "[%d bytes of %s]\n", cb.total, contentType)
+		return
+	}
+	buf.Write(cb.buf.Bytes())
+	if cb.total > cb.buf.Len() {
+		fmt.Fprintf(buf, "\n[truncated, %d bytes total]", cb.total)
+	}
+	buf.WriteString("\n")
+}
+
+//DumpBodies returns an Adapter that logs the full request and
+//response, headers and bodies, to `logger`. This is meant for
+//debugging client integrations during development: it slows
+//every request down and can log sensitive data, so don't
+//enable it in production. Credentials in the Authorization
+//and Cookie headers are redacted, bodies are truncated to
+//config.MaxBodyBytes, and binary bodies are replaced by a
+//placeholder with their length.
+//
+//The request body is dumped as the handler reads it, so
+//a handler that ignores the body dumps no request body.
+func DumpBodies(logger *log.Logger, config *DumpConfig) Adapter {
+	max := config.MaxBodyBytes
+	if max <= 0 {
+		max = defaultDumpBytes
+	}
+	return func(handler http.Handler) http.Handler {
+		if !config.Enabled {
+			return handler
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqBody := &cappedBuffer{max: max}
+			r.Body = &teeBody{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+			respBody := &cappedBuffer{max: max}
+			rw := newResponseRecorder(w)
+			rw.body = respBody
+
+			handler.ServeHTTP(rw, r)
+
+			prefix := ""
+			if id := RequestIDFromContext(r.Context()); len(id) > 0 {
+				prefix = "[" + id + "] "
+			}
+			buf := &bytes.Buffer{}
+			fmt.Fprintf(buf, "%srequest: %s %s %s\n", prefix, r.Method, r.URL.RequestURI(), r.Proto)
+			writeHeaders(buf, r.Header)
+			writeBody(buf, r.Header.Get(headerContentType), reqBody)
+			fmt.Fprintf(buf, "%sresponse: %d %s\n", prefix, rw.status, http.StatusText(rw.status))
+			writeHeaders(buf, w.Header())
+			writeBody(buf, w.Header().Get(headerContentType), respBody)
+			logger.Print(buf.String())
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package httpmw
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//dumpOutput runs `handler` behind DumpBodies and
+//returns everything that was logged
+func dumpOutput(handler http.HandlerFunc, r *http.Request, max int) string {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	config := &DumpConfig{Enabled: true, MaxBodyBytes: max}
+	DumpBodies(logger, config)(handler).ServeHTTP(httptest.NewRecorder(), r)
+	return buf.String()
+}
+
+//echoHandler writes the request body back as the response
+func echoHandler(received *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*received = string(body)
+		w.Header().Set(headerContentType, r.Header.Get(headerContentType))
+		w.Write(body)
+	}
+}
+
+func TestDumpBodies(t *testing.T) {
+	received := ""
+	body := `{"title":"buy milk"}`
+	r := httptest.NewRequest("POST", "/v1/tasks?x=1", strings.NewReader(body))
+	r.Header.Set(headerContentType, contentTypeJSON)
+	output := dumpOutput(echoHandler(&received), r, 1024)
+
+	if received != body {
+		t.Errorf("expected handler to receive %q but got %q", body, received)
+	}
+	for _, expected := range []string{
+		"request: POST /v1/tasks?x=1 HTTP/1.1\n",
+		"Content-Type: " + contentTypeJSON + "\n",
+		"\n" + body + "\n",
+		"response: 200 OK\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Count(output, body) != 2 {
+		t.Errorf("expected both bodies to be dumped, got:\n%s", output)
+	}
+}
+
+func TestDumpBodiesTruncation(t *testing.T) {
+	received := ""
+	body := strings.Repeat("a", 100)
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body))
+	r.Header.Set(headerContentType, "text/plain")
+	output := dumpOutput(echoHandler(&received), r, 10)
+
+	if received != body {
+		t.Errorf("expected handler to receive the full body, got %d bytes", len(received))
+	}
+	if strings.Contains(output, strings.Repeat("a", 11)) {
+		t.Errorf("expected bodies to be truncated, got:\n%s", output)
+	}
+	if strings.Count(output, strings.Repeat("a", 10)+"\n[truncated, 100 bytes total]") != 2 {
+		t.Errorf("expected both bodies to be marked truncated, got:\n%s", output)
+	}
+}
+
+func TestDumpBodiesRedaction(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerSetCookie, "session=secret3")
+	}
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.Header.Set(headerAuthorization, "Bearer secret1")
+	r.Header.Set(headerCookie, "session=secret2")
+	r.Header.Set("X-Custom", "visible")
+	output := dumpOutput(handler, r, 1024)
+
+	if strings.Contains(output, "secret") {
+		t.Errorf("expected credentials to be redacted, got:\n%s", output)
+	}
+	for _, expected := range []string{
+		"Authorization: " + redacted,
+		"Cookie: " + redacted,
+		"Set-Cookie: " + redacted,
+		"X-Custom: visible",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestDumpBodiesBinary(t *testing.T) {
+	received := ""
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 100)
+	r := httptest.NewRequest("POST", "/v1/images", strings.NewReader(png))
+	r.Header.Set(headerContentType, "image/png")
+	output := dumpOutput(echoHandler(&received), r, 1024)
+
+	if strings.Contains(output, "PNG") {
+		t.Errorf("expected binary body to be replaced, got:\n%s", output)
+	}
+	if strings.Count(output, "[108 bytes of image/png]") != 2 {
+		t.Errorf("expected a placeholder for both bodies, got:\n%s", output)
+	}
+}
+
+func TestDumpBodiesDisabled(t *testing.T) {
+	handler := http.HandlerFunc(helloHandler)
+	logger := log.New(ioutil.Discard, "", 0)
+	wrapped := DumpBodies(logger, &DumpConfig{})(handler)
+	if _, ok := wrapped.(http.HandlerFunc); !ok {
+		t.Error("expected handler to be returned unchanged when disabled")
+	}
+}
@@ -0,0 +1,116 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestETags(t *testing.T) {
+	handler := ETags(1024)(http.HandlerFunc(helloHandler))
+
+	//first request gets the ETag and the full body
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+	etag := w.Header().Get(headerETag)
+	if etag != computeETag([]byte("Hello from Handler 1")) {
+		t.Fatalf("unexpected ETag %q", etag)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "Hello from Handler 1" {
+		t.Errorf("expected full response but got %d %q", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"other", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+		{strings.ToUpper(etag), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/hello1", nil)
+		r.Header.Set(headerIfNoneMatch, c.ifNoneMatch)
+		handler.ServeHTTP(w, r)
+		if w.Code != c.expectedStatus {
+			t.Errorf("If-None-Match %s: expected status %d but got %d", c.ifNoneMatch, c.expectedStatus, w.Code)
+		}
+		if w.Header().Get(headerETag) != etag {
+			t.Errorf("If-None-Match %s: expected ETag %s but got %s", c.ifNoneMatch, etag, w.Header().Get(headerETag))
+		}
+		if c.expectedStatus == http.StatusNotModified && w.Body.Len() > 0 {
+			t.Errorf("If-None-Match %s: expected no body but got %q", c.ifNoneMatch, w.Body.String())
+		}
+	}
+}
+
+func TestETagsPassThrough(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	cases := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		etag    string
+	}{
+		{"POST", "POST", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}, ""},
+		{"too large", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body[:50]))
+			w.Write([]byte(body[50:]))
+		}, ""},
+		{"existing ETag", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerETag, `"v1"`)
+			w.Write([]byte(body))
+		}, `"v1"`},
+		{"no-store", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerCacheControl, "private, no-store")
+			w.Write([]byte(body))
+		}, ""},
+		{"error", "GET", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, body, http.StatusInternalServerError)
+		}, ""},
+	}
+
+	for _, c := range cases {
+		handler := ETags(64)(c.handler)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, "/v1/tasks", nil)
+		r.Header.Set(headerIfNoneMatch, "*")
+		handler.ServeHTTP(w, r)
+		if w.Code == http.StatusNotModified {
+			t.Errorf("%s: expected response to pass through but got a 304", c.name)
+		}
+		if !strings.HasPrefix(w.Body.String(), body) {
+			t.Errorf("%s: expected full body but got %q", c.name, w.Body.String())
+		}
+		if etag := w.Header().Get(headerETag); etag != c.etag {
+			t.Errorf("%s: expected ETag %q but got %q", c.name, c.etag, etag)
+		}
+	}
+}
+
+func BenchmarkETags(b *testing.B) {
+	body := []byte(strings.Repeat("a", 4096))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	})
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+
+	b.Run("bare", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+	b.Run("ETags", func(b *testing.B) {
+		wrapped := ETags(64 * 1024)(handler)
+		for i := 0; i < b.N; i++ {
+			wrapped.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+}
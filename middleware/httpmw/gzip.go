@@ -0,0 +1,213 @@
+package httpmw
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+)
+
+const encodingGzip = "gzip"
+
+//compressibleTypes are the media type prefixes worth compressing;
+//images, video, and archives are already compressed
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+//gzipWriters is a pool of gzip writers, which are
+//fairly expensive to allocate for every request
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+//acceptsGzip returns true if the request's
+//Accept-Encoding header allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get(headerAcceptEncoding), ",") {
+		parts := strings.Split(enc, ";")
+		if strings.TrimSpace(parts[0]) != encodingGzip {
+			continue
+		}
+		//a q-value of 0, like "gzip;q=0" or "gzip;q=0.0",
+		//means the client refuses gzip
+		for _, param := range parts[1:] {
+			nameValue := strings.SplitN(param, "=", 2)
+			if len(nameValue) == 2 && strings.EqualFold(strings.TrimSpace(nameValue[0]), "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(nameValue[1]), 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+//isCompressible returns true if the content type is worth compressing
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//gzipResponseWriter buffers the start of the response until it
+//has at least minSize bytes, and then decides whether to compress
+//it, based on the Content-Type and Content-Encoding set by the
+//handler. Responses that never reach minSize are sent uncompressed.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+//WriteHeader records the status code; it's sent once
+//we know whether the response will be compressed
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+	if gw.status == 0 {
+		gw.status = code
+	}
+}
+
+//Write buffers or compresses the data as appropriate
+func (gw *gzipResponseWriter) Write(p []byte) (int, error) {
+	if gw.decided {
+		if gw.gz != nil {
+			return gw.gz.Write(p)
+		}
+		return gw.ResponseWriter.Write(p)
+	}
+
+	gw.buf = append(gw.buf, p...)
+	if len(gw.buf) < gw.minSize {
+		return len(p), nil
+	}
+	if err := gw.decide(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//decide writes the headers and any buffered data, compressing
+//if `compress` is true and the response is compressible
+func (gw *gzipResponseWriter) decide(compress bool) error {
+	gw.decided = true
+	h := gw.Header()
+	if len(h.Get(headerContentType)) == 0 && len(gw.buf) > 0 {
+		h.Set(headerContentType, http.DetectContentType(gw.buf))
+	}
+	if compress && len(h.Get(headerContentEncoding)) == 0 && isCompressible(h.Get(headerContentType)) {
+		h.Set(headerContentEncoding, encodingGzip)
+		h.Del(headerContentLength)
+		gw.gz = gzipWriters.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+	if len(gw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(gw.buf)
+	} else {
+		_, err = gw.ResponseWriter.Write(gw.buf)
+	}
+	gw.buf = nil
+	return err
+}
+
+//Flush sends what's been written so far to the client, for
+//handlers that stream, like server-sent events. A response
+//that hasn't reached minSize yet is decided on now, rather
+//than holding small events back waiting for more.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.decided {
+		gw.decide(true)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//close sends anything still buffered, and flushes and
+//closes the gzip writer if the response was compressed
+func (gw *gzipResponseWriter) close() {
+	if !gw.decided {
+		//the response was smaller than minSize
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+		gw.gz.Reset(nil)
+		gzipWriters.Put(gw.gz)
+		gw.gz = nil
+	}
+}
+
+//abort cleans up after a handler that panicked. If nothing has
+//been sent yet, the buffered start of the response is dropped
+//without sending a status, so that recovery middleware can send
+//a 500 instead; otherwise the gzip stream is closed as in close.
+func (gw *gzipResponseWriter) abort() {
+	if !gw.decided {
+		gw.buf = nil
+		return
+	}
+	gw.close()
+}
+
+//GzipResponses returns an Adapter that gzip-compresses responses
+//when the client accepts gzip and the response has a compressible
+//Content-Type. Responses smaller than minSize bytes, and responses
+//the handler has already encoded, are sent as-is.
+//
+//If the handler panics, the panic is passed on to any recovery
+//middleware. A response that had already started is closed, so
+//that what was written is still a valid gzip stream, while one
+//that hadn't is left unstarted, so that it can still be a 500.
+func GzipResponses(minSize int) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(headerVary, headerAcceptEncoding)
+			if !acceptsGzip(r) || r.Method == "HEAD" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			defer func() {
+				if err := recover(); err != nil {
+					gw.abort()
+					panic(err)
+				}
+				gw.close()
+			}()
+			handler.ServeHTTP(gw, r)
+		})
+	}
+}
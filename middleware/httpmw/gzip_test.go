@@ -0,0 +1,208 @@
+package httpmw
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var largeJSON = `{"tasks":[` + strings.Repeat(`{"title":"Learn Go","complete":false},`, 100) + `{}]}`
+
+func jsonHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.Header().Set(headerContentLength, "12345")
+		//write in chunks to exercise the buffering
+		for len(body) > 0 {
+			n := 100
+			if n > len(body) {
+				n = len(body)
+			}
+			w.Write([]byte(body[:n]))
+			body = body[n:]
+		}
+	}
+}
+
+func gzipRequest(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+	if len(acceptEncoding) > 0 {
+		r.Header.Set(headerAcceptEncoding, acceptEncoding)
+	}
+	GzipResponses(512)(handler).ServeHTTP(w, r)
+	return w
+}
+
+func gunzip(t *testing.T, w *httptest.ResponseRecorder) string {
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("error creating gzip reader: %v", err)
+	}
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("error decompressing body: %v", err)
+	}
+	return string(body)
+}
+
+func TestGzipCompresses(t *testing.T) {
+	w := gzipRequest(jsonHandler(largeJSON), "deflate, gzip")
+	if enc := w.Header().Get(headerContentEncoding); enc != encodingGzip {
+		t.Fatalf("expected Content-Encoding gzip but got %q", enc)
+	}
+	if vary := w.Header().Get(headerVary); vary != headerAcceptEncoding {
+		t.Errorf("expected Vary: Accept-Encoding but got %q", vary)
+	}
+	if cl := w.Header().Get(headerContentLength); len(cl) > 0 {
+		t.Errorf("expected Content-Length to be removed but got %q", cl)
+	}
+	if w.Body.Len() >= len(largeJSON) {
+		t.Errorf("expected compressed body to be smaller than %d bytes but was %d", len(largeJSON), w.Body.Len())
+	}
+
+	plain := gzipRequest(jsonHandler(largeJSON), "")
+	if body := gunzip(t, w); body != plain.Body.String() || body != largeJSON {
+		t.Error("decompressed body didn't match the plain response")
+	}
+
+	//any q-value above 0 accepts gzip
+	for _, acceptEncoding := range []string{"gzip;q=1", "gzip;q=0.5", "gzip; q=0.001"} {
+		if enc := gzipRequest(jsonHandler(largeJSON), acceptEncoding).Header().Get(headerContentEncoding); enc != encodingGzip {
+			t.Errorf("%s: expected Content-Encoding gzip but got %q", acceptEncoding, enc)
+		}
+	}
+}
+
+func TestGzipPassThrough(t *testing.T) {
+	png := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, "image/png")
+		w.Write([]byte(largeJSON))
+	}
+	encoded := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentEncoding, "br")
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.Write([]byte(largeJSON))
+	}
+
+	cases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		acceptEncoding string
+		expected       string
+	}{
+		{"client doesn't accept gzip", jsonHandler(largeJSON), "", largeJSON},
+		{"client refuses gzip", jsonHandler(largeJSON), "gzip;q=0", largeJSON},
+		{"client refuses gzip with q=0.0", jsonHandler(largeJSON), "gzip;q=0.0", largeJSON},
+		{"client refuses gzip with q=0.000", jsonHandler(largeJSON), "deflate, gzip; q=0.000", largeJSON},
+		{"client refuses gzip with Q=0", jsonHandler(largeJSON), "gzip;Q=0", largeJSON},
+		{"tiny response", jsonHandler(`{"ok":true}`), "gzip", `{"ok":true}`},
+		{"incompressible type", png, "gzip", largeJSON},
+		{"already encoded", encoded, "gzip", largeJSON},
+	}
+
+	for _, c := range cases {
+		w := gzipRequest(c.handler, c.acceptEncoding)
+		if enc := w.Header().Get(headerContentEncoding); enc == encodingGzip {
+			t.Errorf("%s: response should not be gzipped", c.name)
+		}
+		if body := w.Body.String(); body != c.expected {
+			t.Errorf("%s: expected body to be unchanged", c.name)
+		}
+	}
+}
+
+func TestGzipStatus(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(largeJSON))
+	}
+	w := gzipRequest(http.HandlerFunc(handler), "gzip")
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d but got %d", http.StatusCreated, w.Code)
+	}
+	if body := gunzip(t, w); body != largeJSON {
+		t.Error("decompressed body didn't match")
+	}
+
+	noContent := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	w = gzipRequest(http.HandlerFunc(noContent), "gzip")
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("expected empty 204 but got %d with %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestGzipClosedOnPanic(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.Write([]byte(largeJSON))
+		panic("oops")
+	}
+
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to propagate")
+			}
+		}()
+		r := httptest.NewRequest("GET", "/v1/tasks", nil)
+		r.Header.Set(headerAcceptEncoding, encodingGzip)
+		GzipResponses(512)(http.HandlerFunc(handler)).ServeHTTP(w, r)
+	}()
+
+	if body := gunzip(t, w); body != largeJSON {
+		t.Error("expected a complete gzip stream even after the panic")
+	}
+}
+
+func TestGzipWithRecover(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"nothing written": func(w http.ResponseWriter, r *http.Request) {
+			panic("oops")
+		},
+		//smaller than minSize, so it's still buffered
+		"partly written": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerContentType, contentTypeJSON)
+			w.Write([]byte(`{"tasks":[`))
+			panic("oops")
+		},
+	}
+	for name, handler := range handlers {
+		logger := log.New(ioutil.Discard, "", 0)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/tasks", nil)
+		r.Header.Set(headerAcceptEncoding, encodingGzip)
+		Chain(handler, Recover(logger), GzipResponses(512)).ServeHTTP(w, r)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d but got %d", name, http.StatusInternalServerError, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"error":"internal server error"`) {
+			t.Errorf("%s: expected the error body but got %q", name, w.Body.String())
+		}
+	}
+}
+
+func TestGzipFlush(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, "text/event-stream")
+		w.Write([]byte("data: hello\n\n"))
+		//the event is much smaller than minSize, but
+		//flushing sends it rather than waiting for more
+		w.(http.Flusher).Flush()
+	})
+	w := gzipRequest(handler, "gzip")
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if body := gunzip(t, w); body != "data: hello\n\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
@@ -0,0 +1,12 @@
+package httpmw
+
+import "net/http"
+
+const contentTypeText = "text/plain"
+
+//helloHandler is a simple handler for
+//the middleware to wrap in tests
+func helloHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add(headerContentType, contentTypeText)
+	w.Write([]byte("Hello from Handler 1"))
+}
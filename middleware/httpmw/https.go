@@ -0,0 +1,61 @@
+package httpmw
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+//HTTPSConfig configures the RedirectToHTTPS middleware
+type HTTPSConfig struct {
+	//TLSPort is the port the TLS listener is on.
+	//Defaults to 443, which is left out of redirect URLs.
+	TLSPort string
+	//CanonicalHost replaces the host the client asked for,
+	//such as redirecting example.com to www.example.com.
+	//Defaults to the request's host.
+	CanonicalHost string
+	//TrustProxy treats requests with X-Forwarded-Proto: https
+	//as already secure, so that servers behind a TLS-terminating
+	//proxy don't redirect clients in a loop
+	TrustProxy bool
+}
+
+//httpsURL returns the https URL for `r`
+func (c *HTTPSConfig) httpsURL(r *http.Request) string {
+	host := c.CanonicalHost
+	if len(host) == 0 {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	host = strings.Trim(host, "[]")
+	if len(c.TLSPort) > 0 && c.TLSPort != "443" {
+		host = net.JoinHostPort(host, c.TLSPort)
+	} else if strings.Contains(host, ":") {
+		//IPv6 literals need brackets even without a port
+		host = "[" + host + "]"
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
+
+//RedirectToHTTPS returns an Adapter that permanently redirects
+//requests that didn't arrive over TLS to the same path on the
+//TLS port. Secure requests are passed through to the handler.
+//To bounce every request on the plain listener, wrap a handler
+//that's never reached, like so:
+//
+//  http.ListenAndServe(":80", RedirectToHTTPS(config)(http.NotFoundHandler()))
+func RedirectToHTTPS(config *HTTPSConfig) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSecure(r, config.TrustProxy) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(headerLocation, config.httpsURL(r))
+			w.WriteHeader(http.StatusMovedPermanently)
+		})
+	}
+}
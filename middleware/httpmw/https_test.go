@@ -0,0 +1,59 @@
+package httpmw
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		name             string
+		config           *HTTPSConfig
+		target           string
+		host             string
+		forwardedProto   string
+		tls              bool
+		expectedLocation string
+	}{
+		{"direct HTTP", &HTTPSConfig{}, "/v1/tasks?done=true", "example.com", "", false, "https://example.com/v1/tasks?done=true"},
+		{"strips port", &HTTPSConfig{}, "/v1/tasks", "example.com:80", "", false, "https://example.com/v1/tasks"},
+		{"default port", &HTTPSConfig{TLSPort: "443"}, "/", "example.com:8080", "", false, "https://example.com/"},
+		{"non-standard port", &HTTPSConfig{TLSPort: "4443"}, "/v1/tasks", "localhost:4000", "", false, "https://localhost:4443/v1/tasks"},
+		{"IPv6", &HTTPSConfig{}, "/", "[::1]:80", "", false, "https://[::1]/"},
+		{"IPv6 with port", &HTTPSConfig{TLSPort: "4443"}, "/", "[::1]:80", "", false, "https://[::1]:4443/"},
+		{"canonical host", &HTTPSConfig{CanonicalHost: "www.example.com"}, "/v1/tasks", "example.com:80", "", false, "https://www.example.com/v1/tasks"},
+		{"untrusted forwarded https", &HTTPSConfig{}, "/", "example.com", "https", false, "https://example.com/"},
+		{"trusted forwarded https", &HTTPSConfig{TrustProxy: true}, "/", "example.com", "https", false, ""},
+		{"trusted forwarded http", &HTTPSConfig{TrustProxy: true}, "/", "example.com", "http", false, "https://example.com/"},
+		{"already TLS", &HTTPSConfig{}, "/", "example.com", "", true, ""},
+	}
+
+	for _, c := range cases {
+		handler := RedirectToHTTPS(c.config)(http.HandlerFunc(helloHandler))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", c.target, nil)
+		r.Host = c.host
+		if len(c.forwardedProto) > 0 {
+			r.Header.Set(headerXForwardedProto, c.forwardedProto)
+		}
+		if c.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		handler.ServeHTTP(w, r)
+
+		if len(c.expectedLocation) == 0 {
+			if w.Code != http.StatusOK {
+				t.Errorf("%s: expected request to pass through but got %d", c.name, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: expected status %d but got %d", c.name, http.StatusMovedPermanently, w.Code)
+		}
+		if location := w.Header().Get(headerLocation); location != c.expectedLocation {
+			t.Errorf("%s: expected Location %s but got %s", c.name, c.expectedLocation, location)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//clfTimeFormat is the timestamp format used
+//by the Common and Combined Log Formats
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+//logRecord holds the details logged for each request.
+//The formatters below turn a record into a log line,
+//so new formats don't need to touch the middleware.
+type logRecord struct {
+	Time       time.Time     `json:"time"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	RequestURI string        `json:"-"`
+	Proto      string        `json:"-"`
+	Status     int           `json:"status"`
+	Duration   time.Duration `json:"-"`
+	DurationMS float64       `json:"duration_ms"`
+	Bytes      int           `json:"bytes"`
+	RemoteAddr string        `json:"remote_addr"`
+	ClientIP   string        `json:"-"`
+	User       string        `json:"user,omitempty"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+	RequestID  string        `json:"request_id,omitempty"`
+	TraceID    string        `json:"trace_id,omitempty"`
+	SpanID     string        `json:"span_id,omitempty"`
+	Slow       bool          `json:"slow,omitempty"`
+}
+
+//defaultMaxDetailLength is the default maximum length
+//of the referer and user agent in log lines
+const defaultMaxDetailLength = 256
+
+//formatText formats a completed request as a line of text
+func formatText(rec *logRecord) string {
+	return fmt.Sprintf("%s%s %s %d %dB %v", textPrefix(rec), rec.Method, rec.Path, rec.Status, rec.Bytes, rec.Duration) +
+		clientDetails(rec)
+}
+
+//clientDetails returns the trace and span IDs, referer, and
+//user agent, if the record has them, to be appended to text lines
+func clientDetails(rec *logRecord) string {
+	details := ""
+	if len(rec.TraceID) > 0 {
+		details += " trace=" + rec.TraceID + " span=" + rec.SpanID
+	}
+	if len(rec.Referer) > 0 {
+		details += " referer=" + strconv.Quote(rec.Referer)
+	}
+	if len(rec.UserAgent) > 0 {
+		details += " user_agent=" + strconv.Quote(rec.UserAgent)
+	}
+	return details
+}
+
+//stripControl removes control characters, such as
+//newlines, so that values from the client can't
+//inject fake lines into the log
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+//sanitizeDetail strips control characters from a value
+//sent by the client and truncates it to `max` bytes,
+//without splitting a multi-byte character
+func sanitizeDetail(s string, max int) string {
+	s = stripControl(s)
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+//ANSI escape codes for coloring terminal output
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorReset  = "\x1b[0m"
+)
+
+//statusColor returns the color for a status code
+func statusColor(status int) string {
+	switch {
+	case status >= 500:
+		return colorRed
+	case status >= 400:
+		return colorYellow
+	case status >= 300:
+		return colorCyan
+	default:
+		return colorGreen
+	}
+}
+
+//formatPretty formats a completed request like formatText,
+//but with the columns aligned and the status code colored
+func formatPretty(rec *logRecord) string {
+	return fmt.Sprintf("%s%-7s %-30s %s%d%s %8s %10s",
+		textPrefix(rec), rec.Method, rec.Path,
+		statusColor(rec.Status), rec.Status, colorReset,
+		strconv.Itoa(rec.Bytes)+"B", rec.Duration) + clientDetails(rec)
+}
+
+//textPrefix returns the request ID in square
+//brackets, or an empty string if there isn't one
+func textPrefix(rec *logRecord) string {
+	if len(rec.RequestID) == 0 {
+		return ""
+	}
+	return "[" + rec.RequestID + "] "
+}
+
+//formatJSON formats a completed request as a JSON object
+func formatJSON(rec *logRecord) ([]byte, error) {
+	rec.DurationMS = float64(rec.Duration) / float64(time.Millisecond)
+	return json.Marshal(rec)
+}
+
+//clfField returns `s` escaped for use in a CLF line,
+//or "-" if it's empty, as the format requires
+func clfField(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return stripControl(strings.Replace(s, `"`, `\"`, -1))
+}
+
+//formatCombined formats a completed request as a line in the
+//Apache/NCSA Combined Log Format:
+//  host ident user [time] "request line" status bytes "referer" "user-agent"
+func formatCombined(rec *logRecord) string {
+	bytes := "-"
+	if rec.Bytes > 0 {
+		bytes = strconv.Itoa(rec.Bytes)
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s "%s" "%s"`,
+		clfField(rec.ClientIP),
+		clfField(rec.User),
+		rec.Time.Format(clfTimeFormat),
+		clfField(rec.Method), clfField(rec.RequestURI), clfField(rec.Proto),
+		rec.Status,
+		bytes,
+		clfField(rec.Referer),
+		clfField(rec.UserAgent))
+}
@@ -0,0 +1,294 @@
+package httpmw
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatCombined(t *testing.T) {
+	loc := time.FixedZone("PDT", -7*60*60)
+	cases := []struct {
+		name     string
+		rec      *logRecord
+		expected string
+	}{
+		{
+			"full record",
+			&logRecord{
+				Time:       time.Date(2017, time.April, 10, 13, 55, 36, 0, loc),
+				Method:     "GET",
+				RequestURI: "/v1/hello1?name=dave",
+				Proto:      "HTTP/1.1",
+				Status:     200,
+				Bytes:      2326,
+				ClientIP:   "127.0.0.1",
+				User:       "frank",
+				Referer:    "http://www.example.com/start.html",
+				UserAgent:  "Mozilla/4.08 [en] (Win98; I ;Nav)",
+			},
+			`127.0.0.1 - frank [10/Apr/2017:13:55:36 -0700] "GET /v1/hello1?name=dave HTTP/1.1" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+		},
+		{
+			"empty fields",
+			&logRecord{
+				Time:       time.Date(2017, time.April, 10, 13, 55, 36, 0, time.UTC),
+				Method:     "DELETE",
+				RequestURI: "/v1/tasks/1",
+				Proto:      "HTTP/1.1",
+				Status:     204,
+				ClientIP:   "::1",
+			},
+			`::1 - - [10/Apr/2017:13:55:36 +0000] "DELETE /v1/tasks/1 HTTP/1.1" 204 - "-" "-"`,
+		},
+		{
+			"quotes and newlines",
+			&logRecord{
+				Time:       time.Date(2017, time.April, 10, 13, 55, 36, 0, time.UTC),
+				Method:     "GET",
+				RequestURI: "/",
+				Proto:      "HTTP/1.1",
+				Status:     200,
+				Bytes:      5,
+				ClientIP:   "10.0.0.1",
+				UserAgent:  "evil\" agent\n127.0.0.1 - - fake",
+			},
+			`10.0.0.1 - - [10/Apr/2017:13:55:36 +0000] "GET / HTTP/1.1" 200 5 "-" "evil\" agent127.0.0.1 - - fake"`,
+		},
+	}
+
+	for _, c := range cases {
+		if actual := formatCombined(c.rec); actual != c.expected {
+			t.Errorf("%s:\nexpected %s\n     got %s", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestLogRequestsCombined(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "ignored: ", log.LstdFlags)
+	handler := LogRequestsWithOptions(logger, &LogOptions{Format: LogFormatCombined})(http.HandlerFunc(helloHandler))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/hello1?x=1", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	r.Header.Set("Referer", "http://localhost/")
+	r.Header.Set("User-Agent", "curl/7.51.0")
+	handler.ServeHTTP(w, r)
+
+	line := buf.String()
+	expectedPrefix := `192.0.2.7 - - [`
+	expectedSuffix := `] "GET /v1/hello1?x=1 HTTP/1.1" 200 20 "http://localhost/" "curl/7.51.0"` + "\n"
+	if len(line) < len(expectedPrefix)+len(expectedSuffix) ||
+		line[:len(expectedPrefix)] != expectedPrefix ||
+		line[len(line)-len(expectedSuffix):] != expectedSuffix {
+		t.Errorf("unexpected combined log line: %q", line)
+	}
+}
+
+func TestFormatPretty(t *testing.T) {
+	cases := []struct {
+		rec      *logRecord
+		expected string
+	}{
+		{
+			&logRecord{Method: "GET", Path: "/v1/tasks", Status: 200, Bytes: 20, Duration: 1500 * time.Microsecond},
+			"GET     /v1/tasks                      \x1b[32m200\x1b[0m      20B      1.5ms",
+		},
+		{
+			&logRecord{Method: "DELETE", Path: "/v1/tasks/1", Status: 404, Bytes: 19, Duration: time.Millisecond, RequestID: "req-1"},
+			"[req-1] DELETE  /v1/tasks/1                    \x1b[33m404\x1b[0m      19B        1ms",
+		},
+		{
+			&logRecord{Method: "POST", Path: "/v1/tasks", Status: 500, Bytes: 0, Duration: 2 * time.Second},
+			"POST    /v1/tasks                      \x1b[31m500\x1b[0m       0B         2s",
+		},
+		{
+			&logRecord{Method: "GET", Path: "/v1/hello1", Status: 301, Duration: time.Millisecond},
+			"GET     /v1/hello1                     \x1b[36m301\x1b[0m       0B        1ms",
+		},
+	}
+
+	for _, c := range cases {
+		if line := formatPretty(c.rec); line != c.expected {
+			t.Errorf("expected\n%q\nbut got\n%q", c.expected, line)
+		}
+	}
+}
+
+//fakeTerminal is a TerminalChecker that
+//always gives the same answer
+type fakeTerminal bool
+
+func (ft fakeTerminal) IsTerminal(w io.Writer) bool {
+	return bool(ft)
+}
+
+func TestLogRequestsPretty(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     *LogOptions
+		expected bool
+	}{
+		{"terminal", &LogOptions{Terminal: fakeTerminal(true)}, true},
+		{"not a terminal", &LogOptions{Terminal: fakeTerminal(false)}, false},
+		{"default checker", &LogOptions{}, false},
+		{"forced on", &LogOptions{Pretty: PrettyOn, Terminal: fakeTerminal(false)}, true},
+		{"forced off", &LogOptions{Pretty: PrettyOff, Terminal: fakeTerminal(true)}, false},
+		{"JSON", &LogOptions{Format: LogFormatJSON, Pretty: PrettyOn}, false},
+		{"Combined", &LogOptions{Format: LogFormatCombined, Pretty: PrettyOn}, false},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+		handler := LogRequestsWithOptions(logger, c.opts)(http.HandlerFunc(http.NotFound))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tasks", nil))
+
+		colored := strings.Contains(buf.String(), "\x1b[")
+		if colored != c.expected {
+			t.Errorf("%s: expected colored to be %t, got %q", c.name, c.expected, buf.String())
+		}
+		if c.expected && !strings.HasPrefix(buf.String(), "GET     /v1/tasks                      "+colorYellow+"404"+colorReset) {
+			t.Errorf("%s: unexpected pretty line %q", c.name, buf.String())
+		}
+	}
+}
+
+func TestFileTerminalChecker(t *testing.T) {
+	f, err := ioutil.TempFile("", "terminal")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	checker := fileTerminalChecker{}
+	if checker.IsTerminal(f) {
+		t.Error("a regular file should not be a terminal")
+	}
+	if checker.IsTerminal(&bytes.Buffer{}) {
+		t.Error("a buffer should not be a terminal")
+	}
+}
+
+func TestSanitizeDetail(t *testing.T) {
+	cases := []struct {
+		in       string
+		max      int
+		expected string
+	}{
+		{"curl/7.51.0", 256, "curl/7.51.0"},
+		{"evil\nagent\r\x00", 256, "evilagent"},
+		{"abcdef", 4, "abcd"},
+		//don't split the 3-byte €
+		{"ab€", 4, "ab"},
+		{"a\nbcdef", 3, "abc"},
+	}
+
+	for _, c := range cases {
+		if actual := sanitizeDetail(c.in, c.max); actual != c.expected {
+			t.Errorf("sanitizeDetail(%q, %d): expected %q but got %q", c.in, c.max, c.expected, actual)
+		}
+	}
+}
+
+func TestLogRequestsClientDetails(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     *LogOptions
+		referer  string
+		agent    string
+		expected []string
+		absent   []string
+	}{
+		{
+			"text",
+			&LogOptions{ClientDetails: true},
+			"http://localhost/", "curl/7.51.0",
+			[]string{` 20B `, ` referer="http://localhost/" user_agent="curl/7.51.0"` + "\n"},
+			nil,
+		},
+		{
+			"text without option",
+			&LogOptions{},
+			"http://localhost/", "curl/7.51.0",
+			nil,
+			[]string{"referer", "curl"},
+		},
+		{
+			"text injection",
+			&LogOptions{ClientDetails: true},
+			"", "curl\nGET /admin 200 0B 1ms",
+			[]string{` user_agent="curlGET /admin 200 0B 1ms"` + "\n"},
+			[]string{"referer"},
+		},
+		{
+			"text truncated",
+			&LogOptions{ClientDetails: true, MaxDetailLength: 4},
+			"http://localhost/", "curl/7.51.0",
+			[]string{` referer="http" user_agent="curl"` + "\n"},
+			nil,
+		},
+		{
+			"JSON",
+			&LogOptions{Format: LogFormatJSON, ClientDetails: true},
+			"http://localhost/", "curl/7.51.0",
+			[]string{`"referer":"http://localhost/"`, `"user_agent":"curl/7.51.0"`},
+			nil,
+		},
+		{
+			"JSON empty",
+			&LogOptions{Format: LogFormatJSON, ClientDetails: true},
+			"", "",
+			nil,
+			[]string{"referer", "user_agent"},
+		},
+		{
+			"JSON without option",
+			&LogOptions{Format: LogFormatJSON},
+			"http://localhost/", "curl/7.51.0",
+			nil,
+			[]string{"referer", "user_agent"},
+		},
+		{
+			"Combined without option",
+			&LogOptions{Format: LogFormatCombined, MaxDetailLength: 4},
+			"http://localhost/", "curl/7.51.0",
+			[]string{` "http" "curl"` + "\n"},
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+		handler := LogRequestsWithOptions(logger, c.opts)(http.HandlerFunc(helloHandler))
+		r := httptest.NewRequest("GET", "/v1/hello1", nil)
+		r.Header.Set("Referer", c.referer)
+		r.Header.Set("User-Agent", c.agent)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		output := buf.String()
+		if strings.Count(output, "\n") != 1 {
+			t.Errorf("%s: expected exactly one line, got %q", c.name, output)
+		}
+		for _, expected := range c.expected {
+			if !strings.Contains(output, expected) {
+				t.Errorf("%s: expected %q in %q", c.name, expected, output)
+			}
+		}
+		for _, absent := range c.absent {
+			if strings.Contains(output, absent) {
+				t.Errorf("%s: expected no %q in %q", c.name, absent, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//names of the non-file log destinations
+const (
+	logOutputStdout  = "stdout"
+	logOutputStderr  = "stderr"
+	logOutputDiscard = "discard"
+)
+
+const (
+	defaultLogFileMB      = 10
+	defaultLogFileBackups = 5
+)
+
+//LogConfig configures where logs are written. It can be
+//loaded from a JSON file like
+//  {"outputs": ["stdout", "/var/log/tasksvr.log"], "maxFileMB": 10}
+type LogConfig struct {
+	//Outputs lists where to write logs: "stdout", "stderr",
+	//"discard", or the path of a file. Every line is written
+	//to all of them. Defaults to stdout.
+	Outputs []string `json:"outputs"`
+	//MaxFileMB is the size at which log files are
+	//rotated. Defaults to 10.
+	MaxFileMB int `json:"maxFileMB"`
+	//MaxFileBackups is the number of rotated log
+	//files that are kept. Defaults to 5.
+	MaxFileBackups int `json:"maxFileBackups"`
+}
+
+//LoadLogConfig loads a LogConfig from a JSON file
+func LoadLogConfig(filePath string) (*LogConfig, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log config file: %v", err)
+	}
+	defer f.Close()
+
+	config := &LogConfig{}
+	if err := json.NewDecoder(f).Decode(config); err != nil {
+		return nil, fmt.Errorf("error decoding log config file: %v", err)
+	}
+	return config, nil
+}
+
+//LogConfigFromEnv returns the LogConfig described by the
+//environment. If LOGCONFIG is set, it's loaded from that
+//JSON file. Otherwise the outputs are read from LOGOUTPUTS,
+//a comma-separated list like "stdout,/var/log/tasksvr.log",
+//or from LOGFILE, which names a single file to log to.
+func LogConfigFromEnv() (*LogConfig, error) {
+	if configFile := os.Getenv("LOGCONFIG"); len(configFile) > 0 {
+		return LoadLogConfig(configFile)
+	}
+	config := &LogConfig{}
+	if outputs := os.Getenv("LOGOUTPUTS"); len(outputs) > 0 {
+		for _, output := range strings.Split(outputs, ",") {
+			if output = strings.TrimSpace(output); len(output) > 0 {
+				config.Outputs = append(config.Outputs, output)
+			}
+		}
+	} else if logFile := os.Getenv("LOGFILE"); len(logFile) > 0 {
+		config.Outputs = []string{logFile}
+	}
+	return config, nil
+}
+
+//LogOutput is an io.Writer that writes to several destinations
+//at once, to pass to log.New(). A failing destination doesn't stop
+//the others from being written to, and its errors are counted
+//rather than returned, so that logging never breaks a request.
+type LogOutput struct {
+	io.Writer
+	files     []*RotatingFile
+	errors    int64
+	mx        sync.Mutex
+	lastError error
+	//terminal is true if the only destination is a terminal
+	terminal bool
+}
+
+//outputWriter wraps one of the destinations, reporting
+//its errors to the LogOutput and hiding them from
+//io.MultiWriter, which would otherwise stop at the first one
+type outputWriter struct {
+	io.Writer
+	lo *LogOutput
+}
+
+func (ow outputWriter) Write(p []byte) (int, error) {
+	if _, err := ow.Writer.Write(p); err != nil {
+		ow.lo.recordError(err)
+	}
+	return len(p), nil
+}
+
+//OpenLogOutput opens the destinations listed in `config`.
+//It returns an error if any of the files can't be opened,
+//which should stop the server from starting.
+func OpenLogOutput(config *LogConfig) (*LogOutput, error) {
+	outputs := config.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{logOutputStdout}
+	}
+	maxMB := config.MaxFileMB
+	if maxMB <= 0 {
+		maxMB = defaultLogFileMB
+	}
+	maxBackups := config.MaxFileBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultLogFileBackups
+	}
+
+	lo := &LogOutput{}
+	writers := make([]io.Writer, 0, len(outputs))
+	for _, output := range outputs {
+		var w io.Writer
+		switch output {
+		case logOutputStdout:
+			w = os.Stdout
+		case logOutputStderr:
+			w = os.Stderr
+		case logOutputDiscard:
+			w = ioutil.Discard
+		default:
+			rf, err := NewRotatingFile(output, int64(maxMB)*1024*1024, maxBackups)
+			if err != nil {
+				lo.Close()
+				return nil, err
+			}
+			lo.files = append(lo.files, rf)
+			w = rf
+		}
+		writers = append(writers, w)
+	}
+	//once it's wrapped, stdout is no longer an *os.File, so
+	//check for a terminal first; color codes would be noise
+	//in any other destinations, so there must be just one
+	lo.terminal = len(writers) == 1 && fileTerminalChecker{}.IsTerminal(writers[0])
+	lo.Writer = newLogOutputWriter(lo, writers...)
+	return lo, nil
+}
+
+//newLogOutputWriter combines `writers` with io.MultiWriter,
+//reporting their errors to `lo`
+func newLogOutputWriter(lo *LogOutput, writers ...io.Writer) io.Writer {
+	wrapped := make([]io.Writer, len(writers))
+	for i, w := range writers {
+		wrapped[i] = outputWriter{Writer: w, lo: lo}
+	}
+	return io.MultiWriter(wrapped...)
+}
+
+//recordError counts a failed write
+func (lo *LogOutput) recordError(err error) {
+	atomic.AddInt64(&lo.errors, 1)
+	lo.mx.Lock()
+	lo.lastError = err
+	lo.mx.Unlock()
+}
+
+//Errors returns the number of writes that have failed
+func (lo *LogOutput) Errors() int64 {
+	return atomic.LoadInt64(&lo.errors)
+}
+
+//Stats returns the error count and the most recent error
+//in a form suitable for publishing with expvar.Func
+func (lo *LogOutput) Stats() interface{} {
+	lo.mx.Lock()
+	defer lo.mx.Unlock()
+	lastError := ""
+	if lo.lastError != nil {
+		lastError = lo.lastError.Error()
+	}
+	return map[string]interface{}{
+		"writeErrors": lo.Errors(),
+		"lastError":   lastError,
+	}
+}
+
+//ReopenOnSIGHUP reopens the log files every time the
+//process receives a SIGHUP; see RotatingFile.ReopenOnSIGHUP
+func (lo *LogOutput) ReopenOnSIGHUP() {
+	for _, rf := range lo.files {
+		rf.ReopenOnSIGHUP()
+	}
+}
+
+//Close closes the log files
+func (lo *LogOutput) Close() error {
+	var firstErr error
+	for _, rf := range lo.files {
+		if err := rf.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
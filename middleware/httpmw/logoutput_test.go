@@ -0,0 +1,154 @@
+package httpmw
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//fullDiskWriter is a log destination that always fails,
+//like a file on a full disk
+type fullDiskWriter struct{}
+
+func (fullDiskWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestLogOutputWriteErrors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lo := &LogOutput{}
+	lo.Writer = newLogOutputWriter(lo, fullDiskWriter{}, buf)
+	handler := LogRequests(log.New(lo, "", 0))(http.HandlerFunc(helloHandler))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "Hello from Handler 1" {
+			t.Errorf("expected the request to succeed, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	if errs := lo.Errors(); errs != 3 {
+		t.Errorf("expected 3 write errors but got %d", errs)
+	}
+	//the failing destination doesn't stop the others
+	if lines := strings.Count(buf.String(), "GET /v1/hello1 200"); lines != 3 {
+		t.Errorf("expected 3 lines in the working destination, got %q", buf.String())
+	}
+	stats := lo.Stats().(map[string]interface{})
+	if stats["lastError"] != "no space left on device" {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestOpenLogOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logoutput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	lo, err := OpenLogOutput(&LogConfig{Outputs: []string{"discard", path}})
+	if err != nil {
+		t.Fatalf("error opening log output: %v", err)
+	}
+	log.New(lo, "", 0).Print("hello")
+	lo.Close()
+	if contents := readFile(t, path); contents != "hello\n" {
+		t.Errorf("unexpected log file contents %q", contents)
+	}
+
+	//a file that can't be opened is reported at startup
+	_, err = OpenLogOutput(&LogConfig{Outputs: []string{"stdout", filepath.Join(dir, "missing", "access.log")}})
+	if err == nil {
+		t.Error("expected an error for a file in a missing directory")
+	}
+}
+
+func TestLogOutputTerminal(t *testing.T) {
+	//the checker treats any character device as a terminal,
+	//so /dev/null can stand in for one
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	r, pipe, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer pipe.Close()
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+
+	cases := []struct {
+		name     string
+		stdout   *os.File
+		outputs  []string
+		expected bool
+	}{
+		{"terminal", devNull, []string{"stdout"}, true},
+		{"default to a terminal", devNull, nil, true},
+		{"pipe", pipe, []string{"stdout"}, false},
+		{"terminal and another", devNull, []string{"stdout", "discard"}, false},
+		{"discard", devNull, []string{"discard"}, false},
+	}
+	opts := &LogOptions{Format: LogFormatText}
+	for _, c := range cases {
+		os.Stdout = c.stdout
+		lo, err := OpenLogOutput(&LogConfig{Outputs: c.outputs})
+		if err != nil {
+			t.Fatalf("%s: error opening log output: %v", c.name, err)
+		}
+		if pretty := opts.pretty(log.New(lo, "", 0).Writer()); pretty != c.expected {
+			t.Errorf("%s: expected pretty to be %t but got %t", c.name, c.expected, pretty)
+		}
+	}
+}
+
+func TestLogConfigFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "log.json")
+	if err := ioutil.WriteFile(configFile, []byte(`{"outputs": ["stdout", "/var/log/grader.log"], "maxFileMB": 50}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("LOGCONFIG")
+	defer os.Unsetenv("LOGOUTPUTS")
+	defer os.Unsetenv("LOGFILE")
+
+	cases := []struct {
+		env      map[string]string
+		expected *LogConfig
+	}{
+		{map[string]string{}, &LogConfig{}},
+		{map[string]string{"LOGFILE": "/var/log/app.log"}, &LogConfig{Outputs: []string{"/var/log/app.log"}}},
+		{map[string]string{"LOGOUTPUTS": "stdout, /var/log/grader.log,", "LOGFILE": "/var/log/app.log"}, &LogConfig{Outputs: []string{"stdout", "/var/log/grader.log"}}},
+		{map[string]string{"LOGCONFIG": configFile, "LOGOUTPUTS": "stderr"}, &LogConfig{Outputs: []string{"stdout", "/var/log/grader.log"}, MaxFileMB: 50}},
+	}
+	for _, c := range cases {
+		for _, name := range []string{"LOGCONFIG", "LOGOUTPUTS", "LOGFILE"} {
+			os.Setenv(name, c.env[name])
+		}
+		config, err := LogConfigFromEnv()
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.env, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, c.expected) {
+			t.Errorf("%v: expected %+v but got %+v", c.env, c.expected, config)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package httpmw
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//slowPrefix starts text lines for slow requests
+const slowPrefix = "WARN slow request: "
+
+//LogFormat selects how LogRequests formats each request
+type LogFormat int
+
+const (
+	//LogFormatText writes human-readable lines
+	LogFormatText LogFormat = iota
+	//LogFormatJSON writes one JSON object per request
+	LogFormatJSON
+	//LogFormatCombined writes Apache/NCSA Combined Log Format lines
+	LogFormatCombined
+)
+
+//PrettyMode selects whether text log lines are colored
+//and aligned for reading in a terminal
+type PrettyMode int
+
+const (
+	//PrettyAuto colors the output if the
+	//logger writes to a terminal
+	PrettyAuto PrettyMode = iota
+	//PrettyOn always colors the output
+	PrettyOn
+	//PrettyOff never colors the output
+	PrettyOff
+)
+
+//LogOptions configures the LogRequests middleware
+type LogOptions struct {
+	Format LogFormat
+	//SkipPaths are exact paths that aren't logged,
+	//such as /health or /favicon.ico
+	SkipPaths []string
+	//SkipPrefixes are path prefixes that aren't logged,
+	//such as /metrics/
+	SkipPrefixes []string
+	//SlowThreshold marks requests that take longer than this
+	//as slow: text lines get a WARN prefix, and JSON objects
+	//get "slow":true. Zero disables slow-request detection.
+	SlowThreshold time.Duration
+	//OnlySlow suppresses the log lines for requests
+	//that complete within SlowThreshold
+	OnlySlow bool
+	//LogStart also writes a line as each request starts,
+	//which helps when debugging long-running handlers.
+	//It only applies to the text format.
+	LogStart bool
+	//SampleRate is the fraction (0.0-1.0) of successful requests
+	//that are logged. Error responses (4xx and 5xx) and slow
+	//requests are always logged. Zero, the default, logs
+	//every request.
+	SampleRate float64
+	//Rand returns a random number in [0.0,1.0) and decides
+	//which requests are sampled. It must be safe to call
+	//concurrently. Defaults to rand.Float64.
+	Rand func() float64
+	//Pretty colors the status code and aligns the columns of
+	//text lines, for reading logs in a terminal during
+	//development. It never applies to JSON or Combined lines.
+	Pretty PrettyMode
+	//Terminal decides whether the logger writes to a terminal
+	//when Pretty is PrettyAuto. Defaults to checking whether
+	//it's an *os.File for a character device, or a LogOutput
+	//writing only to one, so output to files and pipes is
+	//never colored.
+	Terminal TerminalChecker
+	//ClientDetails adds the Referer and User-Agent request
+	//headers to text and JSON lines, when the client sent
+	//them. Combined lines always include them.
+	ClientDetails bool
+	//MaxDetailLength truncates the referer and user agent
+	//to this many bytes. Defaults to 256.
+	MaxDetailLength int
+}
+
+//pretty returns true if text lines written
+//to `w` should be colored and aligned
+func (opts *LogOptions) pretty(w io.Writer) bool {
+	if opts.Format != LogFormatText {
+		return false
+	}
+	switch opts.Pretty {
+	case PrettyOn:
+		return true
+	case PrettyOff:
+		return false
+	}
+	terminal := opts.Terminal
+	if terminal == nil {
+		terminal = fileTerminalChecker{}
+	}
+	return terminal.IsTerminal(w)
+}
+
+//sampled returns true if `rec` should be logged
+//under the configured sample rate
+func (opts *LogOptions) sampled(rec *logRecord) bool {
+	if opts.SampleRate <= 0 || opts.SampleRate >= 1 ||
+		rec.Status >= 400 || rec.Slow {
+		return true
+	}
+	random := opts.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < opts.SampleRate
+}
+
+//skipper decides which requests aren't logged
+type skipper struct {
+	paths    map[string]bool
+	prefixes []string
+}
+
+func newSkipper(opts *LogOptions) *skipper {
+	s := &skipper{
+		paths:    make(map[string]bool, len(opts.SkipPaths)),
+		prefixes: opts.SkipPrefixes,
+	}
+	for _, path := range opts.SkipPaths {
+		s.paths[path] = true
+	}
+	return s
+}
+
+//skip returns true if requests for `path` shouldn't be logged
+func (s *skipper) skip(path string) bool {
+	if s.paths[path] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//logUserKey is the request context key under which
+//LogRequests stores a pointer to the user field of the
+//request's log record, so that authentication middleware
+//installed inside it can fill it in
+const logUserKey contextKey = "logUser"
+
+//setLogUser records `user` as the authenticated user
+//in the log line for the request with context `ctx`.
+//It does nothing if LogRequests isn't installed.
+func setLogUser(ctx context.Context, user string) {
+	if p, ok := ctx.Value(logUserKey).(*string); ok {
+		*p = user
+	}
+}
+
+//LogRequests returns an Adapter that logs each request
+//to `logger` using the default text format.
+func LogRequests(logger *log.Logger) Adapter {
+	return LogRequestsWithOptions(logger, &LogOptions{})
+}
+
+//LogRequestsWithOptions returns an Adapter that logs each
+//request to `logger`. Callers construct the logger, so they
+//control where the output goes, as well as its prefix and flags.
+//
+//Exactly one line is written per request, after the wrapped
+//handler returns, so lines from concurrent requests never
+//interleave. In text format, the line contains the method, path,
+//response status, bytes written, and duration. If the request
+//has an ID (see AssignRequestIDs), the line starts with that ID
+//in square brackets, and if it's part of a trace (see
+//PropagateTraces), the trace and span IDs are added to the end.
+//If opts.LogStart is set, the method and path are also logged
+//as the request starts. See opts.Pretty for coloring text lines
+//in a terminal.
+//
+//In JSON and Combined formats, lines are written directly to
+//the logger's writer, without its prefix or flags, so that
+//log analysis tools can parse them.
+//
+//Requests for paths in opts.SkipPaths or starting with one of
+//opts.SkipPrefixes are handled normally but not logged.
+//If opts.OnlySlow is set, only requests that take longer than
+//opts.SlowThreshold are logged. If opts.SampleRate is set, only
+//that fraction of the remaining successful requests are logged.
+//Since sampling only decides whether the line is written, metrics
+//middleware such as CountRequests still sees every request.
+func LogRequestsWithOptions(logger *log.Logger, opts *LogOptions) Adapter {
+	skipper := newSkipper(opts)
+	maxDetail := opts.MaxDetailLength
+	if maxDetail <= 0 {
+		maxDetail = defaultMaxDetailLength
+	}
+	format := formatText
+	if opts.pretty(logger.Writer()) {
+		format = formatPretty
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skipper.skip(r.URL.Path) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			rec := logRecord{
+				Time:       time.Now(),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				RequestURI: r.RequestURI,
+				Proto:      r.Proto,
+				RemoteAddr: r.RemoteAddr,
+				ClientIP:   ClientIP(r),
+				Referer:    sanitizeDetail(r.Referer(), maxDetail),
+				UserAgent:  sanitizeDetail(r.UserAgent(), maxDetail),
+				RequestID:  RequestIDFromContext(r.Context()),
+			}
+			if len(rec.RequestURI) == 0 {
+				rec.RequestURI = r.URL.RequestURI()
+			}
+			if tc := TraceFromContext(r.Context()); tc != nil {
+				rec.TraceID = tc.TraceID
+				rec.SpanID = tc.SpanID
+			}
+			if opts.Format == LogFormatText && opts.LogStart && !opts.OnlySlow {
+				logger.Printf("%s%s %s", textPrefix(&rec), rec.Method, rec.Path)
+			}
+
+			rw := newResponseRecorder(w)
+			ctx := context.WithValue(r.Context(), logUserKey, &rec.User)
+			handler.ServeHTTP(rw, r.WithContext(ctx))
+			rec.Duration = time.Since(rec.Time)
+			rec.Status = rw.status
+			rec.Bytes = rw.bytes
+			rec.Slow = opts.SlowThreshold > 0 && rec.Duration > opts.SlowThreshold
+			if (opts.OnlySlow && !rec.Slow) || !opts.sampled(&rec) {
+				return
+			}
+
+			if !opts.ClientDetails && opts.Format != LogFormatCombined {
+				rec.Referer = ""
+				rec.UserAgent = ""
+			}
+
+			switch opts.Format {
+			case LogFormatJSON:
+				line, err := formatJSON(&rec)
+				if err != nil {
+					logger.Printf("error formatting log record: %v", err)
+					return
+				}
+				logger.Writer().Write(append(line, '\n'))
+			case LogFormatCombined:
+				logger.Writer().Write([]byte(formatCombined(&rec) + "\n"))
+			default:
+				if rec.Slow {
+					logger.Print(slowPrefix + format(&rec))
+				} else {
+					logger.Print(format(&rec))
+				}
+			}
+		})
+	}
+}
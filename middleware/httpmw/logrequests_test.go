@@ -0,0 +1,398 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//logOutput runs `handler` behind LogRequests and returns
+//the recorded response and everything that was logged
+func logOutput(handler http.HandlerFunc, method string, path string) (*httptest.ResponseRecorder, string) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	LogRequests(logger)(handler).ServeHTTP(w, r)
+	return w, buf.String()
+}
+
+func TestLogRequests(t *testing.T) {
+	handlerCalled := false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+	}
+
+	_, output := logOutput(handler, "POST", "/v1/hello1")
+	if !handlerCalled {
+		t.Error("wrapped handler was not called")
+	}
+
+	//a single line with the status, bytes, and duration
+	expected := regexp.MustCompile(`^POST /v1/hello1 200 0B [0-9.]+[nµm]?s\n$`)
+	if !expected.MatchString(output) {
+		t.Errorf("unexpected log output: %q", output)
+	}
+}
+
+func TestLogRequestsStart(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := LogRequestsWithOptions(logger, &LogOptions{LogStart: true})(http.HandlerFunc(helloHandler))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+
+	expected := regexp.MustCompile(`^GET /v1/hello1\nGET /v1/hello1 200 20B [0-9.]+[nµm]?s\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("unexpected log output: %q", buf.String())
+	}
+}
+
+func TestLogRequestsConcurrent(t *testing.T) {
+	const requests = 50
+	buf := &bytes.Buffer{}
+	//log.Logger serializes writes to its writer
+	logger := log.New(buf, "", 0)
+	handler := AssignRequestIDs(LogRequests(logger)(sleepHandler(time.Millisecond)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+		}()
+	}
+	wg.Wait()
+
+	line := regexp.MustCompile(`^\[[0-9a-f]{32}\] GET /v1/hello1 200 0B [0-9.]+[nµm]?s$`)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != requests {
+		t.Fatalf("expected %d lines but got %d", requests, len(lines))
+	}
+	ids := map[string]bool{}
+	for _, l := range lines {
+		if !line.MatchString(l) {
+			t.Errorf("unexpected log line: %q", l)
+		}
+		ids[l[:34]] = true
+	}
+	if len(ids) != requests {
+		t.Errorf("expected one line per request ID, but got %d distinct IDs", len(ids))
+	}
+}
+
+func TestLogRequestsStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected int
+	}{
+		{
+			"explicit WriteHeader",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("accepted"))
+			},
+			http.StatusAccepted,
+		},
+		{
+			"implicit 200 via Write",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			},
+			http.StatusOK,
+		},
+		{
+			"error status",
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", http.StatusInternalServerError)
+			},
+			http.StatusInternalServerError,
+		},
+		{
+			"second WriteHeader ignored",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.WriteHeader(http.StatusOK)
+			},
+			http.StatusNotFound,
+		},
+		{
+			"no writes at all",
+			func(w http.ResponseWriter, r *http.Request) {},
+			http.StatusOK,
+		},
+	}
+
+	for _, c := range cases {
+		w, output := logOutput(c.handler, "GET", "/v1/hello2")
+		if w.Code != c.expected {
+			t.Errorf("%s: expected response status %d but got %d", c.name, c.expected, w.Code)
+		}
+		if !strings.Contains(output, fmt.Sprintf("GET /v1/hello2 %d ", c.expected)) {
+			t.Errorf("%s: expected status %d in log output, got %q", c.name, c.expected, output)
+		}
+	}
+}
+
+func TestLogRequestsBytes(t *testing.T) {
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{
+			"multiple writes",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("Hello "))
+				w.Write([]byte("from "))
+				w.Write([]byte("Handler 1"))
+			},
+			" 200 20B ",
+		},
+		{
+			"no content",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			" 204 0B ",
+		},
+	}
+
+	for _, c := range cases {
+		w, output := logOutput(c.handler, "GET", "/v1/hello1")
+		if !strings.Contains(output, c.expected) {
+			t.Errorf("%s: expected %q in log output, got %q", c.name, c.expected, output)
+		}
+		if strings.Contains(c.expected, "20B") && w.Body.Len() != 20 {
+			t.Errorf("%s: expected 20 bytes in response but got %d", c.name, w.Body.Len())
+		}
+	}
+}
+
+//failingWriter is an http.ResponseWriter whose
+//Write always fails after writing `n` bytes
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	n int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	return fw.n, errors.New("connection reset")
+}
+
+func TestResponseRecorderWriteError(t *testing.T) {
+	rec := newResponseRecorder(&failingWriter{httptest.NewRecorder(), 3})
+	n, err := rec.Write([]byte("hello"))
+	if err == nil || err.Error() != "connection reset" {
+		t.Errorf("expected underlying error to be returned, got %v", err)
+	}
+	if n != 3 || rec.bytes != 3 {
+		t.Errorf("expected 3 bytes written and counted, got %d written and %d counted", n, rec.bytes)
+	}
+}
+
+func TestLogRequestsJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	//the prefix and flags must not end up in JSON lines
+	logger := log.New(buf, "access: ", log.LstdFlags)
+	handler := AssignRequestIDs(LogRequestsWithOptions(logger, &LogOptions{Format: LogFormatJSON})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		})))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/hello1", nil)
+	r.Header.Set(headerRequestID, "req-1")
+	handler.ServeHTTP(w, r)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one line but got %d: %q", len(lines), buf.String())
+	}
+
+	rec := &logRecord{}
+	if err := json.Unmarshal([]byte(lines[0]), rec); err != nil {
+		t.Fatalf("error unmarshaling log line %q: %v", lines[0], err)
+	}
+	if rec.Method != "POST" || rec.Path != "/v1/hello1" {
+		t.Errorf("unexpected method/path: %s %s", rec.Method, rec.Path)
+	}
+	if rec.Status != http.StatusCreated || rec.Bytes != 7 {
+		t.Errorf("expected status 201 and 7 bytes but got %d and %d", rec.Status, rec.Bytes)
+	}
+	if rec.RequestID != "req-1" {
+		t.Errorf("expected request ID req-1 but got %q", rec.RequestID)
+	}
+	if rec.RemoteAddr != r.RemoteAddr {
+		t.Errorf("expected remote addr %s but got %s", r.RemoteAddr, rec.RemoteAddr)
+	}
+	if rec.Time.IsZero() || rec.DurationMS < 0 {
+		t.Errorf("expected time and duration to be set, got %v and %v", rec.Time, rec.DurationMS)
+	}
+}
+
+func TestLogRequestsSkipPaths(t *testing.T) {
+	formats := []LogFormat{LogFormatText, LogFormatJSON}
+	for _, format := range formats {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+		opts := &LogOptions{
+			Format:       format,
+			SkipPaths:    []string{"/health", "/favicon.ico"},
+			SkipPrefixes: []string{"/metrics/"},
+		}
+
+		calls := 0
+		handler := LogRequestsWithOptions(logger, opts)(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				calls++
+			}))
+
+		for _, path := range []string{"/health", "/favicon.ico", "/metrics/requests"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}
+		if buf.Len() > 0 {
+			t.Errorf("format %d: expected skipped paths to be silent, got %q", format, buf.String())
+		}
+
+		for _, path := range []string{"/v1/hello1", "/healthz", "/metrics"} {
+			buf.Reset()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+			if !strings.Contains(buf.String(), path) {
+				t.Errorf("format %d: expected %s to be logged, got %q", format, path, buf.String())
+			}
+		}
+
+		if calls != 6 {
+			t.Errorf("format %d: expected all 6 requests to be handled but got %d", format, calls)
+		}
+	}
+}
+
+func sleepHandler(d time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(d)
+	}
+}
+
+func TestLogRequestsSlowThreshold(t *testing.T) {
+	threshold := 20 * time.Millisecond
+	cases := []struct {
+		name          string
+		opts          *LogOptions
+		sleep         time.Duration
+		expectedLines int
+		expectedSlow  bool
+	}{
+		{"fast text", &LogOptions{SlowThreshold: threshold}, 0, 1, false},
+		{"slow text", &LogOptions{SlowThreshold: threshold}, threshold * 2, 1, true},
+		{"slow text with start", &LogOptions{SlowThreshold: threshold, LogStart: true}, threshold * 2, 2, true},
+		{"fast only-slow", &LogOptions{SlowThreshold: threshold, OnlySlow: true}, 0, 0, false},
+		{"slow only-slow", &LogOptions{SlowThreshold: threshold, OnlySlow: true}, threshold * 2, 1, true},
+		{"fast JSON", &LogOptions{Format: LogFormatJSON, SlowThreshold: threshold}, 0, 1, false},
+		{"slow JSON", &LogOptions{Format: LogFormatJSON, SlowThreshold: threshold}, threshold * 2, 1, true},
+		{"slow JSON only-slow", &LogOptions{Format: LogFormatJSON, SlowThreshold: threshold, OnlySlow: true}, threshold * 2, 1, true},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+		handler := LogRequestsWithOptions(logger, c.opts)(sleepHandler(c.sleep))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tasks", nil))
+
+		output := strings.TrimSuffix(buf.String(), "\n")
+		lines := 0
+		if len(output) > 0 {
+			lines = len(strings.Split(output, "\n"))
+		}
+		if lines != c.expectedLines {
+			t.Errorf("%s: expected %d lines but got %d: %q", c.name, c.expectedLines, lines, output)
+			continue
+		}
+		if lines == 0 {
+			continue
+		}
+
+		last := output[strings.LastIndex(output, "\n")+1:]
+		if c.opts.Format == LogFormatJSON {
+			rec := &logRecord{}
+			if err := json.Unmarshal([]byte(last), rec); err != nil {
+				t.Fatalf("%s: error unmarshaling %q: %v", c.name, last, err)
+			}
+			if rec.Slow != c.expectedSlow {
+				t.Errorf("%s: expected slow=%t but got %t", c.name, c.expectedSlow, rec.Slow)
+			}
+			if c.expectedSlow && rec.DurationMS < float64(threshold/time.Millisecond) {
+				t.Errorf("%s: expected full timing, got %vms", c.name, rec.DurationMS)
+			}
+		} else if strings.HasPrefix(last, slowPrefix) != c.expectedSlow {
+			t.Errorf("%s: expected WARN prefix to be %t, got %q", c.name, c.expectedSlow, last)
+		}
+	}
+}
+
+func TestLogRequestsSampling(t *testing.T) {
+	threshold := 20 * time.Millisecond
+	cases := []struct {
+		name     string
+		random   float64
+		handler  http.HandlerFunc
+		expected bool
+	}{
+		{"sampled in", 0.05, helloHandler, true},
+		{"sampled out", 0.5, helloHandler, false},
+		{"client error", 0.5, http.NotFound, true},
+		{"server error", 0.99, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}, true},
+		{"slow", 0.99, sleepHandler(threshold * 2), true},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		logger := log.New(buf, "", 0)
+		random := c.random
+		opts := &LogOptions{
+			SampleRate:    0.1,
+			SlowThreshold: threshold,
+			Rand:          func() float64 { return random },
+		}
+		handler := LogRequestsWithOptions(logger, opts)(c.handler)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+		if logged := buf.Len() > 0; logged != c.expected {
+			t.Errorf("%s: expected logged to be %t, got %q", c.name, c.expected, buf.String())
+		}
+	}
+}
+
+func TestLogRequestsSamplingCounts(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	opts := &LogOptions{
+		SampleRate: 0.1,
+		Rand:       func() float64 { return 0.5 },
+	}
+	pattern := "/test/sampling"
+	handler := LogRequestsWithOptions(logger, opts)(CountRequests(pattern)(http.HandlerFunc(helloHandler)))
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", pattern, nil))
+	}
+
+	if buf.Len() > 0 {
+		t.Errorf("expected every request to be sampled out, got %q", buf.String())
+	}
+	if requests := routeCounter(t, pattern, "requests"); requests != 10 {
+		t.Errorf("expected sampled-out requests to be counted, got %d", requests)
+	}
+}
@@ -0,0 +1,102 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+const defaultMaintenanceMessage = "down for maintenance, please try again later"
+
+//MaintenanceState is whether the server is down for
+//maintenance, and what to tell clients if it is
+type MaintenanceState struct {
+	Enabled bool `json:"enabled"`
+	//Message is sent to clients in the error body
+	Message string `json:"message,omitempty"`
+	//RetryAfter, if set, is sent in the Retry-After header,
+	//as the number of seconds until the server is back
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+//Maintenance puts the server into maintenance mode, so that
+//clients get a clean 503 while data is being migrated, rather
+//than responses from a half-working server. It can be turned
+//on and off at runtime, using its ServeHTTP method as an admin
+//handler. The state is swapped as a whole using an atomic.Value,
+//so requests always see a message and Retry-After that belong
+//together, without taking a lock.
+type Maintenance struct {
+	state   atomic.Value
+	allowed map[string]bool
+}
+
+//NewMaintenance creates a new Maintenance, initially off. Requests
+//for the `allowed` paths, such as /health and the admin handler
+//itself, are let through even during maintenance.
+func NewMaintenance(allowed ...string) *Maintenance {
+	m := &Maintenance{allowed: make(map[string]bool, len(allowed))}
+	for _, path := range allowed {
+		m.allowed[path] = true
+	}
+	m.state.Store(&MaintenanceState{})
+	return m
+}
+
+//State returns the current state
+func (m *Maintenance) State() MaintenanceState {
+	return *m.state.Load().(*MaintenanceState)
+}
+
+//Set replaces the current state
+func (m *Maintenance) Set(state MaintenanceState) {
+	if len(state.Message) == 0 {
+		state.Message = defaultMaintenanceMessage
+	}
+	m.state.Store(&state)
+}
+
+//Block is an Adapter that answers every request with a 503
+//while maintenance mode is on, except for the allowed paths
+func (m *Maintenance) Block(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//load the state once, so this request
+		//sees a consistent view of it
+		state := m.state.Load().(*MaintenanceState)
+		if !state.Enabled || m.allowed[r.URL.Path] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if state.RetryAfter > 0 {
+			w.Header().Set(headerRetryAfter, strconv.Itoa(state.RetryAfter))
+		}
+		respondError(w, http.StatusServiceUnavailable, state.Message)
+	})
+}
+
+//ServeHTTP is an admin handler for maintenance mode. GET
+//returns the current state as JSON, and POST replaces it
+//with the JSON in the request body, such as
+//  {"enabled": true, "message": "back at 10pm", "retryAfter": 3600}
+//Protect it with BasicAuth, and include its path in
+//the allowed paths so it can turn maintenance off again.
+func (m *Maintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		state := MaintenanceState{}
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			respondError(w, http.StatusBadRequest, "error decoding maintenance state: "+err.Error())
+			return
+		}
+		m.Set(state)
+	default:
+		w.Header().Set(headerAllow, "GET, POST")
+		respondError(w, http.StatusMethodNotAllowed, "method "+r.Method+" is not allowed")
+		return
+	}
+	state := m.State()
+	w.Header().Set(headerContentType, contentTypeJSON)
+	json.NewEncoder(w).Encode(&state)
+}
@@ -0,0 +1,129 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	m := NewMaintenance("/health", "/admin/maintenance")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/tasks", helloHandler)
+	mux.HandleFunc("/health", helloHandler)
+	mux.Handle("/admin/maintenance", m)
+	handler := m.Block(mux)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := request("GET", "/v1/tasks", ""); w.Code != http.StatusOK {
+		t.Errorf("expected status %d before maintenance but got %d", http.StatusOK, w.Code)
+	}
+
+	w := request("POST", "/admin/maintenance", `{"enabled": true, "message": "migrating tasks", "retryAfter": 600}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d from the toggle but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = request("GET", "/v1/tasks", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d during maintenance but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if retryAfter := w.Header().Get(headerRetryAfter); retryAfter != "600" {
+		t.Errorf("expected Retry-After of 600 but got %q", retryAfter)
+	}
+	er := &errorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(er); err != nil || er.Error != "migrating tasks" {
+		t.Errorf("expected the maintenance message, got %+v, %v", er, err)
+	}
+	if w := request("GET", "/health", ""); w.Code != http.StatusOK {
+		t.Errorf("expected /health to be allowed during maintenance but got %d", w.Code)
+	}
+
+	w = request("GET", "/admin/maintenance", "")
+	state := &MaintenanceState{}
+	if err := json.NewDecoder(w.Body).Decode(state); err != nil || !state.Enabled {
+		t.Errorf("expected the state to be enabled, got %+v, %v", state, err)
+	}
+
+	if w := request("POST", "/admin/maintenance", `{"enabled": false}`); w.Code != http.StatusOK {
+		t.Errorf("expected status %d from the toggle but got %d", http.StatusOK, w.Code)
+	}
+	if w := request("GET", "/v1/tasks", ""); w.Code != http.StatusOK {
+		t.Errorf("expected status %d after maintenance but got %d", http.StatusOK, w.Code)
+	}
+	if w := request("POST", "/admin/maintenance", `{"enabled": `); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid JSON but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestMaintenanceDefaultMessage(t *testing.T) {
+	m := NewMaintenance()
+	m.Set(MaintenanceState{Enabled: true})
+	w := httptest.NewRecorder()
+	m.Block(http.HandlerFunc(helloHandler)).ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	if !strings.Contains(w.Body.String(), defaultMaintenanceMessage) {
+		t.Errorf("expected the default message, got %q", w.Body.String())
+	}
+	if retryAfter := w.Header().Get(headerRetryAfter); len(retryAfter) > 0 {
+		t.Errorf("expected no Retry-After but got %q", retryAfter)
+	}
+}
+
+func TestMaintenanceConcurrentToggle(t *testing.T) {
+	m := NewMaintenance()
+	handler := m.Block(http.HandlerFunc(helloHandler))
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		//each state's message matches its Retry-After, so
+		//a torn read would show up as a mismatch below
+		for i := 1; i <= 500; i++ {
+			m.Set(MaintenanceState{
+				Enabled:    i%2 == 0,
+				Message:    "retry in " + strconv.Itoa(i),
+				RetryAfter: i,
+			})
+		}
+	}()
+
+	errs := make(chan string, 100)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+				switch w.Code {
+				case http.StatusOK:
+				case http.StatusServiceUnavailable:
+					er := &errorResponse{}
+					json.NewDecoder(w.Body).Decode(er)
+					if er.Error != "retry in "+w.Header().Get(headerRetryAfter) {
+						errs <- "message " + er.Error + " with Retry-After " + w.Header().Get(headerRetryAfter)
+						return
+					}
+				default:
+					errs <- "unexpected status " + strconv.Itoa(w.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
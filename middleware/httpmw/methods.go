@@ -0,0 +1,53 @@
+package httpmw
+
+import (
+	"net/http"
+	"strings"
+)
+
+const headerAllow = "Allow"
+
+//allowedMethods returns a set of the upper-cased `allowed`
+//methods, and the value of the Allow header listing them,
+//which always includes OPTIONS
+func allowedMethods(allowed []string) (map[string]bool, string) {
+	methods := make(map[string]bool, len(allowed)+1)
+	list := make([]string, 0, len(allowed)+1)
+	for _, method := range allowed {
+		method = strings.ToUpper(method)
+		if !methods[method] {
+			methods[method] = true
+			list = append(list, method)
+		}
+	}
+	if !methods["OPTIONS"] {
+		list = append(list, "OPTIONS")
+	}
+	return methods, strings.Join(list, ", ")
+}
+
+//Methods returns an Adapter that only lets requests using one
+//of the `allowed` methods through to the wrapped handler.
+//OPTIONS requests are answered with a 204 and an Allow header
+//listing the allowed methods, and any other method gets a 405
+//with the same Allow header. When combined with the CORS
+//middleware, install CORS outside of this one, so that
+//preflight requests are answered by CORS.
+func Methods(allowed ...string) Adapter {
+	methods, allow := allowedMethods(allowed)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if methods[r.Method] {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(headerAllow, allow)
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			respondError(w, http.StatusMethodNotAllowed, "method "+r.Method+" is not allowed")
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	handler := Methods("GET", "post")(http.HandlerFunc(helloHandler))
+
+	cases := []struct {
+		method         string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{"GET", http.StatusOK, ""},
+		{"POST", http.StatusOK, ""},
+		{"PUT", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"DELETE", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"OPTIONS", http.StatusNoContent, "GET, POST, OPTIONS"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, "/v1/hello1", nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.method, c.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get(headerAllow); allow != c.expectedAllow {
+			t.Errorf("%s: expected Allow %q but got %q", c.method, c.expectedAllow, allow)
+		}
+		switch c.expectedStatus {
+		case http.StatusMethodNotAllowed:
+			errResp := &errorResponse{}
+			if err := json.NewDecoder(w.Body).Decode(errResp); err != nil || errResp.Error != "method "+c.method+" is not allowed" {
+				t.Errorf("%s: unexpected error body %q", c.method, w.Body.String())
+			}
+		case http.StatusNoContent:
+			if w.Body.Len() > 0 {
+				t.Errorf("%s: expected empty body but got %q", c.method, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMethodsWithCORS(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST"},
+	}
+	handler := Chain(http.HandlerFunc(helloHandler), CORS(config), Methods("GET", "POST"))
+
+	//preflights are answered by CORS
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("OPTIONS", "/v1/hello1", nil)
+	r.Header.Set(headerOrigin, "https://example.com")
+	r.Header.Set(headerAccessControlRequestMethod, "POST")
+	handler.ServeHTTP(w, r)
+	if w.Header().Get(headerAccessControlAllowOrigin) != "*" {
+		t.Error("expected preflight to be answered by CORS")
+	}
+	if len(w.Header()[headerAllow]) > 0 {
+		t.Errorf("expected no Allow header on preflight but got %v", w.Header()[headerAllow])
+	}
+
+	//plain OPTIONS get exactly one Allow header
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("OPTIONS", "/v1/hello1", nil)
+	r.Header.Set(headerOrigin, "https://example.com")
+	handler.ServeHTTP(w, r)
+	if allow := w.Header()[headerAllow]; len(allow) != 1 || allow[0] != "GET, POST, OPTIONS" {
+		t.Errorf("expected a single Allow header but got %v", allow)
+	}
+}
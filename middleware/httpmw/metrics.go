@@ -0,0 +1,97 @@
+package httpmw
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//routeCounters holds a map of counters for each route,
+//published at /debug/vars along with the standard
+//memstats and cmdline variables
+var routeCounters = expvar.NewMap("routes")
+
+//routeMetrics holds the counters for one route.
+//expvar.Int counters are updated atomically.
+type routeMetrics struct {
+	requests *expvar.Int
+	byClass  [6]*expvar.Int
+}
+
+func newRouteMetrics(pattern string) *routeMetrics {
+	//re-use the counters if the same pattern is wrapped twice
+	counters, ok := routeCounters.Get(pattern).(*expvar.Map)
+	if !ok {
+		counters = new(expvar.Map).Init()
+		routeCounters.Set(pattern, counters)
+	}
+
+	rm := &routeMetrics{requests: counter(counters, "requests")}
+	for class := 1; class <= 5; class++ {
+		rm.byClass[class] = counter(counters, strconv.Itoa(class)+"xx")
+	}
+	return rm
+}
+
+//counter returns the *expvar.Int named `key` in `m`,
+//creating it if necessary
+func counter(m *expvar.Map, key string) *expvar.Int {
+	if c, ok := m.Get(key).(*expvar.Int); ok {
+		return c
+	}
+	c := new(expvar.Int)
+	m.Set(key, c)
+	return c
+}
+
+//record counts a request that completed with `status`
+func (rm *routeMetrics) record(status int) {
+	rm.requests.Add(1)
+	if class := status / 100; class >= 1 && class <= 5 {
+		rm.byClass[class].Add(1)
+	}
+}
+
+//MetricsOptions configures the CountRequests middleware
+type MetricsOptions struct {
+	//Statsd, if set, is sent a "requests" counter and
+	//a "request_time" timing for every request, tagged
+	//with the route pattern and status class
+	Statsd *StatsdClient
+}
+
+//CountRequests returns an Adapter that counts requests, and
+//responses by status class (2xx, 4xx, etc.), under `pattern`.
+//Pass the route pattern the handler is registered under rather
+//than the request path, so that paths containing IDs don't
+//create a new set of counters for every ID.
+func CountRequests(pattern string) Adapter {
+	return CountRequestsWithOptions(pattern, &MetricsOptions{})
+}
+
+//CountRequestsWithOptions is like CountRequests, but
+//can also send each request's metrics to statsd
+func CountRequestsWithOptions(pattern string, opts *MetricsOptions) Adapter {
+	rm := newRouteMetrics(pattern)
+	routeTag := "route:" + pattern
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newResponseRecorder(w)
+			handler.ServeHTTP(rw, r)
+			rm.record(rw.status)
+			if opts.Statsd != nil {
+				statusTag := "status:" + strconv.Itoa(rw.status/100) + "xx"
+				opts.Statsd.Count("requests", 1, routeTag, statusTag)
+				opts.Statsd.Timing("request_time", time.Since(start), routeTag, statusTag)
+			}
+		})
+	}
+}
+
+//HandleCounted registers `handler` on `mux` for `pattern`,
+//wrapped so that its requests are counted under that pattern
+func HandleCounted(mux *http.ServeMux, pattern string, handler http.Handler) {
+	mux.Handle(pattern, CountRequests(pattern)(handler))
+}
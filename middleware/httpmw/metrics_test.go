@@ -0,0 +1,73 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func routeCounter(t *testing.T, pattern string, key string) int64 {
+	counters, ok := routeCounters.Get(pattern).(*expvar.Map)
+	if !ok {
+		t.Fatalf("no counters for %s", pattern)
+	}
+	c, ok := counters.Get(key).(*expvar.Int)
+	if !ok {
+		t.Fatalf("no %s counter for %s", key, pattern)
+	}
+	return c.Value()
+}
+
+func TestCountRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	HandleCounted(mux, "/test/metrics/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/test/metrics/missing":
+			http.NotFound(w, r)
+		case "/test/metrics/broken":
+			http.Error(w, "broken", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+
+	paths := []string{
+		"/test/metrics/1",
+		"/test/metrics/2",
+		"/test/metrics/3",
+		"/test/metrics/missing",
+		"/test/metrics/broken",
+		"/test/metrics/broken",
+	}
+	for _, path := range paths {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	expected := map[string]int64{
+		"requests": 6,
+		"2xx":      3,
+		"4xx":      1,
+		"5xx":      2,
+		"3xx":      0,
+	}
+	for key, count := range expected {
+		if actual := routeCounter(t, "/test/metrics/", key); actual != count {
+			t.Errorf("expected %s counter to be %d but got %d", key, count, actual)
+		}
+	}
+
+	//the counters are published at /debug/vars
+	w := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+	vars := struct {
+		Routes map[string]map[string]int64 `json:"routes"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("error decoding /debug/vars: %v", err)
+	}
+	if vars.Routes["/test/metrics/"]["requests"] != 6 {
+		t.Errorf("expected requests counter in /debug/vars, got %v", vars.Routes)
+	}
+}
@@ -0,0 +1,80 @@
+package httpmw
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//maxSuggestions is the most near-miss patterns
+//suggested in a 404 response
+const maxSuggestions = 3
+
+//unmatchedRoutes counts requests that didn't match
+//any route, published at /debug/vars
+var unmatchedRoutes = expvar.NewInt("unmatchedRoutes")
+
+//NotFoundConfig configures the NotFound handler
+type NotFoundConfig struct {
+	//Patterns are the registered route patterns,
+	//such as those returned by Routes.Patterns
+	Patterns []string
+	//Suggest adds the registered patterns closest to the
+	//requested path to the response. As this reveals the
+	//server's routes, only turn it on during development.
+	Suggest bool
+}
+
+//commonPrefixLength returns the number of leading
+//bytes `a` and `b` have in common, ignoring case
+func commonPrefixLength(a string, b string) int {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+//suggestPatterns returns the patterns that share the longest
+//prefix with `path`, ignoring those that share only the root
+func suggestPatterns(path string, patterns []string) []string {
+	var suggestions []string
+	longest := 1
+	for _, pattern := range patterns {
+		n := commonPrefixLength(path, pattern)
+		switch {
+		case n > longest:
+			longest = n
+			suggestions = []string{pattern}
+		case n == longest && len(suggestions) > 0 && len(suggestions) < maxSuggestions:
+			suggestions = append(suggestions, pattern)
+		}
+	}
+	return suggestions
+}
+
+//NotFound returns a handler for requests that don't match any
+//route, to register on the mux for the "/" pattern. Unlike the
+//mux's default 404, it logs each one as an "unmatched route",
+//with the full path and query, so that clients using the wrong
+//URLs stand out from 404s sent by handlers. It also counts them
+//in the unmatchedRoutes variable at /debug/vars, and responds
+//with the usual JSON error body.
+func NotFound(logger *log.Logger, config *NotFoundConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unmatchedRoutes.Add(1)
+		prefix := ""
+		if id := RequestIDFromContext(r.Context()); len(id) > 0 {
+			prefix = "[" + id + "] "
+		}
+		logger.Printf("%sunmatched route: %s %s", prefix, r.Method, stripControl(r.URL.RequestURI()))
+
+		er := &errorResponse{Error: "no resource at " + r.URL.Path}
+		if config.Suggest {
+			er.Suggestions = suggestPatterns(r.URL.Path, config.Patterns)
+		}
+		respondErrorResponse(w, http.StatusNotFound, er)
+	})
+}
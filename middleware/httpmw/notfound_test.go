@@ -0,0 +1,89 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNotFound(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	mux := http.NewServeMux()
+	rt := NewRoutes(mux)
+	rt.HandleFunc("/v1/tasks", helloHandler, "GET")
+	rt.HandleFunc("/v1/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusNotFound, "no such task")
+	}, "GET")
+	rt.HandleFunc("/v1/users", helloHandler, "GET")
+	mux.Handle("/", NotFound(logger, &NotFoundConfig{Patterns: rt.Patterns()}))
+	handler := LogRequests(logger)(mux)
+
+	before := unmatchedRoutes.Value()
+
+	//a 404 from a handler is only logged as a request
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks/1234", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d but got %d", http.StatusNotFound, w.Code)
+	}
+	if output := buf.String(); strings.Contains(output, "unmatched route") {
+		t.Errorf("expected a handler 404 not to be logged as unmatched, got %q", output)
+	}
+	if unmatchedRoutes.Value() != before {
+		t.Error("expected a handler 404 not to be counted as unmatched")
+	}
+
+	//a request that matches no route is logged distinctly
+	buf.Reset()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/task?done=true", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d but got %d", http.StatusNotFound, w.Code)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != "unmatched route: GET /v1/task?done=true" {
+		t.Errorf("expected an unmatched route line before the request line, got %q", buf.String())
+	}
+	if unmatchedRoutes.Value() != before+1 {
+		t.Errorf("expected the unmatched route to be counted, got %d", unmatchedRoutes.Value()-before)
+	}
+	er := &errorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(er); err != nil || er.Error != "no resource at /v1/task" {
+		t.Errorf("unexpected error body %+v, %v", er, err)
+	}
+	if len(er.Suggestions) > 0 {
+		t.Errorf("expected no suggestions unless turned on, got %v", er.Suggestions)
+	}
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	patterns := []string{"/v1/tasks", "/v1/tasks/", "/v1/users", "/health"}
+	handler := NotFound(log.New(&bytes.Buffer{}, "", 0), &NotFoundConfig{Patterns: patterns, Suggest: true})
+
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{"/v1/task", []string{"/v1/tasks", "/v1/tasks/"}},
+		{"/V1/Users/5", []string{"/v1/users"}},
+		{"/v1/", []string{"/v1/tasks", "/v1/tasks/", "/v1/users"}},
+		{"/nothing", nil},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		er := &errorResponse{}
+		if err := json.NewDecoder(w.Body).Decode(er); err != nil {
+			t.Fatalf("%s: error decoding response: %v", c.path, err)
+		}
+		if !reflect.DeepEqual(er.Suggestions, c.expected) {
+			t.Errorf("%s: expected suggestions %v but got %v", c.path, c.expected, er.Suggestions)
+		}
+	}
+}
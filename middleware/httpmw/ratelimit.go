@@ -0,0 +1,143 @@
+package httpmw
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const headerRetryAfter = "Retry-After"
+
+//RateLimitConfig configures the RateLimit middleware
+type RateLimitConfig struct {
+	//RequestsPerSecond is the sustained rate each client
+	//may make requests at. Defaults to 1.
+	RequestsPerSecond float64
+	//Burst is the number of requests a client may make
+	//in quick succession before being limited. Defaults to 1.
+	Burst int
+	//IdleTimeout is how long a client's bucket is kept
+	//after its last request. Defaults to one minute.
+	IdleTimeout time.Duration
+	//KeyFunc returns the key identifying the client that made
+	//the request, each of which gets its own bucket. Defaults
+	//to the client's IP address.
+	KeyFunc func(r *http.Request) string
+}
+
+//tokenBucket tracks the tokens available to one client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//rateLimiter holds a token bucket for each client,
+//keyed by client IP address
+type rateLimiter struct {
+	mx      sync.Mutex
+	buckets map[string]*tokenBucket
+	rate    float64
+	burst   float64
+	idle    time.Duration
+	now     func() time.Time
+}
+
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	idle := config.IdleTimeout
+	if idle <= 0 {
+		idle = time.Minute
+	}
+	//a rate of 0 would never refill the buckets, and
+	//would divide by zero working out the Retry-After
+	rate := config.RequestsPerSecond
+	if rate <= 0 {
+		rate = 1
+	}
+	burst := config.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		rate:    rate,
+		burst:   float64(burst),
+		idle:    idle,
+		now:     time.Now,
+	}
+}
+
+//allow takes a token from the bucket for `key`, returning
+//false and how long until a token will be available if
+//the bucket is empty
+func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	now := rl.now()
+	b, found := rl.buckets[key]
+	if !found {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+
+	//refill based on the time since the last request
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+//evict removes buckets that haven't been used within the
+//idle timeout, so memory doesn't grow with every client
+//that has ever made a request
+func (rl *rateLimiter) evict() {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	now := rl.now()
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) > rl.idle {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+//RateLimit returns an Adapter that limits how quickly each
+//client can make requests. Clients are identified by IP
+//address, as resolved by ResolveClientIPs if that's installed
+//outside this middleware, unless config.KeyFunc is set.
+//Clients that exceed the limit get a 429 response with a
+//Retry-After header. Idle buckets are evicted by a background
+//goroutine that runs for the life of the program.
+func RateLimit(config *RateLimitConfig) Adapter {
+	rl := newRateLimiter(config)
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = ClientIP
+	}
+	go func() {
+		for range time.Tick(rl.idle) {
+			rl.evict()
+		}
+	}()
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, wait := rl.allow(keyFunc(r))
+			if !allowed {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				w.Header().Set(headerRetryAfter, strconv.Itoa(retryAfter))
+				respondError(w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitBurst(t *testing.T) {
+	config := &RateLimitConfig{RequestsPerSecond: 0.5, Burst: 3}
+	handler := RateLimit(config)(http.HandlerFunc(helloHandler))
+
+	for i := 0; i < config.Burst; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d but got %d", i, http.StatusOK, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d but got %d", http.StatusTooManyRequests, w.Code)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get(headerRetryAfter))
+	if err != nil || retryAfter < 1 || retryAfter > 2 {
+		t.Errorf("expected Retry-After of 1-2 seconds but got %q", w.Header().Get(headerRetryAfter))
+	}
+	errResp := &errorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(errResp); err != nil || len(errResp.Error) == 0 {
+		t.Errorf("expected JSON error body, got %q", w.Body.String())
+	}
+
+	//a different client has its own bucket
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.RemoteAddr = "10.0.0.2:5555"
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got status %d", w.Code)
+	}
+}
+
+func TestRateLimiterRefillAndEvict(t *testing.T) {
+	now := time.Now()
+	rl := newRateLimiter(&RateLimitConfig{RequestsPerSecond: 1, Burst: 1, IdleTimeout: time.Minute})
+	rl.now = func() time.Time { return now }
+
+	if ok, _ := rl.allow("a"); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	if ok, wait := rl.allow("a"); ok || wait != time.Second {
+		t.Fatalf("expected second request to wait 1s, got allowed=%t wait=%v", ok, wait)
+	}
+	now = now.Add(time.Second)
+	if ok, _ := rl.allow("a"); !ok {
+		t.Fatal("expected request to be allowed after refill")
+	}
+
+	now = now.Add(2 * time.Minute)
+	rl.allow("b")
+	rl.evict()
+	if _, found := rl.buckets["a"]; found {
+		t.Error("expected idle bucket to be evicted")
+	}
+	if _, found := rl.buckets["b"]; !found {
+		t.Error("expected active bucket to be kept")
+	}
+}
+
+func TestRateLimiterDefaults(t *testing.T) {
+	now := time.Now()
+	rl := newRateLimiter(&RateLimitConfig{})
+	rl.now = func() time.Time { return now }
+
+	if ok, _ := rl.allow("a"); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	//without a rate, working out the wait would divide by zero
+	if ok, wait := rl.allow("a"); ok || wait != time.Second {
+		t.Errorf("expected the default rate of 1 per second, got allowed=%t wait=%v", ok, wait)
+	}
+}
+
+func TestRateLimitConcurrent(t *testing.T) {
+	config := &RateLimitConfig{RequestsPerSecond: 0.001, Burst: 10}
+	handler := RateLimit(config)(http.HandlerFunc(helloHandler))
+
+	var mx sync.Mutex
+	counts := make(map[int]int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+			mx.Lock()
+			counts[w.Code]++
+			mx.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counts[http.StatusOK] != config.Burst {
+		t.Errorf("expected exactly %d requests to succeed but got %d", config.Burst, counts[http.StatusOK])
+	}
+	if counts[http.StatusTooManyRequests] != 50-config.Burst {
+		t.Errorf("expected %d requests to be limited but got %d", 50-config.Burst, counts[http.StatusTooManyRequests])
+	}
+}
@@ -0,0 +1,111 @@
+package httpmw
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+//responseRecorder wraps an http.ResponseWriter and
+//records the status code and number of bytes written
+//by the handler, so that middleware can report them
+//after the handler returns. If body is set, everything
+//written to the response is copied to it as well, and if
+//beforeHeader is set, it's called with the status code just
+//before the headers are passed along, so it can still add to them.
+type responseRecorder struct {
+	http.ResponseWriter
+	status       int
+	bytes        int
+	wroteHeader  bool
+	body         io.Writer
+	beforeHeader func(status int)
+}
+
+//newResponseRecorder wraps `w` in a new responseRecorder.
+//The status defaults to 200, which is what net/http sends
+//if the handler never calls WriteHeader().
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+//WriteHeader records the status code and passes it along
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.wroteHeader {
+		return
+	}
+	rr.status = code
+	rr.wroteHeader = true
+	if rr.beforeHeader != nil {
+		rr.beforeHeader(code)
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+//Write writes the data to the wrapped ResponseWriter,
+//implicitly writing a 200 status first if the handler
+//didn't call WriteHeader(). Only the bytes the wrapped
+//writer reports as written are counted.
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	n, err := rr.ResponseWriter.Write(p)
+	rr.bytes += n
+	if rr.body != nil {
+		rr.body.Write(p[:n])
+	}
+	return n, err
+}
+
+//Flush sends any buffered data to the client, if the
+//wrapped ResponseWriter supports it. Streaming handlers,
+//such as server-sent events, need this to get through
+//the middleware that wraps them.
+func (rr *responseRecorder) Flush() {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack lets the handler take over the connection, as
+//websocket handlers do, if the wrapped ResponseWriter
+//supports it. Otherwise it returns http.ErrNotSupported.
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !rr.wroteHeader {
+		//the handler now writes the response
+		//itself, directly to the connection
+		rr.status = http.StatusSwitchingProtocols
+		rr.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+//ReadFrom copies from `src` to the response, passing it
+//to the wrapped ResponseWriter's ReadFrom if it has one,
+//so that net/http can still use sendfile when serving files
+func (rr *responseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	if rf, ok := rr.ResponseWriter.(io.ReaderFrom); ok && rr.body == nil {
+		n, err := rf.ReadFrom(src)
+		rr.bytes += int(n)
+		return n, err
+	}
+	//hide our own ReadFrom so that io.Copy
+	//doesn't call it again
+	return io.Copy(struct{ io.Writer }{rr}, src)
+}
@@ -0,0 +1,130 @@
+package httpmw
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//fullWriter is an http.ResponseWriter that supports
+//flushing, hijacking, and ReadFrom, like the one
+//net/http passes to handlers
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	readFrom bool
+}
+
+func (fw *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	fw.hijacked = true
+	client, server := net.Pipe()
+	client.Close()
+	return server, nil, nil
+}
+
+func (fw *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	fw.readFrom = true
+	return io.Copy(fw.ResponseRecorder, src)
+}
+
+//plainWriter is an http.ResponseWriter that
+//supports none of the optional interfaces
+type plainWriter struct {
+	header http.Header
+}
+
+func (pw *plainWriter) Header() http.Header         { return pw.header }
+func (pw *plainWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (pw *plainWriter) WriteHeader(int)             {}
+
+//wrapRecorders wraps `w` in `layers` responseRecorders
+func wrapRecorders(w http.ResponseWriter, layers int) http.ResponseWriter {
+	for i := 0; i < layers; i++ {
+		w = newResponseRecorder(w)
+	}
+	return w
+}
+
+func TestResponseRecorderInterfaces(t *testing.T) {
+	for layers := 1; layers <= 3; layers++ {
+		fw := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+		w := wrapRecorders(fw, layers)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatalf("%d layers: expected http.Flusher", layers)
+		}
+		flusher.Flush()
+		if !fw.Flushed {
+			t.Errorf("%d layers: expected Flush to reach the underlying writer", layers)
+		}
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatalf("%d layers: expected http.Hijacker", layers)
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil || !fw.hijacked {
+			t.Errorf("%d layers: expected Hijack to reach the underlying writer, got %v", layers, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatalf("%d layers: expected io.ReaderFrom", layers)
+		}
+		n, err := rf.ReadFrom(strings.NewReader("hello"))
+		if err != nil || n != 5 || !fw.readFrom {
+			t.Errorf("%d layers: expected ReadFrom to reach the underlying writer, got %d, %v", layers, n, err)
+		}
+		if rr := w.(*responseRecorder); rr.bytes != 5 {
+			t.Errorf("%d layers: expected 5 bytes to be counted but got %d", layers, rr.bytes)
+		}
+	}
+}
+
+func TestResponseRecorderNotSupported(t *testing.T) {
+	for layers := 1; layers <= 3; layers++ {
+		w := wrapRecorders(&plainWriter{header: http.Header{}}, layers)
+
+		//Flush is a no-op, but mustn't panic
+		w.(http.Flusher).Flush()
+
+		if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+			t.Errorf("%d layers: expected http.ErrNotSupported but got %v", layers, err)
+		}
+
+		n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+		if err != nil || n != 5 {
+			t.Errorf("%d layers: expected ReadFrom to fall back to io.Copy, got %d, %v", layers, n, err)
+		}
+		if rr := w.(*responseRecorder); rr.bytes != 5 {
+			t.Errorf("%d layers: expected 5 bytes to be counted but got %d", layers, rr.bytes)
+		}
+	}
+}
+
+func TestLogRequestsFlush(t *testing.T) {
+	handler := LogRequests(log.New(ioutil.Discard, "", 0))(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			flusher, ok := w.(http.Flusher)
+			if !ok {
+				t.Fatal("expected the logged ResponseWriter to be an http.Flusher")
+			}
+			w.Write([]byte("data: hello\n\n"))
+			flusher.Flush()
+		}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/events", nil))
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
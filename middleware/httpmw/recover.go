@@ -0,0 +1,42 @@
+package httpmw
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+//Recover returns an Adapter that recovers from panics in the
+//wrapped handler, so that one bad request doesn't crash the
+//whole server. The panic and a stack trace are logged to
+//`logger`, and the client gets a 500 response if the handler
+//hadn't already started writing one. Install it inside of
+//LogRequests so that these requests are logged as 500s.
+//
+//Panics with http.ErrAbortHandler are passed along, as
+//net/http uses them to abort a response on purpose.
+func Recover(logger *log.Logger) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseRecorder(w)
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				prefix := ""
+				if id := RequestIDFromContext(r.Context()); len(id) > 0 {
+					prefix = "[" + id + "] "
+				}
+				logger.Printf("%spanic serving %s %s: %v\n%s", prefix, r.Method, r.URL.Path, err, debug.Stack())
+				if !rw.wroteHeader {
+					respondError(rw, http.StatusInternalServerError, "internal server error")
+				}
+			}()
+			handler.ServeHTTP(rw, r)
+		})
+	}
+}
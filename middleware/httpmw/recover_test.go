@@ -0,0 +1,69 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	}), AssignRequestIDs, LogRequests(logger), Recover(logger))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set(headerRequestID, "req-1")
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"error":"internal server error"`) {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+	output := buf.String()
+	if !strings.Contains(output, "[req-1] panic serving GET /v1/tasks: something broke") {
+		t.Errorf("expected the panic to be logged, got %q", output)
+	}
+	if !strings.Contains(output, "goroutine ") {
+		t.Errorf("expected a stack trace to be logged, got %q", output)
+	}
+	if !strings.Contains(output, "[req-1] GET /v1/tasks 500 ") {
+		t.Errorf("expected the request to be logged as a 500, got %q", output)
+	}
+}
+
+func TestRecoverAfterWrite(t *testing.T) {
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	handler := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("something broke")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Errorf("expected the partial response to be left alone, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	handler := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tasks", nil))
+}
@@ -0,0 +1,113 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//RedirectMap maps old paths to new ones
+//for the Redirects middleware
+type RedirectMap struct {
+	//Exact maps an old path to its new path
+	Exact map[string]string `json:"exact"`
+	//Prefixes maps an old path prefix to a new one,
+	//so {"/v1/hello/": "/v1/greetings/"} redirects
+	///v1/hello/dave to /v1/greetings/dave. If several
+	//prefixes match, the longest one wins.
+	Prefixes map[string]string `json:"prefixes"`
+}
+
+//LoadRedirectMap loads a RedirectMap from a JSON file like
+//  {"exact": {"/v1/hello1": "/v1/greeting"}, "prefixes": {}}
+//and validates it
+func LoadRedirectMap(filePath string) (*RedirectMap, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening redirects file: %v", err)
+	}
+	defer f.Close()
+
+	rm := &RedirectMap{}
+	if err := json.NewDecoder(f).Decode(rm); err != nil {
+		return nil, fmt.Errorf("error decoding redirects file: %v", err)
+	}
+	if err := rm.Validate(); err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
+
+//target returns the path that `path` redirects to,
+//and whether there was a matching rule
+func (rm *RedirectMap) target(path string) (string, bool) {
+	if target, found := rm.Exact[path]; found {
+		return target, true
+	}
+	longest := ""
+	for prefix := range rm.Prefixes {
+		if len(prefix) > len(longest) && strings.HasPrefix(path, prefix) {
+			longest = prefix
+		}
+	}
+	if len(longest) == 0 {
+		return "", false
+	}
+	return rm.Prefixes[longest] + path[len(longest):], true
+}
+
+//Validate returns an error if any redirect leads to a path
+//that redirects again, as chains make clients follow several
+//redirects, and loops never end. It also rejects targets
+//that aren't absolute paths.
+func (rm *RedirectMap) Validate() error {
+	for from, to := range rm.Exact {
+		if !strings.HasPrefix(to, "/") {
+			return fmt.Errorf("redirect target %q for %s must be an absolute path", to, from)
+		}
+		if next, found := rm.target(to); found {
+			return fmt.Errorf("redirect from %s to %s redirects again to %s", from, to, next)
+		}
+	}
+	for from, to := range rm.Prefixes {
+		if !strings.HasPrefix(to, "/") {
+			return fmt.Errorf("redirect target %q for %s must be an absolute path", to, from)
+		}
+		//any path under `to` must not match another rule
+		for exact := range rm.Exact {
+			if strings.HasPrefix(exact, to) {
+				return fmt.Errorf("redirects from %s to %s may redirect again from %s", from, to, exact)
+			}
+		}
+		for prefix := range rm.Prefixes {
+			if strings.HasPrefix(to, prefix) || strings.HasPrefix(prefix, to) {
+				return fmt.Errorf("redirects from %s to %s may redirect again from %s", from, to, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+//Redirects returns an Adapter that permanently redirects
+//requests for the old paths in `rm` to their new paths,
+//keeping the query string. GET and HEAD requests get a 301,
+//and others get a 308 so that clients resend the same method
+//and body. All other requests are passed to the handler.
+//It returns an error if `rm` isn't valid.
+func Redirects(rm *RedirectMap) (Adapter, error) {
+	if err := rm.Validate(); err != nil {
+		return nil, err
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target, found := rm.target(r.URL.Path)
+			if !found {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			permanentRedirect(w, r, target)
+		})
+	}, nil
+}
@@ -0,0 +1,131 @@
+package httpmw
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	rm := &RedirectMap{
+		Exact: map[string]string{
+			"/v1/hello1": "/v1/greeting",
+		},
+		Prefixes: map[string]string{
+			"/v1/hello/":       "/v1/greetings/",
+			"/v1/hello/admin/": "/v1/admin/greetings/",
+		},
+	}
+	redirects, err := Redirects(rm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := redirects(http.HandlerFunc(helloHandler))
+
+	cases := []struct {
+		method           string
+		path             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"GET", "/v1/hello1", http.StatusMovedPermanently, "/v1/greeting"},
+		{"GET", "/v1/hello1?name=dave", http.StatusMovedPermanently, "/v1/greeting?name=dave"},
+		{"POST", "/v1/hello1", http.StatusPermanentRedirect, "/v1/greeting"},
+		{"GET", "/v1/hello/dave", http.StatusMovedPermanently, "/v1/greetings/dave"},
+		{"DELETE", "/v1/hello/admin/1?x=y", http.StatusPermanentRedirect, "/v1/admin/greetings/1?x=y"},
+		{"GET", "/v1/greeting", http.StatusOK, ""},
+		{"GET", "/v1/hello1/extra", http.StatusOK, ""},
+		{"GET", "/v1/hello", http.StatusOK, ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s %s: expected status %d but got %d", c.method, c.path, c.expectedStatus, w.Code)
+		}
+		if location := w.Header().Get(headerLocation); location != c.expectedLocation {
+			t.Errorf("%s %s: expected Location %q but got %q", c.method, c.path, c.expectedLocation, location)
+		}
+	}
+}
+
+func TestRedirectMapValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		rm    *RedirectMap
+		valid bool
+	}{
+		{"empty", &RedirectMap{}, true},
+		{"simple", &RedirectMap{Exact: map[string]string{"/a": "/b"}}, true},
+		{"chain", &RedirectMap{Exact: map[string]string{"/a": "/b", "/b": "/c"}}, false},
+		{"loop", &RedirectMap{Exact: map[string]string{"/a": "/b", "/b": "/a"}}, false},
+		{"self", &RedirectMap{Exact: map[string]string{"/a": "/a"}}, false},
+		{"exact into prefix", &RedirectMap{
+			Exact:    map[string]string{"/a": "/old/a"},
+			Prefixes: map[string]string{"/old/": "/new/"},
+		}, false},
+		{"prefix into exact", &RedirectMap{
+			Exact:    map[string]string{"/new/a": "/b"},
+			Prefixes: map[string]string{"/old/": "/new/"},
+		}, false},
+		{"prefix into prefix", &RedirectMap{
+			Prefixes: map[string]string{"/old/": "/new/", "/new/": "/newer/"},
+		}, false},
+		{"prefix into itself", &RedirectMap{
+			Prefixes: map[string]string{"/v1/": "/v1/api/"},
+		}, false},
+		{"relative target", &RedirectMap{Exact: map[string]string{"/a": "b"}}, false},
+	}
+
+	for _, c := range cases {
+		err := c.rm.Validate()
+		if c.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+
+	if _, err := Redirects(&RedirectMap{Exact: map[string]string{"/a": "/a"}}); err == nil {
+		t.Error("expected Redirects to reject an invalid map")
+	}
+}
+
+func TestLoadRedirectMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redirects")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good.json")
+	ioutil.WriteFile(good, []byte(`{"exact":{"/v1/hello1":"/v1/greeting"},"prefixes":{"/v1/hello/":"/v1/greetings/"}}`), 0644)
+	rm, err := LoadRedirectMap(good)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rm.Exact["/v1/hello1"] != "/v1/greeting" || rm.Prefixes["/v1/hello/"] != "/v1/greetings/" {
+		t.Errorf("unexpected redirect map %+v", rm)
+	}
+
+	loop := filepath.Join(dir, "loop.json")
+	ioutil.WriteFile(loop, []byte(`{"exact":{"/a":"/b","/b":"/a"}}`), 0644)
+	if _, err := LoadRedirectMap(loop); err == nil || !strings.Contains(err.Error(), "redirects again") {
+		t.Errorf("expected a loop error but got %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	ioutil.WriteFile(bad, []byte(`{"exact":`), 0644)
+	if _, err := LoadRedirectMap(bad); err == nil {
+		t.Error("expected an error decoding invalid JSON")
+	}
+	if _, err := LoadRedirectMap(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
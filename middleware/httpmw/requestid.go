@@ -0,0 +1,58 @@
+package httpmw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+const headerRequestID = "X-Request-ID"
+
+//contextKey is the type for keys this package
+//stores in request contexts, so that they can't
+//collide with keys from other packages
+type contextKey string
+
+//RequestIDKey is the request context key
+//under which the request ID is stored
+const RequestIDKey contextKey = "requestID"
+
+//requestIDRegexp matches request IDs we're willing to accept
+//from clients; anything else is replaced with a new ID so that
+//junk (or log-injection attempts) never reach the logs
+var requestIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+//newRequestID generates a new random request ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("error generating request ID: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+//RequestIDFromContext returns the request ID stored in `ctx`,
+//or an empty string if there isn't one
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+//AssignRequestIDs is an Adapter that gives every request an ID.
+//It uses the X-Request-ID request header if the client sent a
+//valid one, or generates a new one otherwise. The ID is stored
+//in the request context and echoed in the X-Request-ID
+//response header.
+func AssignRequestIDs(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerRequestID)
+		if !requestIDRegexp.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(headerRequestID, id)
+		ctx := context.WithValue(r.Context(), RequestIDKey, id)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,76 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAssignRequestIDs(t *testing.T) {
+	cases := []struct {
+		name        string
+		header      string
+		passthrough bool
+	}{
+		{"no header", "", false},
+		{"valid header", "abc-123.DEF_456", true},
+		{"too long", strings.Repeat("a", 129), false},
+		{"bad characters", "abc\ndef", false},
+		{"spaces", "abc def", false},
+	}
+
+	for _, c := range cases {
+		var ctxID string
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctxID = RequestIDFromContext(r.Context())
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/hello1", nil)
+		if len(c.header) > 0 {
+			r.Header.Set(headerRequestID, c.header)
+		}
+		AssignRequestIDs(handler).ServeHTTP(w, r)
+
+		respID := w.Header().Get(headerRequestID)
+		if len(respID) == 0 {
+			t.Errorf("%s: no request ID in response header", c.name)
+		}
+		if ctxID != respID {
+			t.Errorf("%s: context ID %q didn't match response header %q", c.name, ctxID, respID)
+		}
+		if c.passthrough && respID != c.header {
+			t.Errorf("%s: expected ID %q to be passed through but got %q", c.name, c.header, respID)
+		}
+		if !c.passthrough && respID == c.header {
+			t.Errorf("%s: expected a newly generated ID but got %q", c.name, respID)
+		}
+	}
+}
+
+func TestRequestIDFromContextMissing(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		t.Errorf("expected empty ID but got %q", id)
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := Chain(http.HandlerFunc(helloHandler), AssignRequestIDs, LogRequests(logger))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.Header.Set(headerRequestID, "req-42")
+	handler.ServeHTTP(w, r)
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.HasPrefix(line, "[req-42] GET /v1/hello1") {
+			t.Errorf("expected log line to start with the request ID, got %q", line)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const headerContentType = "Content-Type"
+
+const contentTypeJSON = "application/json; charset=utf-8"
+
+//errorResponse is the JSON body middleware
+//sends when it rejects a request
+type errorResponse struct {
+	Error string `json:"error"`
+	//Suggestions lists similar paths that do exist,
+	//for requests that didn't match any route
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+//respondError writes `msg` to the response as a JSON
+//error body with the given status code
+func respondError(w http.ResponseWriter, status int, msg string) {
+	respondErrorResponse(w, status, &errorResponse{Error: msg})
+}
+
+//respondErrorResponse writes `er` to the response
+//as JSON with the given status code
+func respondErrorResponse(w http.ResponseWriter, status int, er *errorResponse) {
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(er)
+}
@@ -0,0 +1,66 @@
+package httpmw
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	headerXResponseTime = "X-Response-Time"
+	headerServerTiming  = "Server-Timing"
+)
+
+//ResponseTimeConfig configures the ResponseTime middleware
+type ResponseTimeConfig struct {
+	//ServerTiming adds a standard Server-Timing header
+	//as well, which browser dev tools can display
+	ServerTiming bool
+	//ServerTimingName is the metric name used in the
+	//Server-Timing header. Defaults to "app".
+	ServerTimingName string
+}
+
+//formatMillis formats `d` as milliseconds with three decimals.
+//Anything shorter than a microsecond is rounded up to one, so
+//that a response, however quick, never seems to take no time.
+func formatMillis(d time.Duration) string {
+	if d < time.Microsecond {
+		d = time.Microsecond
+	}
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+//ResponseTime returns an Adapter that reports how long the
+//handler took in an X-Response-Time header, such as "12.345ms".
+//Headers can't be changed once the response has started, so
+//the time is measured up to when the handler writes its status
+//code (or its first byte), rather than after it returns. Install
+//it outside of GzipResponses, so that the time includes deciding
+//whether to compress.
+func ResponseTime(config *ResponseTimeConfig) Adapter {
+	name := config.ServerTimingName
+	if len(name) == 0 {
+		name = "app"
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newResponseRecorder(w)
+			rw.beforeHeader = func(status int) {
+				millis := formatMillis(time.Since(start))
+				h := rw.Header()
+				h.Set(headerXResponseTime, millis+"ms")
+				if config.ServerTiming {
+					h.Add(headerServerTiming, name+";dur="+millis)
+				}
+			}
+			handler.ServeHTTP(rw, r)
+			//handlers that write nothing at all still
+			//get a response, so report that one too
+			if !rw.wroteHeader {
+				rw.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//responseTime parses the X-Response-Time header
+func responseTime(t *testing.T, w *httptest.ResponseRecorder) time.Duration {
+	header := w.Header().Get(headerXResponseTime)
+	d, err := time.ParseDuration(header)
+	if err != nil {
+		t.Fatalf("error parsing X-Response-Time %q: %v", header, err)
+	}
+	if d <= 0 {
+		t.Errorf("expected a positive response time but got %s", d)
+	}
+	return d
+}
+
+func TestResponseTime(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"implicit status": helloHandler,
+		"explicit status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		},
+		"empty response": func(w http.ResponseWriter, r *http.Request) {},
+		"slow": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			helloHandler(w, r)
+		},
+	}
+
+	for name, handler := range handlers {
+		w := httptest.NewRecorder()
+		ResponseTime(&ResponseTimeConfig{})(handler).ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+		d := responseTime(t, w)
+		if name == "slow" && d < 5*time.Millisecond {
+			t.Errorf("%s: expected at least 5ms but got %s", name, d)
+		}
+		if timing := w.Header().Get(headerServerTiming); len(timing) > 0 {
+			t.Errorf("%s: expected no Server-Timing header but got %q", name, timing)
+		}
+	}
+}
+
+func TestResponseTimeServerTiming(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler := ResponseTime(&ResponseTimeConfig{ServerTiming: true})(http.HandlerFunc(helloHandler))
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+	responseTime(t, w)
+	millis := strings.TrimSuffix(w.Header().Get(headerXResponseTime), "ms")
+	if timing := w.Header().Get(headerServerTiming); timing != "app;dur="+millis {
+		t.Errorf("expected Server-Timing to match X-Response-Time, got %q", timing)
+	}
+}
+
+func TestResponseTimeWithGzipAndLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := Chain(jsonHandler(largeJSON),
+		LogRequests(log.New(buf, "", 0)),
+		ResponseTime(&ResponseTimeConfig{}),
+		GzipResponses(512),
+	)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set(headerAcceptEncoding, encodingGzip)
+	handler.ServeHTTP(w, r)
+	responseTime(t, w)
+	if enc := w.Header().Get(headerContentEncoding); enc != encodingGzip {
+		t.Errorf("expected the response to be compressed, got Content-Encoding %q", enc)
+	}
+	if body := gunzip(t, w); body != largeJSON {
+		t.Error("decompressed body didn't match")
+	}
+	if !strings.Contains(buf.String(), "GET /v1/tasks 200 ") {
+		t.Errorf("expected the request to be logged, got %q", buf.String())
+	}
+}
+
+func TestFormatMillis(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                     "0.001",
+		400 * time.Nanosecond: "0.001",
+		time.Microsecond:      "0.001",
+		12*time.Millisecond + 345*time.Microsecond: "12.345",
+	}
+	for d, expected := range cases {
+		if got := formatMillis(d); got != expected {
+			t.Errorf("%s: expected %s but got %s", d, expected, got)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package httpmw
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+//RotatingFile is an io.Writer that appends to a file, rotating
+//it once it would grow beyond a maximum size. Rotated files
+//are named path.1 (most recent) through path.N, and older ones
+//are deleted. It's safe for concurrent use, so it can be handed
+//to log.New() and shared across requests.
+type RotatingFile struct {
+	mx         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+//NewRotatingFile opens (or creates) the file at `path` for
+//appending. The file is rotated before any write that would
+//take it beyond `maxBytes`, and up to `maxBackups` rotated
+//files are kept.
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+//open opens the file at rf.path, recording its current size
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error getting log file size: %v", err)
+	}
+	rf.f = f
+	rf.size = info.Size()
+	return nil
+}
+
+//backupPath returns the path of the nth rotated file
+func (rf *RotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", rf.path, n)
+}
+
+//rotate shifts each rotated file up by one, moves the
+//current file to path.1, and opens a new empty file
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	os.Remove(rf.backupPath(rf.maxBackups))
+	for n := rf.maxBackups - 1; n > 0; n-- {
+		os.Rename(rf.backupPath(n), rf.backupPath(n+1))
+	}
+	if rf.maxBackups > 0 {
+		if err := os.Rename(rf.path, rf.backupPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+//Write appends `p` to the file, rotating first if needed
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, fmt.Errorf("error rotating log file: %v", err)
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+//Reopen closes and reopens the file, which is needed
+//after an external tool like logrotate has moved it
+func (rf *RotatingFile) Reopen() error {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+//ReopenOnSIGHUP starts a goroutine that calls Reopen()
+//every time the process receives a SIGHUP
+func (rf *RotatingFile) ReopenOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := rf.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "error reopening log file: %v\n", err)
+			}
+		}
+	}()
+}
+
+//Close closes the file
+func (rf *RotatingFile) Close() error {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+	return rf.f.Close()
+}
@@ -0,0 +1,143 @@
+package httpmw
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("error reading %s: %v", path, err)
+	}
+	return string(buf)
+}
+
+func TestRotatingFileBoundaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	rf, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("error creating rotating file: %v", err)
+	}
+	defer rf.Close()
+
+	//exactly at the limit doesn't rotate
+	rf.Write([]byte("aaaa\n"))
+	rf.Write([]byte("bbbb\n"))
+	if contents := readFile(t, path); contents != "aaaa\nbbbb\n" {
+		t.Fatalf("unexpected contents before rotation: %q", contents)
+	}
+
+	//but going over it does
+	rf.Write([]byte("cccc\n"))
+	rf.Write([]byte("dddddddddddd\n"))
+	rf.Write([]byte("eeee\n"))
+
+	expected := map[string]string{
+		path:        "eeee\n",
+		path + ".1": "dddddddddddd\n",
+		path + ".2": "cccc\n",
+		path + ".3": "",
+	}
+	for p, contents := range expected {
+		if actual := readFile(t, p); actual != contents {
+			t.Errorf("%s: expected %q but got %q", filepath.Base(p), contents, actual)
+		}
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	rf, err := NewRotatingFile(path, 1024, 1)
+	if err != nil {
+		t.Fatalf("error creating rotating file: %v", err)
+	}
+	defer rf.Close()
+
+	rf.Write([]byte("before\n"))
+	//simulate logrotate moving the file
+	os.Rename(path, path+".moved")
+	if err := rf.Reopen(); err != nil {
+		t.Fatalf("error reopening: %v", err)
+	}
+	rf.Write([]byte("after\n"))
+
+	if contents := readFile(t, path); contents != "after\n" {
+		t.Errorf("expected new file to contain only new lines, got %q", contents)
+	}
+	if contents := readFile(t, path+".moved"); contents != "before\n" {
+		t.Errorf("expected moved file to keep old lines, got %q", contents)
+	}
+}
+
+func TestRotatingFileConcurrentWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+
+	const writers = 20
+	const linesPerWriter = 50
+	rf, err := NewRotatingFile(path, 4096, 100)
+	if err != nil {
+		t.Fatalf("error creating rotating file: %v", err)
+	}
+	defer rf.Close()
+	logger := log.New(rf, "", 0)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < linesPerWriter; i++ {
+				logger.Printf("writer %02d line %02d", w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	files, _ := filepath.Glob(path + "*")
+	lines := 0
+	for _, p := range files {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var w, i int
+			if _, err := fmt.Sscanf(scanner.Text(), "writer %02d line %02d", &w, &i); err != nil {
+				t.Errorf("corrupted line in %s: %q", filepath.Base(p), scanner.Text())
+			}
+			lines++
+		}
+		f.Close()
+		if info, _ := os.Stat(p); info.Size() > 4096 {
+			t.Errorf("%s is larger than the limit: %d bytes", filepath.Base(p), info.Size())
+		}
+	}
+	if lines != writers*linesPerWriter {
+		t.Errorf("expected %d lines but found %d", writers*linesPerWriter, lines)
+	}
+}
@@ -0,0 +1,103 @@
+package httpmw
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//route is a registered pattern and the methods it allows
+type route struct {
+	methods map[string]bool
+	allow   string
+}
+
+//Routes registers handlers on a ServeMux along with the
+//methods each one allows, so that OPTIONS requests and
+//CORS preflights can be answered consistently for every
+//route. Register all the routes before serving requests,
+//as Routes isn't safe to modify concurrently.
+type Routes struct {
+	mux    *http.ServeMux
+	routes map[string]*route
+}
+
+//NewRoutes creates a new Routes that registers handlers on `mux`
+func NewRoutes(mux *http.ServeMux) *Routes {
+	return &Routes{
+		mux:    mux,
+		routes: make(map[string]*route),
+	}
+}
+
+//Handle registers `handler` on the mux for `pattern`, wrapped
+//with Methods so that only the `methods` listed reach it
+func (rt *Routes) Handle(pattern string, handler http.Handler, methods ...string) {
+	set, allow := allowedMethods(methods)
+	rt.routes[pattern] = &route{methods: set, allow: allow}
+	rt.mux.Handle(pattern, Methods(methods...)(handler))
+}
+
+//HandleFunc is like Handle, but for a handler function
+func (rt *Routes) HandleFunc(pattern string, handler http.HandlerFunc, methods ...string) {
+	rt.Handle(pattern, handler, methods...)
+}
+
+//Patterns returns the registered patterns, sorted
+func (rt *Routes) Patterns() []string {
+	patterns := make([]string, 0, len(rt.routes))
+	for pattern := range rt.routes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+//match returns the route for `path`, using the same rules as
+//http.ServeMux: an exact match, or else the longest subtree
+//pattern (ending in a slash) that `path` starts with
+func (rt *Routes) match(path string) *route {
+	if r, found := rt.routes[path]; found {
+		return r
+	}
+	var best *route
+	longest := 0
+	for pattern, r := range rt.routes {
+		if strings.HasSuffix(pattern, "/") && len(pattern) > longest && strings.HasPrefix(path, pattern) {
+			best = r
+			longest = len(pattern)
+		}
+	}
+	return best
+}
+
+//Allowed returns the methods allowed for `path`, and false
+//if no route matches. Its signature fits CORSConfig.RouteMethods.
+func (rt *Routes) Allowed(path string) ([]string, bool) {
+	r := rt.match(path)
+	if r == nil {
+		return nil, false
+	}
+	return strings.Split(r.allow, ", "), true
+}
+
+//Options is an Adapter that answers OPTIONS requests for
+//registered routes with a 204 and an Allow header listing
+//the route's methods, and OPTIONS requests for any other
+//path with a 404. Install it inside of CORS, so that
+//preflight requests are answered by CORS.
+func (rt *Routes) Options(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "OPTIONS" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		route := rt.match(r.URL.Path)
+		if route == nil {
+			respondError(w, http.StatusNotFound, "no resource at "+r.URL.Path)
+			return
+		}
+		w.Header().Set(headerAllow, route.allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,104 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestRoutes() (*Routes, http.Handler) {
+	mux := http.NewServeMux()
+	rt := NewRoutes(mux)
+	rt.HandleFunc("/v1/tasks", helloHandler, "GET", "POST")
+	rt.HandleFunc("/v1/tasks/", helloHandler, "GET", "PATCH", "DELETE")
+	rt.HandleFunc("/v1/tasks/archive/", helloHandler, "GET")
+	return rt, rt.Options(mux)
+}
+
+func TestRoutesOptions(t *testing.T) {
+	_, handler := newTestRoutes()
+
+	cases := []struct {
+		path           string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{"/v1/tasks", http.StatusNoContent, "GET, POST, OPTIONS"},
+		{"/v1/tasks/", http.StatusNoContent, "GET, PATCH, DELETE, OPTIONS"},
+		{"/v1/tasks/1234", http.StatusNoContent, "GET, PATCH, DELETE, OPTIONS"},
+		{"/v1/tasks/archive/1234", http.StatusNoContent, "GET, OPTIONS"},
+		{"/v1/users", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("OPTIONS", c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.path, c.expectedStatus, w.Code)
+		}
+		if allow := w.Header().Get(headerAllow); allow != c.expectedAllow {
+			t.Errorf("%s: expected Allow %q but got %q", c.path, c.expectedAllow, allow)
+		}
+	}
+
+	//other methods still reach the registered handlers
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/tasks", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PATCH", "/v1/tasks/1234", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Hello from Handler 1" {
+		t.Errorf("expected handler response but got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRoutesAllowed(t *testing.T) {
+	rt, _ := newTestRoutes()
+	methods, found := rt.Allowed("/v1/tasks/5678")
+	if !found || !reflect.DeepEqual(methods, []string{"GET", "PATCH", "DELETE", "OPTIONS"}) {
+		t.Errorf("unexpected methods %v, %t", methods, found)
+	}
+	if _, found := rt.Allowed("/v1/task"); found {
+		t.Error("expected no route for /v1/task")
+	}
+}
+
+func TestRoutesWithCORS(t *testing.T) {
+	rt, handler := newTestRoutes()
+	config := &CORSConfig{
+		AllowedOrigins: []string{"*"},
+		RouteMethods:   rt.Allowed,
+	}
+	handler = CORS(config)(handler)
+
+	preflight := func(path string, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("OPTIONS", path, nil)
+		r.Header.Set(headerOrigin, "https://example.com")
+		r.Header.Set(headerAccessControlRequestMethod, method)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preflight("/v1/tasks/1234", "PATCH")
+	if w.Header().Get(headerAccessControlAllowOrigin) != "*" {
+		t.Error("expected PATCH preflight on a task to be allowed")
+	}
+	if methods := w.Header().Get(headerAccessControlAllowMethods); methods != "GET, PATCH, DELETE, OPTIONS" {
+		t.Errorf("unexpected allowed methods %q", methods)
+	}
+
+	w = preflight("/v1/tasks", "PATCH")
+	if acao := w.Header().Get(headerAccessControlAllowOrigin); len(acao) > 0 {
+		t.Errorf("expected PATCH preflight on the collection to be refused, got %q", acao)
+	}
+
+	w = preflight("/v1/users", "GET")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown path but got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package httpmw
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+//ScopeKey is the request context key
+//under which the request Scope is stored
+const ScopeKey contextKey = "scope"
+
+//Scope holds values that handlers commonly need for
+//the duration of a request, so that they don't have
+//to re-derive them from the request every time
+type Scope struct {
+	//Logger writes to the server's log, with each
+	//line prefixed by the request ID
+	Logger *log.Logger
+	//RequestID is the request's ID, if AssignRequestIDs
+	//is installed outside of InjectScope
+	RequestID string
+	//ClientIP is the client's IP address, as
+	//resolved by ResolveClientIPs if it's installed
+	ClientIP string
+	//Start is when the request started
+	Start time.Time
+}
+
+//ScopeFromContext returns the Scope stored in `ctx` by
+//InjectScope. If there isn't one, it returns a Scope that
+//logs to the standard logger and starts now, so handlers
+//can always use the result without checking for nil.
+func ScopeFromContext(ctx context.Context) *Scope {
+	if scope, ok := ctx.Value(ScopeKey).(*Scope); ok {
+		return scope
+	}
+	return &Scope{
+		Logger:    log.New(log.Writer(), log.Prefix(), log.Flags()),
+		RequestID: RequestIDFromContext(ctx),
+		Start:     time.Now(),
+	}
+}
+
+//InjectScope returns an Adapter that stores a Scope in each
+//request's context. Its Logger writes to `logger`, with the
+//request ID added to the prefix. Install it inside of
+//AssignRequestIDs and ResolveClientIPs, so that the ID and
+//client IP are available when the Scope is built.
+func InjectScope(logger *log.Logger) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := &Scope{
+				Logger:    logger,
+				RequestID: RequestIDFromContext(r.Context()),
+				ClientIP:  ClientIP(r),
+				Start:     time.Now(),
+			}
+			if len(scope.RequestID) > 0 {
+				scope.Logger = log.New(logger.Writer(), logger.Prefix()+"["+scope.RequestID+"] ", logger.Flags())
+			}
+			ctx := context.WithValue(r.Context(), ScopeKey, scope)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInjectScope(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "tasks: ", 0)
+
+	var scope *Scope
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope = ScopeFromContext(r.Context())
+		scope.Logger.Print("inserting task")
+	}), AssignRequestIDs, InjectScope(logger))
+
+	before := time.Now()
+	r := httptest.NewRequest("POST", "/v1/tasks", nil)
+	r.RemoteAddr = "203.0.113.7:5555"
+	r.Header.Set(headerRequestID, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if scope.RequestID != "req-1" {
+		t.Errorf("expected request ID req-1 but got %q", scope.RequestID)
+	}
+	if scope.ClientIP != "203.0.113.7" {
+		t.Errorf("expected client IP 203.0.113.7 but got %q", scope.ClientIP)
+	}
+	if scope.Start.Before(before) || scope.Start.After(time.Now()) {
+		t.Errorf("unexpected start time %v", scope.Start)
+	}
+	if buf.String() != "tasks: [req-1] inserting task\n" {
+		t.Errorf("unexpected log output %q", buf.String())
+	}
+}
+
+func TestInjectScopeWithoutRequestID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := InjectScope(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ScopeFromContext(r.Context()).Logger.Print("hello")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello1", nil))
+	if buf.String() != "hello\n" {
+		t.Errorf("expected the logger to be used as-is, got %q", buf.String())
+	}
+}
+
+func TestScopeFromContextNotInstalled(t *testing.T) {
+	var scope *Scope
+	handler := AssignRequestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope = ScopeFromContext(r.Context())
+	}))
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.Header.Set(headerRequestID, "req-2")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if scope == nil || scope.Logger == nil {
+		t.Fatal("expected a fallback scope with a logger")
+	}
+	if scope.RequestID != "req-2" {
+		t.Errorf("expected the fallback scope to pick up the request ID, got %q", scope.RequestID)
+	}
+	if scope.Start.IsZero() {
+		t.Error("expected the fallback scope to have a start time")
+	}
+}
@@ -0,0 +1,86 @@
+package httpmw
+
+import "net/http"
+
+const (
+	headerContentTypeOptions      = "X-Content-Type-Options"
+	headerFrameOptions            = "X-Frame-Options"
+	headerReferrerPolicy          = "Referrer-Policy"
+	headerContentSecurityPolicy   = "Content-Security-Policy"
+	headerStrictTransportSecurity = "Strict-Transport-Security"
+	headerXForwardedProto         = "X-Forwarded-Proto"
+)
+
+//disabled can be used as the value of any SecurityHeaders
+//field to stop that header from being set
+const disabled = "-"
+
+//SecurityHeaders configures the SetSecurityHeaders middleware.
+//Fields left empty get the default value shown, and fields
+//set to "-" disable that header.
+type SecurityHeaders struct {
+	//ContentTypeOptions defaults to "nosniff"
+	ContentTypeOptions string
+	//FrameOptions defaults to "DENY"
+	FrameOptions string
+	//ReferrerPolicy defaults to "strict-origin-when-cross-origin"
+	ReferrerPolicy string
+	//ContentSecurityPolicy defaults to "default-src 'none'; frame-ancestors 'none'",
+	//which suits an API that only returns JSON
+	ContentSecurityPolicy string
+	//StrictTransportSecurity defaults to "max-age=31536000; includeSubDomains".
+	//It's only sent on requests that arrived over TLS.
+	StrictTransportSecurity string
+	//TrustProxy treats requests with X-Forwarded-Proto: https
+	//as having arrived over TLS, for servers behind a
+	//TLS-terminating proxy
+	TrustProxy bool
+}
+
+//securityHeader is a header name and the value to set
+type securityHeader struct {
+	name  string
+	value string
+}
+
+//orDefault returns `value`, or `def` if `value` is empty
+func orDefault(value string, def string) string {
+	if len(value) == 0 {
+		return def
+	}
+	return value
+}
+
+//isSecure returns true if the request arrived over TLS
+func isSecure(r *http.Request, trustProxy bool) bool {
+	return r.TLS != nil || (trustProxy && r.Header.Get(headerXForwardedProto) == "https")
+}
+
+//SetSecurityHeaders returns an Adapter that sets baseline security
+//headers on every response. They're set before the wrapped handler
+//runs, so a handler can still override any of them, and headers
+//that are already set aren't changed.
+func SetSecurityHeaders(config *SecurityHeaders) Adapter {
+	headers := []securityHeader{
+		{headerContentTypeOptions, orDefault(config.ContentTypeOptions, "nosniff")},
+		{headerFrameOptions, orDefault(config.FrameOptions, "DENY")},
+		{headerReferrerPolicy, orDefault(config.ReferrerPolicy, "strict-origin-when-cross-origin")},
+		{headerContentSecurityPolicy, orDefault(config.ContentSecurityPolicy, "default-src 'none'; frame-ancestors 'none'")},
+	}
+	hsts := orDefault(config.StrictTransportSecurity, "max-age=31536000; includeSubDomains")
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for _, header := range headers {
+				if header.value != disabled && len(h.Get(header.name)) == 0 {
+					h.Set(header.name, header.value)
+				}
+			}
+			if hsts != disabled && isSecure(r, config.TrustProxy) && len(h.Get(headerStrictTransportSecurity)) == 0 {
+				h.Set(headerStrictTransportSecurity, hsts)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package httpmw
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func secureRequest(config *SecurityHeaders, r *http.Request, handler http.HandlerFunc) http.Header {
+	if handler == nil {
+		handler = helloHandler
+	}
+	w := httptest.NewRecorder()
+	SetSecurityHeaders(config)(handler).ServeHTTP(w, r)
+	return w.Header()
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	h := secureRequest(&SecurityHeaders{}, httptest.NewRequest("GET", "/v1/hello1", nil), nil)
+
+	expected := map[string]string{
+		headerContentTypeOptions:      "nosniff",
+		headerFrameOptions:            "DENY",
+		headerReferrerPolicy:          "strict-origin-when-cross-origin",
+		headerContentSecurityPolicy:   "default-src 'none'; frame-ancestors 'none'",
+		headerStrictTransportSecurity: "",
+	}
+	for name, value := range expected {
+		if actual := h.Get(name); actual != value {
+			t.Errorf("%s: expected %q but got %q", name, value, actual)
+		}
+	}
+}
+
+func TestSecurityHeadersOverrides(t *testing.T) {
+	config := &SecurityHeaders{
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        disabled,
+		ContentSecurityPolicy: "default-src 'self'",
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentSecurityPolicy, "default-src *")
+	}
+	r := httptest.NewRequest("GET", "/v1/hello1", nil)
+	r.Header.Set(headerXForwardedProto, "https")
+	h := secureRequest(config, r, handler)
+
+	expected := map[string]string{
+		headerContentTypeOptions:      "nosniff",
+		headerFrameOptions:            "SAMEORIGIN",
+		headerReferrerPolicy:          "",
+		headerContentSecurityPolicy:   "default-src *",
+		headerStrictTransportSecurity: "",
+	}
+	for name, value := range expected {
+		if actual := h.Get(name); actual != value {
+			t.Errorf("%s: expected %q but got %q", name, value, actual)
+		}
+	}
+
+	//headers set before the middleware runs aren't clobbered
+	w := httptest.NewRecorder()
+	w.Header().Set(headerFrameOptions, "ALLOW-FROM https://example.com")
+	SetSecurityHeaders(config)(http.HandlerFunc(helloHandler)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if actual := w.Header().Get(headerFrameOptions); actual != "ALLOW-FROM https://example.com" {
+		t.Errorf("expected existing header to be kept but got %q", actual)
+	}
+}
+
+func TestSecurityHeadersHSTS(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   *SecurityHeaders
+		tls      bool
+		proto    string
+		expected string
+	}{
+		{"plain HTTP", &SecurityHeaders{}, false, "", ""},
+		{"TLS", &SecurityHeaders{}, true, "", "max-age=31536000; includeSubDomains"},
+		{"forwarded without trust", &SecurityHeaders{}, false, "https", ""},
+		{"forwarded with trust", &SecurityHeaders{TrustProxy: true}, false, "https", "max-age=31536000; includeSubDomains"},
+		{"forwarded http with trust", &SecurityHeaders{TrustProxy: true}, false, "http", ""},
+		{"custom", &SecurityHeaders{StrictTransportSecurity: "max-age=60"}, true, "", "max-age=60"},
+		{"disabled", &SecurityHeaders{StrictTransportSecurity: disabled}, true, "", ""},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/hello1", nil)
+		if c.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if len(c.proto) > 0 {
+			r.Header.Set(headerXForwardedProto, c.proto)
+		}
+		h := secureRequest(c.config, r, nil)
+		if actual := h.Get(headerStrictTransportSecurity); actual != c.expected {
+			t.Errorf("%s: expected %q but got %q", c.name, c.expected, actual)
+		}
+	}
+}
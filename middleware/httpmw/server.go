@@ -0,0 +1,98 @@
+package httpmw
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+//ServerTimeouts holds the timeouts applied to an http.Server.
+//Without these, a slow client can hold a connection open forever.
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+//DefaultTimeouts are used for any timeout not set in the environment
+var DefaultTimeouts = ServerTimeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       10 * time.Second,
+	Write:      30 * time.Second,
+	Idle:       120 * time.Second,
+}
+
+//durationFromEnv parses the environment variable `name`
+//as a duration, returning `def` if it isn't set
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(name)
+	if len(s) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 10s: %q", name, s)
+	}
+	return d, nil
+}
+
+//TimeoutsFromEnv reads the server timeouts from the
+//READHEADERTIMEOUT, READTIMEOUT, WRITETIMEOUT, and
+//IDLETIMEOUT environment variables, using the defaults
+//for any that aren't set
+func TimeoutsFromEnv() (*ServerTimeouts, error) {
+	t := DefaultTimeouts
+	var err error
+	if t.ReadHeader, err = durationFromEnv("READHEADERTIMEOUT", t.ReadHeader); err != nil {
+		return nil, err
+	}
+	if t.Read, err = durationFromEnv("READTIMEOUT", t.Read); err != nil {
+		return nil, err
+	}
+	if t.Write, err = durationFromEnv("WRITETIMEOUT", t.Write); err != nil {
+		return nil, err
+	}
+	if t.Idle, err = durationFromEnv("IDLETIMEOUT", t.Idle); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+//NewServer creates an http.Server listening on `addr`
+//with the timeouts in `t`. If `handler` is nil, the
+//default router is used, just like http.ListenAndServe()
+func NewServer(addr string, handler http.Handler, t *ServerTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+	}
+}
+
+//NoWriteTimeout returns an Adapter that lifts the server's
+//WriteTimeout for requests to the given paths, such as
+//server-sent event streams and WebSockets, which stay open
+//for as long as the client is listening. It must be installed
+//outermost, as it needs the ResponseWriter from the server
+//itself, rather than one wrapped by other middleware.
+func NoWriteTimeout(paths ...string) Adapter {
+	exempt := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		exempt[path] = true
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] {
+				//the zero time means no deadline; this also
+				//applies to connections that are hijacked
+				http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
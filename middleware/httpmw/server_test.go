@@ -0,0 +1,98 @@
+package httpmw
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsFromEnv(t *testing.T) {
+	os.Setenv("WRITETIMEOUT", "2s")
+	defer os.Unsetenv("WRITETIMEOUT")
+
+	timeouts, err := TimeoutsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeouts.Write != 2*time.Second {
+		t.Errorf("expected WriteTimeout of 2s but got %v", timeouts.Write)
+	}
+	if timeouts.Idle != DefaultTimeouts.Idle {
+		t.Errorf("expected default IdleTimeout but got %v", timeouts.Idle)
+	}
+
+	os.Setenv("WRITETIMEOUT", "soon")
+	if _, err := TimeoutsFromEnv(); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
+
+//getWithin gets `url`, returning an error if the server
+//closes the connection, or the whole response takes more
+//than a few seconds, which means the server hung
+func getWithin(t *testing.T, url string) (string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if elapsed := time.Since(start); elapsed >= client.Timeout {
+		t.Errorf("expected the server to finish or close the connection, but the client timed out after %v", elapsed)
+	}
+	return string(body), err
+}
+
+func TestWriteTimeoutClosesConnection(t *testing.T) {
+	writeTimeout := 100 * time.Millisecond
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(writeTimeout * 3)
+		w.Write([]byte("too late"))
+	})
+
+	timeouts := DefaultTimeouts
+	timeouts.Write = writeTimeout
+	srv := httptest.NewUnstartedServer(slowHandler)
+	srv.Config = NewServer("", slowHandler, &timeouts)
+	srv.Start()
+	defer srv.Close()
+
+	if _, err := getWithin(t, srv.URL); err == nil {
+		t.Fatal("expected the connection to be closed, but got a response")
+	}
+}
+
+func TestNoWriteTimeout(t *testing.T) {
+	writeTimeout := 100 * time.Millisecond
+	//streams events for longer than the write timeout
+	streamHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("data: tick\n\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(writeTimeout)
+		}
+	})
+	//wrapped like any other middleware would
+	handler := NoWriteTimeout("/events")(LogRequests(log.New(ioutil.Discard, "", 0))(streamHandler))
+
+	timeouts := DefaultTimeouts
+	timeouts.Write = writeTimeout
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config = NewServer("", handler, &timeouts)
+	srv.Start()
+	defer srv.Close()
+
+	body, err := getWithin(t, srv.URL+"/events")
+	if err != nil || body != "data: tick\n\ndata: tick\n\ndata: tick\n\n" {
+		t.Errorf("expected the whole stream, got %q, %v", body, err)
+	}
+	if body, err := getWithin(t, srv.URL+"/other"); err == nil && len(body) == len("data: tick\n\n")*3 {
+		t.Error("expected other paths to keep the write timeout")
+	}
+}
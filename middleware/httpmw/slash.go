@@ -0,0 +1,71 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const headerLocation = "Location"
+
+//SlashConfig configures the TrimTrailingSlash middleware
+type SlashConfig struct {
+	//Rewrite changes the request path internally instead
+	//of redirecting the client to the canonical path
+	Rewrite bool
+	//Subtrees are the subtree patterns registered on the
+	//mux, such as /zips/city/, which are left alone as
+	//the trailing slash is part of the route
+	Subtrees []string
+}
+
+//permanentRedirect redirects the client to `path`, keeping the
+//query string. GET and HEAD requests get a 301, and others get
+//a 308, so that clients resend the same method and body.
+func permanentRedirect(w http.ResponseWriter, r *http.Request, path string) {
+	status := http.StatusPermanentRedirect
+	if r.Method == "GET" || r.Method == "HEAD" {
+		status = http.StatusMovedPermanently
+	}
+	location := &url.URL{Path: path, RawQuery: r.URL.RawQuery}
+	w.Header().Set(headerLocation, location.String())
+	w.WriteHeader(status)
+}
+
+//TrimTrailingSlash returns an Adapter that makes paths with
+//a trailing slash, like /v1/hello1/, behave the same as the
+//canonical path without it. By default the client is redirected
+//to the canonical path, with a 301 for GET and HEAD requests and
+//a 308 for others, so that clients resend the same method and
+//body. If config.Rewrite is set, the path is changed internally
+//instead. The root path and config.Subtrees are left alone.
+func TrimTrailingSlash(config *SlashConfig) Adapter {
+	subtrees := make(map[string]bool, len(config.Subtrees))
+	for _, pattern := range config.Subtrees {
+		subtrees[pattern] = true
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			canonical := strings.TrimRight(path, "/")
+			//don't turn //example.com/ into a protocol-relative
+			//redirect to some other site
+			if canonical == path || len(canonical) == 0 || subtrees[path] ||
+				strings.HasPrefix(canonical, "//") {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if config.Rewrite {
+				r.URL.Path = canonical
+				if len(r.URL.RawPath) > 0 {
+					r.URL.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+				}
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			permanentRedirect(w, r, canonical)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrimTrailingSlashRedirect(t *testing.T) {
+	config := &SlashConfig{Subtrees: []string{"/zips/city/"}}
+	handler := TrimTrailingSlash(config)(http.HandlerFunc(helloHandler))
+
+	cases := []struct {
+		method           string
+		path             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"GET", "/v1/hello1/", http.StatusMovedPermanently, "/v1/hello1"},
+		{"HEAD", "/v1/hello1/", http.StatusMovedPermanently, "/v1/hello1"},
+		{"GET", "/v1/hello1//", http.StatusMovedPermanently, "/v1/hello1"},
+		{"GET", "/v1/hello1/?name=dave&x=1", http.StatusMovedPermanently, "/v1/hello1?name=dave&x=1"},
+		{"POST", "/v1/tasks/", http.StatusPermanentRedirect, "/v1/tasks"},
+		{"DELETE", "/v1/tasks/123/?force=true", http.StatusPermanentRedirect, "/v1/tasks/123?force=true"},
+		{"GET", "/v1/hello%20world/", http.StatusMovedPermanently, "/v1/hello%20world"},
+		{"GET", "/v1/hello1", http.StatusOK, ""},
+		{"GET", "/", http.StatusOK, ""},
+		{"GET", "/zips/city/", http.StatusOK, ""},
+		{"GET", "//example.com/", http.StatusOK, ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s %s: expected status %d but got %d", c.method, c.path, c.expectedStatus, w.Code)
+		}
+		if location := w.Header().Get(headerLocation); location != c.expectedLocation {
+			t.Errorf("%s %s: expected Location %q but got %q", c.method, c.path, c.expectedLocation, location)
+		}
+	}
+}
+
+func TestTrimTrailingSlashRewrite(t *testing.T) {
+	config := &SlashConfig{Rewrite: true, Subtrees: []string{"/zips/city/"}}
+
+	cases := []struct {
+		method       string
+		path         string
+		expectedPath string
+	}{
+		{"GET", "/v1/hello1/", "/v1/hello1"},
+		{"POST", "/v1/tasks/?x=1", "/v1/tasks"},
+		{"GET", "/", "/"},
+		{"GET", "/zips/city/", "/zips/city/"},
+	}
+
+	for _, c := range cases {
+		var path string
+		handler := TrimTrailingSlash(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: expected no redirect but got %d", c.method, c.path, w.Code)
+		}
+		if path != c.expectedPath {
+			t.Errorf("%s %s: expected handler to see %s but got %s", c.method, c.path, c.expectedPath, path)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package httpmw
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//maxStatsdPacket keeps each UDP packet within a
+	//typical Ethernet MTU, so it isn't fragmented
+	maxStatsdPacket = 1432
+	//statsdQueueSize is the number of metrics that can be
+	//waiting to be sent before new ones are dropped
+	statsdQueueSize = 1024
+	//statsdErrorInterval is the minimum time between
+	//log messages about failures to send metrics
+	statsdErrorInterval = time.Minute
+)
+
+//StatsdClient sends metrics to a statsd server over UDP,
+//using the DogStatsD format for tags. Metrics are queued and
+//sent in batches by a background goroutine, several per packet,
+//so that requests don't each make a syscall and are never
+//slowed down by a slow or missing statsd server.
+type StatsdClient struct {
+	conn      net.Conn
+	namespace string
+	logger    *log.Logger
+	metrics   chan string
+	interval  time.Duration
+	done      chan struct{}
+	wg        sync.WaitGroup
+	errors    int
+	lastError time.Time
+}
+
+//NewStatsdClient creates a new StatsdClient that sends metrics
+//to the statsd server at `addr`, flushing at least every
+//`interval`. Metric names are prefixed with `namespace` and a dot.
+//Errors sending metrics are logged to `logger`, at most once
+//a minute. Call Close to send any remaining metrics.
+func NewStatsdClient(addr string, namespace string, interval time.Duration, logger *log.Logger) (*StatsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to statsd: %v", err)
+	}
+	sc := &StatsdClient{
+		conn:      conn,
+		namespace: namespace,
+		logger:    logger,
+		metrics:   make(chan string, statsdQueueSize),
+		interval:  interval,
+		done:      make(chan struct{}),
+	}
+	sc.wg.Add(1)
+	go sc.run()
+	return sc, nil
+}
+
+//Count queues a counter metric
+func (sc *StatsdClient) Count(name string, value int64, tags ...string) {
+	sc.queue(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+//Timing queues a timing metric, in milliseconds
+func (sc *StatsdClient) Timing(name string, d time.Duration, tags ...string) {
+	ms := float64(d) / float64(time.Millisecond)
+	sc.queue(name, strconv.FormatFloat(ms, 'f', -1, 64), "ms", tags)
+}
+
+//queue formats a metric as `namespace.name:value|type|#tag,tag`
+//and queues it to be sent. If the queue is full, the metric is
+//dropped rather than making the caller wait.
+func (sc *StatsdClient) queue(name string, value string, metricType string, tags []string) {
+	buf := bytes.Buffer{}
+	if len(sc.namespace) > 0 {
+		buf.WriteString(sc.namespace + ".")
+	}
+	buf.WriteString(name + ":" + value + "|" + metricType)
+	for i, tag := range tags {
+		if i == 0 {
+			buf.WriteString("|#")
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(tag)
+	}
+	select {
+	case sc.metrics <- buf.String():
+	default:
+	}
+}
+
+//run batches queued metrics into packets, sending each
+//packet when it's full or when the interval elapses
+func (sc *StatsdClient) run() {
+	defer sc.wg.Done()
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+
+	packet := bytes.Buffer{}
+	for {
+		select {
+		case metric := <-sc.metrics:
+			sc.add(&packet, metric)
+		case <-ticker.C:
+			sc.send(&packet)
+		case <-sc.done:
+			//drain whatever is left in the queue
+			for {
+				select {
+				case metric := <-sc.metrics:
+					sc.add(&packet, metric)
+				default:
+					sc.send(&packet)
+					return
+				}
+			}
+		}
+	}
+}
+
+//add adds a metric to the packet, sending
+//the packet first if the metric won't fit
+func (sc *StatsdClient) add(packet *bytes.Buffer, metric string) {
+	if packet.Len() > 0 && packet.Len()+1+len(metric) > maxStatsdPacket {
+		sc.send(packet)
+	}
+	if packet.Len() > 0 {
+		packet.WriteByte('\n')
+	}
+	packet.WriteString(metric)
+}
+
+//send writes the packet, if there is one,
+//and logs any errors without flooding the log
+func (sc *StatsdClient) send(packet *bytes.Buffer) {
+	if packet.Len() == 0 {
+		return
+	}
+	_, err := sc.conn.Write(packet.Bytes())
+	packet.Reset()
+	if err == nil {
+		return
+	}
+	sc.errors++
+	if time.Since(sc.lastError) >= statsdErrorInterval {
+		sc.logger.Printf("error sending metrics to statsd (%d errors since last report): %v", sc.errors, err)
+		sc.lastError = time.Now()
+		sc.errors = 0
+	}
+}
+
+//Close sends any queued metrics and closes the connection
+func (sc *StatsdClient) Close() error {
+	close(sc.done)
+	sc.wg.Wait()
+	return sc.conn.Close()
+}
@@ -0,0 +1,132 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+//statsdListener starts a UDP listener for a fake statsd server
+func statsdListener(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for UDP: %v", err)
+	}
+	return conn
+}
+
+//readPacket reads one packet from `conn`
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("error reading packet: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsdMetrics(t *testing.T) {
+	listener := statsdListener(t)
+	defer listener.Close()
+
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	sc, err := NewStatsdClient(listener.LocalAddr().String(), "tasksvr", time.Hour, logger)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	opts := &MetricsOptions{Statsd: sc}
+	handler := CountRequestsWithOptions("/test/statsd/", opts)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/test/statsd/missing" {
+				http.NotFound(w, r)
+			}
+		}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/statsd/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/statsd/missing", nil))
+	//closing flushes the batch as one packet
+	sc.Close()
+
+	lines := strings.Split(readPacket(t, listener), "\n")
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`^tasksvr\.requests:1\|c\|#route:/test/statsd/,status:2xx$`),
+		regexp.MustCompile(`^tasksvr\.request_time:[0-9.]+\|ms\|#route:/test/statsd/,status:2xx$`),
+		regexp.MustCompile(`^tasksvr\.requests:1\|c\|#route:/test/statsd/,status:4xx$`),
+		regexp.MustCompile(`^tasksvr\.request_time:[0-9.]+\|ms\|#route:/test/statsd/,status:4xx$`),
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d metrics in one packet but got %d: %q", len(expected), len(lines), lines)
+	}
+	for i, re := range expected {
+		if !re.MatchString(lines[i]) {
+			t.Errorf("metric %d: expected to match %s but got %q", i, re, lines[i])
+		}
+	}
+}
+
+func TestStatsdBatching(t *testing.T) {
+	listener := statsdListener(t)
+	defer listener.Close()
+
+	sc, err := NewStatsdClient(listener.LocalAddr().String(), "", time.Hour, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	const metrics = 200
+	for i := 0; i < metrics; i++ {
+		sc.Count("requests", 1, "route:/v1/tasks")
+	}
+	sc.Close()
+
+	total := 0
+	for total < metrics {
+		packet := readPacket(t, listener)
+		if len(packet) > maxStatsdPacket {
+			t.Errorf("packet of %d bytes exceeds the maximum", len(packet))
+		}
+		for _, line := range strings.Split(packet, "\n") {
+			if line != "requests:1|c|#route:/v1/tasks" {
+				t.Errorf("unexpected metric %q", line)
+			}
+			total++
+		}
+	}
+	if total != metrics {
+		t.Errorf("expected %d metrics but got %d", metrics, total)
+	}
+}
+
+func TestStatsdUnreachable(t *testing.T) {
+	//find a port with nothing listening on it
+	listener := statsdListener(t)
+	addr := listener.LocalAddr().String()
+	listener.Close()
+
+	buf := &bytes.Buffer{}
+	sc, err := NewStatsdClient(addr, "", time.Millisecond, log.New(buf, "", 0))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	handler := CountRequestsWithOptions("/test/statsd/down", &MetricsOptions{Statsd: sc})(http.HandlerFunc(helloHandler))
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/test/statsd/down", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected requests to succeed but got %d", w.Code)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	sc.Close()
+
+	//errors, if any are reported, are logged at most once a minute
+	if lines := strings.Count(buf.String(), "\n"); lines > 1 {
+		t.Errorf("expected at most one error to be logged but got %d: %q", lines, buf.String())
+	}
+}
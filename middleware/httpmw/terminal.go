@@ -0,0 +1,33 @@
+package httpmw
+
+import (
+	"io"
+	"os"
+)
+
+//TerminalChecker reports whether a writer is a terminal,
+//which decides whether LogRequests colors its output
+type TerminalChecker interface {
+	IsTerminal(w io.Writer) bool
+}
+
+//fileTerminalChecker is the default TerminalChecker. Only
+//an *os.File can be a terminal, and only if it's a character
+//device rather than a regular file or a pipe. A LogOutput is
+//a terminal if OpenLogOutput found that it only writes to one.
+type fileTerminalChecker struct{}
+
+func (fileTerminalChecker) IsTerminal(w io.Writer) bool {
+	if lo, ok := w.(*LogOutput); ok {
+		return lo.terminal
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
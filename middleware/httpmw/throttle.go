@@ -1,4 +1,4 @@
-package main
+package httpmw
 
 /*
 TODO: Similar to the LogRequests middleware function, define a
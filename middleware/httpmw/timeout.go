@@ -0,0 +1,152 @@
+package httpmw
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//timeoutWriter guards the real ResponseWriter so that only
+//one of the handler or the timeout gets to write the response.
+//The handler gets its own header map, which is copied to the
+//real one when it writes the header, so that it can't race
+//with the timeout response.
+type timeoutWriter struct {
+	ctx         context.Context
+	w           http.ResponseWriter
+	h           http.Header
+	mx          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+//Header returns the handler's header map
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+//WriteHeader writes the header, unless the request already timed out
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	//the handler may notice the deadline before the
+	//middleware does, but it's still too late to write
+	if tw.ctx.Err() != nil {
+		tw.timedOut = true
+		return
+	}
+	tw.sendHeaderLocked(code)
+}
+
+//sendHeaderLocked copies the handler's header map
+//to the real one, and writes the header with `code`
+func (tw *timeoutWriter) sendHeaderLocked(code int) {
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+//Write writes the data, or returns http.ErrHandlerTimeout
+//if the timeout response was already sent
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.w.Write(p)
+}
+
+//Flush sends what the handler has written so far, for
+//handlers that stream, unless the timeout response was
+//already sent. Like Write, it sends the header first.
+func (tw *timeoutWriter) Flush() {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Timeout returns an Adapter that gives each request a deadline
+//of `d`. The handler gets a context that is canceled at the
+//deadline, so store calls and the like can give up. If the
+//handler hasn't written anything by the deadline, the client
+//gets a 503 and anything the handler writes afterwards is
+//discarded. If the handler already started writing, it's
+//allowed to finish.
+func Timeout(d time.Duration) Adapter {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{ctx: ctx, w: w, h: make(http.Header)}
+			done := make(chan struct{})
+			panics := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panics <- p
+					}
+				}()
+				handler.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			finished := false
+			select {
+			case <-done:
+				finished = true
+			case p := <-panics:
+				panic(p)
+			case <-ctx.Done():
+			}
+
+			tw.mx.Lock()
+			if !tw.wroteHeader && ctx.Err() != nil {
+				tw.timedOut = true
+				respondError(w, http.StatusServiceUnavailable, "request timed out")
+				tw.mx.Unlock()
+				return
+			}
+			if finished {
+				//the handler may have only set headers, which
+				//still need sending, with a 200 like net/http does
+				if !tw.wroteHeader {
+					tw.sendHeaderLocked(http.StatusOK)
+				}
+				tw.mx.Unlock()
+				return
+			}
+			tw.mx.Unlock()
+
+			//the handler already started the response,
+			//so let it finish rather than truncating it
+			select {
+			case <-done:
+			case p := <-panics:
+				panic(p)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutSlowHandler(t *testing.T) {
+	writeErrs := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("X-Too-Late", "true")
+		_, err := w.Write([]byte("too late"))
+		writeErrs <- err
+	})
+
+	w := httptest.NewRecorder()
+	Timeout(20*time.Millisecond)(slow).ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeJSON {
+		t.Errorf("expected JSON error but got Content-Type %q", ctype)
+	}
+	if err := <-writeErrs; err != http.ErrHandlerTimeout {
+		t.Errorf("expected late write to fail with ErrHandlerTimeout but got %v", err)
+	}
+	if w.Header().Get("X-Too-Late") != "" {
+		t.Error("headers set by the handler after the timeout should not be sent")
+	}
+}
+
+func TestTimeoutFastHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Timeout(time.Second)(http.HandlerFunc(helloHandler)).ServeHTTP(w, httptest.NewRequest("GET", "/v1/hello1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "Hello from Handler 1" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeText {
+		t.Errorf("expected handler's Content-Type to be copied, got %q", ctype)
+	}
+}
+
+func TestTimeoutHandlerOnlySetsHeaders(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Foo", "bar")
+	})
+
+	w := httptest.NewRecorder()
+	Timeout(time.Second)(handler).ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if foo := w.Header().Get("X-Foo"); foo != "bar" {
+		t.Errorf("expected the handler's X-Foo header to be copied, got %q", foo)
+	}
+}
+
+func TestTimeoutAfterHandlerStartedWriting(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("started "))
+		<-r.Context().Done()
+		w.Write([]byte("finished"))
+	})
+
+	w := httptest.NewRecorder()
+	Timeout(20*time.Millisecond)(handler).ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "started finished" {
+		t.Errorf("expected handler to finish its response, got %q", w.Body.String())
+	}
+}
+
+func TestTimeoutFlush(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerContentType, "text/event-stream")
+		w.(http.Flusher).Flush()
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/events", nil))
+	if !w.Flushed || w.Code != http.StatusOK {
+		t.Errorf("expected the response to be flushed with status %d, got %d", http.StatusOK, w.Code)
+	}
+	//the header was sent before flushing
+	if ct := w.Header().Get(headerContentType); ct != "text/event-stream" {
+		t.Errorf("expected the handler's Content-Type but got %q", ct)
+	}
+}
@@ -0,0 +1,137 @@
+package httpmw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const headerTraceparent = "traceparent"
+
+//TraceKey is the request context key
+//under which the *TraceContext is stored
+const TraceKey contextKey = "trace"
+
+//TraceContext identifies this server's part of a distributed
+//trace, as described by the W3C Trace Context spec. A traceparent
+//header looks like this, with the fields separated by dashes:
+//
+//	00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
+//
+//which is the version, the trace ID shared by every service
+//handling the request, the ID of the caller's span, and flags,
+//of which only the lowest bit ("sampled") is defined.
+type TraceContext struct {
+	//TraceID is the 32 hex digit ID of the whole trace
+	TraceID string
+	//ParentID is the 16 hex digit ID of the caller's span,
+	//or an empty string if this is a new trace
+	ParentID string
+	//SpanID is the 16 hex digit ID of this server's span
+	SpanID string
+	//Flags are the trace flags, passed along unchanged
+	Flags byte
+}
+
+//Traceparent returns the traceparent header for this span,
+//which is the one to send on the response, and on any
+//outbound requests made while handling this one
+func (tc *TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+//randomHex returns `n` random bytes as hex
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic("error generating trace ID: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+//isLowerHex returns true if `s` is all lowercase hex digits,
+//and false if it's all zeros, which the spec says is invalid
+func isLowerHex(s string) bool {
+	zeros := true
+	for _, c := range s {
+		switch {
+		case c == '0':
+		case c >= '1' && c <= '9', c >= 'a' && c <= 'f':
+			zeros = false
+		default:
+			return false
+		}
+	}
+	return !zeros
+}
+
+//parseTraceparent parses a traceparent header. Versions after
+//00 may add fields, so for those only the first four are parsed.
+//The returned TraceContext has the caller's span as its ParentID,
+//and no SpanID yet.
+func parseTraceparent(header string) (*TraceContext, error) {
+	header = strings.TrimSpace(header)
+	//version-traceid-parentid-flags is 2+1+32+1+16+1+2 chars
+	if len(header) < 55 {
+		return nil, fmt.Errorf("traceparent is too short")
+	}
+	version := header[:2]
+	if (!isLowerHex(version) && version != "00") || version == "ff" {
+		return nil, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	if (version == "00" && len(header) != 55) || (len(header) > 55 && header[55] != '-') {
+		return nil, fmt.Errorf("invalid traceparent length")
+	}
+	if header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return nil, fmt.Errorf("invalid traceparent separators")
+	}
+	tc := &TraceContext{
+		TraceID:  header[3:35],
+		ParentID: header[36:52],
+	}
+	if !isLowerHex(tc.TraceID) {
+		return nil, fmt.Errorf("invalid trace ID %q", tc.TraceID)
+	}
+	if !isLowerHex(tc.ParentID) {
+		return nil, fmt.Errorf("invalid parent ID %q", tc.ParentID)
+	}
+	flags, err := hex.DecodeString(header[53:55])
+	if err != nil || strings.ToLower(header[53:55]) != header[53:55] {
+		return nil, fmt.Errorf("invalid trace flags %q", header[53:55])
+	}
+	tc.Flags = flags[0]
+	return tc, nil
+}
+
+//TraceFromContext returns the TraceContext stored in `ctx`,
+//or nil if there isn't one
+func TraceFromContext(ctx context.Context) *TraceContext {
+	tc, _ := ctx.Value(TraceKey).(*TraceContext)
+	return tc
+}
+
+//PropagateTraces is an Adapter that lets this server take part
+//in distributed traces. It parses the traceparent request header
+//and starts a new span for this hop, within the caller's trace.
+//If the header is missing or malformed, a new trace is started
+//instead, as the spec recommends, rather than rejecting the request.
+//The TraceContext is stored in the request context, and the
+//updated traceparent is sent in the response. To continue the
+//trace, set the traceparent header of any outbound request to
+//TraceFromContext(ctx).Traceparent(). Install it outside of
+//LogRequests so that the IDs are logged.
+func PropagateTraces(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, err := parseTraceparent(r.Header.Get(headerTraceparent))
+		if err != nil {
+			tc = &TraceContext{TraceID: randomHex(16)}
+		}
+		tc.SpanID = randomHex(8)
+		w.Header().Set(headerTraceparent, tc.Traceparent())
+		ctx := context.WithValue(r.Context(), TraceKey, tc)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,137 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	//examples from the W3C Trace Context spec
+	valid := []struct {
+		header   string
+		traceID  string
+		parentID string
+		flags    byte
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", 0x01},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", 0x00},
+		//future versions may add fields after the flags
+		{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", 0x01},
+	}
+	for _, c := range valid {
+		tc, err := parseTraceparent(c.header)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.header, err)
+			continue
+		}
+		if tc.TraceID != c.traceID || tc.ParentID != c.parentID || tc.Flags != c.flags {
+			t.Errorf("%s: unexpected result %+v", c.header, tc)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"garbage",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-0x",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"00_0af7651916cd43dd8448eb211c80319c_b7ad6b7169203331_01",
+		"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01x",
+	}
+	for _, header := range invalid {
+		if tc, err := parseTraceparent(header); err == nil {
+			t.Errorf("%q: expected an error but got %+v", header, tc)
+		}
+	}
+}
+
+func TestTraceparentFormat(t *testing.T) {
+	tc := &TraceContext{
+		TraceID: "0af7651916cd43dd8448eb211c80319c",
+		SpanID:  "b7ad6b7169203331",
+		Flags:   0x01,
+	}
+	if header := tc.Traceparent(); header != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" {
+		t.Errorf("unexpected traceparent %q", header)
+	}
+}
+
+func TestPropagateTraces(t *testing.T) {
+	var tc *TraceContext
+	handler := PropagateTraces(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc = TraceFromContext(r.Context())
+	}))
+
+	incoming := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set(headerTraceparent, incoming)
+	handler.ServeHTTP(w, r)
+	if tc == nil {
+		t.Fatal("expected a TraceContext in the request context")
+	}
+	if tc.TraceID != "0af7651916cd43dd8448eb211c80319c" || tc.ParentID != "b7ad6b7169203331" {
+		t.Errorf("expected the caller's trace to be continued, got %+v", tc)
+	}
+	if len(tc.SpanID) != 16 || tc.SpanID == tc.ParentID {
+		t.Errorf("expected a new span ID but got %q", tc.SpanID)
+	}
+	if header := w.Header().Get(headerTraceparent); header != tc.Traceparent() || header == incoming {
+		t.Errorf("expected the updated traceparent in the response, got %q", header)
+	}
+
+	//malformed headers start a new trace
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set(headerTraceparent, "00-garbage")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if len(tc.TraceID) != 32 || len(tc.ParentID) > 0 {
+		t.Errorf("expected a new trace but got %+v", tc)
+	}
+	if _, err := parseTraceparent(w.Header().Get(headerTraceparent)); err != nil {
+		t.Errorf("expected a valid traceparent in the response: %v", err)
+	}
+}
+
+func TestPropagateTracesLogged(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	handler := Chain(http.HandlerFunc(helloHandler),
+		PropagateTraces,
+		LogRequestsWithOptions(logger, &LogOptions{Format: LogFormatJSON}),
+	)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set(headerTraceparent, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	handler.ServeHTTP(w, r)
+
+	rec := &logRecord{}
+	if err := json.Unmarshal(buf.Bytes(), rec); err != nil {
+		t.Fatalf("error parsing log line %q: %v", buf.String(), err)
+	}
+	if rec.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("expected the trace ID to be logged, got %q", rec.TraceID)
+	}
+	if !strings.Contains(w.Header().Get(headerTraceparent), "-"+rec.SpanID+"-") {
+		t.Errorf("expected the logged span ID %q to match the response", rec.SpanID)
+	}
+
+	buf.Reset()
+	handler = Chain(http.HandlerFunc(helloHandler), PropagateTraces, LogRequests(logger))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !strings.Contains(buf.String(), " trace=0af7651916cd43dd8448eb211c80319c span=") {
+		t.Errorf("expected the trace ID in the text log, got %q", buf.String())
+	}
+}
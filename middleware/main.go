@@ -1,9 +1,21 @@
 package main
 
 import (
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
+)
+
+const (
+	maxBodyBytes = 1 << 20
+	maxInFlight  = 100
+	maxQueued    = 100
+	maxQueueWait = 5 * time.Second
 )
 
 func main() {
@@ -11,22 +23,90 @@ func main() {
 
 	mux := http.NewServeMux()
 	muxLogged := http.NewServeMux()
-	muxLogged.HandleFunc("/v1/hello1", HelloHandler1)
-	muxLogged.HandleFunc("/v1/hello2", HelloHandler2)
-	mux.HandleFunc("/v1/hello3", HelloHandler3)
+	getOnly := httpmw.Methods("GET")
+	httpmw.HandleCounted(muxLogged, "/v1/greeting", getOnly(http.HandlerFunc(HelloHandler1)))
+	httpmw.HandleCounted(muxLogged, "/v1/hello2", getOnly(http.HandlerFunc(HelloHandler2)))
+	mux.Handle("/v1/hello3", getOnly(http.HandlerFunc(HelloHandler3)))
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	//log to stdout, or to the destinations listed in
+	//LOGOUTPUTS or the LOGCONFIG file, such as stdout
+	//and a rotating file at the same time
+	logConfig, err := httpmw.LogConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logOutput, err := httpmw.OpenLogOutput(logConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logOutput.ReopenOnSIGHUP()
+	expvar.Publish("logOutput", expvar.Func(logOutput.Stats))
+	logger := log.New(logOutput, "", log.LstdFlags)
+	//cap the number of requests handled at once, and
+	//publish the limiter's counters at /debug/vars
+	cl := httpmw.NewConcurrencyLimiter(&httpmw.ConcurrencyConfig{
+		MaxInFlight: maxInFlight,
+		MaxQueued:   maxQueued,
+		MaxWait:     maxQueueWait,
+	})
+	expvar.Publish("concurrency", expvar.Func(cl.Stats))
 
-	mux.Handle("/v1/", logRequests(muxLogged))
+	//set CHAOS to add latency and errors to some requests,
+	//for testing clients; /admin/chaos adjusts the settings
+	chaos := httpmw.NewChaos(&httpmw.ChaosConfig{
+		Enabled: len(os.Getenv("CHAOS")) > 0,
+		Default: httpmw.ChaosRule{
+			LatencyRate:  0.2,
+			MinLatencyMS: 100,
+			MaxLatencyMS: 2000,
+			ErrorRate:    0.1,
+		},
+	})
+	mux.Handle("/admin/chaos", httpmw.BasicAuth("admin", nil)(chaos))
 
-	// http.HandleFunc("/v1/hello1/", logReqs(HelloHandler1))
-	// http.HandleFunc("/v1/hello2/", logReqs(HelloHandler2))
-	// http.HandleFunc("/v1/hello3/", logReqs(HelloHandler3))
+	v1 := httpmw.New(
+		httpmw.AssignRequestIDs,
+		httpmw.PropagateTraces,
+		httpmw.InjectScope(logger),
+		httpmw.LogRequests(logger),
+		httpmw.Recover(logger),
+		httpmw.CORS(&httpmw.CORSConfig{AllowedOrigins: []string{"*"}}),
+		httpmw.ResponseTime(&httpmw.ResponseTimeConfig{ServerTiming: true}),
+		cl.Limit,
+		httpmw.LimitBody(&httpmw.BodyLimitConfig{MaxBytes: maxBodyBytes}),
+		//set DEBUGDUMP to log full requests and responses
+		httpmw.DumpBodies(logger, &httpmw.DumpConfig{Enabled: len(os.Getenv("DEBUGDUMP")) > 0}),
+		chaos.Inject,
+	)
+	mux.Handle("/v1/", v1(muxLogged))
 
-	// log.Fata
+	//redirect old paths to their new names; set
+	//REDIRECTSFILE to load the redirects from a JSON file
+	rm := &httpmw.RedirectMap{
+		Exact: map[string]string{"/v1/hello1": "/v1/greeting"},
+	}
+	if redirectsFile := os.Getenv("REDIRECTSFILE"); len(redirectsFile) > 0 {
+		var err error
+		if rm, err = httpmw.LoadRedirectMap(redirectsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	redirects, err := httpmw.Redirects(rm)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	logger := log.New(os.Stout, "", log.LstdFlags)
-	mux.Handle("/v1/", logRequests(logger)(muxLogged))
+	//get the server timeouts, which can be overridden
+	//using environment variables like WRITETIMEOUT=30s
+	timeouts, err := httpmw.TimeoutsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Printf("listening at %s...\n", addr)
-	// log.Fatal(http.ListenAndServe(addr, nil))
-	log.Fatal(http.ListenAndServe(addr, mux))
+	//redirect /v1/greeting/ to /v1/greeting
+	slashes := httpmw.TrimTrailingSlash(&httpmw.SlashConfig{Subtrees: []string{"/v1/"}})
+	srv := httpmw.NewServer(addr, httpmw.Chain(mux, slashes, redirects), timeouts)
+	log.Fatal(srv.ListenAndServe())
 }
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//attachmentFileField is the name of the form
+//field holding the file to attach
+const attachmentFileField = "file"
+
+//attachmentTypes are the types of files that can be attached
+//to tasks. Others, like HTML, could be used to serve pages
+//that run scripts as if they came from the server.
+var attachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+//attachmentTooLargeError is returned by an attachmentReader
+//when the file is longer than `max` bytes
+type attachmentTooLargeError struct {
+	max int64
+}
+
+func (e *attachmentTooLargeError) Error() string {
+	return fmt.Sprintf("attachments must be at most %d bytes", e.max)
+}
+
+//attachmentReader reads the bytes of an attached file, returning
+//an attachmentTooLargeError once it's read more than `max` of
+//them, so that the store stops storing it and cleans up
+type attachmentReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (ar *attachmentReader) Read(p []byte) (int, error) {
+	n, err := ar.r.Read(p)
+	ar.read += int64(n)
+	if ar.read > ar.max {
+		return 0, &attachmentTooLargeError{max: ar.max}
+	}
+	return n, err
+}
+
+//handleAttachments handles requests for the /v1/tasks/some-task-id/attachments
+//sub-resource: POST attaches the file in the "file" field of a multipart/form-data
+//form to the end of the task, and responds with the updated task. The file's type
+//is the Content-Type of that part of the form, and must be one of attachmentTypes.
+func (ctx *Context) handleAttachments(w http.ResponseWriter, r *http.Request, idHex string) {
+	if !checkMethod(w, r, allowAttachments) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+	if !ctx.checkFormData(w, r, "attachment") {
+		return
+	}
+	if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+		ctx.respondStoreError(w, r, err, id, "updating")
+		return
+	}
+
+	//the file is streamed to the store as it's uploaded, and
+	//the rest of the form is limited like any other body
+	r.Body = http.MaxBytesReader(w, r.Body, ctx.MaxAttachmentBytes+ctx.MaxBodyBytes)
+	file, err := formFile(r, attachmentFileField, "the file to attach")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.respondAttachmentError(w, r, err, id, "")
+			return
+		}
+		ctx.logf(r, "rejected attachment: %v", err)
+		respondError(w, http.StatusBadRequest, codeInvalidUpload, "error attaching file: "+err.Error())
+		return
+	}
+	ctype, _, err := mime.ParseMediaType(file.Header.Get(headerContentType))
+	if err != nil || !attachmentTypes[ctype] {
+		ctx.logf(r, "rejected attachment with Content-Type %q", file.Header.Get(headerContentType))
+		respondError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType,
+			"attachments must be one of "+strings.Join(sortedKeys(attachmentTypes), ", ")+", but got "+strconv.Quote(file.Header.Get(headerContentType)))
+		return
+	}
+	newatt := &tasks.NewAttachment{Filename: file.FileName(), ContentType: ctype}
+	if err := newatt.Validate(); err != nil {
+		ctx.logf(r, "rejected invalid attachment for task %s: %v", id.Hex(), err)
+		respondInvalid(w, "error validating attachment", err)
+		return
+	}
+
+	task, err := ctx.TasksStore.AddAttachment(id, newatt, &attachmentReader{r: file, max: ctx.MaxAttachmentBytes})
+	if err != nil {
+		ctx.respondAttachmentError(w, r, err, id, "")
+		return
+	}
+	//it's always added to the end
+	added := task.Attachments[len(task.Attachments)-1]
+	w.Header().Set(headerLocation, specificTaskPrefix(r.URL.Path)+id.Hex()+"/"+subAttachments+"/"+added.ID)
+	w.Header().Set(headerETag, taskETag(task))
+	respondTasks(w, r, http.StatusCreated, task)
+	ctx.notify(EventUpdated, task)
+}
+
+//handleAttachment handles requests for the /v1/tasks/some-task-id/attachments/some-attachment-id
+//sub-resource: GET responds with the file's bytes, and DELETE removes it,
+//responding with the updated task
+func (ctx *Context) handleAttachment(w http.ResponseWriter, r *http.Request, idHex string, attID string) {
+	if len(attID) == 0 || strings.Contains(attID, "/") {
+		respondError(w, http.StatusNotFound, codeNotFound, "no such attachment resource "+attID)
+		return
+	}
+	if !checkMethod(w, r, allowAttachment) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+	//attachment IDs are ObjectIds too, so
+	//anything else can't be one of them
+	if !bson.IsObjectIdHex(attID) {
+		ctx.respondAttachmentError(w, r, tasks.ErrAttachmentNotFound, id, attID)
+		return
+	}
+	if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+		ctx.respondStoreError(w, r, err, id, "getting")
+		return
+	}
+
+	if r.Method == "DELETE" {
+		task, err := ctx.TasksStore.DeleteAttachment(id, attID)
+		if err != nil {
+			ctx.respondAttachmentError(w, r, err, id, attID)
+			return
+		}
+		w.Header().Set(headerETag, taskETag(task))
+		respondTasks(w, r, http.StatusOK, task)
+		ctx.notify(EventUpdated, task)
+		return
+	}
+
+	att, content, err := ctx.TasksStore.OpenAttachment(id, attID)
+	if err != nil {
+		ctx.respondAttachmentError(w, r, err, id, attID)
+		return
+	}
+	defer content.Close()
+	w.Header().Set(headerContentType, att.ContentType)
+	w.Header().Set(headerContentLength, strconv.FormatInt(att.Size, 10))
+	w.Header().Set(headerContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": att.Filename}))
+	//browsers must use the type we checked,
+	//not guess a more dangerous one
+	w.Header().Set(headerNoSniff, "nosniff")
+	w.WriteHeader(http.StatusOK)
+	//it's too late for an error response if this fails
+	if _, err := io.Copy(w, content); err != nil {
+		ctx.logf(r, "error sending attachment %s of task %s: %v", attID, id.Hex(), err)
+	}
+}
+
+//respondAttachmentError responds to a request for the attachment
+//with ID `attID` of the task with ID `id`, or to add an attachment
+//if `attID` is empty, that failed with `err`
+func (ctx *Context) respondAttachmentError(w http.ResponseWriter, r *http.Request, err error, id bson.ObjectId, attID string) {
+	var tooLarge *attachmentTooLargeError
+	var bodyTooLarge *http.MaxBytesError
+	switch {
+	case err == tasks.ErrAttachmentNotFound:
+		respondError(w, http.StatusNotFound, codeNotFound, "task "+id.Hex()+" has no attachment with ID "+attID)
+	case err == tasks.ErrTooManyAttachments:
+		respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+			Code:    codeValidationFailed,
+			Message: "task " + id.Hex() + " already has the most attachments a task can have",
+			Status:  http.StatusBadRequest,
+			Fields: []*tasks.FieldError{{
+				Field:   "attachments",
+				Code:    tasks.CodeTooMany,
+				Message: "tasks can have at most " + strconv.Itoa(tasks.MaxAttachments) + " attachments",
+			}},
+		}})
+	case errors.As(err, &tooLarge):
+		ctx.logf(r, "rejected attachment: %v", err)
+		respondError(w, http.StatusBadRequest, codeBodyTooLarge, err.Error())
+	case errors.As(err, &bodyTooLarge):
+		ctx.logf(r, "rejected attachment: %v", err)
+		respondError(w, http.StatusBadRequest, codeBodyTooLarge, (&attachmentTooLargeError{max: ctx.MaxAttachmentBytes}).Error())
+	default:
+		ctx.respondStoreError(w, r, err, id, "updating the attachments of")
+	}
+}
+
+//sortedKeys returns the keys of `m` in order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
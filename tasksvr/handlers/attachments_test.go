@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//newAttachmentRequest returns a POST to `path` as `user`, uploading
+//`content` as a file named `filename` of type `ctype`
+func newAttachmentRequest(t *testing.T, user string, path string, filename string, ctype string, content string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="`+attachmentFileField+`"; filename="`+filename+`"`)
+	header.Set(headerContentType, ctype)
+	fw, err := mw.CreatePart(header)
+	if err != nil {
+		t.Fatalf("error creating form: %v", err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	r := httptest.NewRequest("POST", path, body)
+	r.Header.Set(headerContentType, mw.FormDataContentType())
+	r.Header.Set(headerUser, user)
+	return r
+}
+
+func TestAttachments(t *testing.T) {
+	ctx, seeded := newTestContext(t, "File taxes")
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/attachments"
+
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, newAttachmentRequest(t, "", path, "w2.txt", "text/plain", "hello"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	task := &tasks.Task{}
+	if err := json.Unmarshal(w.Body.Bytes(), task); err != nil || len(task.Attachments) != 1 {
+		t.Fatalf("expected a task with one attachment, got %s, %v", w.Body.String(), err)
+	}
+	att := task.Attachments[0]
+	if att.Filename != "w2.txt" || att.ContentType != "text/plain" || att.Size != 5 {
+		t.Errorf("unexpected attachment %+v", att)
+	}
+	location := w.Header().Get(headerLocation)
+	if location != path+"/"+att.ID {
+		t.Errorf("expected Location %s but got %s", path+"/"+att.ID, location)
+	}
+	if etag := w.Header().Get(headerETag); etag != taskETag(task) {
+		t.Errorf("expected ETag %s but got %s", taskETag(task), etag)
+	}
+
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", location, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("expected the file's bytes but got %d: %s", w.Code, w.Body.String())
+	}
+	expectedHeaders := map[string]string{
+		headerContentType:        "text/plain",
+		headerContentLength:      "5",
+		headerContentDisposition: `attachment; filename=w2.txt`,
+		headerNoSniff:            "nosniff",
+	}
+	for name, expected := range expectedHeaders {
+		if got := w.Header().Get(name); got != expected {
+			t.Errorf("expected %s %q but got %q", name, expected, got)
+		}
+	}
+
+	//other users can't see the task at all
+	if w, _ := doSubtaskRequest(t, ctx, "mallory", "DELETE", location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for another user but got %d", http.StatusNotFound, w.Code)
+	}
+
+	w, task = doSubtaskRequest(t, ctx, "", "DELETE", location, "")
+	if w.Code != http.StatusOK || len(task.Attachments) != 0 {
+		t.Errorf("expected no attachments after deleting it, got %d %s", w.Code, w.Body.String())
+	}
+	for _, target := range []string{location, path + "/nope"} {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected status %d but got %d", target, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestAttachmentsRejected(t *testing.T) {
+	ctx, seeded := newTestContext(t, "File taxes")
+	ctx.MaxAttachmentBytes = 16
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/attachments"
+
+	notForm := httptest.NewRequest("POST", path, strings.NewReader(`{"file": "hello"}`))
+	notForm.Header.Set(headerContentType, contentTypeJSON)
+	form := &bytes.Buffer{}
+	mw := multipart.NewWriter(form)
+	mw.WriteField("note", "no file here")
+	mw.Close()
+	noFile := httptest.NewRequest("POST", path, form)
+	noFile.Header.Set(headerContentType, mw.FormDataContentType())
+
+	requests := []struct {
+		name           string
+		r              *http.Request
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"too large", newAttachmentRequest(t, "", path, "big.txt", "text/plain", strings.Repeat("a", 17)), http.StatusBadRequest, codeBodyTooLarge},
+		{"HTML", newAttachmentRequest(t, "", path, "page.html", "text/html", "<script></script>"), http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"not a form", notForm, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"no file", noFile, http.StatusBadRequest, codeInvalidUpload},
+		{"other user", newAttachmentRequest(t, "mallory", path, "w2.txt", "text/plain", "hello"), http.StatusNotFound, codeNotFound},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, req.r)
+		if w.Code != req.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", req.name, req.expectedStatus, w.Code, w.Body.String())
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != req.expectedCode {
+			t.Errorf("%s: expected code %s but got %s", req.name, req.expectedCode, apierr.Code)
+		}
+	}
+	if task, _ := ctx.TasksStore.Get(seeded[0].ID); len(task.Attachments) != 0 {
+		t.Errorf("expected nothing to be attached, got %d attachments", len(task.Attachments))
+	}
+}
+
+func TestAttachmentsLimit(t *testing.T) {
+	ctx, seeded := newTestContext(t, "File taxes")
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/attachments"
+	for i := 0; i < tasks.MaxAttachments; i++ {
+		ctx.TasksStore.AddAttachment(seeded[0].ID, &tasks.NewAttachment{Filename: "w2.txt", ContentType: "text/plain"}, strings.NewReader("hello"))
+	}
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, newAttachmentRequest(t, "", path, "1099.txt", "text/plain", "hello"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	apierr := decodeError(t, w)
+	if apierr.Code != codeValidationFailed || len(apierr.Fields) != 1 || apierr.Fields[0].Field != "attachments" {
+		t.Errorf("expected a validation error for the attachments field, got %+v", apierr)
+	}
+}
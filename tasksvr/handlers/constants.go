@@ -1,11 +1,157 @@
 package handlers
 
+import "time"
+
 const (
+	headerAllow       = "Allow"
 	headerContentType = "Content-Type"
+	headerLocation    = "Location"
+	headerTotalCount  = "X-Total-Count"
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerUser        = "X-User"
+
+	headerContentDisposition = "Content-Disposition"
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerLastModified       = "Last-Modified"
+	headerIfModifiedSince    = "If-Modified-Since"
+	headerLastEventID        = "Last-Event-ID"
+	headerCacheControl       = "Cache-Control"
+	headerContentLength      = "Content-Length"
+	headerNoSniff            = "X-Content-Type-Options"
+)
+
+//the methods supported by each resource, for the Allow header
+const (
+	allowTasks        = "GET, POST, PATCH, DELETE, OPTIONS"
+	allowSpecificTask = "GET, PUT, PATCH, DELETE, OPTIONS"
+	allowTaskComplete = "POST, DELETE, OPTIONS"
+	allowTaskRestore  = "POST, OPTIONS"
+	allowTaskReminded = "POST, OPTIONS"
+	allowTaskStats    = "GET, OPTIONS"
+	allowGroupedTasks = "GET, OPTIONS"
+	allowCompleted    = "GET, OPTIONS"
+	allowDueSoon      = "GET, OPTIONS"
+	allowTrash        = "GET, DELETE, OPTIONS"
+	allowTaskExport   = "GET, OPTIONS"
+	allowTaskImport   = "POST, OPTIONS"
+	allowWebSocket    = "GET, OPTIONS"
+	allowTaskEvents   = "GET, OPTIONS"
+	allowHealth       = "GET, OPTIONS"
+	allowSubtasks     = "POST, OPTIONS"
+	allowSubtask      = "PATCH, DELETE, OPTIONS"
+	allowAttachments  = "POST, OPTIONS"
+	allowAttachment   = "GET, DELETE, OPTIONS"
+	allowLabels       = "GET, POST, OPTIONS"
+	allowLabel        = "GET, PATCH, DELETE, OPTIONS"
+)
+
+//specificTaskPath is the path prefix
+//of the /v1/tasks/some-task-id resource,
+//and specificTaskPathV2 of its /v2 version
+const (
+	specificTaskPath   = "/v1/tasks/"
+	specificTaskPathV2 = "/v2/tasks/"
+)
+
+//labelsPath is the path of the /v1/labels resource, and
+//specificLabelPath the prefix of each /v1/labels/some-label-id
+const (
+	labelsPath        = "/v1/labels"
+	specificLabelPath = labelsPath + "/"
+)
+
+//subComplete is the sub-resource of a task
+//that marks it complete or incomplete
+const subComplete = "complete"
+
+//subRestore is the sub-resource of a
+//deleted task that restores it
+const subRestore = "restore"
+
+//subReminded is the sub-resource of a task that
+//records that its owner was reminded it's due soon
+const subReminded = "reminded"
+
+//subSubtasks is the sub-resource of a task that holds its
+//checklist, with a sub-resource of its own for each subtask
+const subSubtasks = "subtasks"
+
+//subAttachments is the sub-resource of a task that files are
+//attached to, with a sub-resource of its own for each file
+const subAttachments = "attachments"
+
+//the number of tasks returned by GET /v1/tasks when the
+//client doesn't ask for a ?limit=, and the most it can ask for
+const (
+	defaultTasksLimit = 20
+	maxTasksLimit     = 100
+)
+
+//how far ahead GET /v1/tasks/due-soon looks when the client
+//doesn't ask for a ?within=, and the furthest it can ask for
+const (
+	defaultDueSoonWithin = 24 * time.Hour
+	maxDueSoonWithin     = 7 * 24 * time.Hour
 )
 
+//defaultMaxBodyBytes is the default for Context.MaxBodyBytes,
+//which is plenty for a full batch of tasks
+const defaultMaxBodyBytes = 1 << 20
+
+//defaultMaxAttachmentBytes is the default
+//for Context.MaxAttachmentBytes
+const defaultMaxAttachmentBytes = 5 << 20
+
+//defaultDuplicateWindow is the default for Context.DuplicateWindow,
+//which is long enough to catch a double-click, or a form
+//submitted again, but not a task that's really done twice
+const defaultDuplicateWindow = 30 * time.Second
+
+//maxBatchSize is the most tasks that can
+//be created with one POST to /v1/tasks
+const maxBatchSize = 100
+
+//maxIDsParam is the most tasks that can be
+//fetched with one GET /v1/tasks?ids=
+const maxIDsParam = 50
+
+//maxSearchLength is the longest ?q= that GET /v1/tasks accepts
+const maxSearchLength = 100
+
+//exportPageSize is how many tasks GET /v1/tasks/export
+//gets from the store at a time, and writes before flushing
+const exportPageSize = maxTasksLimit
+
+//maxImportRows is the most tasks that can be imported
+//with one POST to /v1/tasks/import; the file's size is
+//limited by Context.MaxBodyBytes, like any other body
+const maxImportRows = 1000
+
+//maxPendingEvents is how many events a WebSocket or SSE client
+//can fall behind by before it's disconnected, and eventWriteTimeout
+//is how long it has to accept each one. The Notifier remembers the
+//last maxReplayEvents events, so that SSE clients that reconnect
+//with a Last-Event-ID can catch up on what they missed.
+const (
+	maxPendingEvents  = 16
+	maxReplayEvents   = 64
+	eventWriteTimeout = 10 * time.Second
+)
+
+//healthTimeout is how long GET /v1/health waits for the
+//store to answer, which must be well within the timeout
+//of whatever is checking the server's health
+const healthTimeout = 2 * time.Second
+
 const (
 	charsetUTF8         = "charset=utf-8"
 	contentTypeJSON     = "application/json"
 	contentTypeJSONUTF8 = contentTypeJSON + "; " + charsetUTF8
+	contentTypeCSV      = "text/csv; " + charsetUTF8
+	contentTypeNDJSON   = "application/x-ndjson"
+	contentTypeFormData = "multipart/form-data"
+	contentTypeEvents   = "text/event-stream"
 )
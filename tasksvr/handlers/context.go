@@ -1,9 +1,89 @@
 package handlers
 
-import "github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
 
 //Context holds all the shared values that
 //multiple HTTP Handlers will need
 type Context struct {
 	TasksStore tasks.Store
+	//Logger is where handlers log errors
+	//that the client can't fix
+	Logger *log.Logger
+	//RequireIfMatch makes requests that change a task send
+	//its ETag in an If-Match header, so that they can't
+	//overwrite changes they haven't seen. When it's false,
+	//requests without If-Match change the task regardless.
+	//Batch updates are refused when it's true, as they
+	//can't send an ETag for each task.
+	RequireIfMatch bool
+	//RequireContentType makes requests with a body say that
+	//it's JSON in the Content-Type header. When it's false,
+	//requests without one are assumed to be JSON, which is
+	//friendlier to tools like curl.
+	RequireContentType bool
+	//AutoCompleteTasks marks a task complete when all of its
+	//subtasks are, so that ticking off the last item in the
+	//checklist finishes the task
+	AutoCompleteTasks bool
+	//Admins can see everyone's tasks, using ?all=true, and
+	//delete them for good, using ?permanent=true, once
+	//they're authenticated as one of these principals
+	Admins []string
+	//Notifier tells WebSocket clients about changes to tasks
+	Notifier *Notifier
+	//MaxBodyBytes is the largest request body
+	//that handlers will read
+	MaxBodyBytes int64
+	//MaxAttachmentBytes is the largest file that
+	//can be attached to a task
+	MaxAttachmentBytes int64
+	//DuplicateWindow is how long after a task is created that
+	//creating another with the same title is refused as a
+	//duplicate, unless the client asks for ?force=true, which
+	//catches double-clicks; zero allows duplicates at any time
+	DuplicateWindow time.Duration
+	//Quota, if set, limits how many tasks each client
+	//can create; reading tasks is never limited
+	Quota *WriteQuota
+	//purgedAt is when tasks were last permanently deleted,
+	//in Unix nanoseconds; it's accessed atomically
+	purgedAt int64
+	//now returns the current time; tests replace it
+	now func() time.Time
+}
+
+//NewContext creates a new Context, returning an error if
+//the store or logger are nil, so that a misconfigured
+//server fails when it starts rather than on a request
+func NewContext(store tasks.Store, logger *log.Logger) (*Context, error) {
+	if store == nil {
+		return nil, errors.New("handlers: the tasks store is nil")
+	}
+	if logger == nil {
+		return nil, errors.New("handlers: the logger is nil")
+	}
+	return &Context{
+		TasksStore:         store,
+		Logger:             logger,
+		Notifier:           NewNotifier(),
+		MaxBodyBytes:       defaultMaxBodyBytes,
+		MaxAttachmentBytes: defaultMaxAttachmentBytes,
+		DuplicateWindow:    defaultDuplicateWindow,
+		now:                time.Now,
+	}, nil
+}
+
+//logf logs a message about the request, prefixed with its
+//method and path. Never log request bodies, which can be
+//large, and may contain things users wouldn't want logged.
+func (ctx *Context) logf(r *http.Request, format string, v ...interface{}) {
+	ctx.Logger.Printf("%s %s: %s", r.Method, r.URL.Path, fmt.Sprintf(format, v...))
 }
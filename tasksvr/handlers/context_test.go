@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNewContext(t *testing.T) {
+	logger := log.New(ioutil.Discard, "", 0)
+	if _, err := NewContext(nil, logger); err == nil {
+		t.Errorf("expected an error for a nil store")
+	}
+	if _, err := NewContext(tasks.NewMemStore(), nil); err == nil {
+		t.Errorf("expected an error for a nil logger")
+	}
+	if ctx, err := NewContext(tasks.NewMemStore(), logger); err != nil || ctx.Logger != logger {
+		t.Errorf("unexpected context %+v, %v", ctx, err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	id := bson.NewObjectId().Hex()
+	cases := []struct {
+		name     string
+		store    tasks.Store
+		method   string
+		path     string
+		body     string
+		expected string
+	}{
+		{"list failure", failingStore{}, "GET", "/v1/tasks",
+			"", "GET /v1/tasks: error getting tasks: " + errStore.Error()},
+		{"update failure", failingStore{}, "PATCH", "/v1/tasks/" + id,
+			`{"complete": true}`, "PATCH /v1/tasks/" + id + ": error updating task " + id + ": " + errStore.Error()},
+		{"delete failure", failingStore{}, "DELETE", "/v1/tasks/" + id,
+			"", "DELETE /v1/tasks/" + id + ": error deleting task " + id + ": " + errStore.Error()},
+		{"invalid task", tasks.NewMemStore(), "POST", "/v1/tasks",
+			`{"title": "", "tags": ["s3cret"]}`, "POST /v1/tasks: rejected invalid task: title must be something"},
+		{"invalid JSON", tasks.NewMemStore(), "POST", "/v1/tasks",
+			`{"title": "s3cret"`, "POST /v1/tasks: rejected invalid JSON"},
+		{"invalid batch", tasks.NewMemStore(), "POST", "/v1/tasks",
+			`[{"title": "s3cret"}, {"title": ""}]`, "POST /v1/tasks: rejected batch with invalid tasks at indices 1"},
+		{"invalid updates", failingStore{}, "PATCH", "/v1/tasks/" + id,
+			`{"title": ""}`, "PATCH /v1/tasks/" + id + ": rejected invalid updates for task " + id},
+	}
+	for _, c := range cases {
+		ctx, logs := newLoggedContext(t, c.store)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if c.path == "/v1/tasks" {
+			ctx.HandleTasks(w, r)
+		} else {
+			ctx.HandleSpecificTask(w, r)
+		}
+		if !strings.Contains(logs.String(), c.expected) {
+			t.Errorf("%s: expected the log to contain %q, got %q", c.name, c.expected, logs.String())
+		}
+		//request bodies are never logged
+		if strings.Contains(logs.String(), "s3cret") {
+			t.Errorf("%s: expected the request body not to be logged, got %q", c.name, logs.String())
+		}
+	}
+}
+
+func TestNoLoggingOnSuccess(t *testing.T) {
+	store := tasks.NewMemStore()
+	ctx, logs := newLoggedContext(t, store)
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn Go"}`)))
+	w = httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	//not found is the client's problem, not the server's
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", "/v1/tasks/"+bson.NewObjectId().Hex(), nil))
+	if logs.Len() > 0 {
+		t.Errorf("expected nothing to be logged, got %q", logs.String())
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//bodyTooLargeError is returned by readBody when the
+//request body is longer than `max` bytes
+type bodyTooLargeError struct {
+	max int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body must be at most %d bytes", e.max)
+}
+
+//unsupportedMediaTypeError is returned by readBody when
+//the request body isn't JSON, according to its Content-Type
+type unsupportedMediaTypeError struct {
+	contentType string
+}
+
+func (e *unsupportedMediaTypeError) Error() string {
+	if len(e.contentType) == 0 {
+		return "the Content-Type header is required: set it to " + contentTypeJSON
+	}
+	return "Content-Type must be " + contentTypeJSON + ", but got " + e.contentType
+}
+
+//checkContentType returns an unsupportedMediaTypeError if the
+//request's Content-Type isn't JSON. Parameters like charset are
+//allowed. Requests without one are assumed to be JSON, unless
+//ctx.RequireContentType is set.
+func (ctx *Context) checkContentType(r *http.Request) error {
+	ctype := r.Header.Get(headerContentType)
+	if len(ctype) == 0 {
+		if ctx.RequireContentType {
+			return &unsupportedMediaTypeError{}
+		}
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil || mediaType != contentTypeJSON {
+		return &unsupportedMediaTypeError{contentType: ctype}
+	}
+	return nil
+}
+
+//readBody reads the request body, returning an
+//unsupportedMediaTypeError if it isn't JSON, or a
+//bodyTooLargeError if it's longer than ctx.MaxBodyBytes
+func (ctx *Context) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if err := ctx.checkContentType(r); err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, ctx.MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &bodyTooLargeError{max: ctx.MaxBodyBytes}
+		}
+		return nil, fmt.Errorf("error reading request body: %v", err)
+	}
+	return body, nil
+}
+
+//readJSON reads the request body like readBody,
+//and decodes it into `v` like decodeJSON
+func (ctx *Context) readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := ctx.readBody(w, r)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(body, v)
+}
+
+//decodeJSON decodes `data` into `v`, returning an error if it
+//has fields that `v` doesn't, so that typos like "titel" aren't
+//silently ignored, or if there's anything after the JSON value
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		//the errors for unknown fields name them, like
+		//json: unknown field "titel"
+		return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+//respondBodyError responds to a request whose body
+//couldn't be read or decoded with readBody or readJSON
+func (ctx *Context) respondBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err.(type) {
+	case *unsupportedMediaTypeError:
+		ctx.logf(r, "rejected request body: %v", err)
+		respondError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, err.Error())
+		return
+	case *bodyTooLargeError:
+		ctx.logf(r, "rejected request body: %v", err)
+		respondError(w, http.StatusBadRequest, codeBodyTooLarge, err.Error())
+		return
+	}
+	ctx.logf(r, "rejected invalid JSON: %v", err)
+	respondError(w, http.StatusBadRequest, codeInvalidJSON, "invalid JSON: "+err.Error())
+}
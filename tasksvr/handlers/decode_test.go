@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestStrictJSON(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	ctx.MaxBodyBytes = 100
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+	oversized := `{"title": "` + strings.Repeat("x", 100) + `"}`
+
+	cases := []struct {
+		name            string
+		handler         http.HandlerFunc
+		method          string
+		path            string
+		body            string
+		expectedStatus  int
+		expectedMessage string
+		//the messages for unknown fields name them
+		expectedField string
+	}{
+		{"valid", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn JSON"}`, http.StatusCreated, "", ""},
+		{"unknown field", ctx.HandleTasks, "POST", "/v1/tasks", `{"titel": "Learn JSON"}`, http.StatusBadRequest, "unknown field", "titel"},
+		{"unknown field in a batch", ctx.HandleTasks, "POST", "/v1/tasks", `[{"title": "a"}, {"titel": "b"}]`, http.StatusBadRequest, "unknown field", "titel"},
+		{"concatenated", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "a"}{"title": "b"}`, http.StatusBadRequest, "after the JSON value", ""},
+		{"trailing garbage", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "a"} oops`, http.StatusBadRequest, "after the JSON value", ""},
+		{"oversized", ctx.HandleTasks, "POST", "/v1/tasks", oversized, http.StatusBadRequest, "at most 100 bytes", ""},
+		{"valid PATCH", ctx.HandleSpecificTask, "PATCH", path, `{"complete": true}`, http.StatusOK, "", ""},
+		{"unknown PATCH field", ctx.HandleSpecificTask, "PATCH", path, `{"done": true}`, http.StatusBadRequest, "unknown field", "done"},
+		{"concatenated PATCH", ctx.HandleSpecificTask, "PATCH", path, `{"complete": true} {"complete": false}`, http.StatusBadRequest, "after the JSON value", ""},
+		{"oversized PATCH", ctx.HandleSpecificTask, "PATCH", path, oversized, http.StatusBadRequest, "at most 100 bytes", ""},
+		{"unknown PUT field", ctx.HandleSpecificTask, "PUT", path, `{"title": "a", "owner": "bob"}`, http.StatusBadRequest, "unknown field", "owner"},
+		{"oversized PUT", ctx.HandleSpecificTask, "PUT", path, oversized, http.StatusBadRequest, "at most 100 bytes", ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.handler(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", c.name, c.expectedStatus, w.Code, w.Body.String())
+		}
+		for _, expected := range []string{c.expectedMessage, c.expectedField} {
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("%s: expected the response to contain %q, got %s", c.name, expected, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	cases := []struct {
+		name           string
+		contentType    string
+		require        bool
+		expectedStatus int
+	}{
+		{"JSON", "application/json", false, http.StatusOK},
+		{"JSON with a charset", "application/json; charset=utf-8", false, http.StatusOK},
+		{"JSON in capitals", "Application/JSON", false, http.StatusOK},
+		{"plain text", "text/plain", false, http.StatusUnsupportedMediaType},
+		{"form", "application/x-www-form-urlencoded", false, http.StatusUnsupportedMediaType},
+		{"multipart", "multipart/form-data; boundary=xyz", false, http.StatusUnsupportedMediaType},
+		{"malformed", "application/json; charset", false, http.StatusUnsupportedMediaType},
+		{"missing", "", false, http.StatusOK},
+		{"missing when required", "", true, http.StatusUnsupportedMediaType},
+		{"JSON when required", "application/json", true, http.StatusOK},
+	}
+	for _, c := range cases {
+		ctx.RequireContentType = c.require
+		//POST, PATCH and PUT all check it
+		requests := []struct {
+			handler        http.HandlerFunc
+			method         string
+			path           string
+			body           string
+			expectedStatus int
+		}{
+			//the same task is posted for each case
+			{ctx.HandleTasks, "POST", "/v1/tasks?force=true", `{"title": "Learn Go"}`, http.StatusCreated},
+			{ctx.HandleSpecificTask, "PATCH", path, `{"complete": true}`, http.StatusOK},
+			{ctx.HandleSpecificTask, "PUT", path, `{"title": "Learn Go"}`, http.StatusOK},
+		}
+		for _, req := range requests {
+			expectedStatus := c.expectedStatus
+			if expectedStatus == http.StatusOK {
+				expectedStatus = req.expectedStatus
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+			if len(c.contentType) > 0 {
+				r.Header.Set(headerContentType, c.contentType)
+			}
+			req.handler(w, r)
+			if w.Code != expectedStatus {
+				t.Errorf("%s %s: expected status %d but got %d: %s", c.name, req.method, expectedStatus, w.Code, w.Body.String())
+			}
+			if expectedStatus == http.StatusUnsupportedMediaType {
+				if apierr := decodeError(t, w); apierr.Code != codeUnsupportedMediaType {
+					t.Errorf("%s %s: expected code %s but got %s", c.name, req.method, codeUnsupportedMediaType, apierr.Code)
+				}
+			}
+		}
+	}
+}
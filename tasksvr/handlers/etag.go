@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//taskETag returns the ETag for the current version of `t`
+func taskETag(t *tasks.Task) string {
+	return `"` + strconv.Itoa(t.Version) + `"`
+}
+
+//ifMatch returns the task version in the request's If-Match
+//header, or nil if changes shouldn't be checked against one,
+//because the header is missing or is "*". It answers the
+//request and returns false if the header is required but
+//missing, or if it isn't an ETag this server would send,
+//as that can never match.
+func (ctx *Context) ifMatch(w http.ResponseWriter, r *http.Request) (*int, bool) {
+	etag := strings.TrimSpace(r.Header.Get(headerIfMatch))
+	switch etag {
+	case "":
+		if ctx.RequireIfMatch {
+			respondError(w, http.StatusPreconditionRequired, codePreconditionRequired, "the If-Match header is required: set it to the task's ETag")
+			return nil, false
+		}
+		return nil, true
+	case "*":
+		return nil, true
+	}
+	version, err := strconv.Atoi(strings.Trim(etag, `"`))
+	if err != nil || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		respondError(w, http.StatusPreconditionFailed, codePreconditionFailed, "If-Match "+etag+" doesn't match the task's ETag")
+		return nil, false
+	}
+	return &version, true
+}
+
+//updateTask applies the updates to the task with the given ID,
+//only if it's still at `version`, unless that's nil
+func (ctx *Context) updateTask(id bson.ObjectId, version *int, updates *tasks.TaskUpdates) (*tasks.Task, error) {
+	if version == nil {
+		return ctx.TasksStore.Update(id, updates)
+	}
+	return ctx.TasksStore.UpdateIfVersion(id, *version, updates)
+}
+
+//deleteTask deletes the task with the given ID,
+//only if it's still at `version`, unless that's nil
+func (ctx *Context) deleteTask(id bson.ObjectId, version *int) error {
+	if version == nil {
+		return ctx.TasksStore.Delete(id)
+	}
+	return ctx.TasksStore.DeleteIfVersion(id, *version)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestLostUpdate(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	//two tabs get the task
+	etags := []string{}
+	for i := 0; i < 2; i++ {
+		w := doWith(ctx.HandleSpecificTask, "GET", path, "", nil)
+		etags = append(etags, w.Header().Get(headerETag))
+	}
+	if etags[0] != `"1"` || etags[1] != `"1"` {
+		t.Fatalf("expected both tabs to get ETag \"1\", got %v", etags)
+	}
+
+	//the first tab saves its change
+	w := doWith(ctx.HandleSpecificTask, "PATCH", path, `{"title": "Learn Go well"}`, map[string]string{headerIfMatch: etags[0]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get(headerETag); etag != `"2"` {
+		t.Errorf("expected the new ETag \"2\" but got %q", etag)
+	}
+
+	//the second tab's change would overwrite it, so it fails
+	w = doWith(ctx.HandleSpecificTask, "PATCH", path, `{"title": "Learn Go fast"}`, map[string]string{headerIfMatch: etags[1]})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusPreconditionFailed, w.Code, w.Body.String())
+	}
+	decodeError(t, w)
+	if task, _ := ctx.TasksStore.Get(seeded[0].ID); task.Title != "Learn Go well" || task.Version != 2 {
+		t.Errorf("expected the first tab's change to survive, got %+v", task)
+	}
+
+	//and so does deleting it
+	w = doWith(ctx.HandleSpecificTask, "DELETE", path, "", map[string]string{headerIfMatch: etags[1]})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d but got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	if _, err := ctx.TasksStore.Get(seeded[0].ID); err != nil {
+		t.Errorf("expected the task to still exist, got %v", err)
+	}
+
+	//until it gets the task again
+	w = doWith(ctx.HandleSpecificTask, "GET", path, "", nil)
+	w = doWith(ctx.HandleSpecificTask, "DELETE", path, "", map[string]string{headerIfMatch: w.Header().Get(headerETag)})
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d but got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	id := seeded[0].ID.(bson.ObjectId).Hex()
+	path := "/v1/tasks/" + id
+	body := `{"complete": true}`
+
+	cases := []struct {
+		name    string
+		require bool
+		path    string
+		etag    string
+		status  int
+	}{
+		{"not required or sent", false, path, "", http.StatusOK},
+		{"required but not sent", true, path, "", http.StatusPreconditionRequired},
+		{"any version", true, path, "*", http.StatusOK},
+		{"current version", true, path, `"3"`, http.StatusOK},
+		{"not quoted", true, path, "4", http.StatusPreconditionFailed},
+		{"weak", true, path, `W/"4"`, http.StatusPreconditionFailed},
+		{"not ours", true, path, `"abc"`, http.StatusPreconditionFailed},
+		{"complete sub-resource", true, path + "/complete", `"4"`, http.StatusOK},
+		{"stale complete sub-resource", true, path + "/complete", `"4"`, http.StatusPreconditionFailed},
+		{"no such task", true, "/v1/tasks/" + bson.NewObjectId().Hex(), `"1"`, http.StatusNotFound},
+	}
+	method := func(path string) string {
+		if strings.HasSuffix(path, "/complete") {
+			return "POST"
+		}
+		return "PATCH"
+	}
+	for _, c := range cases {
+		ctx.RequireIfMatch = c.require
+		w := doWith(ctx.HandleSpecificTask, method(c.path), c.path, body, map[string]string{headerIfMatch: c.etag})
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d but got %d: %s", c.name, c.status, w.Code, w.Body.String())
+		}
+		if w.Code != http.StatusOK {
+			decodeError(t, w)
+			continue
+		}
+		task := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+			t.Fatalf("%s: error decoding task: %v", c.name, err)
+		}
+		if etag := w.Header().Get(headerETag); etag != taskETag(task) {
+			t.Errorf("%s: expected ETag %s but got %s", c.name, taskETag(task), etag)
+		}
+	}
+
+	ctx.RequireIfMatch = true
+	if w := doWith(ctx.HandleSpecificTask, "DELETE", path, "", nil); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("expected status %d but got %d", http.StatusPreconditionRequired, w.Code)
+	}
+	//reading doesn't need If-Match
+	if w := doWith(ctx.HandleSpecificTask, "GET", path, "", nil); w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//eventKeepAlive is how often an idle event stream is sent
+//a comment, so that proxies don't close it for being quiet;
+//it's a variable so that the tests needn't wait that long
+var eventKeepAlive = 15 * time.Second
+
+//HandleTaskEvents will handle requests for the /v1/tasks/events
+//resource, streaming the same Events as HandleWebSocket, but as
+//server-sent events, which browsers can read with an EventSource.
+//Each is sent with its number as its id, so a client that reconnects
+//with a Last-Event-ID header is first sent the recent events it missed.
+func (ctx *Context) HandleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTaskEvents) {
+		return
+	}
+	//every event has to be flushed as soon as it's
+	//written, so any middleware must pass Flush through
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, codeInternalError, "streaming isn't supported")
+		return
+	}
+
+	//an ID we never sent can't be replayed
+	//from, so it's treated like no ID at all
+	var l *listener
+	owner := ctx.owner(r)
+	if after, err := strconv.ParseUint(r.Header.Get(headerLastEventID), 10, 64); err == nil {
+		l, ok = ctx.Notifier.listenAfter(owner, after)
+	} else {
+		l, ok = ctx.Notifier.listen(owner)
+	}
+	if !ok {
+		respondError(w, http.StatusServiceUnavailable, codeUnavailable, "the server is shutting down")
+		return
+	}
+	defer ctx.Notifier.unlisten(l)
+
+	w.Header().Set(headerContentType, contentTypeEvents)
+	w.Header().Set(headerCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case e, open := <-l.send:
+			if !open {
+				//the Notifier is closing, or we fell too far
+				//behind; either way the client can reconnect
+				return
+			}
+			//the JSON has no newlines, so it fits on one data line
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.eventType, e.data)
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			//the client has gone away
+			return
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//sseEvent is one event read from an event stream;
+//keep-alive comments have only a comment
+type sseEvent struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+//openEvents starts streaming the /v1/tasks/events handler
+//served by `server`, as `user`, sending `lastEventID`
+//as the Last-Event-ID header if it isn't empty
+func openEvents(t *testing.T, server *httptest.Server, user string, lastEventID string) (*http.Response, *bufio.Reader) {
+	r, _ := http.NewRequest("GET", server.URL+"/v1/tasks/events", nil)
+	r.Header.Set(headerUser, user)
+	if len(lastEventID) > 0 {
+		r.Header.Set(headerLastEventID, lastEventID)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(r)
+	if err != nil {
+		t.Fatalf("error opening event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+//readEvent reads the next event from `stream`,
+//which ends at the first blank line
+func readEvent(t *testing.T, stream *bufio.Reader) *sseEvent {
+	e := &sseEvent{}
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			return e
+		}
+		if strings.HasPrefix(line, ":") {
+			e.comment = strings.TrimSpace(line[1:])
+			continue
+		}
+		field := strings.SplitN(line, ": ", 2)
+		if len(field) != 2 {
+			t.Fatalf("malformed line %q", line)
+		}
+		switch field[0] {
+		case "id":
+			e.id = field[1]
+		case "event":
+			e.event = field[1]
+		case "data":
+			e.data = field[1]
+		}
+	}
+}
+
+//readTaskEvent reads the next event from `stream`,
+//checking that it's an Event with the given id and type
+func readTaskEvent(t *testing.T, stream *bufio.Reader, id string, eventType string) *Event {
+	e := readEvent(t, stream)
+	if e.id != id || e.event != eventType {
+		t.Fatalf("expected event %s of type %s but got %+v", id, eventType, e)
+	}
+	decoded := &Event{}
+	if err := json.Unmarshal([]byte(e.data), decoded); err != nil {
+		t.Fatalf("error decoding event data %q: %v", e.data, err)
+	}
+	if decoded.Type != eventType || decoded.Task == nil {
+		t.Fatalf("unexpected event data %+v", decoded)
+	}
+	return decoded
+}
+
+func TestTaskEvents(t *testing.T) {
+	//idle streams get keep-alives almost at once
+	defer func(d time.Duration) { eventKeepAlive = d }(eventKeepAlive)
+	eventKeepAlive = 20 * time.Millisecond
+
+	ctx, _ := newTestContext(t)
+	defer ctx.Notifier.Close()
+	server := httptest.NewServer(http.HandlerFunc(ctx.HandleTaskEvents))
+	defer server.Close()
+
+	resp, stream := openEvents(t, server, "alice", "")
+	defer resp.Body.Close()
+	if ct := resp.Header.Get(headerContentType); ct != contentTypeEvents {
+		t.Errorf("expected content type %s but got %s", contentTypeEvents, ct)
+	}
+	//bob doesn't hear about alice's tasks
+	bobResp, bob := openEvents(t, server, "bob", "")
+	defer bobResp.Body.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn SSE"}`))
+	r.Header.Set(headerUser, "alice")
+	ctx.HandleTasks(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	//skip any keep-alives sent before the event
+	e := readEvent(t, stream)
+	for len(e.comment) > 0 {
+		e = readEvent(t, stream)
+	}
+	if e.id != "1" || e.event != EventCreated || !strings.Contains(e.data, "Learn SSE") {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	//bob only gets keep-alives
+	for i := 0; i < 2; i++ {
+		if e := readEvent(t, bob); e.comment != "keep-alive" || len(e.id) > 0 {
+			t.Errorf("expected a keep-alive comment but got %+v", e)
+		}
+	}
+}
+
+func TestTaskEventsReplay(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	defer ctx.Notifier.Close()
+	server := httptest.NewServer(http.HandlerFunc(ctx.HandleTaskEvents))
+	defer server.Close()
+
+	resp, stream := openEvents(t, server, "", "")
+	defer resp.Body.Close()
+	titles := []string{"Learn Go", "Learn Mongo", "Learn SSE"}
+	for _, title := range titles {
+		task, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: title})
+		ctx.notify(EventCreated, task)
+	}
+	//reading them all means they're remembered
+	for i, title := range titles {
+		if e := readTaskEvent(t, stream, string('1'+rune(i)), EventCreated); e.Task.Title != title {
+			t.Errorf("expected event for %q but got %q", title, e.Task.Title)
+		}
+	}
+
+	//a client that reconnects gets what it missed
+	resp2, replayed := openEvents(t, server, "", "1")
+	defer resp2.Body.Close()
+	readTaskEvent(t, replayed, "2", EventCreated)
+	readTaskEvent(t, replayed, "3", EventCreated)
+
+	//an ID from before a restart gets everything
+	resp3, restarted := openEvents(t, server, "", "999")
+	defer resp3.Body.Close()
+	for i := range titles {
+		readTaskEvent(t, restarted, string('1'+rune(i)), EventCreated)
+	}
+
+	//and then new events as they happen
+	task, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: "Learn more"})
+	ctx.notify(EventUpdated, task)
+	readTaskEvent(t, replayed, "4", EventUpdated)
+	readTaskEvent(t, restarted, "4", EventUpdated)
+}
+
+func TestTaskEventsDisconnect(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	defer ctx.Notifier.Close()
+
+	reqctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/v1/tasks/events", nil).WithContext(reqctx)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		ctx.HandleTaskEvents(w, r)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler didn't return after the client disconnected")
+	}
+	if w.Code != http.StatusOK || w.Header().Get(headerCacheControl) != "no-cache" {
+		t.Errorf("expected an uncached stream, got %d with headers %v", w.Code, w.Header())
+	}
+}
+
+func TestTaskEventsClosed(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.Notifier.Close()
+	w := httptest.NewRecorder()
+	ctx.HandleTaskEvents(w, httptest.NewRequest("GET", "/v1/tasks/events", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d after closing but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//the formats that GET /v1/tasks/export can write
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "json"
+)
+
+//exportColumns is the header row of a CSV export
+var exportColumns = []string{"id", "title", "complete", "tags", "createdAt", "dueDate"}
+
+//taskPages returns a function that gets the tasks matching
+//`q` from the store a page at a time, returning an empty
+//page once there are no more
+func (ctx *Context) taskPages(q *tasks.Query) func() ([]*tasks.Task, error) {
+	q.Skip = 0
+	q.Limit = exportPageSize
+	done := false
+	return func() ([]*tasks.Task, error) {
+		if done {
+			return nil, nil
+		}
+		page, _, err := ctx.TasksStore.Find(q)
+		if err != nil {
+			return nil, err
+		}
+		q.Skip += len(page)
+		done = len(page) < q.Limit
+		return page, nil
+	}
+}
+
+//HandleTaskExport will handle requests for the /v1/tasks/export
+//resource, which downloads all of the caller's tasks, as CSV for
+//spreadsheets, or with ?format=json, as newline-delimited JSON.
+//It accepts the same filters as GET /v1/tasks, like ?tag=school,
+//and admins can export everyone's tasks with ?all=true. The tasks
+//are written a page at a time, so the export is never held in
+//memory all at once.
+func (ctx *Context) HandleTaskExport(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTaskExport) {
+		return
+	}
+	format := r.URL.Query().Get(paramFormat)
+	if len(format) == 0 {
+		format = exportCSV
+	}
+	if format != exportCSV && format != exportNDJSON {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, paramFormat+" must be "+exportCSV+" or "+exportNDJSON+", but got "+strconv.Quote(format))
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+
+	//get the first page before writing anything, so
+	//that if the store is down, the client gets an error
+	next := ctx.taskPages(q)
+	page, err := next()
+	if err != nil {
+		ctx.logf(r, "error exporting tasks: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error exporting tasks: "+err.Error())
+		return
+	}
+
+	filename := "tasks-" + time.Now().UTC().Format("20060102T150405Z")
+	if format == exportCSV {
+		w.Header().Set(headerContentType, contentTypeCSV)
+		filename += ".csv"
+	} else {
+		w.Header().Set(headerContentType, contentTypeNDJSON)
+		filename += ".ndjson"
+	}
+	w.Header().Set(headerContentDisposition, `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	if format == exportCSV {
+		err = ctx.exportCSV(w, page, next)
+	} else {
+		err = ctx.exportNDJSON(w, r, page, next)
+	}
+	//it's too late to send an error response, so the
+	//client just gets a truncated file
+	if err != nil {
+		ctx.logf(r, "error exporting tasks: %v", err)
+	}
+}
+
+//flush sends whatever has been written to the
+//client, if the ResponseWriter supports it
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//exportCSV writes `page`, and the pages after it, as CSV,
+//flushing after each one
+func (ctx *Context) exportCSV(w http.ResponseWriter, page []*tasks.Task, next func() ([]*tasks.Task, error)) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	var err error
+	for ; len(page) > 0; page, err = next() {
+		for _, task := range page {
+			dueDate := ""
+			if task.DueDate != nil {
+				dueDate = task.DueDate.Format(time.RFC3339)
+			}
+			cw.Write([]string{
+				task.ID.(bson.ObjectId).Hex(),
+				task.Title,
+				strconv.FormatBool(task.Complete),
+				strings.Join(task.Tags, ";"),
+				task.CreatedAt.Format(time.RFC3339),
+				dueDate,
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	return err
+}
+
+//exportNDJSON writes `page`, and the pages after it, as one
+//JSON task per line, in the shape of the version of the API
+//that `r` is for, flushing after each page
+func (ctx *Context) exportNDJSON(w http.ResponseWriter, r *http.Request, page []*tasks.Task, next func() ([]*tasks.Task, error)) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var err error
+	for ; len(page) > 0; page, err = next() {
+		for _, task := range page {
+			var v interface{} = task
+			if isV2(r.URL.Path) {
+				v = newTaskV2(task)
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	return err
+}
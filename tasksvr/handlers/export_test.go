@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//pagingStore is a tasks.Store that counts the pages it's asked
+//for, and fails after `failAfter` of them, unless it's negative,
+//or if asked for all the tasks at once
+type pagingStore struct {
+	tasks.Store
+	pages     int
+	failAfter int
+}
+
+func (ps *pagingStore) Find(q *tasks.Query) ([]*tasks.Task, int, error) {
+	ps.pages++
+	if ps.failAfter >= 0 && ps.pages > ps.failAfter {
+		return nil, 0, errStore
+	}
+	return ps.Store.Find(q)
+}
+
+func (ps *pagingStore) GetAll() ([]*tasks.Task, error) {
+	return nil, errors.New("exports must page through the tasks")
+}
+
+//newExportContext returns a Context whose store has `n`
+//tasks, the first of which has an awkward title and tags
+func newExportContext(t *testing.T, n int) (*Context, *pagingStore) {
+	mem := tasks.NewMemStore()
+	mem.Insert(&tasks.NewTask{Title: `Buy "milk", eggs`, Tags: []string{"errand", "food"}})
+	newtasks := []*tasks.NewTask{}
+	for i := 1; i < n; i++ {
+		newtasks = append(newtasks, &tasks.NewTask{Title: "Learn Go"})
+	}
+	mem.InsertMany(newtasks)
+	store := &pagingStore{Store: mem, failAfter: -1}
+	ctx, _ := newLoggedContext(t, store)
+	return ctx, store
+}
+
+func TestExportCSV(t *testing.T) {
+	const n = exportPageSize*2 + 50
+	ctx, store := newExportContext(t, n)
+	w := httptest.NewRecorder()
+	ctx.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export?format=csv&sort=createdAt", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeCSV {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeCSV, ctype)
+	}
+	disposition := w.Header().Get(headerContentDisposition)
+	if !regexp.MustCompile(`^attachment; filename="tasks-\d{8}T\d{6}Z\.csv"$`).MatchString(disposition) {
+		t.Errorf("expected a timestamped attachment but got %q", disposition)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("error parsing CSV: %v", err)
+	}
+	if len(rows) != n+1 {
+		t.Fatalf("expected a header and %d rows but got %d rows", n, len(rows))
+	}
+	if strings.Join(rows[0], ",") != "id,title,complete,tags,createdAt,dueDate" {
+		t.Errorf("unexpected header row %v", rows[0])
+	}
+	//the commas and quotes survive the trip
+	if first := rows[1]; first[1] != `Buy "milk", eggs` || first[2] != "false" || first[3] != "errand;food" || first[5] != "" {
+		t.Errorf("unexpected first row %q", first)
+	}
+	if store.pages != 3 {
+		t.Errorf("expected the tasks to be read in 3 pages but got %d", store.pages)
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	ctx, _ := newExportContext(t, 3)
+	w := httptest.NewRecorder()
+	ctx.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export?format=json&q=milk", nil))
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeNDJSON {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeNDJSON, ctype)
+	}
+	if !strings.HasSuffix(w.Header().Get(headerContentDisposition), `.ndjson"`) {
+		t.Errorf("expected an .ndjson file but got %q", w.Header().Get(headerContentDisposition))
+	}
+	//the filters apply, and there's one task per line
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line but got %d: %s", len(lines), w.Body.String())
+	}
+	task := &tasks.Task{}
+	if err := json.Unmarshal([]byte(lines[0]), task); err != nil || task.Title != `Buy "milk", eggs` {
+		t.Errorf("expected the milk task but got %+v, %v", task, err)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	ctx, store := newExportContext(t, exportPageSize+1)
+	w := httptest.NewRecorder()
+	ctx.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export?format=xlsx", nil))
+	if w.Code != http.StatusBadRequest || decodeError(t, w).Code != codeInvalidQuery {
+		t.Errorf("expected status %d for an unknown format but got %d", http.StatusBadRequest, w.Code)
+	}
+
+	//if the store fails at first, the client gets an error
+	store.failAfter = 0
+	w = httptest.NewRecorder()
+	ctx.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export", nil))
+	if w.Code != http.StatusInternalServerError || decodeError(t, w).Code != codeStoreError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	//but after that, it's too late, so the file is cut short
+	store.pages = 0
+	store.failAfter = 1
+	w = httptest.NewRecorder()
+	ctx.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export", nil))
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || err != nil || len(rows) != exportPageSize+1 {
+		t.Errorf("expected the first page of %d tasks but got %d rows, %v", exportPageSize, len(rows), err)
+	}
+}
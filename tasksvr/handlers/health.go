@@ -0,0 +1,27 @@
+package handlers
+
+import "net/http"
+
+//the values of a healthResponse's fields
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+//HandleHealth will handle requests for the /v1/health resource,
+//which tells load balancers and Kubernetes whether the server can
+//actually serve requests, by checking that the store answers.
+//It responds with a 200 if it can, and a 503 if it can't. Either
+//way, the body is a healthResponse rather than an error, so that
+//it's the same shape whether or not the server is healthy.
+func (ctx *Context) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowHealth) {
+		return
+	}
+	if err := ctx.TasksStore.Ping(healthTimeout); err != nil {
+		ctx.logf(r, "health check failed: %v", err)
+		respond(w, http.StatusServiceUnavailable, &healthResponse{Status: healthUnavailable, Store: err.Error()})
+		return
+	}
+	respond(w, http.StatusOK, &healthResponse{Status: healthOK, Store: healthOK})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	ctx.HandleHealth(w, httptest.NewRequest("GET", "/v1/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	health := &healthResponse{}
+	if err := json.NewDecoder(w.Body).Decode(health); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if *health != (healthResponse{Status: "ok", Store: "ok"}) {
+		t.Errorf("unexpected response %+v", health)
+	}
+}
+
+func TestHealthStoreDown(t *testing.T) {
+	logs := &bytes.Buffer{}
+	ctx, err := NewContext(failingStore{}, log.New(logs, "", 0))
+	if err != nil {
+		t.Fatalf("error creating context: %v", err)
+	}
+	w := httptest.NewRecorder()
+	ctx.HandleHealth(w, httptest.NewRequest("GET", "/v1/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+	health := &healthResponse{}
+	if err := json.NewDecoder(w.Body).Decode(health); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if health.Status != "unavailable" || health.Store != errStore.Error() {
+		t.Errorf("expected the store's error in the response, got %+v", health)
+	}
+	if !strings.Contains(logs.String(), "health check failed: "+errStore.Error()) {
+		t.Errorf("expected the failure to be logged, got %q", logs.String())
+	}
+
+	w = httptest.NewRecorder()
+	ctx.HandleHealth(w, httptest.NewRequest("POST", "/v1/health", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d for POST but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//applyIdempotencyKey copies the Idempotency-Key header, if
+//there is one, into the new task's ClientKey, which clients
+//can also send in the body. If both are sent and disagree,
+//it responds with a 400 and returns false.
+func (ctx *Context) applyIdempotencyKey(w http.ResponseWriter, r *http.Request, newtask *tasks.NewTask) bool {
+	key := r.Header.Get(headerIdempotencyKey)
+	if len(key) == 0 {
+		return true
+	}
+	if len(newtask.ClientKey) > 0 && newtask.ClientKey != key {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, "the "+headerIdempotencyKey+" header and clientKey must be the same")
+		return false
+	}
+	newtask.ClientKey = key
+	return true
+}
+
+//respondRepeatedCreate responds to a request to create
+//`newtask` when the store says its owner already created a
+//task with the same ClientKey. If it's the same task, the
+//request is a retry, so the existing task is sent with a 200,
+//rather than the 201 of a fresh create; if it's not, the key
+//has been reused for something else, which gets a 422.
+func (ctx *Context) respondRepeatedCreate(w http.ResponseWriter, r *http.Request, newtask *tasks.NewTask) {
+	task, err := ctx.TasksStore.GetByClientKey(newtask.Owner, newtask.ClientKey)
+	if err != nil {
+		//it could have been permanently deleted since
+		ctx.logf(r, "error finding task with a duplicate client key: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error finding task by client key: "+err.Error())
+		return
+	}
+	ctx.respondExisting(w, r, newtask, task)
+}
+
+//respondIfRepeated answers the request to create `newtask`
+//and returns true if its owner already created a task with
+//the same ClientKey, like respondRepeatedCreate. This is
+//checked before the write quota is charged, so that retries,
+//which don't create anything, aren't counted against it.
+func (ctx *Context) respondIfRepeated(w http.ResponseWriter, r *http.Request, newtask *tasks.NewTask) bool {
+	if len(newtask.ClientKey) == 0 {
+		return false
+	}
+	task, err := ctx.TasksStore.GetByClientKey(newtask.Owner, newtask.ClientKey)
+	if err == tasks.ErrNotFound {
+		return false
+	}
+	if err != nil {
+		ctx.logf(r, "error finding task by client key: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error finding task by client key: "+err.Error())
+		return true
+	}
+	ctx.respondExisting(w, r, newtask, task)
+	return true
+}
+
+//respondExisting responds with `task`, which has the same
+//ClientKey as `newtask`, if it's the same task, or with a 422
+//if the key has been reused for a different one
+func (ctx *Context) respondExisting(w http.ResponseWriter, r *http.Request, newtask *tasks.NewTask, task *tasks.Task) {
+	if !task.SameAs(newtask) {
+		ctx.logf(r, "rejected client key %q reused for a different task", newtask.ClientKey)
+		respondError(w, http.StatusUnprocessableEntity, codeIdempotencyConflict, "the client key was already used to create a different task")
+		return
+	}
+	w.Header().Set(headerLocation, specificTaskPrefix(r.URL.Path)+task.ID.(bson.ObjectId).Hex())
+	w.Header().Set(headerETag, taskETag(task))
+	respondTasks(w, r, http.StatusOK, task)
+}
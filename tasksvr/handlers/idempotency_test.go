@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+func TestIdempotentCreate(t *testing.T) {
+	ctx, _ := newTestContext(t)
+
+	w := doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Go", "tags": ["go"]}`, map[string]string{headerIdempotencyKey: "abc"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := &tasks.Task{}
+	json.NewDecoder(w.Body).Decode(created)
+	location := w.Header().Get(headerLocation)
+
+	//retrying gets the same task, with a 200
+	w = doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Go", "tags": ["go"]}`, map[string]string{headerIdempotencyKey: "abc"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d for a retry but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	retried := &tasks.Task{}
+	json.NewDecoder(w.Body).Decode(retried)
+	if retried.ID != created.ID || w.Header().Get(headerLocation) != location {
+		t.Errorf("expected the retry to return task %v at %s, got %v at %s", created.ID, location, retried.ID, w.Header().Get(headerLocation))
+	}
+	//the key can also be sent in the body
+	if w := doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Go", "tags": ["go"], "clientKey": "abc"}`, nil); w.Code != http.StatusOK {
+		t.Errorf("expected status %d for a retry with clientKey but got %d", http.StatusOK, w.Code)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 1 {
+		t.Errorf("expected only one task but got %d", total)
+	}
+
+	//a different task with the same key is refused
+	w = doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Rust"}`, map[string]string{headerIdempotencyKey: "abc"})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	if apierr := decodeError(t, w); apierr.Code != codeIdempotencyConflict {
+		t.Errorf("expected code %s but got %s", codeIdempotencyConflict, apierr.Code)
+	}
+
+	//other users can use the same key
+	w = doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Go", "tags": ["go"]}`, map[string]string{
+		headerIdempotencyKey: "abc",
+		headerUser:           "bob",
+	})
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d for another user but got %d", http.StatusCreated, w.Code)
+	}
+}
+
+func TestIdempotencyKeyInvalid(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	cases := []struct {
+		name string
+		key  string
+		body string
+	}{
+		{"header and body disagree", "abc", `{"title": "Learn Go", "clientKey": "xyz"}`},
+		{"too long", strings.Repeat("k", tasks.MaxClientKeyLength+1), `{"title": "Learn Go"}`},
+		{"header on a batch", "abc", `[{"title": "Learn Go"}]`},
+		{"clientKey in a batch", "", `[{"title": "Learn Go"}, {"title": "Learn Rust", "clientKey": "abc"}]`},
+	}
+	for _, c := range cases {
+		w := doWith(ctx.HandleTasks, "POST", "/v1/tasks", c.body, map[string]string{headerIdempotencyKey: c.key})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d: %s", c.name, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 0 {
+		t.Errorf("expected no tasks but got %d", total)
+	}
+}
+
+func TestIdempotentCreateRace(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	const clients = 20
+	var wg sync.WaitGroup
+	responses := make(chan *httptest.ResponseRecorder, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses <- doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "Learn Go"}`, map[string]string{headerIdempotencyKey: "abc"})
+		}()
+	}
+	wg.Wait()
+	close(responses)
+
+	created := 0
+	ids := map[interface{}]bool{}
+	for w := range responses {
+		switch w.Code {
+		case http.StatusCreated:
+			created++
+		case http.StatusOK:
+		default:
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		task := &tasks.Task{}
+		json.NewDecoder(w.Body).Decode(task)
+		ids[task.ID] = true
+	}
+	if created != 1 || len(ids) != 1 {
+		t.Errorf("expected one create and one task, got %d creates of %d tasks", created, len(ids))
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 1 {
+		t.Errorf("expected only one task in the store but got %d", total)
+	}
+}
+
+func TestIdempotentCreateQuota(t *testing.T) {
+	ctx, _ := newQuotaContext(t, 2)
+	post := func(key string, title string) *httptest.ResponseRecorder {
+		return doWith(ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "`+title+`"}`, map[string]string{
+			headerUser:           "alice",
+			headerIdempotencyKey: key,
+		})
+	}
+
+	if w := post("abc", "Learn Go"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if w := post("xyz", "Learn Rust"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	//the quota is used up, but retries don't create
+	//anything, so they still get the original task
+	for i := 0; i < 3; i++ {
+		if w := post("abc", "Learn Go"); w.Code != http.StatusOK {
+			t.Fatalf("retry %d: expected status %d but got %d: %s", i, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	if w := post("def", "Learn Docker"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a new task to be over the quota, but got %d", w.Code)
+	}
+}
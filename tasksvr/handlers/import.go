@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//importFileField is the name of the form field
+//holding the CSV file to import
+const importFileField = "file"
+
+//the columns of an imported CSV file. They're found by their
+//names in its header row, ignoring case, so they can be in any
+//order, and other columns, like the id and createdAt columns of
+//an export, are ignored. Only the title column is required.
+const (
+	importTitle    = "title"
+	importComplete = "complete"
+	importTags     = "tags"
+	importDueDate  = "duedate"
+)
+
+//uploadError is returned by readImport when the uploaded
+//file can't be imported at all, as opposed to some of
+//its rows being invalid
+type uploadError struct {
+	msg string
+}
+
+func (e *uploadError) Error() string {
+	return e.msg
+}
+
+//HandleTaskImport will handle requests for the /v1/tasks/import
+//resource, which creates tasks from the rows of a CSV file, posted
+//as the "file" field of a multipart/form-data form. Each row is
+//validated like a new task, and the valid ones are created, even
+//if others are invalid, unless ?atomic=true is set, in which case
+//either they're all created or none are. It responds with how many
+//were created, and why each invalid row is invalid.
+func (ctx *Context) HandleTaskImport(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTaskImport) {
+		return
+	}
+	atomic, err := boolParam(r, paramAtomic)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	if !ctx.checkFormData(w, r, "import") {
+		return
+	}
+
+	//the file is read as it's uploaded, rather than
+	//being saved first, so it's limited like other bodies
+	r.Body = http.MaxBytesReader(w, r.Body, ctx.MaxBodyBytes)
+	owner := ctx.owner(r)
+	newtasks, failed, err := readImport(r, owner)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			ctx.respondBodyError(w, r, &bodyTooLargeError{max: ctx.MaxBodyBytes})
+		default:
+			ctx.logf(r, "rejected import: %v", err)
+			respondError(w, http.StatusBadRequest, codeInvalidUpload, "error importing tasks: "+err.Error())
+		}
+		return
+	}
+	if len(failed) > 0 {
+		ctx.logf(r, "rejected %d invalid rows of an import", len(failed))
+		if atomic != nil && *atomic {
+			respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+				Code:    codeValidationFailed,
+				Message: fmt.Sprintf("no tasks were imported, as %d rows are invalid", len(failed)),
+				Status:  http.StatusBadRequest,
+				Failed:  failed,
+			}})
+			return
+		}
+	}
+
+	//imported tasks count against the write quota like
+	//any others, so an import can't be used to get around it
+	if !ctx.takeQuota(w, r, len(newtasks)) {
+		return
+	}
+	inserted := []*tasks.Task{}
+	if len(newtasks) > 0 {
+		inserted, err = ctx.TasksStore.InsertMany(newtasks)
+		if err != nil {
+			ctx.logf(r, "error importing tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error importing tasks: "+err.Error())
+			return
+		}
+	}
+	respond(w, http.StatusOK, &importReport{Created: len(inserted), Failed: failed})
+	for _, task := range inserted {
+		ctx.notify(EventCreated, task)
+	}
+}
+
+//checkFormData answers the request and returns false if it
+//isn't a multipart/form-data upload, logging that it rejected
+//the `what`
+func (ctx *Context) checkFormData(w http.ResponseWriter, r *http.Request, what string) bool {
+	ctype := r.Header.Get(headerContentType)
+	if mediaType, _, err := mime.ParseMediaType(ctype); err != nil || mediaType != contentTypeFormData {
+		ctx.logf(r, "rejected %s with Content-Type %q", what, ctype)
+		respondError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be "+contentTypeFormData+", but got "+strconv.Quote(ctype))
+		return false
+	}
+	return true
+}
+
+//formFile returns the part of the multipart form posted with
+//`r` that is its `field` field, which holds `what`, skipping
+//any fields before it
+func formFile(r *http.Request, field string, what string) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, &uploadError{err.Error()}
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, &uploadError{"the form must have a " + field + " field with " + what}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return part, nil
+		}
+	}
+}
+
+//readImport reads the rows of the CSV file posted with `r`,
+//returning a new task owned by `owner` for each valid row, and
+//an importFailure for each invalid one. It returns an error if
+//the file as a whole can't be imported.
+func readImport(r *http.Request, owner string) ([]*tasks.NewTask, []*importFailure, error) {
+	file, err := formFile(r, importFileField, "the CSV file")
+	if err != nil {
+		return nil, nil, err
+	}
+	cr := csv.NewReader(file)
+	//rows can leave out the columns at the end
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, &uploadError{"the CSV file is empty"}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		//spreadsheets often start the file with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[importTitle]; !ok {
+		return nil, nil, &uploadError{"the CSV file's header row must have a " + importTitle + " column"}
+	}
+
+	newtasks := []*tasks.NewTask{}
+	failed := []*importFailure{}
+	for rows := 1; ; rows++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if rows > maxImportRows {
+			return nil, nil, &uploadError{fmt.Sprintf("the CSV file can have at most %d rows", maxImportRows)}
+		}
+		//a malformed row is just another invalid one
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			failed = append(failed, &importFailure{Line: perr.StartLine, Error: perr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		newtask, err := importRow(record, columns)
+		if err != nil {
+			failed = append(failed, &importFailure{Line: line, Error: err.Error(), Fields: fieldErrors(err)})
+			continue
+		}
+		newtask.Owner = owner
+		newtasks = append(newtasks, newtask)
+	}
+	return newtasks, failed, nil
+}
+
+//importRow returns the validated new task in `record`, a
+//row of an imported CSV file whose header row gave `columns`,
+//or a *tasks.ValidationError listing every problem with it
+func importRow(record []string, columns map[string]int) (*tasks.NewTask, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	ve := &tasks.ValidationError{}
+	newtask := &tasks.NewTask{Title: field(importTitle)}
+	//tags are separated by semicolons, like in exports
+	if tags := field(importTags); len(tags) > 0 {
+		newtask.Tags = strings.Split(tags, ";")
+	}
+	if complete := field(importComplete); len(complete) > 0 {
+		var err error
+		if newtask.Complete, err = strconv.ParseBool(complete); err != nil {
+			ve.Fields = append(ve.Fields, &tasks.FieldError{
+				Field:   importComplete,
+				Code:    tasks.CodeInvalid,
+				Message: "complete must be true or false, but got " + strconv.Quote(complete),
+			})
+		}
+	}
+	if dueDate := field(importDueDate); len(dueDate) > 0 {
+		due, err := time.Parse(time.RFC3339, dueDate)
+		if err != nil {
+			ve.Fields = append(ve.Fields, &tasks.FieldError{
+				Field:   "dueDate",
+				Code:    tasks.CodeInvalid,
+				Message: "dueDate must be a time like " + time.RFC3339 + ", but got " + strconv.Quote(dueDate),
+			})
+		} else {
+			newtask.DueDate = &due
+		}
+	}
+	if err := newtask.Validate(); err != nil {
+		ve.Fields = append(ve.Fields, fieldErrors(err)...)
+	}
+	if len(ve.Fields) > 0 {
+		return nil, ve
+	}
+	return newtask, nil
+}
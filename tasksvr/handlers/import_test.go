@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//mixedImport has valid rows, and rows that are invalid
+//in each of the ways a row can be, on lines 4 to 7
+const mixedImport = `title,complete,tags,dueDate
+Buy milk,false,errand;food,
+"Call mom, then ""dad""",TRUE,,2030-01-01T00:00:00Z
+,false,,
+Study,maybe,,
+Essay,false,school,tomorrow
+"Bad "quote",false,,
+Walk the dog
+`
+
+//newImportRequest returns a POST to /v1/tasks/import`query`
+//uploading `file` as the CSV file
+func newImportRequest(t *testing.T, query string, file string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("note", "fields before the file are skipped")
+	fw, err := mw.CreateFormFile(importFileField, "tasks.csv")
+	if err != nil {
+		t.Fatalf("error creating form: %v", err)
+	}
+	fw.Write([]byte(file))
+	mw.Close()
+	r := httptest.NewRequest("POST", "/v1/tasks/import"+query, body)
+	r.Header.Set(headerContentType, mw.FormDataContentType())
+	return r
+}
+
+//failedLines returns the lines of the failures
+func failedLines(failed []*importFailure) []int {
+	lines := []int{}
+	for _, f := range failed {
+		lines = append(lines, f.Line)
+	}
+	return lines
+}
+
+func TestImportTasks(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	r := newImportRequest(t, "", mixedImport)
+	r.Header.Set(headerUser, "alice")
+	ctx.HandleTaskImport(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	report := &importReport{}
+	if err := json.NewDecoder(w.Body).Decode(report); err != nil {
+		t.Fatalf("error decoding report: %v", err)
+	}
+	if report.Created != 3 {
+		t.Errorf("expected 3 tasks to be created but got %d", report.Created)
+	}
+	if lines := failedLines(report.Failed); !reflect.DeepEqual(lines, []int{4, 5, 6, 7}) {
+		t.Errorf("expected lines 4 to 7 to fail but got %v", lines)
+	}
+	expectedFields := map[int]string{4: "title", 5: "complete", 6: "dueDate"}
+	for _, f := range report.Failed {
+		if field, ok := expectedFields[f.Line]; ok && (len(f.Fields) != 1 || f.Fields[0].Field != field) {
+			t.Errorf("line %d: expected a problem with %s but got %+v", f.Line, field, f.Fields)
+		}
+		if len(f.Error) == 0 {
+			t.Errorf("line %d: expected an error message", f.Line)
+		}
+	}
+
+	imported, _, _ := ctx.TasksStore.Find(&tasks.Query{Sort: "createdAt"})
+	if len(imported) != 3 {
+		t.Fatalf("expected 3 tasks in the store but got %d", len(imported))
+	}
+	milk, mom, dog := imported[0], imported[1], imported[2]
+	if !reflect.DeepEqual(milk.Tags, []string{"errand", "food"}) || milk.Complete || milk.Owner != "alice" {
+		t.Errorf("unexpected task %+v", milk)
+	}
+	if mom.Title != `Call mom, then "dad"` || !mom.Complete || mom.DueDate == nil || mom.DueDate.Year() != 2030 {
+		t.Errorf("unexpected task %+v", mom)
+	}
+	if dog.Title != "Walk the dog" || len(dog.Tags) != 0 {
+		t.Errorf("unexpected task %+v", dog)
+	}
+}
+
+func TestImportAtomic(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	ctx.HandleTaskImport(w, newImportRequest(t, "?atomic=true", mixedImport))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	apierr := decodeError(t, w)
+	if apierr.Code != codeValidationFailed || !reflect.DeepEqual(failedLines(apierr.Failed), []int{4, 5, 6, 7}) {
+		t.Errorf("expected lines 4 to 7 to fail validation, got %+v", apierr)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 0 {
+		t.Errorf("expected nothing to be imported but got %d tasks", total)
+	}
+
+	//when every row is valid, they're all imported
+	w = httptest.NewRecorder()
+	ctx.HandleTaskImport(w, newImportRequest(t, "?atomic=true", "Title\nBuy milk\nWalk the dog\n"))
+	if w.Code != http.StatusOK || w.Body.String() != `{"created":2,"failed":[]}`+"\n" {
+		t.Errorf("expected 2 tasks to be created, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	//an export can be imported again, ignoring its extra columns
+	from, _ := newExportContext(t, 3)
+	w := httptest.NewRecorder()
+	from.HandleTaskExport(w, httptest.NewRequest("GET", "/v1/tasks/export", nil))
+
+	to, _ := newTestContext(t)
+	imported := httptest.NewRecorder()
+	to.HandleTaskImport(imported, newImportRequest(t, "", w.Body.String()))
+	if imported.Body.String() != `{"created":3,"failed":[]}`+"\n" {
+		t.Errorf("expected the export to be imported, got %d %s", imported.Code, imported.Body.String())
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	//big enough for the rows, but not much more
+	ctx.MaxBodyBytes = 4096
+	var tooManyRows strings.Builder
+	tooManyRows.WriteString("title\n")
+	for i := 0; i <= maxImportRows; i++ {
+		tooManyRows.WriteString("x\n")
+	}
+
+	noFile := httptest.NewRequest("POST", "/v1/tasks/import", strings.NewReader("--b--\r\n"))
+	noFile.Header.Set(headerContentType, contentTypeFormData+"; boundary=b")
+	requests := []struct {
+		name           string
+		r              *http.Request
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"JSON", httptest.NewRequest("POST", "/v1/tasks/import", strings.NewReader(`[{"title": "x"}]`)),
+			http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"no file", noFile, http.StatusBadRequest, codeInvalidUpload},
+		{"empty file", newImportRequest(t, "", ""), http.StatusBadRequest, codeInvalidUpload},
+		{"no title column", newImportRequest(t, "", "name,complete\nBuy milk,false\n"), http.StatusBadRequest, codeInvalidUpload},
+		{"too many rows", newImportRequest(t, "", tooManyRows.String()), http.StatusBadRequest, codeInvalidUpload},
+		{"too large", newImportRequest(t, "", "title\n"+strings.Repeat("x", int(ctx.MaxBodyBytes))), http.StatusBadRequest, codeBodyTooLarge},
+		{"bad atomic", newImportRequest(t, "?atomic=yes", "title\nx\n"), http.StatusBadRequest, codeInvalidQuery},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		ctx.HandleTaskImport(w, req.r)
+		if w.Code != req.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", req.name, req.expectedStatus, w.Code, w.Body.String())
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != req.expectedCode {
+			t.Errorf("%s: expected code %s but got %s", req.name, req.expectedCode, apierr.Code)
+		}
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 0 {
+		t.Errorf("expected nothing to be imported but got %d tasks", total)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//HandleLabels will handle requests for the /v1/labels resource:
+//GET lists the caller's labels, sorted by name, and POST creates
+//a new one, which must be named differently from the others
+func (ctx *Context) HandleLabels(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowLabels) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		labels, err := ctx.TasksStore.FindLabels(ctx.owner(r))
+		if err != nil {
+			ctx.logf(r, "error getting labels: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error getting labels: "+err.Error())
+			return
+		}
+		respond(w, http.StatusOK, labels)
+
+	case "POST":
+		newlabel := &tasks.NewLabel{}
+		if err := ctx.readJSON(w, r, newlabel); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if err := newlabel.Validate(); err != nil {
+			ctx.logf(r, "rejected invalid label: %v", err)
+			respondInvalid(w, "error validating label", err)
+			return
+		}
+		newlabel.Owner = ctx.owner(r)
+
+		label, err := ctx.TasksStore.InsertLabel(newlabel)
+		if err != nil {
+			ctx.respondLabelError(w, r, err, "", "inserting")
+			return
+		}
+		w.Header().Set(headerLocation, specificLabelPath+tasks.LabelRef(label.ID))
+		respond(w, http.StatusCreated, label)
+	}
+}
+
+//HandleSpecificLabel will handle requests for the /v1/labels/some-label-id
+//resource: GET responds with the label, PATCH renames and/or recolors it,
+//and DELETE deletes it. Labels that are on any tasks, including deleted
+//ones, can only be deleted with ?cascade=true, which also removes them
+//from those tasks.
+func (ctx *Context) HandleSpecificLabel(w http.ResponseWriter, r *http.Request) {
+	idHex := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, specificLabelPath), "/")
+	if strings.Contains(idHex, "/") {
+		respondError(w, http.StatusNotFound, codeNotFound, "no such label resource "+idHex)
+		return
+	}
+	if !checkMethod(w, r, allowLabel) {
+		return
+	}
+	id, err := parseLabelID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		label, err := ctx.getOwnedLabel(id, ctx.owner(r))
+		if err != nil {
+			ctx.respondLabelError(w, r, err, id, "getting")
+			return
+		}
+		respond(w, http.StatusOK, label)
+
+	case "PATCH":
+		updates := &tasks.LabelUpdates{}
+		if err := ctx.readJSON(w, r, updates); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if err := updates.Validate(); err != nil {
+			ctx.logf(r, "rejected invalid updates for label %s: %v", id.Hex(), err)
+			respondInvalid(w, "error validating updates", err)
+			return
+		}
+		if _, err := ctx.getOwnedLabel(id, ctx.owner(r)); err != nil {
+			ctx.respondLabelError(w, r, err, id, "updating")
+			return
+		}
+		label, err := ctx.TasksStore.UpdateLabel(id, updates)
+		if err != nil {
+			ctx.respondLabelError(w, r, err, id, "updating")
+			return
+		}
+		respond(w, http.StatusOK, label)
+
+	case "DELETE":
+		cascade, err := boolParam(r, paramCascade)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+		if _, err := ctx.getOwnedLabel(id, ctx.owner(r)); err != nil {
+			ctx.respondLabelError(w, r, err, id, "deleting")
+			return
+		}
+		//WebSocket clients aren't told about the tasks it's
+		//removed from, as the store doesn't say which they are
+		if err := ctx.TasksStore.DeleteLabel(id, cascade != nil && *cascade); err != nil {
+			ctx.respondLabelError(w, r, err, id, "deleting")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+//parseLabelID returns the label ID from a request path, or
+//an error if it's missing, or isn't a valid Mongo ObjectId
+func parseLabelID(id string) (bson.ObjectId, error) {
+	if len(id) == 0 {
+		return "", errors.New("missing label ID")
+	}
+	if !bson.IsObjectIdHex(id) {
+		return "", fmt.Errorf("invalid label ID %q", id)
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+//getOwnedLabel gets the label with the given ID, returning
+//tasks.ErrLabelNotFound if it belongs to someone else,
+//like getOwnedTask
+func (ctx *Context) getOwnedLabel(id bson.ObjectId, owner string) (*tasks.Label, error) {
+	label, err := ctx.TasksStore.GetLabel(id)
+	if err != nil {
+		return nil, err
+	}
+	if label.Owner != owner {
+		return nil, tasks.ErrLabelNotFound
+	}
+	return label, nil
+}
+
+//respondLabelError responds to a request for the label with
+//the given ID, or to create one if it's empty, that the store
+//failed while `action`ing it, like respondStoreError
+func (ctx *Context) respondLabelError(w http.ResponseWriter, r *http.Request, err error, id bson.ObjectId, action string) {
+	switch err {
+	case tasks.ErrLabelNotFound:
+		respondError(w, http.StatusNotFound, codeNotFound, "no label with ID "+id.Hex())
+		return
+	case tasks.ErrDuplicateLabelName:
+		respondError(w, http.StatusConflict, codeConflict, "you already have a label with that name")
+		return
+	case tasks.ErrLabelInUse:
+		respondError(w, http.StatusConflict, codeConflict, "label "+id.Hex()+" is still on some tasks, which may include deleted ones: "+
+			"remove it from them first, or delete it with "+paramCascade+"=true to remove it from them too")
+		return
+	}
+	if len(id) == 0 {
+		ctx.logf(r, "error %s label: %v", action, err)
+	} else {
+		ctx.logf(r, "error %s label %s: %v", action, id.Hex(), err)
+	}
+	respondError(w, http.StatusInternalServerError, codeStoreError, "error "+action+" label: "+err.Error())
+}
+
+//checkLabels returns true if every label that `updates` puts
+//on a task is one of the caller's labels. If any aren't, it
+//answers the request with a validation error naming each
+//one, and returns false. A label deleted between the check
+//and the update is left on the task, where it refers to
+//nothing, until the task's labels are next set without it.
+func (ctx *Context) checkLabels(w http.ResponseWriter, r *http.Request, updates *tasks.TaskUpdates) bool {
+	if updates.Labels == nil || len(*updates.Labels) == 0 {
+		return true
+	}
+	labels, err := ctx.TasksStore.FindLabels(ctx.owner(r))
+	if err != nil {
+		ctx.logf(r, "error getting labels: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error getting labels: "+err.Error())
+		return false
+	}
+	owned := map[string]bool{}
+	for _, label := range labels {
+		owned[tasks.LabelRef(label.ID)] = true
+	}
+	ve := &tasks.ValidationError{}
+	//Validate has removed duplicates, so the
+	//indices may not match the request's
+	for _, ref := range *updates.Labels {
+		if !owned[ref] {
+			ve.Fields = append(ve.Fields, &tasks.FieldError{
+				Field:   "labels",
+				Code:    tasks.CodeNotFound,
+				Message: "no label with ID " + ref,
+			})
+		}
+	}
+	if len(ve.Fields) > 0 {
+		ctx.logf(r, "rejected updates with labels that don't exist: %v", ve)
+		respondInvalid(w, "error validating updates", ve)
+		return false
+	}
+	return true
+}
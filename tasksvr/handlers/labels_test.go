@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//createLabel creates a label as `user`, and returns its ID
+func createLabel(t *testing.T, ctx *Context, user string, body string) string {
+	w := doAs(ctx.HandleLabels, user, "POST", labelsPath, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	label := &tasks.Label{}
+	if err := json.NewDecoder(w.Body).Decode(label); err != nil {
+		t.Fatalf("error decoding label: %v", err)
+	}
+	if location := w.Header().Get(headerLocation); location != specificLabelPath+label.ID.(string) {
+		t.Errorf("expected Location %s but got %s", specificLabelPath+label.ID.(string), location)
+	}
+	return label.ID.(string)
+}
+
+func TestLabels(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	school := createLabel(t, ctx, "alice", `{"name": "school", "color": "#1E90FF"}`)
+	createLabel(t, ctx, "alice", `{"name": "errand", "color": "#ff0000"}`)
+	createLabel(t, ctx, "bob", `{"name": "school", "color": "#000000"}`)
+
+	w := doAs(ctx.HandleLabels, "alice", "GET", labelsPath, "")
+	labels := []*tasks.Label{}
+	if err := json.NewDecoder(w.Body).Decode(&labels); err != nil {
+		t.Fatalf("error decoding labels: %v", err)
+	}
+	if len(labels) != 2 || labels[0].Name != "errand" || labels[1].Name != "school" || labels[1].Color != "#1e90ff" {
+		t.Errorf("expected alice's labels sorted by name, got %s", w.Body.String())
+	}
+
+	w = doAs(ctx.HandleSpecificLabel, "alice", "PATCH", specificLabelPath+school, `{"color": "#00ff00"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = doAs(ctx.HandleSpecificLabel, "alice", "GET", specificLabelPath+school, "")
+	label := &tasks.Label{}
+	if err := json.NewDecoder(w.Body).Decode(label); err != nil || label.Name != "school" || label.Color != "#00ff00" {
+		t.Errorf("expected the recolored label, got %+v, %v", label, err)
+	}
+
+	requests := []struct {
+		user           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		//names are unique per owner
+		{"alice", "POST", labelsPath, `{"name": "school", "color": "#000000"}`, http.StatusConflict, codeConflict},
+		{"alice", "PATCH", specificLabelPath + school, `{"name": "errand"}`, http.StatusConflict, codeConflict},
+		{"alice", "POST", labelsPath, `{"name": "work", "color": "blue"}`, http.StatusBadRequest, codeValidationFailed},
+		{"alice", "POST", labelsPath, `{"name": "work", "color": "#12345"}`, http.StatusBadRequest, codeValidationFailed},
+		{"alice", "PATCH", specificLabelPath + school, `{}`, http.StatusBadRequest, codeValidationFailed},
+		{"alice", "GET", specificLabelPath + "nope", "", http.StatusBadRequest, codeInvalidID},
+		{"alice", "GET", specificLabelPath + school + "/extra", "", http.StatusNotFound, codeNotFound},
+		{"alice", "PUT", specificLabelPath + school, "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"alice", "DELETE", specificLabelPath + school + "?cascade=maybe", "", http.StatusBadRequest, codeInvalidQuery},
+		//other users can't see the label at all
+		{"bob", "GET", specificLabelPath + school, "", http.StatusNotFound, codeNotFound},
+		{"bob", "PATCH", specificLabelPath + school, `{"color": "#000000"}`, http.StatusNotFound, codeNotFound},
+		{"bob", "DELETE", specificLabelPath + school, "", http.StatusNotFound, codeNotFound},
+	}
+	for _, req := range requests {
+		handler := ctx.HandleSpecificLabel
+		if req.path == labelsPath {
+			handler = ctx.HandleLabels
+		}
+		w := doAs(handler, req.user, req.method, req.path, req.body)
+		if w.Code != req.expectedStatus {
+			t.Errorf("%s %s as %s: expected status %d but got %d: %s", req.method, req.path, req.user, req.expectedStatus, w.Code, w.Body.String())
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != req.expectedCode {
+			t.Errorf("%s %s as %s: expected code %s but got %s", req.method, req.path, req.user, req.expectedCode, apierr.Code)
+		}
+	}
+}
+
+func TestTaskLabels(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Study", "Buy milk")
+	school := createLabel(t, ctx, "", `{"name": "school", "color": "#1e90ff"}`)
+	bobs := createLabel(t, ctx, "bob", `{"name": "school", "color": "#1e90ff"}`)
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex()
+
+	w := doAs(ctx.HandleSpecificTask, "", "PATCH", path, `{"labels": ["`+school+`"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	task := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil || len(task.Labels) != 1 || task.Labels[0] != school {
+		t.Errorf("expected the task to have the label, got %+v, %v", task, err)
+	}
+	if titles := titlesIn(t, doAs(ctx.HandleTasks, "", "GET", "/v1/tasks?label="+school, "")); len(titles) != 1 || titles[0] != "Study" {
+		t.Errorf("expected only the labeled task, got %v", titles)
+	}
+
+	//labels must exist, and be the caller's own
+	missing := bson.NewObjectId().Hex()
+	for _, labels := range []string{`["` + missing + `"]`, `["` + bobs + `"]`, `["school"]`} {
+		w := doAs(ctx.HandleSpecificTask, "", "PATCH", path, `{"labels": `+labels+`}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d: %s", labels, http.StatusBadRequest, w.Code, w.Body.String())
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != codeValidationFailed || len(apierr.Fields) != 1 || !strings.HasPrefix(apierr.Fields[0].Field, "labels") {
+			t.Errorf("%s: expected a validation error for the labels, got %+v", labels, apierr)
+		}
+	}
+	w = doAs(ctx.HandleTasks, "", "PATCH", "/v1/tasks", `{"ids": ["`+path[len(specificTaskPath):]+`"], "updates": {"labels": ["`+missing+`"]}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a batch with a missing label but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if w := doAs(ctx.HandleTasks, "", "GET", "/v1/tasks?label=school", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid ?label= but got %d", http.StatusBadRequest, w.Code)
+	}
+	if stored, _ := ctx.TasksStore.Get(seeded[0].ID); len(stored.Labels) != 1 || stored.Labels[0] != school {
+		t.Errorf("expected the task to keep its label, got %v", stored.Labels)
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Study")
+	school := createLabel(t, ctx, "", `{"name": "school", "color": "#1e90ff"}`)
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex()
+	doAs(ctx.HandleSpecificTask, "", "PATCH", path, `{"labels": ["`+school+`"]}`)
+
+	//it's in use, so it can't be deleted without cascading
+	w := doAs(ctx.HandleSpecificLabel, "", "DELETE", specificLabelPath+school, "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if apierr := decodeError(t, w); apierr.Code != codeConflict {
+		t.Errorf("expected code %s but got %s", codeConflict, apierr.Code)
+	}
+	//even once the task is deleted, as it can be restored
+	doAs(ctx.HandleSpecificTask, "", "DELETE", path, "")
+	if w := doAs(ctx.HandleSpecificLabel, "", "DELETE", specificLabelPath+school, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected status %d with a deleted task but got %d", http.StatusConflict, w.Code)
+	}
+
+	w = doAs(ctx.HandleSpecificLabel, "", "DELETE", specificLabelPath+school+"?cascade=true", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if stored, _ := ctx.TasksStore.Get(seeded[0].ID); len(stored.Labels) != 0 {
+		t.Errorf("expected the label to be removed from the task, got %v", stored.Labels)
+	}
+	if w := doAs(ctx.HandleSpecificLabel, "", "GET", specificLabelPath+school, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after deleting but got %d", http.StatusNotFound, w.Code)
+	}
+
+	//unused labels are deleted without cascading
+	unused := createLabel(t, ctx, "", `{"name": "unused", "color": "#1e90ff"}`)
+	if w := doAs(ctx.HandleSpecificLabel, "", "DELETE", specificLabelPath+unused, ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d deleting an unused label but got %d", http.StatusNoContent, w.Code)
+	}
+}
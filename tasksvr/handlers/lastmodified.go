@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+//lastModified returns when the tasks owned by `owner`, or all
+//the tasks if it's nil, last changed: the newest of their
+//ModifiedAt times, or when tasks were last permanently deleted,
+//which leaves nothing in the store to say when it happened
+func (ctx *Context) lastModified(owner *string) (time.Time, error) {
+	max, err := ctx.TasksStore.MaxModified(owner)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if purged := time.Unix(0, atomic.LoadInt64(&ctx.purgedAt)); purged.After(max) {
+		max = purged
+	}
+	return max, nil
+}
+
+//recordPurge notes that tasks were just permanently deleted,
+//so that lastModified moves on. It's per server, not per owner,
+//which only makes some conditional GETs send the list again.
+func (ctx *Context) recordPurge() {
+	atomic.StoreInt64(&ctx.purgedAt, ctx.now().UnixNano())
+}
+
+//checkNotModified sets the Last-Modified header for a response
+//whose content last changed at `lastModified`, and if the request
+//has an If-Modified-Since header at least as new, answers it with
+//a 304 and returns true, so the client can keep what it has.
+func (ctx *Context) checkNotModified(w http.ResponseWriter, r *http.Request, lastModified time.Time) bool {
+	if lastModified.IsZero() {
+		return false
+	}
+	//HTTP dates only have whole seconds, so a change later in
+	//the same second couldn't be told apart from the last one;
+	//Last-Modified isn't sent until that second is over, so that
+	//clients never hold a date that such a change wouldn't pass
+	modified := lastModified.UTC().Truncate(time.Second)
+	if modified.Before(ctx.now().Truncate(time.Second)) {
+		w.Header().Set(headerLastModified, modified.Format(http.TimeFormat))
+	}
+	since, err := http.ParseTime(r.Header.Get(headerIfModifiedSince))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
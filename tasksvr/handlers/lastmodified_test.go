@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestLastModified(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	modifiedAt := seeded[0].ModifiedAt
+
+	//within the same second, another change could still
+	//come, so there's no Last-Modified yet
+	ctx.now = func() time.Time { return modifiedAt }
+	w := doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", nil)
+	if w.Code != http.StatusOK || len(w.Header().Get(headerLastModified)) > 0 {
+		t.Errorf("expected no Last-Modified in the same second, got %d %q", w.Code, w.Header().Get(headerLastModified))
+	}
+
+	ctx.now = func() time.Time { return modifiedAt.Add(time.Second) }
+	w = doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", nil)
+	lastModified := w.Header().Get(headerLastModified)
+	if expected := modifiedAt.UTC().Format(http.TimeFormat); lastModified != expected {
+		t.Fatalf("expected Last-Modified %q but got %q", expected, lastModified)
+	}
+
+	//replaying it gets a 304 without a body
+	w = doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: lastModified})
+	if w.Code != http.StatusNotModified || w.Body.Len() > 0 {
+		t.Errorf("expected status %d with no body but got %d: %s", http.StatusNotModified, w.Code, w.Body.String())
+	}
+	earlier := modifiedAt.Add(-time.Second).UTC().Format(http.TimeFormat)
+	if w := doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: earlier}); w.Code != http.StatusOK {
+		t.Errorf("expected status %d for an older If-Modified-Since but got %d", http.StatusOK, w.Code)
+	}
+	if w := doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: "yesterday"}); w.Code != http.StatusOK {
+		t.Errorf("expected an invalid If-Modified-Since to be ignored but got %d", w.Code)
+	}
+
+	//the store's clock is real, so wait for it to reach
+	//the next second, like a real change would have to
+	time.Sleep(time.Until(modifiedAt.Truncate(time.Second).Add(time.Second)))
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex()
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", path, strings.NewReader(`{"complete": true}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d updating but got %d", http.StatusOK, w.Code)
+	}
+	ctx.now = func() time.Time { return time.Now().Add(time.Second) }
+	w = doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: lastModified})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d after a change but got %d", http.StatusOK, w.Code)
+	}
+	updated, _ := http.ParseTime(w.Header().Get(headerLastModified))
+	if since, _ := http.ParseTime(lastModified); !updated.After(since) {
+		t.Errorf("expected Last-Modified to move past %s, got %q", lastModified, w.Header().Get(headerLastModified))
+	}
+	lastModified = w.Header().Get(headerLastModified)
+	if w := doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: lastModified}); w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d for the new Last-Modified but got %d", http.StatusNotModified, w.Code)
+	}
+
+	//permanently deleting a task leaves nothing in the
+	//store to say when, but still counts as a change
+	ctx.Admins = []string{"admin"}
+	r := httptest.NewRequest("DELETE", path+"?permanent=true", nil)
+	r = authenticated(r, "admin")
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d deleting but got %d", http.StatusNoContent, w.Code)
+	}
+	ctx.now = func() time.Time { return time.Now().Add(2 * time.Second) }
+	if w := doWith(ctx.HandleTasks, "GET", "/v1/tasks", "", map[string]string{headerIfModifiedSince: lastModified}); w.Code != http.StatusOK {
+		t.Errorf("expected status %d after a permanent delete but got %d", http.StatusOK, w.Code)
+	}
+}
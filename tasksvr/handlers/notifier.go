@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"golang.org/x/net/websocket"
+)
+
+//the types of Event
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+//Event is the JSON message sent to WebSocket and
+//SSE clients when one of their tasks changes
+type Event struct {
+	Type string      `json:"type"`
+	Task *tasks.Task `json:"task"`
+}
+
+//sentEvent is an Event as it's sent to clients: encoded
+//once for all of them, and numbered, so that SSE clients
+//can say which one they saw last when they reconnect
+type sentEvent struct {
+	id        uint64
+	eventType string
+	owner     string
+	data      []byte
+}
+
+//listener is a WebSocket or SSE client waiting for
+//events about the tasks owned by `owner`
+type listener struct {
+	owner string
+	//if replay is true, the listener is first sent
+	//the remembered events numbered after `after`
+	replay bool
+	after  uint64
+	//send holds the events waiting to be written;
+	//the Notifier closes it to disconnect
+	send chan *sentEvent
+}
+
+//Notifier fans out Events to WebSocket and SSE clients. A single
+//goroutine owns the set of listeners, and the other methods
+//talk to it over channels, so no locks are needed. Clients
+//that fall more than maxPendingEvents behind are disconnected,
+//so that a slow client can't hold up anyone else.
+type Notifier struct {
+	add       chan *listener
+	remove    chan *listener
+	events    chan *Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//NewNotifier creates a Notifier and starts its goroutine,
+//which runs until Close is called
+func NewNotifier() *Notifier {
+	n := &Notifier{
+		add:    make(chan *listener),
+		remove: make(chan *listener),
+		events: make(chan *Event, maxPendingEvents),
+		done:   make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+//run adds and removes listeners, and sends them events,
+//until the Notifier is closed
+func (n *Notifier) run() {
+	listeners := map[*listener]bool{}
+	//the events are numbered from 1, and the
+	//most recent ones are kept for replaying
+	var lastID uint64
+	var recent []*sentEvent
+	for {
+		select {
+		case l := <-n.add:
+			listeners[l] = true
+			if l.replay {
+				//an ID we haven't reached yet must be from
+				//before a restart, so send all we have
+				after := l.after
+				if after > lastID {
+					after = 0
+				}
+				for _, e := range recent {
+					//listenAfter left room for all of these
+					if e.id > after && e.owner == l.owner {
+						l.send <- e
+					}
+				}
+			}
+		case l := <-n.remove:
+			//it may already be gone for being too slow
+			if listeners[l] {
+				delete(listeners, l)
+				close(l.send)
+			}
+		case e := <-n.events:
+			msg, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			lastID++
+			sent := &sentEvent{id: lastID, eventType: e.Type, owner: e.Task.Owner, data: msg}
+			recent = append(recent, sent)
+			if len(recent) > maxReplayEvents {
+				recent = recent[1:]
+			}
+			for l := range listeners {
+				if l.owner != sent.owner {
+					continue
+				}
+				select {
+				case l.send <- sent:
+				default:
+					//its buffer is full, so disconnect it
+					delete(listeners, l)
+					close(l.send)
+				}
+			}
+		case <-n.done:
+			for l := range listeners {
+				close(l.send)
+			}
+			return
+		}
+	}
+}
+
+//Notify sends an Event of the given type about `task`
+//to the clients listening for changes to its owner's
+//tasks. It never waits on the clients themselves.
+func (n *Notifier) Notify(eventType string, task *tasks.Task) {
+	select {
+	case n.events <- &Event{Type: eventType, Task: task}:
+	case <-n.done:
+	}
+}
+
+//Close disconnects all the clients, and stops the
+//Notifier. It's safe to call more than once.
+func (n *Notifier) Close() {
+	n.closeOnce.Do(func() { close(n.done) })
+}
+
+//listen adds a listener for `owner`'s tasks, returning
+//false if the Notifier is closed
+func (n *Notifier) listen(owner string) (*listener, bool) {
+	return n.subscribe(&listener{owner: owner, send: make(chan *sentEvent, maxPendingEvents)})
+}
+
+//listenAfter is like listen, but the listener is first sent
+//the remembered events after the one numbered `after`, so its
+//buffer has room for all of them on top of the usual amount
+func (n *Notifier) listenAfter(owner string, after uint64) (*listener, bool) {
+	return n.subscribe(&listener{
+		owner:  owner,
+		replay: true,
+		after:  after,
+		send:   make(chan *sentEvent, maxPendingEvents+maxReplayEvents),
+	})
+}
+
+//subscribe hands `l` to the Notifier's goroutine
+func (n *Notifier) subscribe(l *listener) (*listener, bool) {
+	select {
+	case n.add <- l:
+		return l, true
+	case <-n.done:
+		return nil, false
+	}
+}
+
+//unlisten removes the listener, if it hasn't already been
+func (n *Notifier) unlisten(l *listener) {
+	select {
+	case n.remove <- l:
+	case <-n.done:
+	}
+}
+
+//serve writes the listener's events to `ws` until
+//either the Notifier or the client disconnects
+func (n *Notifier) serve(ws *websocket.Conn, l *listener) {
+	defer ws.Close()
+	//clients don't send us anything, but reading
+	//is how we find out that they've gone away
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		n.unlisten(l)
+	}()
+	for e := range l.send {
+		//a dead client that never reads could
+		//otherwise hold this goroutine forever
+		ws.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if err := websocket.Message.Send(ws, string(e.data)); err != nil {
+			return
+		}
+	}
+}
+
+//HandleWebSocket will handle requests for the /v1/ws resource,
+//upgrading them to a WebSocket that is sent an Event whenever
+//one of the caller's tasks is created, updated or deleted
+func (ctx *Context) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowWebSocket) {
+		return
+	}
+	//listen before upgrading, so that the client
+	//gets every event after it's connected
+	l, ok := ctx.Notifier.listen(ctx.owner(r))
+	if !ok {
+		respondError(w, http.StatusServiceUnavailable, codeUnavailable, "the server is shutting down")
+		return
+	}
+	defer ctx.Notifier.unlisten(l)
+	websocket.Handler(func(ws *websocket.Conn) {
+		ctx.Notifier.serve(ws, l)
+	}).ServeHTTP(w, r)
+}
+
+//notify tells WebSocket and SSE clients about a change to `task`
+func (ctx *Context) notify(eventType string, task *tasks.Task) {
+	if ctx.Notifier != nil {
+		ctx.Notifier.Notify(eventType, task)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"golang.org/x/net/websocket"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//dialEvents connects a WebSocket client to the /v1/ws
+//handler served by `server`, as `user`
+func dialEvents(t *testing.T, server *httptest.Server, user string) *websocket.Conn {
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", server.URL)
+	if err != nil {
+		t.Fatalf("error configuring WebSocket: %v", err)
+	}
+	config.Header.Set(headerUser, user)
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("error dialing WebSocket: %v", err)
+	}
+	return ws
+}
+
+//receiveEvent reads the next Event from `ws`,
+//failing the test if there isn't one soon
+func receiveEvent(t *testing.T, ws *websocket.Conn) *Event {
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	e := &Event{}
+	if err := websocket.JSON.Receive(ws, e); err != nil {
+		t.Fatalf("error receiving event: %v", err)
+	}
+	return e
+}
+
+func TestWebSocketEvents(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	defer ctx.Notifier.Close()
+	server := httptest.NewServer(http.HandlerFunc(ctx.HandleWebSocket))
+	defer server.Close()
+
+	clients := []*websocket.Conn{dialEvents(t, server, "alice"), dialEvents(t, server, "alice")}
+	for _, ws := range clients {
+		defer ws.Close()
+	}
+	//bob doesn't hear about alice's tasks
+	bob := dialEvents(t, server, "bob")
+	defer bob.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn WebSockets"}`))
+	r.Header.Set(headerUser, "alice")
+	ctx.HandleTasks(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	for i, ws := range clients {
+		e := receiveEvent(t, ws)
+		if e.Type != EventCreated || e.Task == nil || e.Task.Title != "Learn WebSockets" {
+			t.Errorf("client %d: unexpected event %+v", i, e)
+		}
+	}
+
+	bob.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if err := websocket.JSON.Receive(bob, &Event{}); err == nil {
+		t.Errorf("expected no event for another owner's task")
+	}
+}
+
+func TestWebSocketEventTypes(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	defer ctx.Notifier.Close()
+	server := httptest.NewServer(http.HandlerFunc(ctx.HandleWebSocket))
+	defer server.Close()
+	ws := dialEvents(t, server, "")
+	defer ws.Close()
+
+	task, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: "Learn Go"})
+	path := specificTaskPath + task.ID.(bson.ObjectId).Hex()
+	requests := []struct {
+		method       string
+		path         string
+		body         string
+		expectedType string
+	}{
+		{"PATCH", path, `{"title": "Learn Go well"}`, EventUpdated},
+		{"POST", path + "/complete", "", EventUpdated},
+		{"DELETE", path, "", EventDeleted},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		e := receiveEvent(t, ws)
+		if e.Type != req.expectedType || e.Task.Title != "Learn Go well" {
+			t.Errorf("%s %s: unexpected event %+v", req.method, req.path, e)
+		}
+	}
+}
+
+func TestNotifierDropsSlowListeners(t *testing.T) {
+	n := NewNotifier()
+	defer n.Close()
+	slow, _ := n.listen("")
+	task := &tasks.Task{Title: "Learn Go"}
+
+	//this must not block, even though nobody is reading
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < maxPendingEvents*3; i++ {
+			n.Notify(EventCreated, task)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on a slow listener")
+	}
+
+	//it's sent what fits in its buffer, then disconnected
+	received := 0
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-slow.send:
+			if !ok {
+				if received > maxPendingEvents {
+					t.Errorf("expected at most %d events but got %d", maxPendingEvents, received)
+				}
+				return
+			}
+			received++
+		case <-timeout:
+			t.Fatal("slow listener was never disconnected")
+		}
+	}
+}
+
+func TestNotifierClose(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	server := httptest.NewServer(http.HandlerFunc(ctx.HandleWebSocket))
+	defer server.Close()
+	ws := dialEvents(t, server, "")
+	defer ws.Close()
+
+	ctx.Notifier.Close()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	if err := websocket.JSON.Receive(ws, &Event{}); err != io.EOF {
+		t.Errorf("expected the connection to be closed, got %v", err)
+	}
+	//nothing blocks once it's closed
+	ctx.Notifier.Notify(EventCreated, &tasks.Task{})
+	ctx.Notifier.Close()
+
+	w := httptest.NewRecorder()
+	ctx.HandleWebSocket(w, httptest.NewRequest("GET", "/v1/ws", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d after closing but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//owner returns who is making the request: the principal or
+//API key owner set by the authentication middleware, if it's
+//installed, or else the X-User header. Requests from nobody in
+//particular share the tasks owned by "".
+func (ctx *Context) owner(r *http.Request) string {
+	if principal := httpmw.PrincipalFromContext(r.Context()); len(principal) > 0 {
+		return principal
+	}
+	if owner := httpmw.APIKeyOwnerFromContext(r.Context()); len(owner) > 0 {
+		return owner
+	}
+	return r.Header.Get(headerUser)
+}
+
+//isAdmin returns true if the request was authenticated, by
+//middleware like BasicAuth, as one of the Admins. Anyone can
+//send an X-User header, so it never makes anyone an admin.
+func (ctx *Context) isAdmin(r *http.Request) bool {
+	principal := httpmw.PrincipalFromContext(r.Context())
+	if len(principal) == 0 {
+		return false
+	}
+	for _, admin := range ctx.Admins {
+		if principal == admin {
+			return true
+		}
+	}
+	return false
+}
+
+//ownerQuery builds a tasks.Query from the request like
+//taskQuery, limited to the tasks owned by the caller, or
+//to all the tasks for admins asking for ?all=true. It
+//answers the request and returns false if it's invalid.
+func (ctx *Context) ownerQuery(w http.ResponseWriter, r *http.Request) (*tasks.Query, bool) {
+	q, err := taskQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return nil, false
+	}
+	all, err := boolParam(r, paramAll)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return nil, false
+	}
+	owner := ctx.owner(r)
+	if all != nil && *all {
+		if !ctx.isAdmin(r) {
+			respondError(w, http.StatusForbidden, codeForbidden, "only admins can use "+paramAll+"=true")
+			return nil, false
+		}
+		return q, true
+	}
+	q.Owner = &owner
+	return q, true
+}
+
+//getOwnedTask gets the task with the given ID, returning
+//tasks.ErrNotFound if it belongs to someone else, so that
+//callers can't tell it exists. Tasks never change owner,
+//so the check stays true for any change that follows.
+//Archived tasks have been deleted, so they aren't found
+//either.
+func (ctx *Context) getOwnedTask(id bson.ObjectId, owner string) (*tasks.Task, error) {
+	task, err := ctx.getOwnedTaskOrArchived(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if task.ArchivedAt != nil {
+		return nil, tasks.ErrNotFound
+	}
+	return task, nil
+}
+
+//getOwnedTaskOrArchived is like getOwnedTask,
+//but also finds archived tasks
+func (ctx *Context) getOwnedTaskOrArchived(id bson.ObjectId, owner string) (*tasks.Task, error) {
+	task, err := ctx.TasksStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Owner != owner {
+		return nil, tasks.ErrNotFound
+	}
+	return task, nil
+}
+
+//permanentParam returns true if the request asks to delete
+//tasks permanently with ?permanent=true, rather than archiving
+//them, which only admins can do. It answers the request and
+//returns false for its second result if it can't.
+func (ctx *Context) permanentParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	permanent, err := boolParam(r, paramPermanent)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return false, false
+	}
+	if permanent == nil || !*permanent {
+		return false, true
+	}
+	if !ctx.isAdmin(r) {
+		ctx.logf(r, "rejected %s=true from a user who isn't an admin", paramPermanent)
+		respondError(w, http.StatusForbidden, codeForbidden, "only admins can use "+paramPermanent+"=true")
+		return false, false
+	}
+	return true, true
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//doWith sends a request to `handler` with the given
+//headers, leaving out any whose value is empty
+func doWith(handler http.HandlerFunc, method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range headers {
+		if len(value) > 0 {
+			r.Header.Set(name, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+//doAs sends a request to `handler` from `user`,
+//in the X-User header
+func doAs(handler http.HandlerFunc, user string, method string, path string, body string) *httptest.ResponseRecorder {
+	return doWith(handler, method, path, body, map[string]string{headerUser: user})
+}
+
+//authenticated returns `r` as if authentication
+//middleware had verified that it's from `principal`
+func authenticated(r *http.Request, principal string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), httpmw.PrincipalKey, principal))
+}
+
+//titlesIn decodes a JSON array of tasks from the
+//response, and returns their titles
+func titlesIn(t *testing.T, w *httptest.ResponseRecorder) []string {
+	found := []*tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(&found); err != nil {
+		t.Fatalf("error decoding tasks: %v", err)
+	}
+	titles := []string{}
+	for _, task := range found {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func TestOwnedTasks(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.Admins = []string{"admin"}
+
+	//clients can't choose the owner
+	if w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "alice's", "owner": "bob"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d setting the owner but got %d", http.StatusBadRequest, w.Code)
+	}
+	w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "alice's"}`)
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if created.Owner != "alice" {
+		t.Errorf("expected the task to be owned by alice, got %q", created.Owner)
+	}
+	doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `[{"title": "alice's batch"}]`)
+	doAs(ctx.HandleTasks, "bob", "POST", "/v1/tasks", `{"title": "bob's"}`)
+
+	cases := []struct {
+		user string
+		//authed users are the principal, rather than X-User
+		authed bool
+		query  string
+		status int
+		titles []string
+	}{
+		{"alice", false, "", http.StatusOK, []string{"alice's", "alice's batch"}},
+		{"bob", false, "", http.StatusOK, []string{"bob's"}},
+		{"", false, "", http.StatusOK, []string{}},
+		{"bob", false, "?all=false", http.StatusOK, []string{"bob's"}},
+		{"bob", false, "?all=true", http.StatusForbidden, nil},
+		{"", false, "?all=true", http.StatusForbidden, nil},
+		{"admin", true, "?all=true", http.StatusOK, []string{"alice's", "alice's batch", "bob's"}},
+		{"admin", true, "", http.StatusOK, []string{}},
+		{"bob", true, "?all=true", http.StatusForbidden, nil},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/tasks?sort=createdAt&"+strings.TrimPrefix(c.query, "?"), nil)
+		if c.authed {
+			r = authenticated(r, c.user)
+		} else {
+			r.Header.Set(headerUser, c.user)
+		}
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, r)
+		if w.Code != c.status {
+			t.Errorf("%q %s: expected status %d but got %d", c.user, c.query, c.status, w.Code)
+			continue
+		}
+		if c.status != http.StatusOK {
+			decodeError(t, w)
+			continue
+		}
+		if titles := titlesIn(t, w); strings.Join(titles, "|") != strings.Join(c.titles, "|") {
+			t.Errorf("%q %s: expected %v but got %v", c.user, c.query, c.titles, titles)
+		}
+	}
+}
+
+func TestOtherOwnersTask(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "alice's"}`)
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	path := "/v1/tasks/" + created.ID.(string)
+
+	//bob can't tell alice's task exists
+	attempts := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", path, ""},
+		{"PATCH", path, `{"title": "bob's now"}`},
+		{"DELETE", path, ""},
+		{"POST", path + "/complete", ""},
+		{"DELETE", path + "/complete", ""},
+	}
+	for _, a := range attempts {
+		for _, user := range []string{"bob", ""} {
+			w := doAs(ctx.HandleSpecificTask, user, a.method, a.path, a.body)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%q %s %s: expected status %d but got %d", user, a.method, a.path, http.StatusNotFound, w.Code)
+			}
+		}
+	}
+	w = doAs(ctx.HandleSpecificTask, "alice", "GET", path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected alice to get her task, got status %d", w.Code)
+	}
+	task := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if task.Title != "alice's" || task.Complete || task.Version != 1 {
+		t.Errorf("expected alice's task to be unchanged, got %+v", task)
+	}
+
+	//bob can't clear alice's completed tasks either
+	doAs(ctx.HandleSpecificTask, "alice", "POST", path+"/complete", "")
+	w = doAs(ctx.HandleTasks, "bob", "DELETE", "/v1/tasks?complete=true", "")
+	if body := strings.TrimSpace(w.Body.String()); body != `{"deleted":0}` {
+		t.Errorf("expected bob to delete nothing, got %s", body)
+	}
+	w = doAs(ctx.HandleTasks, "alice", "DELETE", "/v1/tasks?complete=true", "")
+	if body := strings.TrimSpace(w.Body.String()); body != `{"deleted":1}` {
+		t.Errorf("expected alice to delete her task, got %s", body)
+	}
+}
+
+func TestOwnerFromPrincipal(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	//authentication middleware takes precedence over X-User
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "carol's"}`))
+	r.Header.Set(headerUser, "alice")
+	r = authenticated(r, "carol")
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, r)
+
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if created.Owner != "carol" {
+		t.Errorf("expected the task to be owned by carol, got %q", created.Owner)
+	}
+}
+
+func TestSpoofedAdmin(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	ctx.Admins = []string{"admin"}
+	//anyone can send X-User, so it doesn't make them an admin
+	paths := []struct {
+		handler http.HandlerFunc
+		method  string
+		path    string
+	}{
+		{ctx.HandleTasks, "GET", "/v1/tasks?all=true"},
+		{ctx.HandleSpecificTask, "DELETE", specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "?permanent=true"},
+	}
+	for _, p := range paths {
+		w := doAs(p.handler, "admin", p.method, p.path, "")
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected status %d but got %d", p.method, p.path, http.StatusForbidden, w.Code)
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != codeForbidden {
+			t.Errorf("%s %s: expected code %s but got %s", p.method, p.path, codeForbidden, apierr.Code)
+		}
+	}
+	if alltasks, _ := ctx.TasksStore.GetAll(); len(alltasks) != 1 {
+		t.Errorf("expected the task to still be there, got %d tasks", len(alltasks))
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//names of the query string parameters for GET /v1/tasks
+const (
+	paramLimit     = "limit"
+	paramSkip      = "skip"
+	paramComplete  = "complete"
+	paramSort      = "sort"
+	paramSearch    = "q"
+	paramTag       = "tag"
+	paramPriority  = "priority"
+	paramDueBefore = "due_before"
+	paramOverdue   = "overdue"
+	paramAll       = "all"
+	paramArchived  = "archived"
+	paramPermanent = "permanent"
+	paramFormat    = "format"
+	paramAtomic    = "atomic"
+	paramSince     = "since"
+	paramAfter     = "after"
+	paramIDs       = "ids"
+	paramLabel     = "label"
+	paramCascade   = "cascade"
+	paramWithin    = "within"
+	paramForce     = "force"
+)
+
+//intParam returns the value of the query string parameter
+//`name` as an int, or `def` if it's not in the query string.
+//It returns an error if the value isn't a whole number.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a whole number, but got %q", name, s)
+	}
+	return n, nil
+}
+
+//boolParam returns a pointer to the value of the query string
+//parameter `name`, or nil if it's not in the query string.
+//It returns an error if the value isn't "true" or "false".
+func boolParam(r *http.Request, name string) (*bool, error) {
+	switch s := r.URL.Query().Get(name); s {
+	case "":
+		return nil, nil
+	case "true", "false":
+		b := s == "true"
+		return &b, nil
+	default:
+		return nil, fmt.Errorf("%s must be true or false, but got %q", name, s)
+	}
+}
+
+//timeParam returns the value of the query string parameter
+//`name` as a time, or nil if it's not in the query string.
+//It returns an error if the value isn't in RFC3339 format.
+func timeParam(r *http.Request, name string) (*time.Time, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a time like %s, but got %q", name, time.RFC3339, s)
+	}
+	return &t, nil
+}
+
+//durationParam returns the value of the query string parameter
+//`name` as a duration, like "24h" or "90m", or `def` if it's not
+//in the query string. It returns an error if the value isn't a
+//duration, or isn't more than zero and at most `max`.
+func durationParam(r *http.Request, name string, def time.Duration, max time.Duration) (time.Duration, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration like 24h or 90m, but got %q", name, s)
+	}
+	if d <= 0 || d > max {
+		return 0, fmt.Errorf("%s must be more than zero and at most %s, but got %q", name, max, s)
+	}
+	return d, nil
+}
+
+//sortParam returns the ?sort= parameter, which must be one
+//of the tasks.SortFields, optionally prefixed with "-" for
+//descending order, or tasks.DefaultSort if it's not set
+func sortParam(r *http.Request) (string, error) {
+	s := r.URL.Query().Get(paramSort)
+	if len(s) == 0 {
+		return tasks.DefaultSort, nil
+	}
+	field := strings.TrimPrefix(s, "-")
+	for _, allowed := range tasks.SortFields {
+		if field == allowed {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("%s must be one of %s, optionally prefixed with - for descending order, but got %q",
+		paramSort, strings.Join(tasks.SortFields, ", "), s)
+}
+
+//priorityParam returns the ?priority= parameter, or zero
+//if it's not set. It returns an error if it isn't one of
+//the tasks.PriorityNames.
+func priorityParam(r *http.Request) (tasks.Priority, error) {
+	s := r.URL.Query().Get(paramPriority)
+	if len(s) == 0 {
+		return 0, nil
+	}
+	return tasks.ParsePriority(s)
+}
+
+//labelParam returns the ?label= parameter, which must be the
+//ID of a label, or "" if it's not set. A label that doesn't
+//exist, or is someone else's, is on none of the caller's
+//tasks, so it isn't looked up.
+func labelParam(r *http.Request) (string, error) {
+	s := strings.TrimSpace(r.URL.Query().Get(paramLabel))
+	if len(s) > 0 && !bson.IsObjectIdHex(s) {
+		return "", fmt.Errorf("%s must be the ID of a label, but got %q", paramLabel, s)
+	}
+	return s, nil
+}
+
+//cursorParam returns true if the request pages through tasks
+//with the ?after= cursor, rather than ?skip=, setting the
+//query to start after that task in the order they were
+//created. An empty cursor starts from the first task. It
+//returns an error if the cursor isn't a task ID, or the
+//request also asks for a different order or an offset.
+func cursorParam(r *http.Request, q *tasks.Query) (bool, error) {
+	values, ok := r.URL.Query()[paramAfter]
+	if !ok {
+		return false, nil
+	}
+	for _, param := range []string{paramSort, paramSkip} {
+		if len(r.URL.Query().Get(param)) > 0 {
+			return false, fmt.Errorf("%s can't be used with %s, which pages in the order tasks were created", param, paramAfter)
+		}
+	}
+	q.Sort = tasks.IDSort
+	if after := values[0]; len(after) > 0 {
+		id, err := parseTaskID(after)
+		if err != nil {
+			return false, fmt.Errorf("%s must be the ID of the last task on the previous page: %v", paramAfter, err)
+		}
+		q.After = id
+	}
+	return true, nil
+}
+
+//taskQuery builds a tasks.Query from the query string of a
+//GET /v1/tasks request, such as
+//  ?q=groceries&tag=errand&complete=false&sort=title&limit=10&skip=20
+//or, for the most urgent tasks first
+//  ?priority=high&sort=-priority
+//or
+//  ?overdue=true&due_before=2017-05-01T00:00:00Z
+//or, for the tasks with one of the caller's labels
+//  ?label=5912b7d3e7c7f23f0c0e4fd1
+//or, for the tasks that have been deleted
+//  ?archived=true
+//It returns an error describing the first invalid parameter.
+func taskQuery(r *http.Request) (*tasks.Query, error) {
+	q := &tasks.Query{}
+	var err error
+	if q.Limit, err = intParam(r, paramLimit, defaultTasksLimit); err != nil {
+		return nil, err
+	}
+	if q.Limit < 1 || q.Limit > maxTasksLimit {
+		return nil, fmt.Errorf("%s must be between 1 and %d", paramLimit, maxTasksLimit)
+	}
+	if q.Skip, err = intParam(r, paramSkip, 0); err != nil {
+		return nil, err
+	}
+	if q.Complete, err = boolParam(r, paramComplete); err != nil {
+		return nil, err
+	}
+	if q.Sort, err = sortParam(r); err != nil {
+		return nil, err
+	}
+	if q.DueBefore, err = timeParam(r, paramDueBefore); err != nil {
+		return nil, err
+	}
+	overdue, err := boolParam(r, paramOverdue)
+	if err != nil {
+		return nil, err
+	}
+	q.Overdue = overdue != nil && *overdue
+	archived, err := boolParam(r, paramArchived)
+	if err != nil {
+		return nil, err
+	}
+	q.Archived = archived != nil && *archived
+	if q.Priority, err = priorityParam(r); err != nil {
+		return nil, err
+	}
+	q.Tag = strings.TrimSpace(r.URL.Query().Get(paramTag))
+	if q.Label, err = labelParam(r); err != nil {
+		return nil, err
+	}
+	q.Search = strings.TrimSpace(r.URL.Query().Get(paramSearch))
+	if utf8.RuneCountInString(q.Search) > maxSearchLength {
+		return nil, fmt.Errorf("%s must be at most %d characters", paramSearch, maxSearchLength)
+	}
+	return q, nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//WriteQuota limits how many tasks each client can create within
+//a sliding window of time, so that a buggy client can't fill up
+//the store. It remembers when each of a client's recent tasks
+//were created, which is at most `max` times per client, and
+//forgets clients that haven't created any within the window.
+type WriteQuota struct {
+	mx      sync.Mutex
+	max     int
+	window  time.Duration
+	clients map[string][]time.Time
+	//swept is when idle clients were last forgotten
+	swept time.Time
+	//now returns the current time; tests replace it
+	now func() time.Time
+}
+
+//NewWriteQuota creates a WriteQuota that lets each
+//client create `max` tasks within any `window` of time
+func NewWriteQuota(max int, window time.Duration) *WriteQuota {
+	return &WriteQuota{
+		max:     max,
+		window:  window,
+		clients: map[string][]time.Time{},
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+//take counts `n` new tasks created by the client identified
+//by `key`, unless that would take it over the quota. It returns
+//how many more the client can create, and if the new tasks
+//weren't counted, how long until enough of the client's earlier
+//tasks leave the window that they would be.
+func (wq *WriteQuota) take(key string, n int) (int, time.Duration, bool) {
+	wq.mx.Lock()
+	defer wq.mx.Unlock()
+	now := wq.now()
+	wq.sweep(now)
+
+	//drop the times that have slid out of the window
+	created := wq.clients[key]
+	for len(created) > 0 && now.Sub(created[0]) >= wq.window {
+		created = created[1:]
+	}
+	if len(created)+n > wq.max {
+		wait := wq.window
+		//a batch bigger than the whole quota can never fit
+		if n <= wq.max {
+			wait = created[len(created)+n-wq.max-1].Add(wq.window).Sub(now)
+		}
+		wq.store(key, created)
+		return wq.max - len(created), wait, false
+	}
+	for i := 0; i < n; i++ {
+		created = append(created, now)
+	}
+	wq.store(key, created)
+	return wq.max - len(created), 0, true
+}
+
+//giveBack stops counting `n` of the tasks most recently
+//counted for the client identified by `key`, as they weren't
+//created after all. It returns how many more the client can create.
+func (wq *WriteQuota) giveBack(key string, n int) int {
+	wq.mx.Lock()
+	defer wq.mx.Unlock()
+	created := wq.clients[key]
+	if n > len(created) {
+		n = len(created)
+	}
+	created = created[:len(created)-n]
+	wq.store(key, created)
+	return wq.max - len(created)
+}
+
+//store saves the times that `key` created tasks,
+//forgetting the client if there aren't any
+func (wq *WriteQuota) store(key string, created []time.Time) {
+	if len(created) == 0 {
+		delete(wq.clients, key)
+		return
+	}
+	wq.clients[key] = created
+}
+
+//sweep forgets the clients that haven't created a task
+//within the window, at most once per window, so that memory
+//doesn't grow with every client that has ever created one.
+//It must be called with the lock held.
+func (wq *WriteQuota) sweep(now time.Time) {
+	if now.Sub(wq.swept) < wq.window {
+		return
+	}
+	wq.swept = now
+	for key, created := range wq.clients {
+		if now.Sub(created[len(created)-1]) >= wq.window {
+			delete(wq.clients, key)
+		}
+	}
+}
+
+//quotaKey returns the key identifying the client that made
+//`r` to the WriteQuota: its user, or its IP address if it
+//doesn't say who it is
+func (ctx *Context) quotaKey(r *http.Request) string {
+	if owner := ctx.owner(r); len(owner) > 0 {
+		return "user:" + owner
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+//takeQuota counts `n` new tasks against the write quota of the
+//client that made `r`, if the Context has a WriteQuota, and sets
+//the X-RateLimit-Remaining header. If that would go over the
+//quota, it responds with a 429 and returns false.
+func (ctx *Context) takeQuota(w http.ResponseWriter, r *http.Request, n int) bool {
+	if ctx.Quota == nil {
+		return true
+	}
+	remaining, wait, ok := ctx.Quota.take(ctx.quotaKey(r), n)
+	w.Header().Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+	if !ok {
+		ctx.logf(r, "rejected %d new tasks over the write quota", n)
+		w.Header().Set(headerRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondError(w, http.StatusTooManyRequests, codeTooManyRequests,
+			fmt.Sprintf("too many new tasks: at most %d can be created every %v", ctx.Quota.max, ctx.Quota.window))
+		return false
+	}
+	return true
+}
+
+//returnQuota gives back `n` new tasks taken from the write
+//quota by takeQuota, when they weren't created after all,
+//and updates the X-RateLimit-Remaining header to match
+func (ctx *Context) returnQuota(w http.ResponseWriter, r *http.Request, n int) {
+	if ctx.Quota == nil {
+		return
+	}
+	remaining := ctx.Quota.giveBack(ctx.quotaKey(r), n)
+	w.Header().Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+}
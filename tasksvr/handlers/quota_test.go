@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//newQuotaContext returns a Context whose write quota allows
+//`max` new tasks per hour, and a pointer to the quota's clock
+func newQuotaContext(t *testing.T, max int) (*Context, *time.Time) {
+	ctx, _ := newTestContext(t)
+	now := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx.Quota = NewWriteQuota(max, time.Hour)
+	ctx.Quota.now = func() time.Time { return now }
+	ctx.Quota.swept = now
+	//the tests post the same task over and over
+	ctx.DuplicateWindow = 0
+	return ctx, &now
+}
+
+//fromAddr wraps `handler` so that each request
+//seems to come from the client at `addr`
+func fromAddr(handler http.HandlerFunc, addr string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = addr
+		handler(w, r)
+	}
+}
+
+func TestWriteQuota(t *testing.T) {
+	ctx, now := newQuotaContext(t, 3)
+	alice := "alice"
+
+	for i, remaining := range []string{"2", "1", "0"} {
+		*now = now.Add(10 * time.Minute)
+		w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "Learn Go"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("task %d: expected status %d but got %d: %s", i, http.StatusCreated, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get(headerRateLimitRemaining); got != remaining {
+			t.Errorf("task %d: expected %s remaining but got %q", i, remaining, got)
+		}
+	}
+
+	//the fourth is over the quota, until the first leaves the window
+	w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "Learn Go"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusTooManyRequests, w.Code, w.Body.String())
+	}
+	if apierr := decodeError(t, w); apierr.Code != codeTooManyRequests {
+		t.Errorf("expected code %s but got %s", codeTooManyRequests, apierr.Code)
+	}
+	if got := w.Header().Get(headerRetryAfter); got != "2400" {
+		t.Errorf("expected Retry-After 2400 but got %q", got)
+	}
+	if got := w.Header().Get(headerRateLimitRemaining); got != "0" {
+		t.Errorf("expected 0 remaining but got %q", got)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{Owner: &alice}); total != 3 {
+		t.Errorf("expected 3 tasks to have been created but got %d", total)
+	}
+
+	//reads aren't limited
+	w = doAs(ctx.HandleTasks, "alice", "GET", "/v1/tasks", "")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected reads to be allowed but got %d", w.Code)
+	}
+
+	//other users have their own quota
+	if w := doAs(ctx.HandleTasks, "bob", "POST", "/v1/tasks", `{"title": "Learn Go"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected bob to be allowed but got %d", w.Code)
+	}
+
+	//the window slides past the first task
+	*now = now.Add(40 * time.Minute)
+	w = doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "Learn Go"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d after the window slid but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(headerRateLimitRemaining); got != "0" {
+		t.Errorf("expected 0 remaining but got %q", got)
+	}
+}
+
+func TestWriteQuotaBatch(t *testing.T) {
+	ctx, _ := newQuotaContext(t, 3)
+	alice := "alice"
+
+	w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `[{"title": "a"}, {"title": "b"}]`)
+	if w.Code != http.StatusCreated || w.Header().Get(headerRateLimitRemaining) != "1" {
+		t.Fatalf("expected the batch to leave 1 but got %d, %q", w.Code, w.Header().Get(headerRateLimitRemaining))
+	}
+	//a batch that doesn't fit is rejected whole
+	w = doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `[{"title": "c"}, {"title": "d"}]`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d but got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{Owner: &alice}); total != 2 {
+		t.Errorf("expected 2 tasks but got %d", total)
+	}
+	//invalid tasks don't count
+	if w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "e"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected the last task to fit but got %d", w.Code)
+	}
+}
+
+func TestWriteQuotaByIP(t *testing.T) {
+	ctx, _ := newQuotaContext(t, 1)
+
+	if w := doWith(fromAddr(ctx.HandleTasks, "10.0.0.1:1234"), "POST", "/v1/tasks", `{"title": "a"}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d", http.StatusCreated, w.Code)
+	}
+	//a different port is still the same client
+	if w := doWith(fromAddr(ctx.HandleTasks, "10.0.0.1:5678"), "POST", "/v1/tasks", `{"title": "b"}`, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d but got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if w := doWith(fromAddr(ctx.HandleTasks, "10.0.0.2:1234"), "POST", "/v1/tasks", `{"title": "c"}`, nil); w.Code != http.StatusCreated {
+		t.Errorf("expected another IP to be allowed but got %d", w.Code)
+	}
+}
+
+func TestWriteQuotaEvictsIdleClients(t *testing.T) {
+	wq := NewWriteQuota(2, time.Hour)
+	now := time.Now()
+	wq.now = func() time.Time { return now }
+	wq.take("alice", 1)
+	wq.take("bob", 1)
+
+	now = now.Add(2 * time.Hour)
+	wq.take("carol", 1)
+	if len(wq.clients) != 1 {
+		t.Errorf("expected only carol to be remembered but got %d clients", len(wq.clients))
+	}
+}
+
+func TestWriteQuotaGiveBack(t *testing.T) {
+	wq := NewWriteQuota(2, time.Hour)
+	wq.take("alice", 2)
+	if remaining := wq.giveBack("alice", 1); remaining != 1 {
+		t.Errorf("expected 1 remaining but got %d", remaining)
+	}
+	if _, _, ok := wq.take("alice", 1); !ok {
+		t.Error("expected the given back task to fit")
+	}
+	//giving back more than was taken forgets the client
+	if remaining := wq.giveBack("alice", 5); remaining != 2 || len(wq.clients) != 0 {
+		t.Errorf("expected 2 remaining and no clients but got %d, %v", remaining, wq.clients)
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//the codes in error responses, which clients can rely on
+//to tell errors apart, unlike the messages
+const (
+	codeInvalidJSON          = "invalid_json"
+	codeBodyTooLarge         = "body_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeValidationFailed     = "validation_failed"
+	codeInvalidQuery         = "invalid_query"
+	codeInvalidUpload        = "invalid_upload"
+	codeInvalidID            = "invalid_id"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeForbidden            = "forbidden"
+	codeConflict             = "conflict"
+	codeIdempotencyConflict  = "idempotency_conflict"
+	codeTooManyRequests      = "too_many_requests"
+	codePreconditionFailed   = "precondition_failed"
+	codePreconditionRequired = "precondition_required"
+	codeUnavailable          = "unavailable"
+	codeStoreError           = "store_error"
+	codeInternalError        = "internal_error"
+)
+
+//errorResponse is the JSON body sent with error responses,
+//like {"error": {"code": "not_found", "message": "...", "status": 404}}
+type errorResponse struct {
+	Error *apiError `json:"error"`
+}
+
+//apiError describes what went wrong
+type apiError struct {
+	//Code is one of the codes above
+	Code string `json:"code"`
+	//Message explains the error to a person
+	Message string `json:"message"`
+	//Status is the same as the response's status code
+	Status int `json:"status"`
+	//Fields lists every problem with an invalid task
+	Fields []*tasks.FieldError `json:"fields,omitempty"`
+	//Invalid lists why each invalid task in a batch is invalid
+	Invalid []indexError `json:"invalid,omitempty"`
+	//Failed lists why each invalid row in an import is invalid
+	Failed []*importFailure `json:"failed,omitempty"`
+	//Existing is the task that a new one duplicates,
+	//in the shape of the API version it was sent to
+	Existing interface{} `json:"existing,omitempty"`
+}
+
+//indexError is why the task at Index in a batch is invalid
+type indexError struct {
+	Index  int                 `json:"index"`
+	Error  string              `json:"error"`
+	Fields []*tasks.FieldError `json:"fields,omitempty"`
+}
+
+//taskPage is the JSON body sent by GET /v1/tasks?after=,
+//which pages through tasks with a cursor. NextCursor is
+//the ?after= for the next page, or "" on the last page.
+type taskPage struct {
+	Tasks      []*tasks.Task `json:"tasks"`
+	NextCursor string        `json:"nextCursor"`
+}
+
+//taskBatch is the JSON body sent by GET /v1/tasks?ids=,
+//holding the tasks in the order they were asked for.
+//Missing lists the IDs that weren't found.
+type taskBatch struct {
+	Tasks   []*tasks.Task `json:"tasks"`
+	Missing []string      `json:"missing"`
+}
+
+//groupedResponse is the JSON body sent by GET /v1/tasks/grouped
+type groupedResponse struct {
+	Active   []*tasks.Task `json:"active"`
+	Complete []*tasks.Task `json:"complete"`
+}
+
+//healthResponse is the JSON body sent by GET /v1/health
+type healthResponse struct {
+	Status string `json:"status"`
+	Store  string `json:"store"`
+}
+
+//importReport is the JSON body sent after importing tasks
+type importReport struct {
+	Created int              `json:"created"`
+	Failed  []*importFailure `json:"failed"`
+}
+
+//importFailure is why the row of an import
+//that starts on line Line is invalid
+type importFailure struct {
+	Line   int                 `json:"line"`
+	Error  string              `json:"error"`
+	Fields []*tasks.FieldError `json:"fields,omitempty"`
+}
+
+//batchUpdate is the JSON body of PATCH /v1/tasks,
+//which applies the same updates to several tasks
+type batchUpdate struct {
+	IDs     []string           `json:"ids"`
+	Updates *tasks.TaskUpdates `json:"updates"`
+}
+
+//batchUpdateResponse is the JSON body sent after updating
+//several tasks at once. Matched is how many of the tasks
+//were found, and Modified how many of those were changed,
+//as the ones already as the updates would make them are
+//left alone. NotFound lists the IDs that weren't found.
+type batchUpdateResponse struct {
+	Matched  int      `json:"matched"`
+	Modified int      `json:"modified"`
+	NotFound []string `json:"notFound"`
+}
+
+//deleteResponse is the JSON body sent after
+//deleting several tasks at once
+type deleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+//respond writes `v` to the response as JSON
+//with the given status code
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	//encode before writing anything, so that if it fails,
+	//the client can still be sent an error instead
+	buf, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("error encoding response: %v", err)
+		status = http.StatusInternalServerError
+		buf, _ = json.Marshal(&errorResponse{Error: &apiError{
+			Code:    codeInternalError,
+			Message: "error encoding response",
+			Status:  status,
+		}})
+	}
+	w.Header().Set(headerContentType, contentTypeJSONUTF8)
+	w.WriteHeader(status)
+	w.Write(append(buf, '\n'))
+}
+
+//respondError writes an error response with
+//the given status code, error code and message
+func respondError(w http.ResponseWriter, status int, code string, msg string) {
+	respond(w, status, &errorResponse{Error: &apiError{Code: code, Message: msg, Status: status}})
+}
+
+//fieldErrors returns the problems listed by `err`,
+//if it's a *tasks.ValidationError, or nil if it isn't
+func fieldErrors(err error) []*tasks.FieldError {
+	if ve, ok := err.(*tasks.ValidationError); ok {
+		return ve.Fields
+	}
+	return nil
+}
+
+//respondInvalid writes a 400 validation_failed response
+//for `err`, an error returned by one of the Validate methods,
+//listing every problem with the task's fields
+func respondInvalid(w http.ResponseWriter, msg string, err error) {
+	respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+		Code:    codeValidationFailed,
+		Message: msg + ": " + err.Error(),
+		Status:  http.StatusBadRequest,
+		Fields:  fieldErrors(err),
+	}})
+}
+
+//checkMethod returns true if the request method is one of the
+//comma-separated methods in `allow`. If it isn't, it answers
+//the request and returns false: OPTIONS requests get a 204,
+//and other methods a 405, both with an Allow header.
+func checkMethod(w http.ResponseWriter, r *http.Request, allow string) bool {
+	if r.Method != "OPTIONS" {
+		for _, method := range strings.Split(allow, ", ") {
+			if r.Method == method {
+				return true
+			}
+		}
+	}
+	w.Header().Set(headerAllow, allow)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	respondError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method "+r.Method+" is not allowed")
+	return false
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestErrorCodes(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	ctx.MaxBodyBytes = 100
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+	missing := "/v1/tasks/" + bson.NewObjectId().Hex()
+
+	cases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		method         string
+		path           string
+		body           string
+		ifMatch        string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"invalid JSON", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": `, "", http.StatusBadRequest, codeInvalidJSON},
+		{"unknown field", ctx.HandleTasks, "POST", "/v1/tasks", `{"titel": "a"}`, "", http.StatusBadRequest, codeInvalidJSON},
+		{"too large", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": "` + strings.Repeat("x", 100) + `"}`, "", http.StatusBadRequest, codeBodyTooLarge},
+		{"invalid task", ctx.HandleTasks, "POST", "/v1/tasks", `{"title": ""}`, "", http.StatusBadRequest, codeValidationFailed},
+		{"invalid batch", ctx.HandleTasks, "POST", "/v1/tasks", `[{"title": ""}]`, "", http.StatusBadRequest, codeValidationFailed},
+		{"invalid query", ctx.HandleTasks, "GET", "/v1/tasks?limit=lots", "", "", http.StatusBadRequest, codeInvalidQuery},
+		{"not an admin", ctx.HandleTasks, "GET", "/v1/tasks?all=true", "", "", http.StatusForbidden, codeForbidden},
+		{"method not allowed", ctx.HandleTasks, "PUT", "/v1/tasks", "", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"invalid ID", ctx.HandleSpecificTask, "GET", "/v1/tasks/1234", "", "", http.StatusBadRequest, codeInvalidID},
+		{"not found", ctx.HandleSpecificTask, "GET", missing, "", "", http.StatusNotFound, codeNotFound},
+		{"no such sub-resource", ctx.HandleSpecificTask, "GET", path + "/nope", "", "", http.StatusNotFound, codeNotFound},
+		{"invalid updates", ctx.HandleSpecificTask, "PATCH", path, `{}`, "", http.StatusBadRequest, codeValidationFailed},
+		{"stale version", ctx.HandleSpecificTask, "PATCH", path, `{"complete": true}`, `"7"`, http.StatusPreconditionFailed, codePreconditionFailed},
+		{"not deleted", ctx.HandleSpecificTask, "POST", path + "/restore", "", "", http.StatusConflict, codeConflict},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if len(c.ifMatch) > 0 {
+			r.Header.Set(headerIfMatch, c.ifMatch)
+		}
+		c.handler(w, r)
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		if apierr := decodeError(t, w); apierr.Code != c.expectedCode {
+			t.Errorf("%s: expected code %s but got %s", c.name, c.expectedCode, apierr.Code)
+		}
+	}
+
+	//the store failing is the server's fault
+	ctx, _ = newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", missing, nil))
+	if apierr := decodeError(t, w); apierr.Code != codeStoreError || apierr.Status != http.StatusInternalServerError {
+		t.Errorf("unexpected error for a store failure: %+v", apierr)
+	}
+}
+
+func TestRespondEncodeError(t *testing.T) {
+	//NaN can't be encoded as JSON
+	w := httptest.NewRecorder()
+	respond(w, http.StatusOK, math.NaN())
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	if apierr := decodeError(t, w); apierr.Code != codeInternalError {
+		t.Errorf("expected code %s but got %s", codeInternalError, apierr.Code)
+	}
+}
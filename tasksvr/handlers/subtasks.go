@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Subtasks are changed with the store's subtask methods, which
+//change just the one subtask, so that clients ticking off
+//different items at the same time don't overwrite each other.
+//For the same reason, they don't check If-Match: the task's
+//version still goes up, but a client doesn't need to have
+//seen the other subtasks' changes to change its own.
+
+//handleSubtasks handles requests for the /v1/tasks/some-task-id/subtasks
+//sub-resource: POST adds a subtask to the end of the task's checklist,
+//and responds with the updated task
+func (ctx *Context) handleSubtasks(w http.ResponseWriter, r *http.Request, idHex string) {
+	if !checkMethod(w, r, allowSubtasks) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+	newsub := &tasks.NewSubtask{}
+	if err := ctx.readJSON(w, r, newsub); err != nil {
+		ctx.respondBodyError(w, r, err)
+		return
+	}
+	if err := newsub.Validate(); err != nil {
+		ctx.logf(r, "rejected invalid subtask for task %s: %v", id.Hex(), err)
+		respondInvalid(w, "error validating subtask", err)
+		return
+	}
+	if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+		ctx.respondStoreError(w, r, err, id, "updating")
+		return
+	}
+
+	task, err := ctx.TasksStore.AddSubtask(id, newsub)
+	if err != nil {
+		ctx.respondSubtaskError(w, r, err, id, "")
+		return
+	}
+	//it's always added to the end
+	added := task.Subtasks[len(task.Subtasks)-1]
+	w.Header().Set(headerLocation, specificTaskPrefix(r.URL.Path)+id.Hex()+"/"+subSubtasks+"/"+added.ID)
+	ctx.respondSubtasksChanged(w, r, http.StatusCreated, task)
+}
+
+//handleSubtask handles requests for the /v1/tasks/some-task-id/subtasks/some-subtask-id
+//sub-resource: PATCH updates the subtask's title and/or complete flag, and DELETE
+//removes it. Both respond with the updated task.
+func (ctx *Context) handleSubtask(w http.ResponseWriter, r *http.Request, idHex string, subID string) {
+	if len(subID) == 0 || strings.Contains(subID, "/") {
+		respondError(w, http.StatusNotFound, codeNotFound, "no such subtask resource "+subID)
+		return
+	}
+	if !checkMethod(w, r, allowSubtask) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+
+	var updates *tasks.SubtaskUpdates
+	if r.Method == "PATCH" {
+		updates = &tasks.SubtaskUpdates{}
+		if err := ctx.readJSON(w, r, updates); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if err := updates.Validate(); err != nil {
+			ctx.logf(r, "rejected invalid updates for subtask %s of task %s: %v", subID, id.Hex(), err)
+			respondInvalid(w, "error validating updates", err)
+			return
+		}
+	}
+	if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+		ctx.respondStoreError(w, r, err, id, "updating")
+		return
+	}
+
+	var task *tasks.Task
+	if updates != nil {
+		task, err = ctx.TasksStore.UpdateSubtask(id, subID, updates)
+	} else {
+		task, err = ctx.TasksStore.DeleteSubtask(id, subID)
+	}
+	if err != nil {
+		ctx.respondSubtaskError(w, r, err, id, subID)
+		return
+	}
+	ctx.respondSubtasksChanged(w, r, http.StatusOK, task)
+}
+
+//respondSubtaskError responds to a request for the subtask with
+//ID `subID` of the task with ID `id`, or to add a subtask if
+//`subID` is empty, that the store failed
+func (ctx *Context) respondSubtaskError(w http.ResponseWriter, r *http.Request, err error, id bson.ObjectId, subID string) {
+	switch err {
+	case tasks.ErrSubtaskNotFound:
+		respondError(w, http.StatusNotFound, codeNotFound, "task "+id.Hex()+" has no subtask with ID "+subID)
+	case tasks.ErrTooManySubtasks:
+		respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+			Code:    codeValidationFailed,
+			Message: "task " + id.Hex() + " already has the most subtasks a task can have",
+			Status:  http.StatusBadRequest,
+			Fields: []*tasks.FieldError{{
+				Field:   "subtasks",
+				Code:    tasks.CodeTooMany,
+				Message: "tasks can have at most " + strconv.Itoa(tasks.MaxSubtasks) + " subtasks",
+			}},
+		}})
+	default:
+		ctx.respondStoreError(w, r, err, id, "updating the subtasks of")
+	}
+}
+
+//respondSubtasksChanged responds with `task` after its subtasks
+//have changed, first marking it complete if they're all complete
+//and the Context's AutoCompleteTasks is set
+func (ctx *Context) respondSubtasksChanged(w http.ResponseWriter, r *http.Request, status int, task *tasks.Task) {
+	if ctx.AutoCompleteTasks && !task.Complete && task.AllSubtasksComplete() {
+		complete := true
+		completed, err := ctx.TasksStore.Update(task.ID, &tasks.TaskUpdates{Complete: &complete})
+		if err != nil {
+			ctx.respondStoreError(w, r, err, task.ID.(bson.ObjectId), "completing")
+			return
+		}
+		task = completed
+	}
+	w.Header().Set(headerETag, taskETag(task))
+	respondTasks(w, r, status, task)
+	ctx.notify(EventUpdated, task)
+}
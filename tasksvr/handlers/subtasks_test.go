@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//doSubtaskRequest sends a request to HandleSpecificTask as
+//`user`, returning the response and the task in its body
+func doSubtaskRequest(t *testing.T, ctx *Context, user string, method string, path string, body string) (*httptest.ResponseRecorder, *tasks.Task) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set(headerUser, user)
+	ctx.HandleSpecificTask(w, r)
+	task := &tasks.Task{}
+	if w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), task); err != nil {
+			t.Fatalf("%s %s: error decoding task: %v", method, path, err)
+		}
+	}
+	return w, task
+}
+
+func TestSubtasks(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Plan trip")
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/subtasks"
+
+	w, task := doSubtaskRequest(t, ctx, "", "POST", path, `{"title": "Book flights"}`)
+	if w.Code != http.StatusCreated || len(task.Subtasks) != 1 {
+		t.Fatalf("expected status %d with one subtask but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	flights := task.Subtasks[0].ID
+	if location := w.Header().Get(headerLocation); location != path+"/"+flights {
+		t.Errorf("expected Location %s but got %s", path+"/"+flights, location)
+	}
+	if etag := w.Header().Get(headerETag); etag != taskETag(task) {
+		t.Errorf("expected ETag %s but got %s", taskETag(task), etag)
+	}
+	_, task = doSubtaskRequest(t, ctx, "", "POST", path, `{"title": "Book hotel"}`)
+	hotel := task.Subtasks[1].ID
+
+	w, task = doSubtaskRequest(t, ctx, "", "PATCH", path+"/"+flights, `{"complete": true}`)
+	if w.Code != http.StatusOK || !task.Subtasks[0].Complete || task.Subtasks[1].Complete || task.Subtasks[0].Title != "Book flights" {
+		t.Errorf("expected only the flights to be complete, got %d %s", w.Code, w.Body.String())
+	}
+	w, task = doSubtaskRequest(t, ctx, "", "PATCH", path+"/"+hotel+"/", `{"title": "Book a hostel"}`)
+	if w.Code != http.StatusOK || task.Subtasks[1].Title != "Book a hostel" {
+		t.Errorf("expected the hotel to be renamed, got %d %s", w.Code, w.Body.String())
+	}
+	//without AutoCompleteTasks, the task isn't completed
+	if task.Complete {
+		t.Errorf("expected the task to be incomplete")
+	}
+
+	w, task = doSubtaskRequest(t, ctx, "", "DELETE", path+"/"+flights, "")
+	if w.Code != http.StatusOK || len(task.Subtasks) != 1 || task.Subtasks[0].ID != hotel {
+		t.Errorf("expected only the hotel to be left, got %d %s", w.Code, w.Body.String())
+	}
+
+	//the /v2 API sends them too
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("POST", strings.Replace(path, "/v1/", "/v2/", 1), strings.NewReader(`{"title": "Pack"}`)))
+	if !strings.Contains(w.Body.String(), `"subtasks":[{"id":"`+hotel+`","title":"Book a hostel","complete":false},{"id":"`) {
+		t.Errorf("expected the v2 subtasks, got %d %s", w.Code, w.Body.String())
+	}
+
+	requests := []struct {
+		user           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"", "PATCH", path + "/" + flights, `{"complete": true}`, http.StatusNotFound, codeNotFound},
+		{"", "DELETE", path + "/" + flights, "", http.StatusNotFound, codeNotFound},
+		{"", "PATCH", path + "/" + hotel + "/extra", `{"complete": true}`, http.StatusNotFound, codeNotFound},
+		{"", "PATCH", path + "/" + hotel, `{}`, http.StatusBadRequest, codeValidationFailed},
+		{"", "POST", path, `{"title": ""}`, http.StatusBadRequest, codeValidationFailed},
+		{"", "GET", path, "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"", "POST", path + "/" + hotel, "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"", "POST", "/v1/tasks/nope/subtasks", `{"title": "x"}`, http.StatusBadRequest, codeInvalidID},
+		//other users can't see the task at all
+		{"mallory", "POST", path, `{"title": "x"}`, http.StatusNotFound, codeNotFound},
+		{"mallory", "DELETE", path + "/" + hotel, "", http.StatusNotFound, codeNotFound},
+	}
+	for _, req := range requests {
+		w, _ := doSubtaskRequest(t, ctx, req.user, req.method, req.path, req.body)
+		if w.Code != req.expectedStatus {
+			t.Errorf("%s %s: expected status %d but got %d: %s", req.method, req.path, req.expectedStatus, w.Code, w.Body.String())
+			continue
+		}
+		if apierr := decodeError(t, w); apierr.Code != req.expectedCode {
+			t.Errorf("%s %s: expected code %s but got %s", req.method, req.path, req.expectedCode, apierr.Code)
+		}
+	}
+}
+
+func TestSubtasksLimit(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Plan trip")
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/subtasks"
+	for i := 0; i < tasks.MaxSubtasks; i++ {
+		ctx.TasksStore.AddSubtask(seeded[0].ID, &tasks.NewSubtask{Title: "Pack"})
+	}
+	w, _ := doSubtaskRequest(t, ctx, "", "POST", path, `{"title": "One too many"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	apierr := decodeError(t, w)
+	if apierr.Code != codeValidationFailed || len(apierr.Fields) != 1 || apierr.Fields[0].Field != "subtasks" {
+		t.Errorf("expected a validation error for the subtasks field, got %+v", apierr)
+	}
+}
+
+func TestSubtasksAutoComplete(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Plan trip")
+	ctx.AutoCompleteTasks = true
+	ctx.TasksStore.AddSubtask(seeded[0].ID, &tasks.NewSubtask{Title: "Book flights"})
+	task, _ := ctx.TasksStore.AddSubtask(seeded[0].ID, &tasks.NewSubtask{Title: "Book hotel"})
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/subtasks/"
+
+	_, task = doSubtaskRequest(t, ctx, "", "PATCH", path+task.Subtasks[0].ID, `{"complete": true}`)
+	if task.Complete {
+		t.Errorf("expected the task to be incomplete with a subtask left")
+	}
+	w, task := doSubtaskRequest(t, ctx, "", "PATCH", path+task.Subtasks[1].ID, `{"complete": true}`)
+	if !task.Complete {
+		t.Errorf("expected the task to be completed with its last subtask")
+	}
+	if stored, _ := ctx.TasksStore.Get(seeded[0].ID); !stored.Complete || w.Header().Get(headerETag) != taskETag(stored) {
+		t.Errorf("expected the completed task's ETag %s but got %s", taskETag(stored), w.Header().Get(headerETag))
+	}
+}
+
+func TestSubtasksConcurrent(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Plan trip")
+	const n = 20
+	var task *tasks.Task
+	for i := 0; i < n; i++ {
+		task, _ = ctx.TasksStore.AddSubtask(seeded[0].ID, &tasks.NewSubtask{Title: fmt.Sprintf("Pack %d", i)})
+	}
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/subtasks/"
+
+	//every client's change survives, even though
+	//none of them has seen the others'
+	done := make(chan int)
+	for i, st := range task.Subtasks {
+		go func(i int, subID string) {
+			w := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"title": "Packed %d", "complete": true}`, i)
+			ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", path+subID, strings.NewReader(body)))
+			done <- w.Code
+		}(i, st.ID)
+	}
+	for i := 0; i < n; i++ {
+		if status := <-done; status != http.StatusOK {
+			t.Errorf("expected status %d but got %d", http.StatusOK, status)
+		}
+	}
+	stored, _ := ctx.TasksStore.Get(seeded[0].ID)
+	for i, st := range stored.Subtasks {
+		if !st.Complete || st.Title != fmt.Sprintf("Packed %d", i) {
+			t.Errorf("subtask %d: lost an update, got %+v", i, st)
+		}
+	}
+	if stored.Version != task.Version+n {
+		t.Errorf("expected version %d but got %d", task.Version+n, stored.Version)
+	}
+}
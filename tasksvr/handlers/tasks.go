@@ -1,40 +1,885 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
 )
 
 //HandleTasks will handle requests for the /v1/tasks resource
 func (ctx *Context) HandleTasks(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTasks) {
+		return
+	}
+
 	switch r.Method {
+	case "GET":
+		//?ids= asks for particular tasks, rather than a page
+		if _, ok := r.URL.Query()[paramIDs]; ok {
+			ctx.getTaskBatch(w, r)
+			return
+		}
+		q, ok := ctx.ownerQuery(w, r)
+		if !ok {
+			return
+		}
+		//clients that already have the list can ask
+		//for it only if it's changed since they got it
+		lastModified, err := ctx.lastModified(q.Owner)
+		if err != nil {
+			ctx.logf(r, "error getting tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error getting tasks: "+err.Error())
+			return
+		}
+		if ctx.checkNotModified(w, r, lastModified) {
+			return
+		}
+		//with ?after=, pages don't shift when tasks are
+		//created between them, as they do with ?skip=
+		paging, err := cursorParam(r, q)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+		found, total, err := ctx.TasksStore.Find(q)
+		if err != nil {
+			ctx.logf(r, "error getting tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error getting tasks: "+err.Error())
+			return
+		}
+		//encode an empty page as [] rather than null
+		if found == nil {
+			found = []*tasks.Task{}
+		}
+		if paging {
+			//the total counts the tasks after the cursor,
+			//so if it's more than this page, there's another
+			page := &taskPage{Tasks: found}
+			if total > len(found) {
+				page.NextCursor = found[len(found)-1].ID.(bson.ObjectId).Hex()
+			}
+			respondTasks(w, r, http.StatusOK, page)
+			return
+		}
+		//the total lets clients work out how many pages there are
+		w.Header().Set(headerTotalCount, strconv.Itoa(total))
+		respondTasks(w, r, http.StatusOK, found)
+
 	case "POST":
-		decoder := json.NewDecoder(r.Body)
+		body, err := ctx.readBody(w, r)
+		if err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		//an array is a batch of new tasks
+		if tok, _ := json.NewDecoder(bytes.NewReader(body)).Token(); tok == json.Delim('[') {
+			ctx.postTaskBatch(w, r, body)
+			return
+		}
+
 		newtask := &tasks.NewTask{}
-		if err := decoder.Decode(newtask); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if err := decodeJSON(body, newtask); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if !ctx.applyIdempotencyKey(w, r, newtask) {
 			return
 		}
 
 		if err := newtask.Validate(); err != nil {
-			http.Error(w, "error validating task: "+err.Error(), http.StatusBadRequest)
+			ctx.logf(r, "rejected invalid task: %v", err)
+			respondInvalid(w, "error validating task", err)
+			return
+		}
+		newtask.Owner = ctx.owner(r)
+		if !ctx.checkDuplicate(w, r, newtask) {
+			return
+		}
+		if ctx.respondIfRepeated(w, r, newtask) {
+			return
+		}
+		if !ctx.takeQuota(w, r, 1) {
 			return
 		}
 
 		task, err := ctx.TasksStore.Insert(newtask)
+		if err == tasks.ErrDuplicateClientKey {
+			//a retry raced with the first request,
+			//so it didn't create a task after all
+			ctx.returnQuota(w, r, 1)
+			ctx.respondRepeatedCreate(w, r, newtask)
+			return
+		}
+		if err != nil {
+			ctx.logf(r, "error inserting task: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error inserting task: "+err.Error())
+			return
+		}
+
+		//tell the client where the new task lives; headers
+		//must be set before respond() writes the status
+		w.Header().Set(headerLocation, specificTaskPrefix(r.URL.Path)+task.ID.(bson.ObjectId).Hex())
+		w.Header().Set(headerETag, taskETag(task))
+		respondTasks(w, r, http.StatusCreated, task)
+		ctx.notify(EventCreated, task)
+
+	case "PATCH":
+		ctx.patchTaskBatch(w, r)
+
+	case "DELETE":
+		//deletes all the tasks matching the same filters as GET,
+		//like ?complete=true to clear the completed tasks.
+		//WebSocket clients aren't told about each one, as
+		//the store doesn't say which tasks it deleted.
+		q, ok := ctx.ownerQuery(w, r)
+		if !ok {
+			return
+		}
+		permanent, ok := ctx.permanentParam(w, r)
+		if !ok {
+			return
+		}
+		if !q.Filtered() {
+			respondError(w, http.StatusBadRequest, codeInvalidQuery, "refusing to delete every task: add a filter, like ?complete=true")
+			return
+		}
+		if q.Archived && !permanent {
+			respondError(w, http.StatusBadRequest, codeInvalidQuery, "archived tasks are already deleted: admins can remove them with "+paramPermanent+"=true")
+			return
+		}
+		var deleted int
+		var err error
+		if permanent {
+			deleted, err = ctx.TasksStore.DeleteMany(q)
+			ctx.recordPurge()
+		} else {
+			//archive them, so they can be restored
+			archivedAt := ctx.now()
+			deleted, err = ctx.TasksStore.UpdateMany(q, &tasks.TaskUpdates{ArchivedAt: &archivedAt})
+		}
+		if err != nil {
+			ctx.logf(r, "error deleting tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error deleting tasks: "+err.Error())
+			return
+		}
+		respond(w, http.StatusOK, &deleteResponse{Deleted: deleted})
+	}
+}
+
+//checkDuplicate returns true if `newtask` can be created. If its
+//owner created a task with the same title, ignoring case and the
+//spaces around it, within the last DuplicateWindow, which hasn't
+//been deleted, it answers the request with a 409 including that
+//task, and returns false, unless the request has ?force=true.
+//Tasks with a ClientKey aren't checked, as the key already
+//tells a repeated request from a new task with the same title.
+func (ctx *Context) checkDuplicate(w http.ResponseWriter, r *http.Request, newtask *tasks.NewTask) bool {
+	force, err := boolParam(r, paramForce)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return false
+	}
+	if force != nil && *force || ctx.DuplicateWindow <= 0 || len(newtask.ClientKey) > 0 {
+		return true
+	}
+	since := ctx.now().Add(-ctx.DuplicateWindow)
+	found, _, err := ctx.TasksStore.Find(&tasks.Query{
+		Owner:        &newtask.Owner,
+		Title:        newtask.Title,
+		CreatedAfter: &since,
+		Sort:         "-createdAt",
+		Limit:        1,
+	})
+	if err != nil {
+		ctx.logf(r, "error checking for duplicate tasks: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error checking for duplicate tasks: "+err.Error())
+		return false
+	}
+	if len(found) == 0 {
+		return true
+	}
+	var existing interface{} = found[0]
+	if isV2(r.URL.Path) {
+		existing = newTaskV2(found[0])
+	}
+	respond(w, http.StatusConflict, &errorResponse{Error: &apiError{
+		Code:     codeConflict,
+		Message:  "you just created a task with the same title: create it again with " + paramForce + "=true if you meant to",
+		Status:   http.StatusConflict,
+		Existing: existing,
+	}})
+	return false
+}
+
+//postTaskBatch inserts the JSON array of new tasks in `body`,
+//the body of `r`, responding with the created tasks in the
+//same order. If any of them are invalid, none are inserted,
+//and the response lists the index of each invalid one.
+func (ctx *Context) postTaskBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	newtasks := []*tasks.NewTask{}
+	if err := decodeJSON(body, &newtasks); err != nil {
+		ctx.respondBodyError(w, r, err)
+		return
+	}
+	if len(newtasks) == 0 {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, "there must be at least one task in the batch")
+		return
+	}
+	if len(newtasks) > maxBatchSize {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, fmt.Sprintf("there can be at most %d tasks in a batch", maxBatchSize))
+		return
+	}
+	//one key can't stand for many tasks
+	if len(r.Header.Get(headerIdempotencyKey)) > 0 {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, "the "+headerIdempotencyKey+" header can only be used when creating one task")
+		return
+	}
+
+	owner := ctx.owner(r)
+	invalid := []indexError{}
+	indices := []string{}
+	for i, newtask := range newtasks {
+		//null elements decode as nil
+		err := errors.New("task must be an object")
+		if newtask != nil {
+			err = newtask.Validate()
+			newtask.Owner = owner
+			if err == nil && len(newtask.ClientKey) > 0 {
+				err = &tasks.ValidationError{Fields: []*tasks.FieldError{
+					{Field: "clientKey", Code: tasks.CodeInvalid, Message: "clientKey can only be used when creating one task"},
+				}}
+			}
+		}
+		if err != nil {
+			invalid = append(invalid, indexError{Index: i, Error: err.Error(), Fields: fieldErrors(err)})
+			indices = append(indices, strconv.Itoa(i))
+		}
+	}
+	if len(invalid) > 0 {
+		ctx.logf(r, "rejected batch with invalid tasks at indices %s", strings.Join(indices, ", "))
+		respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+			Code:    codeValidationFailed,
+			Message: "invalid tasks at indices " + strings.Join(indices, ", "),
+			Status:  http.StatusBadRequest,
+			Invalid: invalid,
+		}})
+		return
+	}
+	//the batch counts as one new task per element
+	if !ctx.takeQuota(w, r, len(newtasks)) {
+		return
+	}
+
+	inserted, err := ctx.TasksStore.InsertMany(newtasks)
+	if err != nil {
+		ctx.logf(r, "error inserting tasks: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error inserting tasks: "+err.Error())
+		return
+	}
+	respondTasks(w, r, http.StatusCreated, inserted)
+	for _, task := range inserted {
+		ctx.notify(EventCreated, task)
+	}
+}
+
+//patchTaskBatch applies the updates in the body of `r` to
+//each of the caller's tasks it lists, like
+//  {"ids": ["...", "..."], "updates": {"complete": true}}
+//The updates are the same as for PATCH /v1/tasks/some-task-id.
+//If any of the IDs are invalid, none of the tasks are updated.
+//A batch can't say which version of each task it expects,
+//so it's refused when If-Match is required; clients have
+//to PATCH each task with its ETag instead.
+func (ctx *Context) patchTaskBatch(w http.ResponseWriter, r *http.Request) {
+	if ctx.RequireIfMatch {
+		respondError(w, http.StatusPreconditionRequired, codePreconditionRequired, "tasks can't be updated in a batch when If-Match is required: PATCH each task with its ETag instead")
+		return
+	}
+	batch := &batchUpdate{}
+	if err := ctx.readJSON(w, r, batch); err != nil {
+		ctx.respondBodyError(w, r, err)
+		return
+	}
+	if len(batch.IDs) == 0 {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, "there must be at least one ID in ids")
+		return
+	}
+	if len(batch.IDs) > maxBatchSize {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, fmt.Sprintf("there can be at most %d IDs in a batch", maxBatchSize))
+		return
+	}
+	if batch.Updates == nil {
+		respondError(w, http.StatusBadRequest, codeValidationFailed, "updates is required")
+		return
+	}
+
+	invalid := []indexError{}
+	indices := []string{}
+	ids := []interface{}{}
+	seen := map[bson.ObjectId]bool{}
+	for i, idHex := range batch.IDs {
+		id, err := parseTaskID(idHex)
+		if err != nil {
+			invalid = append(invalid, indexError{Index: i, Error: err.Error()})
+			indices = append(indices, strconv.Itoa(i))
+			continue
+		}
+		//listing a task twice updates it once
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(invalid) > 0 {
+		ctx.logf(r, "rejected batch update with invalid IDs at indices %s", strings.Join(indices, ", "))
+		respond(w, http.StatusBadRequest, &errorResponse{Error: &apiError{
+			Code:    codeInvalidID,
+			Message: "invalid IDs at indices " + strings.Join(indices, ", "),
+			Status:  http.StatusBadRequest,
+			Invalid: invalid,
+		}})
+		return
+	}
+	if err := batch.Updates.Validate(); err != nil {
+		ctx.logf(r, "rejected invalid batch updates: %v", err)
+		respondInvalid(w, "error validating updates", err)
+		return
+	}
+	if !ctx.checkLabels(w, r, batch.Updates) {
+		return
+	}
+
+	//only the caller's tasks are found, so other people's
+	//tasks are reported as not found, like they are by
+	//PATCH /v1/tasks/some-task-id
+	owner := ctx.owner(r)
+	found, _, err := ctx.TasksStore.Find(&tasks.Query{Owner: &owner, IDs: ids})
+	if err != nil {
+		ctx.logf(r, "error finding tasks to update: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error finding tasks to update: "+err.Error())
+		return
+	}
+	changed := []interface{}{}
+	for _, task := range found {
+		delete(seen, task.ID.(bson.ObjectId))
+		if batch.Updates.Changes(task) {
+			changed = append(changed, task.ID)
+		}
+	}
+	//what's left of `seen` wasn't found; list it
+	//in the same order as the request
+	notFound := []string{}
+	for _, id := range ids {
+		if seen[id.(bson.ObjectId)] {
+			notFound = append(notFound, id.(bson.ObjectId).Hex())
+		}
+	}
+
+	modified := 0
+	if len(changed) > 0 {
+		q := &tasks.Query{Owner: &owner, IDs: changed}
+		if modified, err = ctx.TasksStore.UpdateMany(q, batch.Updates); err != nil {
+			ctx.logf(r, "error updating tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error updating tasks: "+err.Error())
+			return
+		}
+		//the store doesn't return the updated tasks,
+		//but WebSocket clients want to see them
+		if updated, _, err := ctx.TasksStore.Find(q); err == nil {
+			defer func() {
+				for _, task := range updated {
+					ctx.notify(EventUpdated, task)
+				}
+			}()
+		}
+	}
+	respond(w, http.StatusOK, &batchUpdateResponse{Matched: len(found), Modified: modified, NotFound: notFound})
+}
+
+//getTaskBatch responds with the caller's tasks listed in
+//the ?ids= parameter, in the same order, like
+//  ?ids=5912b7d3e7c7f23f0c0e4fd1,5912b7d3e7c7f23f0c0e4fd2
+//which saves clients a round trip for each one. It can't be
+//combined with any other parameters, as it isn't a page of a
+//list. Tasks that weren't found, including other people's,
+//are listed as missing, rather than failing the request.
+func (ctx *Context) getTaskBatch(w http.ResponseWriter, r *http.Request) {
+	for param := range r.URL.Query() {
+		if param != paramIDs {
+			respondError(w, http.StatusBadRequest, codeInvalidQuery, paramIDs+" can't be used with "+param)
+			return
+		}
+	}
+	var idHexes []string
+	for _, idHex := range strings.Split(r.URL.Query().Get(paramIDs), ",") {
+		if idHex = strings.TrimSpace(idHex); len(idHex) > 0 {
+			idHexes = append(idHexes, idHex)
+		}
+	}
+	if len(idHexes) == 0 {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, "there must be at least one ID in "+paramIDs)
+		return
+	}
+	if len(idHexes) > maxIDsParam {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, fmt.Sprintf("there can be at most %d IDs in %s", maxIDsParam, paramIDs))
+		return
+	}
+	ids := []interface{}{}
+	for _, idHex := range idHexes {
+		id, err := parseTaskID(idHex)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, codeInvalidID, paramIDs+" must be task IDs: "+err.Error())
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	owner := ctx.owner(r)
+	found, _, err := ctx.TasksStore.Find(&tasks.Query{Owner: &owner, IDs: ids})
+	if err != nil {
+		ctx.logf(r, "error getting tasks: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error getting tasks: "+err.Error())
+		return
+	}
+	byID := map[interface{}]*tasks.Task{}
+	for _, task := range found {
+		byID[task.ID] = task
+	}
+	//listing a task twice sends it once
+	batch := &taskBatch{Tasks: []*tasks.Task{}, Missing: []string{}}
+	for _, id := range ids {
+		task, ok := byID[id]
+		switch {
+		case !ok:
+			batch.Missing = append(batch.Missing, id.(bson.ObjectId).Hex())
+		case task != nil:
+			batch.Tasks = append(batch.Tasks, task)
+		}
+		byID[id] = nil
+	}
+	respondTasks(w, r, http.StatusOK, batch)
+}
+
+//HandleTaskStats will handle requests for the /v1/tasks/stats
+//resource, which summarizes the caller's tasks. It accepts the
+//same filters as GET /v1/tasks, like ?tag=school.
+func (ctx *Context) HandleTaskStats(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTaskStats) {
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+	stats, err := ctx.TasksStore.Stats(q)
+	if err != nil {
+		ctx.logf(r, "error getting task stats: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error getting task stats: "+err.Error())
+		return
+	}
+	respond(w, http.StatusOK, stats)
+}
+
+//HandleGroupedTasks will handle requests for the /v1/tasks/grouped
+//resource, which responds with the caller's incomplete and complete
+//tasks in separate lists, each sorted and limited separately. It
+//accepts the same filters as GET /v1/tasks, like ?tag=school,
+//except ?complete, and ?limit= applies to each list.
+func (ctx *Context) HandleGroupedTasks(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowGroupedTasks) {
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+	if q.Complete != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, paramComplete+" can't be used when the tasks are grouped by it")
+		return
+	}
+
+	grouped := &groupedResponse{}
+	for _, complete := range []bool{false, true} {
+		group := *q
+		group.Complete = &complete
+		found, _, err := ctx.TasksStore.Find(&group)
 		if err != nil {
-			http.Error(w, "error inserting task: "+err.Error(), http.StatusInternalServerError)
+			ctx.logf(r, "error getting tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error getting tasks: "+err.Error())
 			return
 		}
+		//encode empty groups as [] rather than null
+		if found == nil {
+			found = []*tasks.Task{}
+		}
+		if complete {
+			grouped.Complete = found
+		} else {
+			grouped.Active = found
+		}
+	}
+	respondTasks(w, r, http.StatusOK, grouped)
+}
+
+//HandleCompletedTasks will handle requests for the
+///v1/tasks/completed resource, which lists the caller's tasks
+//that were completed after the required ?since= time, most
+//recently completed first, like
+//  /v1/tasks/completed?since=2017-05-01T00:00:00Z
+//for a summary of what got done since then. It accepts the same
+//filters and paging as GET /v1/tasks, like ?tag=school&limit=50.
+func (ctx *Context) HandleCompletedTasks(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowCompleted) {
+		return
+	}
+	since, err := timeParam(r, paramSince)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	if since == nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, paramSince+" is required, like "+paramSince+"="+time.RFC3339)
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+	q.CompletedAfter = since
+	if len(r.URL.Query().Get(paramSort)) == 0 {
+		q.Sort = "-completedAt"
+	}
+
+	found, total, err := ctx.TasksStore.Find(q)
+	if err != nil {
+		ctx.logf(r, "error getting completed tasks: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error getting completed tasks: "+err.Error())
+		return
+	}
+	w.Header().Set(headerTotalCount, strconv.Itoa(total))
+	respondTasks(w, r, http.StatusOK, found)
+}
+
+//HandleDueSoon will handle requests for the /v1/tasks/due-soon
+//resource, which lists the caller's incomplete tasks that are
+//due within the next ?within= duration, 24h by default, soonest
+//first, like
+//  /v1/tasks/due-soon?within=2h
+//for sending reminders. Tasks that the caller was reminded about
+//within that duration, with POST /v1/tasks/some-task-id/reminded,
+//are left out, so each reminder is only sent once. It accepts the
+//same filters and paging as GET /v1/tasks, except ?complete.
+func (ctx *Context) HandleDueSoon(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowDueSoon) {
+		return
+	}
+	within, err := durationParam(r, paramWithin, defaultDueSoonWithin, maxDueSoonWithin)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+	if q.Complete != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidQuery, paramComplete+" can't be used, as only incomplete tasks are due")
+		return
+	}
+
+	now := ctx.now()
+	complete := false
+	dueBefore := now.Add(within)
+	remindedSince := now.Add(-within)
+	q.Complete = &complete
+	q.DueAfter = &now
+	if q.DueBefore == nil || dueBefore.Before(*q.DueBefore) {
+		q.DueBefore = &dueBefore
+	}
+	q.NotRemindedSince = &remindedSince
+	if len(r.URL.Query().Get(paramSort)) == 0 {
+		q.Sort = "dueDate"
+	}
+
+	found, total, err := ctx.TasksStore.Find(q)
+	if err != nil {
+		ctx.logf(r, "error getting tasks due soon: %v", err)
+		respondError(w, http.StatusInternalServerError, codeStoreError, "error getting tasks due soon: "+err.Error())
+		return
+	}
+	w.Header().Set(headerTotalCount, strconv.Itoa(total))
+	respondTasks(w, r, http.StatusOK, found)
+}
+
+//splitTaskPath splits a /v1/tasks/some-task-id/sub-resource
+//path, or its /v2 version, which may end with a slash, into the task ID and the
+//sub-resource, which is empty for the task itself
+func splitTaskPath(urlPath string) (string, string) {
+	rest := strings.TrimSuffix(strings.TrimPrefix(urlPath, specificTaskPrefix(urlPath)), "/")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
 
-		w.Header().Add(headerContentType, contentTypeJSONUTF8)
-		encoder := json.NewEncoder(w)
-		encoder.Encode(task)
+//parseTaskID returns the task ID from a request path, or an
+//error if it's missing, or isn't a valid Mongo ObjectId
+func parseTaskID(id string) (bson.ObjectId, error) {
+	if len(id) == 0 {
+		return "", errors.New("missing task ID")
 	}
+	if !bson.IsObjectIdHex(id) {
+		return "", fmt.Errorf("invalid task ID %q", id)
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+//respondStoreError responds to a request for the task with
+//the given ID that the store failed, while `action`ing it:
+//404 if there's no such task, 412 if it's been changed since
+//the version in If-Match, or 500 if the store is broken,
+//which is also logged
+func (ctx *Context) respondStoreError(w http.ResponseWriter, r *http.Request, err error, id bson.ObjectId, action string) {
+	switch err {
+	case tasks.ErrNotFound:
+		respondError(w, http.StatusNotFound, codeNotFound, "no task with ID "+id.Hex())
+		return
+	case tasks.ErrVersionMismatch:
+		respondError(w, http.StatusPreconditionFailed, codePreconditionFailed, "task "+id.Hex()+" has changed since the version in If-Match: get it again and retry")
+		return
+	}
+	ctx.logf(r, "error %s task %s: %v", action, id.Hex(), err)
+	respondError(w, http.StatusInternalServerError, codeStoreError, "error "+action+" task: "+err.Error())
 }
 
-//HandleSpecificTask will handle requests for the /v1/tasks/some-task-id resource
+//HandleSpecificTask will handle requests for the /v1/tasks/some-task-id
+//resource, and its sub-resources, like /v1/tasks/some-task-id/complete
 func (ctx *Context) HandleSpecificTask(w http.ResponseWriter, r *http.Request) {
+	idHex, sub := splitTaskPath(r.URL.Path)
+	switch {
+	case sub == "":
+	case sub == subComplete:
+		ctx.handleTaskComplete(w, r, idHex)
+		return
+	case sub == subRestore:
+		ctx.handleTaskRestore(w, r, idHex)
+		return
+	case sub == subReminded:
+		ctx.handleTaskReminded(w, r, idHex)
+		return
+	case sub == subSubtasks:
+		ctx.handleSubtasks(w, r, idHex)
+		return
+	case strings.HasPrefix(sub, subSubtasks+"/"):
+		ctx.handleSubtask(w, r, idHex, strings.TrimPrefix(sub, subSubtasks+"/"))
+		return
+	case sub == subAttachments:
+		ctx.handleAttachments(w, r, idHex)
+		return
+	case strings.HasPrefix(sub, subAttachments+"/"):
+		ctx.handleAttachment(w, r, idHex, strings.TrimPrefix(sub, subAttachments+"/"))
+		return
+	default:
+		respondError(w, http.StatusNotFound, codeNotFound, "no such task resource "+sub)
+		return
+	}
+
+	if !checkMethod(w, r, allowSpecificTask) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		task, err := ctx.getOwnedTask(id, ctx.owner(r))
+		if err != nil {
+			ctx.respondStoreError(w, r, err, id, "getting")
+			return
+		}
+		w.Header().Set(headerETag, taskETag(task))
+		respondTasks(w, r, http.StatusOK, task)
+
+	case "PUT":
+		//PUT replaces all the fields the client can change
+		replacement := &tasks.TaskReplacement{}
+		if err := ctx.readJSON(w, r, replacement); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if err := replacement.Validate(); err != nil {
+			ctx.logf(r, "rejected invalid task %s: %v", id.Hex(), err)
+			respondInvalid(w, "error validating task", err)
+			return
+		}
+		ctx.updateSpecificTask(w, r, id, replacement.Updates())
+
+	case "PATCH":
+		updates := &tasks.TaskUpdates{}
+		if err := ctx.readJSON(w, r, updates); err != nil {
+			ctx.respondBodyError(w, r, err)
+			return
+		}
+		if err := updates.Validate(); err != nil {
+			ctx.logf(r, "rejected invalid updates for task %s: %v", id.Hex(), err)
+			respondInvalid(w, "error validating updates", err)
+			return
+		}
+		if !ctx.checkLabels(w, r, updates) {
+			return
+		}
+		ctx.updateSpecificTask(w, r, id, updates)
+
+	case "DELETE":
+		//tasks are archived, so they can be restored,
+		//unless an admin asks for ?permanent=true
+		version, ok := ctx.ifMatch(w, r)
+		if !ok {
+			return
+		}
+		permanent, ok := ctx.permanentParam(w, r)
+		if !ok {
+			return
+		}
+		if permanent {
+			//admins can permanently delete anyone's
+			//tasks, including archived ones
+			task, err := ctx.TasksStore.Get(id)
+			if err != nil {
+				ctx.respondStoreError(w, r, err, id, "deleting")
+				return
+			}
+			if err := ctx.deleteTask(id, version); err != nil {
+				ctx.respondStoreError(w, r, err, id, "deleting")
+				return
+			}
+			ctx.recordPurge()
+			w.WriteHeader(http.StatusNoContent)
+			if task.ArchivedAt == nil {
+				ctx.notify(EventDeleted, task)
+			}
+			return
+		}
+
+		if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+			ctx.respondStoreError(w, r, err, id, "deleting")
+			return
+		}
+		archivedAt := ctx.now()
+		task, err := ctx.updateTask(id, version, &tasks.TaskUpdates{ArchivedAt: &archivedAt})
+		if err != nil {
+			ctx.respondStoreError(w, r, err, id, "deleting")
+			return
+		}
+		//204 responses must not have a body
+		w.WriteHeader(http.StatusNoContent)
+		ctx.notify(EventDeleted, task)
+	}
+}
+
+//handleTaskRestore handles requests for the /v1/tasks/some-task-id/restore
+//sub-resource, which restores a deleted task, responding with the task.
+//It's a 409 Conflict to restore a task that isn't deleted.
+func (ctx *Context) handleTaskRestore(w http.ResponseWriter, r *http.Request, idHex string) {
+	if !checkMethod(w, r, allowTaskRestore) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+	version, ok := ctx.ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	archived, err := ctx.getOwnedTaskOrArchived(id, ctx.owner(r))
+	if err != nil {
+		ctx.respondStoreError(w, r, err, id, "restoring")
+		return
+	}
+	if archived.ArchivedAt == nil {
+		respondError(w, http.StatusConflict, codeConflict, "task "+id.Hex()+" isn't deleted")
+		return
+	}
+	task, err := ctx.updateTask(id, version, &tasks.TaskUpdates{Unarchive: true})
+	if err != nil {
+		ctx.respondStoreError(w, r, err, id, "restoring")
+		return
+	}
+	w.Header().Set(headerETag, taskETag(task))
+	respondTasks(w, r, http.StatusOK, task)
+	//to clients, the task has come back
+	ctx.notify(EventCreated, task)
+}
+
+//handleTaskComplete handles requests for the /v1/tasks/some-task-id/complete
+//sub-resource: POST marks the task complete, and DELETE marks it incomplete.
+//Both respond with the updated task, and can safely be repeated.
+func (ctx *Context) handleTaskComplete(w http.ResponseWriter, r *http.Request, idHex string) {
+	if !checkMethod(w, r, allowTaskComplete) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+
+	complete := r.Method == "POST"
+	ctx.updateSpecificTask(w, r, id, &tasks.TaskUpdates{Complete: &complete})
+}
+
+//handleTaskReminded handles requests for the
+///v1/tasks/some-task-id/reminded sub-resource: POST records
+//that the owner was just reminded the task is due soon, so
+//GET /v1/tasks/due-soon leaves it out, and responds with the
+//updated task. Completing the task clears the reminder.
+func (ctx *Context) handleTaskReminded(w http.ResponseWriter, r *http.Request, idHex string) {
+	if !checkMethod(w, r, allowTaskReminded) {
+		return
+	}
+	id, err := parseTaskID(idHex)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, codeInvalidID, err.Error())
+		return
+	}
+
+	remindedAt := ctx.now()
+	ctx.updateSpecificTask(w, r, id, &tasks.TaskUpdates{RemindedAt: &remindedAt})
+}
+
+//updateSpecificTask applies `updates` to the task with the
+//given ID, if it belongs to the caller and matches the version
+//in any If-Match header, and responds with the updated task
+func (ctx *Context) updateSpecificTask(w http.ResponseWriter, r *http.Request, id bson.ObjectId, updates *tasks.TaskUpdates) {
+	version, ok := ctx.ifMatch(w, r)
+	if !ok {
+		return
+	}
+	if _, err := ctx.getOwnedTask(id, ctx.owner(r)); err != nil {
+		ctx.respondStoreError(w, r, err, id, "updating")
+		return
+	}
+	task, err := ctx.updateTask(id, version, updates)
+	if err != nil {
+		ctx.respondStoreError(w, r, err, id, "updating")
+		return
+	}
+	w.Header().Set(headerETag, taskETag(task))
+	respondTasks(w, r, http.StatusOK, task)
+	ctx.notify(EventUpdated, task)
 }
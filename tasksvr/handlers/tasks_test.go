@@ -0,0 +1,1940 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//errStore is the error returned by failingStore by default
+var errStore = errors.New("mongo is down")
+
+//failingStore is a tasks.Store whose methods all fail, with
+//`err` or errStore if that's not set. Methods it doesn't
+//override panic, as the embedded Store is nil, so tests
+//notice unexpected calls.
+type failingStore struct {
+	tasks.Store
+	err error
+}
+
+func (fs failingStore) error() error {
+	if fs.err != nil {
+		return fs.err
+	}
+	return errStore
+}
+
+func (fs failingStore) Get(ID interface{}) (*tasks.Task, error) {
+	return nil, fs.error()
+}
+
+func (fs failingStore) Find(q *tasks.Query) ([]*tasks.Task, int, error) {
+	return nil, 0, fs.error()
+}
+
+func (fs failingStore) Update(ID interface{}, updates *tasks.TaskUpdates) (*tasks.Task, error) {
+	return nil, fs.error()
+}
+
+func (fs failingStore) Delete(ID interface{}) error {
+	return fs.error()
+}
+
+func (fs failingStore) MaxModified(owner *string) (time.Time, error) {
+	return time.Time{}, fs.error()
+}
+
+func (fs failingStore) Ping(timeout time.Duration) error {
+	return fs.error()
+}
+
+func (fs failingStore) Stats(q *tasks.Query) (*tasks.Stats, error) {
+	return nil, fs.error()
+}
+
+//newTestContext returns a Context using an in-memory
+//store, seeded with a task for each of the `titles`,
+//along with the seeded tasks
+func newTestContext(t *testing.T, titles ...string) (*Context, []*tasks.Task) {
+	store := tasks.NewMemStore()
+	seeded := []*tasks.Task{}
+	for _, title := range titles {
+		task, err := store.Insert(&tasks.NewTask{Title: title})
+		if err != nil {
+			t.Fatalf("error seeding store: %v", err)
+		}
+		seeded = append(seeded, task)
+	}
+	ctx, _ := newLoggedContext(t, store)
+	return ctx, seeded
+}
+
+//newLoggedContext returns a Context using `store`,
+//along with a buffer holding what it logs
+func newLoggedContext(t *testing.T, store tasks.Store) (*Context, *bytes.Buffer) {
+	logs := &bytes.Buffer{}
+	ctx, err := NewContext(store, log.New(logs, "", 0))
+	if err != nil {
+		t.Fatalf("error creating context: %v", err)
+	}
+	return ctx, logs
+}
+
+//decodeError decodes a JSON error body, failing the test if
+//the response doesn't have one with a code, a message, and the
+//same status as the response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) *apiError {
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeJSONUTF8 {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeJSONUTF8, ctype)
+	}
+	body := w.Body.String()
+	er := &errorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(er); err != nil || er.Error == nil {
+		t.Errorf("expected a JSON error body but got %q", body)
+		return &apiError{}
+	}
+	if len(er.Error.Code) == 0 || len(er.Error.Message) == 0 || er.Error.Status != w.Code {
+		t.Errorf("expected an error with a code, a message and status %d, but got %q", w.Code, body)
+	}
+	return er.Error
+}
+
+func TestGetAllTasks(t *testing.T) {
+	ctx, _ := newTestContext(t, "Learn Go", "Learn MongoDB")
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if ctype := w.Header().Get(headerContentType); ctype != contentTypeJSONUTF8 {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeJSONUTF8, ctype)
+	}
+	alltasks := []*tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(&alltasks); err != nil {
+		t.Fatalf("error decoding tasks: %v", err)
+	}
+	//newest first by default
+	if len(alltasks) != 2 || alltasks[0].Title != "Learn MongoDB" || alltasks[1].Title != "Learn Go" {
+		t.Errorf("unexpected tasks %+v", alltasks)
+	}
+}
+
+func TestGetAllTasksEmpty(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("expected an empty array but got %s", body)
+	}
+}
+
+func TestGetAllTasksStoreError(t *testing.T) {
+	ctx, _ := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	if msg := decodeError(t, w).Message; !strings.Contains(msg, errStore.Error()) {
+		t.Errorf("expected the store error in the message, got %q", msg)
+	}
+}
+
+func TestGetAllTasksPaged(t *testing.T) {
+	titles := []string{}
+	for i := 0; i < 25; i++ {
+		titles = append(titles, fmt.Sprintf("task %d", i))
+	}
+	ctx, _ := newTestContext(t, titles...)
+
+	cases := []struct {
+		query  string
+		titles []string
+	}{
+		//the default limit is 20
+		{"?sort=createdAt", titles[:20]},
+		{"?sort=createdAt&limit=10", titles[:10]},
+		{"?sort=createdAt&limit=10&skip=10", titles[10:20]},
+		{"?sort=createdAt&limit=10&skip=20", titles[20:]},
+		//beyond the last page
+		{"?sort=createdAt&limit=10&skip=30", []string{}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+c.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		if total := w.Header().Get(headerTotalCount); total != "25" {
+			t.Errorf("%s: expected %s of 25 but got %q", c.query, headerTotalCount, total)
+		}
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: error decoding tasks: %v", c.query, err)
+		}
+		got := []string{}
+		for _, task := range page {
+			got = append(got, task.Title)
+		}
+		if !reflect.DeepEqual(got, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.query, c.titles, got)
+		}
+	}
+}
+
+func TestGetAllTasksSorted(t *testing.T) {
+	ctx, seeded := newTestContext(t, "banana", "apple", "cherry")
+	complete := true
+	if _, err := ctx.TasksStore.Update(seeded[1].ID, &tasks.TaskUpdates{Complete: &complete}); err != nil {
+		t.Fatalf("error completing task: %v", err)
+	}
+
+	cases := []struct {
+		query  string
+		titles []string
+	}{
+		//newest first by default
+		{"", []string{"cherry", "apple", "banana"}},
+		{"?sort=-createdAt", []string{"cherry", "apple", "banana"}},
+		{"?sort=createdAt", []string{"banana", "apple", "cherry"}},
+		{"?sort=title", []string{"apple", "banana", "cherry"}},
+		{"?sort=-title", []string{"cherry", "banana", "apple"}},
+		//ties stay in the order they were inserted,
+		//or the reverse of that when descending
+		{"?sort=complete", []string{"banana", "cherry", "apple"}},
+		{"?sort=-complete", []string{"apple", "cherry", "banana"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+c.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: error decoding tasks: %v", c.query, err)
+		}
+		got := []string{}
+		for _, task := range page {
+			got = append(got, task.Title)
+		}
+		if !reflect.DeepEqual(got, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.query, c.titles, got)
+		}
+	}
+}
+
+func TestGetAllTasksBadSort(t *testing.T) {
+	ctx, _ := newTestContext(t, "Learn Go")
+	for _, query := range []string{"?sort=urgency", "?sort=Title", "?sort=--title", "?sort=-"} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		}
+		//the error lists the fields that can be sorted by
+		if msg := decodeError(t, w).Message; !strings.Contains(msg, "createdAt, title, complete") {
+			t.Errorf("%s: expected the allowed fields in the message, got %q", query, msg)
+		}
+	}
+}
+
+func TestGetAllTasksSearch(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Buy groceries", "Learn C++", "More GROCERIES", "Walk the dog", "groceries again")
+	complete := true
+	if _, err := ctx.TasksStore.Update(seeded[0].ID, &tasks.TaskUpdates{Complete: &complete}); err != nil {
+		t.Fatalf("error completing task: %v", err)
+	}
+
+	cases := []struct {
+		query  string
+		total  string
+		titles []string
+	}{
+		{"?q=groceries", "3", []string{"Buy groceries", "More GROCERIES", "groceries again"}},
+		{"?q=GROCERIES", "3", []string{"Buy groceries", "More GROCERIES", "groceries again"}},
+		{"?q=cat", "0", []string{}},
+		//regular expression characters match themselves
+		{"?q=" + url.QueryEscape("C++"), "1", []string{"Learn C++"}},
+		{"?q=" + url.QueryEscape(".*"), "0", []string{}},
+		{"?q=" + url.QueryEscape("(groceries"), "0", []string{}},
+		//search composes with the other parameters
+		{"?q=groceries&complete=false", "2", []string{"More GROCERIES", "groceries again"}},
+		{"?q=groceries&limit=1&skip=1", "3", []string{"More GROCERIES"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+c.query+"&sort=createdAt", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		if total := w.Header().Get(headerTotalCount); total != c.total {
+			t.Errorf("%s: expected %s of %s but got %q", c.query, headerTotalCount, c.total, total)
+		}
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: error decoding tasks: %v", c.query, err)
+		}
+		got := []string{}
+		for _, task := range page {
+			got = append(got, task.Title)
+		}
+		if !reflect.DeepEqual(got, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.query, c.titles, got)
+		}
+	}
+}
+
+func TestGetAllTasksBadQuery(t *testing.T) {
+	ctx, _ := newTestContext(t, "Learn Go")
+	for _, query := range []string{"?limit=0", "?limit=101", "?limit=ten", "?limit=-1", "?skip=-1", "?skip=1.5", "?complete=yes", "?complete=1", "?q=" + strings.Repeat("a", maxSearchLength+1),
+		"?overdue=yes", "?due_before=tomorrow", "?due_before=2017-05-01"} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		}
+		decodeError(t, w)
+	}
+}
+
+func TestGetAllTasksComplete(t *testing.T) {
+	ctx, seeded := newTestContext(t, "one", "two", "three", "four", "five")
+	complete := true
+	for _, task := range seeded[:3] {
+		if _, err := ctx.TasksStore.Update(task.ID, &tasks.TaskUpdates{Complete: &complete}); err != nil {
+			t.Fatalf("error completing task: %v", err)
+		}
+	}
+
+	cases := []struct {
+		query  string
+		total  string
+		titles []string
+	}{
+		{"?sort=createdAt", "5", []string{"one", "two", "three", "four", "five"}},
+		{"?sort=createdAt&complete=true", "3", []string{"one", "two", "three"}},
+		{"?sort=createdAt&complete=false", "2", []string{"four", "five"}},
+		{"?sort=createdAt&complete=true&limit=2&skip=1", "3", []string{"two", "three"}},
+		{"?sort=createdAt&complete=false&limit=1&skip=1", "2", []string{"five"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+c.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		if total := w.Header().Get(headerTotalCount); total != c.total {
+			t.Errorf("%s: expected %s of %s but got %q", c.query, headerTotalCount, c.total, total)
+		}
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: error decoding tasks: %v", c.query, err)
+		}
+		got := []string{}
+		for _, task := range page {
+			got = append(got, task.Title)
+		}
+		if !reflect.DeepEqual(got, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.query, c.titles, got)
+		}
+	}
+}
+
+func TestPostTask(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn Go", "tags": ["go"]}`))
+	ctx.HandleTasks(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	location := w.Header().Get(headerLocation)
+	if location != "/v1/tasks/"+created.ID.(string) {
+		t.Errorf("expected Location /v1/tasks/%s but got %q", created.ID, location)
+	}
+
+	//the Location gets the same task
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", location, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d getting the Location but got %d", http.StatusOK, w.Code)
+	}
+	fetched := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(fetched); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if !reflect.DeepEqual(fetched, created) {
+		t.Errorf("expected the same task from the Location\ncreated: %+v\nfetched: %+v", created, fetched)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	specificPath := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	cases := []struct {
+		handler       http.HandlerFunc
+		method        string
+		path          string
+		expectedAllow string
+	}{
+		{ctx.HandleTasks, "PUT", "/v1/tasks", "GET, POST, PATCH, DELETE, OPTIONS"},
+		{ctx.HandleSpecificTask, "POST", specificPath, "GET, PUT, PATCH, DELETE, OPTIONS"},
+		//the method is checked before the ID
+		{ctx.HandleSpecificTask, "POST", "/v1/tasks/1234", "GET, PUT, PATCH, DELETE, OPTIONS"},
+	}
+
+	for _, c := range cases {
+		body := `{"title": "Changed", "complete": true}`
+		w := httptest.NewRecorder()
+		c.handler(w, httptest.NewRequest(c.method, c.path, strings.NewReader(body)))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status %d but got %d", c.method, c.path, http.StatusMethodNotAllowed, w.Code)
+		}
+		if allow := w.Header().Get(headerAllow); allow != c.expectedAllow {
+			t.Errorf("%s %s: expected Allow %q but got %q", c.method, c.path, c.expectedAllow, allow)
+		}
+		decodeError(t, w)
+
+		w = httptest.NewRecorder()
+		c.handler(w, httptest.NewRequest("OPTIONS", c.path, nil))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("OPTIONS %s: expected status %d but got %d", c.path, http.StatusNoContent, w.Code)
+		}
+		if allow := w.Header().Get(headerAllow); allow != c.expectedAllow {
+			t.Errorf("OPTIONS %s: expected Allow %q but got %q", c.path, c.expectedAllow, allow)
+		}
+		if w.Body.Len() > 0 {
+			t.Errorf("OPTIONS %s: expected no body but got %q", c.path, w.Body.String())
+		}
+	}
+
+	//nothing was created or changed
+	alltasks, _ := ctx.TasksStore.GetAll()
+	if len(alltasks) != 1 || alltasks[0].Title != "Learn Go" || alltasks[0].Complete {
+		t.Errorf("expected the tasks to be unchanged, got %+v", alltasks)
+	}
+}
+
+func TestGetSpecificTask(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go", "Learn MongoDB")
+	id := seeded[1].ID.(bson.ObjectId).Hex()
+
+	cases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"found", "/v1/tasks/" + id, http.StatusOK},
+		{"trailing slash", "/v1/tasks/" + id + "/", http.StatusOK},
+		{"not found", "/v1/tasks/" + bson.NewObjectId().Hex(), http.StatusNotFound},
+		{"malformed ID", "/v1/tasks/not-an-id", http.StatusBadRequest},
+		{"short ID", "/v1/tasks/" + id[:10], http.StatusBadRequest},
+		{"empty ID", "/v1/tasks/", http.StatusBadRequest},
+		{"unknown sub-resource", "/v1/tasks/" + id + "/more", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			decodeError(t, w)
+			continue
+		}
+		task := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+			t.Fatalf("%s: error decoding task: %v", c.name, err)
+		}
+		if task.ID != id || task.Title != "Learn MongoDB" {
+			t.Errorf("%s: unexpected task %+v", c.name, task)
+		}
+	}
+}
+
+func TestGetSpecificTaskStoreError(t *testing.T) {
+	ctx, _ := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", "/v1/tasks/"+bson.NewObjectId().Hex(), nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	decodeError(t, w)
+}
+
+func TestPatchSpecificTask(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	patch := func(path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", path, strings.NewReader(body)))
+		return w
+	}
+
+	//updating only complete leaves the title alone
+	w := patch(path, `{"complete": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	task := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if task.Title != "Learn Go" || !task.Complete {
+		t.Errorf("unexpected task after updating complete: %+v", task)
+	}
+
+	//and the reverse, including setting complete to false
+	w = patch(path, `{"title": "Learn Go well", "complete": false}`)
+	task = &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if task.Title != "Learn Go well" || task.Complete {
+		t.Errorf("unexpected task after updating title: %+v", task)
+	}
+	if stored, _ := ctx.TasksStore.Get(seeded[0].ID); stored.Title != "Learn Go well" {
+		t.Errorf("expected the update to be stored, got %+v", stored)
+	}
+
+	cases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"empty body", path, "", http.StatusBadRequest},
+		{"invalid JSON", path, `{"complete": `, http.StatusBadRequest},
+		{"no recognized fields", path, `{"done": true}`, http.StatusBadRequest},
+		{"empty object", path, `{}`, http.StatusBadRequest},
+		{"empty title", path, `{"title": ""}`, http.StatusBadRequest},
+		{"wrong type", path, `{"complete": "yes"}`, http.StatusBadRequest},
+		{"not found", "/v1/tasks/" + bson.NewObjectId().Hex(), `{"complete": true}`, http.StatusNotFound},
+		{"malformed ID", "/v1/tasks/1234", `{"complete": true}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := patch(c.path, c.body)
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		decodeError(t, w)
+	}
+}
+
+func TestPatchSpecificTaskStoreError(t *testing.T) {
+	ctx, _ := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PATCH", "/v1/tasks/"+bson.NewObjectId().Hex(), strings.NewReader(`{"complete": true}`))
+	ctx.HandleSpecificTask(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	decodeError(t, w)
+}
+
+func TestPutSpecificTask(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.RFC3339)
+	body := `{"title": "Learn Go", "tags": ["school"], "dueDate": "` + tomorrow + `"}`
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body))
+	r.Header.Set(headerUser, "dave")
+	ctx.HandleTasks(w, r)
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	path := "/v1/tasks/" + created.ID.(string)
+
+	put := func(path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		r.Header.Set(headerUser, "dave")
+		ctx.HandleSpecificTask(w, r)
+		return w
+	}
+
+	//fields left out of the replacement are reset,
+	//including the due date, but the ID, owner and
+	//creation time stay the same
+	w = put(path, `{"title": "Learn Go well", "complete": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	task := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if task.Title != "Learn Go well" || !task.Complete || len(task.Tags) != 0 || task.DueDate != nil {
+		t.Errorf("unexpected task after replacing: %+v", task)
+	}
+	if task.ID != created.ID || task.Owner != "dave" || !task.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("expected the ID, owner and createdAt to be kept, got %+v", task)
+	}
+	if task.Version != created.Version+1 {
+		t.Errorf("expected version %d but got %d", created.Version+1, task.Version)
+	}
+
+	w = put(path, `{"title": "Learn Go", "tags": ["school", "go"], "dueDate": "`+tomorrow+`"}`)
+	task = &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if task.Complete || !reflect.DeepEqual(task.Tags, []string{"school", "go"}) || task.DueDate == nil {
+		t.Errorf("unexpected task after replacing: %+v", task)
+	}
+
+	cases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"empty body", path, "", http.StatusBadRequest},
+		{"invalid JSON", path, `{"title": `, http.StatusBadRequest},
+		{"missing title", path, `{"complete": true}`, http.StatusBadRequest},
+		{"invalid due date", path, `{"title": "Learn Go", "dueDate": "tomorrow"}`, http.StatusBadRequest},
+		//PUT doesn't create tasks that don't exist
+		{"not found", "/v1/tasks/" + bson.NewObjectId().Hex(), `{"title": "New"}`, http.StatusNotFound},
+		{"malformed ID", "/v1/tasks/1234", `{"title": "New"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := put(c.path, c.body)
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		decodeError(t, w)
+	}
+	if all, _ := ctx.TasksStore.GetAll(); len(all) != 1 {
+		t.Errorf("expected 1 task in the store, but there are %d", len(all))
+	}
+}
+
+func TestDeleteSpecificTask(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go", "Learn MongoDB")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("DELETE", path, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d", http.StatusNoContent, w.Code)
+	}
+	if w.Body.Len() > 0 {
+		t.Errorf("expected no body with a 204 but got %q", w.Body.String())
+	}
+
+	//the task is gone, but the other one isn't
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d getting a deleted task but got %d", http.StatusNotFound, w.Code)
+	}
+	if remaining, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 1 || remaining[0].Title != "Learn MongoDB" {
+		t.Errorf("expected only the other task to be left, got %d tasks", total)
+	}
+	//it's archived rather than removed, so it can be restored
+	if archived, err := ctx.TasksStore.Get(seeded[0].ID); err != nil || archived.ArchivedAt == nil {
+		t.Errorf("expected the deleted task to be archived, got %+v, %v", archived, err)
+	}
+
+	cases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"already deleted", path, http.StatusNotFound},
+		{"never existed", "/v1/tasks/" + bson.NewObjectId().Hex(), http.StatusNotFound},
+		{"malformed ID", "/v1/tasks/1234", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest("DELETE", c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		decodeError(t, w)
+	}
+}
+
+func TestArchivedTasks(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go", "Learn MongoDB")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	do := func(method string, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	list := func(query string) []string {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+query, nil))
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("error decoding tasks: %v", err)
+		}
+		titles := []string{}
+		for _, task := range page {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	if w := do("DELETE", path); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d", http.StatusNoContent, w.Code)
+	}
+	//archived tasks are only listed when asked for
+	if titles := list(""); !reflect.DeepEqual(titles, []string{"Learn MongoDB"}) {
+		t.Errorf("unexpected tasks by default: %v", titles)
+	}
+	if titles := list("?archived=true"); !reflect.DeepEqual(titles, []string{"Learn Go"}) {
+		t.Errorf("unexpected archived tasks: %v", titles)
+	}
+
+	w := do("POST", path+"/restore")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	restored := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(restored); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if restored.ArchivedAt != nil || restored.Title != "Learn Go" {
+		t.Errorf("unexpected task after restoring: %+v", restored)
+	}
+	if titles := list("?sort=createdAt"); !reflect.DeepEqual(titles, []string{"Learn Go", "Learn MongoDB"}) {
+		t.Errorf("unexpected tasks after restoring: %v", titles)
+	}
+
+	cases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"restore a task that isn't deleted", "POST", path + "/restore", http.StatusConflict},
+		{"restore a task that never existed", "POST", "/v1/tasks/" + bson.NewObjectId().Hex() + "/restore", http.StatusNotFound},
+		{"restore with the wrong method", "GET", path + "/restore", http.StatusMethodNotAllowed},
+		{"permanently delete as a non-admin", "DELETE", path + "?permanent=true", http.StatusForbidden},
+		{"permanently delete with a bad flag", "DELETE", path + "?permanent=yes", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := do(c.method, c.path)
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.name, c.expectedStatus, w.Code)
+		}
+		decodeError(t, w)
+	}
+}
+
+func TestPermanentlyDeleteTask(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	ctx.Admins = []string{"admin"}
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex()
+
+	//archived tasks can be permanently deleted too
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("DELETE", path, nil))
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", path+"?permanent=true", nil)
+	r = authenticated(r, "admin")
+	ctx.HandleSpecificTask(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if alltasks, _ := ctx.TasksStore.GetAll(); len(alltasks) != 0 {
+		t.Errorf("expected the task to be removed, but there are %d tasks", len(alltasks))
+	}
+
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("POST", path+"/restore", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d restoring a removed task but got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDeleteSpecificTaskStoreError(t *testing.T) {
+	ctx, _ := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("DELETE", "/v1/tasks/"+bson.NewObjectId().Hex(), nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	decodeError(t, w)
+}
+
+func TestSpecificTaskErrorStatuses(t *testing.T) {
+	id := bson.NewObjectId().Hex()
+	cases := []struct {
+		name    string
+		path    string
+		err     error
+		status  int
+		message string
+	}{
+		{"invalid ID", "/v1/tasks/not-an-id", nil, http.StatusBadRequest, "invalid task ID"},
+		{"missing ID", "/v1/tasks/", nil, http.StatusBadRequest, "missing task ID"},
+		{"not found", "/v1/tasks/" + id, tasks.ErrNotFound, http.StatusNotFound, "no task with ID " + id},
+		{"store failure", "/v1/tasks/" + id, errStore, http.StatusInternalServerError, errStore.Error()},
+	}
+	//all three methods map errors the same way
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		for _, c := range cases {
+			ctx, _ := newLoggedContext(t, failingStore{err: c.err})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(method, c.path, strings.NewReader(`{"complete": true}`))
+			ctx.HandleSpecificTask(w, r)
+			if w.Code != c.status {
+				t.Errorf("%s %s: expected status %d but got %d", method, c.name, c.status, w.Code)
+			}
+			if msg := decodeError(t, w).Message; !strings.Contains(msg, c.message) {
+				t.Errorf("%s %s: expected %q in the message, got %q", method, c.name, c.message, msg)
+			}
+		}
+	}
+}
+
+func TestTaskTags(t *testing.T) {
+	ctx, _ := newTestContext(t, "Homework")
+
+	//create a task with tags, which are normalized
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks",
+		strings.NewReader(`{"title": "Buy milk", "tags": ["errand ", "shopping", "errand"]}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if expected := []string{"errand", "shopping"}; !reflect.DeepEqual(created.Tags, expected) {
+		t.Errorf("expected tags %v but got %v", expected, created.Tags)
+	}
+
+	//filter by tag
+	w = httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks?tag=errand", nil))
+	found := []*tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(&found); err != nil {
+		t.Fatalf("error decoding tasks: %v", err)
+	}
+	if len(found) != 1 || found[0].Title != "Buy milk" || w.Header().Get(headerTotalCount) != "1" {
+		t.Errorf("expected only the errand, got %+v", found)
+	}
+
+	//replace the tags
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", "/v1/tasks/"+created.ID.(string),
+		strings.NewReader(`{"tags": ["groceries"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	updated := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(updated); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if expected := []string{"groceries"}; !reflect.DeepEqual(updated.Tags, expected) {
+		t.Errorf("expected tags %v but got %v", expected, updated.Tags)
+	}
+	if updated.Title != "Buy milk" {
+		t.Errorf("expected the title to be unchanged, got %q", updated.Title)
+	}
+}
+
+func TestTaskTagsInvalid(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Homework")
+	bodies := []string{
+		`{"title": "Buy milk", "tags": [""]}`,
+		`{"title": "Buy milk", "tags": ["` + strings.Repeat("x", 51) + `"]}`,
+		`{"title": "Buy milk", "tags": ["1","2","3","4","5","6","7","8","9","10","11"]}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: expected status %d but got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", "/v1/tasks/"+seeded[0].ID.(bson.ObjectId).Hex(),
+		strings.NewReader(`{"tags": ["  "]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH: expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTaskValidationFields(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Homework")
+	requests := []struct {
+		method   string
+		path     string
+		body     string
+		expected []string
+	}{
+		{"POST", "/v1/tasks", `{"title": "", "tags": ["ok", "", "` + strings.Repeat("x", 51) + `"], "dueDate": "0001-01-01T00:00:00Z"}`,
+			[]string{"title:required", "tags[1]:required", "tags[2]:too_long", "dueDate:invalid"}},
+		{"PUT", "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex(), `{"title": "", "tags": [" "]}`,
+			[]string{"title:required", "tags[0]:required"}},
+		{"PATCH", "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex(), `{"title": "", "dueDate": "0001-01-01T00:00:00Z"}`,
+			[]string{"title:required", "dueDate:invalid"}},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+		if req.method == "POST" {
+			ctx.HandleTasks(w, r)
+		} else {
+			ctx.HandleSpecificTask(w, r)
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", req.method, http.StatusBadRequest, w.Code)
+			continue
+		}
+		apierr := decodeError(t, w)
+		fields := []string{}
+		for _, fe := range apierr.Fields {
+			if len(fe.Message) == 0 {
+				t.Errorf("%s: expected a message for %s", req.method, fe.Field)
+			}
+			fields = append(fields, fe.Field+":"+fe.Code)
+		}
+		if apierr.Code != codeValidationFailed || !reflect.DeepEqual(fields, req.expected) {
+			t.Errorf("%s: expected %s with fields %v but got %s with %v", req.method, codeValidationFailed, req.expected, apierr.Code, fields)
+		}
+	}
+}
+
+func TestTaskPriority(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ids := map[string]string{}
+	for _, body := range []string{
+		`{"title": "normal"}`,
+		`{"title": "high", "priority": "high"}`,
+		`{"title": "low", "priority": "low"}`,
+	} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body)))
+		task := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(task); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("POST %s: expected status %d but got %d, %v", body, http.StatusCreated, w.Code, err)
+		}
+		//without a priority, it's normal
+		if task.Priority.String() != task.Title {
+			t.Errorf("POST %s: expected priority %s but got %s", body, task.Title, task.Priority)
+		}
+		ids[task.Title] = task.ID.(string)
+	}
+
+	get := func(query string) []string {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+query, nil))
+		found := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&found); err != nil {
+			t.Fatalf("GET %s: error decoding tasks: %v", query, err)
+		}
+		titles := []string{}
+		for _, task := range found {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+	for _, p := range tasks.PriorityNames {
+		if titles := get("?priority=" + p); !reflect.DeepEqual(titles, []string{p}) {
+			t.Errorf("?priority=%s: expected only the %s task but got %v", p, p, titles)
+		}
+	}
+	//by urgency, rather than alphabetically
+	if titles := get("?sort=-priority"); !reflect.DeepEqual(titles, []string{"high", "normal", "low"}) {
+		t.Errorf("?sort=-priority: expected high, normal, low but got %v", titles)
+	}
+	if titles := get("?sort=priority"); !reflect.DeepEqual(titles, []string{"low", "normal", "high"}) {
+		t.Errorf("?sort=priority: expected low, normal, high but got %v", titles)
+	}
+
+	//PATCH moves it between them
+	path := "/v1/tasks/" + ids["low"]
+	for _, p := range []string{"high", "normal", "low"} {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest("PATCH", path, strings.NewReader(`{"priority": "`+p+`"}`)))
+		task := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(task); err != nil || task.Priority.String() != p {
+			t.Errorf("PATCH to %s: got %d %+v, %v", p, w.Code, task, err)
+		}
+	}
+
+	requests := []struct {
+		method       string
+		path         string
+		body         string
+		expectedCode string
+	}{
+		{"POST", "/v1/tasks", `{"title": "urgent", "priority": "urgent"}`, codeValidationFailed},
+		{"PATCH", path, `{"priority": "High"}`, codeValidationFailed},
+		{"GET", "/v1/tasks?priority=urgent", "", codeInvalidQuery},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+		if req.method == "PATCH" {
+			ctx.HandleSpecificTask(w, r)
+		} else {
+			ctx.HandleTasks(w, r)
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d but got %d", req.method, req.path, http.StatusBadRequest, w.Code)
+			continue
+		}
+		apierr := decodeError(t, w)
+		if apierr.Code != req.expectedCode {
+			t.Errorf("%s %s: expected code %s but got %s", req.method, req.path, req.expectedCode, apierr.Code)
+		}
+		if req.expectedCode == codeValidationFailed && (len(apierr.Fields) != 1 || apierr.Fields[0].Field != "priority") {
+			t.Errorf("%s %s: expected a problem with the priority, got %+v", req.method, req.path, apierr.Fields)
+		}
+	}
+}
+
+func TestTaskDueDates(t *testing.T) {
+	ctx, _ := newTestContext(t, "whenever")
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.RFC3339)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.RFC3339)
+	for _, body := range []string{
+		`{"title": "late", "dueDate": "` + yesterday + `"}`,
+		`{"title": "soon", "dueDate": "` + tomorrow + `"}`,
+	} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"dueDate":"`) {
+			t.Errorf("expected a due date in the response, got %s", w.Body.String())
+		}
+	}
+
+	cases := []struct {
+		query  string
+		titles []string
+	}{
+		{"?overdue=true", []string{"late"}},
+		{"?overdue=false", []string{"whenever", "late", "soon"}},
+		{"?due_before=" + url.QueryEscape(time.Now().AddDate(0, 0, 2).Format(time.RFC3339)), []string{"late", "soon"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+c.query+"&sort=createdAt", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		page := []*tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: error decoding tasks: %v", c.query, err)
+		}
+		got := []string{}
+		for _, task := range page {
+			got = append(got, task.Title)
+		}
+		if !reflect.DeepEqual(got, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.query, c.titles, got)
+		}
+	}
+
+	invalid := []string{
+		`{"title": "late", "dueDate": "tomorrow"}`,
+		`{"title": "late", "dueDate": "0001-01-01T00:00:00Z"}`,
+		`{"title": "late", "dueDate": "2999-01-01T00:00:00Z"}`,
+	}
+	for _, body := range invalid {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: expected status %d but got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestTaskComplete(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	path := "/v1/tasks/" + seeded[0].ID.(bson.ObjectId).Hex() + "/complete"
+
+	//each is repeated, to check that it's idempotent
+	steps := []struct {
+		method   string
+		complete bool
+	}{
+		{"POST", true},
+		{"POST", true},
+		{"DELETE", false},
+		{"DELETE", false},
+	}
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest(step.method, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", step.method, http.StatusOK, w.Code, w.Body.String())
+		}
+		task := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(task); err != nil {
+			t.Fatalf("%s: error decoding task: %v", step.method, err)
+		}
+		if task.Complete != step.complete || task.Title != "Learn Go" {
+			t.Errorf("%s: expected complete to be %t, got %+v", step.method, step.complete, task)
+		}
+		if stored, _ := ctx.TasksStore.Get(seeded[0].ID); stored.Complete != step.complete {
+			t.Errorf("%s: expected the stored task's complete to be %t", step.method, step.complete)
+		}
+	}
+}
+
+func TestTaskCompleteErrors(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	id := seeded[0].ID.(bson.ObjectId).Hex()
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"POST", "/v1/tasks/" + id + "/complete/", http.StatusOK},
+		{"POST", "/v1/tasks/" + id + "/done", http.StatusNotFound},
+		{"POST", "/v1/tasks/" + id + "/complete/now", http.StatusNotFound},
+		{"POST", "/v1/tasks/" + bson.NewObjectId().Hex() + "/complete", http.StatusNotFound},
+		{"POST", "/v1/tasks/not-an-id/complete", http.StatusBadRequest},
+		{"GET", "/v1/tasks/" + id + "/complete", http.StatusMethodNotAllowed},
+		{"POST", "/v1/tasks/" + id, http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleSpecificTask(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d but got %d", c.method, c.path, c.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("OPTIONS", "/v1/tasks/"+id+"/complete", nil))
+	if allow := w.Header().Get(headerAllow); allow != allowTaskComplete {
+		t.Errorf("expected Allow %q but got %q", allowTaskComplete, allow)
+	}
+}
+
+func TestPostTaskBatch(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks",
+		strings.NewReader(` [{"title": "one"}, {"title": "two", "tags": ["x"]}, {"title": "three"}]`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := []*tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("error decoding tasks: %v", err)
+	}
+	titles := []string{}
+	for _, task := range created {
+		titles = append(titles, task.Title)
+		if task.ID == nil {
+			t.Errorf("expected %q to have an ID", task.Title)
+		}
+	}
+	//in the same order as the request
+	if expected := []string{"one", "two", "three"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v but got %v", expected, titles)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 3 {
+		t.Errorf("expected 3 tasks in the store but got %d", total)
+	}
+}
+
+func TestPostTaskBatchInvalid(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks",
+		strings.NewReader(`[{"title": "one"}, {"title": ""}, {"title": "three"}, null, {"title": "five", "tags": [""]}]`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	apierr := decodeError(t, w)
+	if apierr.Code != codeValidationFailed {
+		t.Errorf("expected code %s but got %s", codeValidationFailed, apierr.Code)
+	}
+	indices := []int{}
+	for _, ie := range apierr.Invalid {
+		indices = append(indices, ie.Index)
+	}
+	if expected := []int{1, 3, 4}; !reflect.DeepEqual(indices, expected) {
+		t.Errorf("expected invalid indices %v but got %v", expected, indices)
+	}
+	if !strings.Contains(apierr.Message, "1, 3, 4") {
+		t.Errorf("expected the indices in the message, got %q", apierr.Message)
+	}
+	if len(apierr.Invalid) == 3 {
+		if fe := apierr.Invalid[2].Fields; len(fe) != 1 || fe[0].Field != "tags[0]" {
+			t.Errorf("expected the invalid tag's field for index 4, got %+v", fe)
+		}
+	}
+	//none of the valid ones were inserted
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 0 {
+		t.Errorf("expected no tasks in the store but got %d", total)
+	}
+}
+
+func TestPostTaskBatchSize(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	batch := func(n int) string {
+		newtasks := []string{}
+		for i := 0; i < n; i++ {
+			newtasks = append(newtasks, `{"title": "task"}`)
+		}
+		return "[" + strings.Join(newtasks, ",") + "]"
+	}
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{batch(maxBatchSize), http.StatusCreated},
+		{batch(maxBatchSize + 1), http.StatusBadRequest},
+		{batch(0), http.StatusBadRequest},
+		{`[{"title": "unterminated"`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("expected status %d but got %d: %s", c.status, w.Code, w.Body.String())
+		}
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != maxBatchSize {
+		t.Errorf("expected only the first batch in the store, got %d tasks", total)
+	}
+}
+
+func TestDeleteCompletedTasks(t *testing.T) {
+	ctx, seeded := newTestContext(t, "one", "two", "three", "four")
+	complete := true
+	for _, task := range seeded[1:3] {
+		if _, err := ctx.TasksStore.Update(task.ID, &tasks.TaskUpdates{Complete: &complete}); err != nil {
+			t.Fatalf("error completing task: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("DELETE", "/v1/tasks?complete=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	dr := &deleteResponse{}
+	if err := json.NewDecoder(w.Body).Decode(dr); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if dr.Deleted != 2 {
+		t.Errorf("expected 2 tasks to be deleted but got %d", dr.Deleted)
+	}
+	//the incomplete ones survive
+	remaining, total, _ := ctx.TasksStore.Find(&tasks.Query{Sort: "createdAt"})
+	if total != 2 || remaining[0].Title != "one" || remaining[1].Title != "four" {
+		t.Errorf("expected the incomplete tasks to remain, got %d tasks", total)
+	}
+
+	//deleting again finds nothing to delete
+	w = httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("DELETE", "/v1/tasks?complete=true", nil))
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != `{"deleted":0}` {
+		t.Errorf("expected nothing to be deleted, got %d %s", w.Code, body)
+	}
+
+	//they were archived, and admins can remove them for good
+	ctx.Admins = []string{"admin"}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/v1/tasks?archived=true&complete=true", nil)
+	ctx.HandleTasks(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d deleting archived tasks again but got %d", http.StatusBadRequest, w.Code)
+	}
+	w = httptest.NewRecorder()
+	//the tasks aren't the admin's own, so this needs ?all=true
+	r = httptest.NewRequest("DELETE", "/v1/tasks?archived=true&complete=true&permanent=true&all=true", nil)
+	r = authenticated(r, "admin")
+	ctx.HandleTasks(w, r)
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != `{"deleted":2}` {
+		t.Errorf("expected the archived tasks to be removed, got %d %s", w.Code, body)
+	}
+	if alltasks, _ := ctx.TasksStore.GetAll(); len(alltasks) != 2 {
+		t.Errorf("expected only the incomplete tasks to remain, got %d tasks", len(alltasks))
+	}
+}
+
+func TestDeleteTasksNeedsFilter(t *testing.T) {
+	ctx, _ := newTestContext(t, "one", "two")
+	//paging and sorting don't filter anything
+	for _, query := range []string{"", "?limit=1", "?sort=title"} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("DELETE", "/v1/tasks"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		}
+		decodeError(t, w)
+	}
+	if _, total, _ := ctx.TasksStore.Find(&tasks.Query{}); total != 2 {
+		t.Errorf("expected no tasks to be deleted, but %d remain", total)
+	}
+}
+
+//statsStore is a tasks.Store that returns `stats`
+//from Stats, and records the query it was given
+type statsStore struct {
+	tasks.Store
+	stats *tasks.Stats
+	query *tasks.Query
+}
+
+func (ss *statsStore) Stats(q *tasks.Query) (*tasks.Stats, error) {
+	ss.query = q
+	return ss.stats, nil
+}
+
+func TestTaskStats(t *testing.T) {
+	store := &statsStore{stats: &tasks.Stats{
+		Total:           5,
+		Complete:        2,
+		Incomplete:      3,
+		Tags:            map[string]int{"school": 3, "errand": 1},
+		CreatedLastDay:  1,
+		CreatedLastWeek: 4,
+	}}
+	ctx, _ := newLoggedContext(t, store)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks/stats?tag=school", nil)
+	r.Header.Set(headerUser, "alice")
+	ctx.HandleTaskStats(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	expected := `{"total":5,"complete":2,"incomplete":3,"tags":{"errand":1,"school":3},"createdLastDay":1,"createdLastWeek":4}`
+	if body := strings.TrimSpace(w.Body.String()); body != expected {
+		t.Errorf("expected %s but got %s", expected, body)
+	}
+	//only the caller's tasks are counted
+	if store.query.Owner == nil || *store.query.Owner != "alice" || store.query.Tag != "school" {
+		t.Errorf("unexpected query %+v", store.query)
+	}
+
+	w = httptest.NewRecorder()
+	ctx.HandleTaskStats(w, httptest.NewRequest("POST", "/v1/tasks/stats", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get(headerAllow) != allowTaskStats {
+		t.Errorf("expected status %d with Allow %q, got %d with %q", http.StatusMethodNotAllowed, allowTaskStats, w.Code, w.Header().Get(headerAllow))
+	}
+}
+
+func TestTaskStatsStoreError(t *testing.T) {
+	ctx, logs := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleTaskStats(w, httptest.NewRequest("GET", "/v1/tasks/stats", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	decodeError(t, w)
+	if !strings.Contains(logs.String(), errStore.Error()) {
+		t.Errorf("expected the store error to be logged, got %q", logs.String())
+	}
+}
+
+func TestGroupedTasks(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	complete := true
+	seeds := []struct {
+		owner    string
+		title    string
+		tags     []string
+		complete bool
+	}{
+		{"alice", "one", []string{"school"}, false},
+		{"alice", "two", nil, true},
+		{"alice", "three", []string{"school"}, true},
+		{"alice", "four", []string{"school"}, false},
+		{"alice", "five", nil, false},
+		{"bob", "bob's", []string{"school"}, false},
+	}
+	for _, seed := range seeds {
+		task, err := ctx.TasksStore.Insert(&tasks.NewTask{Owner: seed.owner, Title: seed.title, Tags: seed.tags})
+		if err != nil {
+			t.Fatalf("error seeding store: %v", err)
+		}
+		if seed.complete {
+			ctx.TasksStore.Update(task.ID, &tasks.TaskUpdates{Complete: &complete})
+		}
+	}
+
+	cases := []struct {
+		query            string
+		expectedActive   []string
+		expectedComplete []string
+	}{
+		//newest first, and only alice's
+		{"", []string{"five", "four", "one"}, []string{"three", "two"}},
+		//the limit applies to each group
+		{"?limit=1", []string{"five"}, []string{"three"}},
+		{"?tag=school", []string{"four", "one"}, []string{"three"}},
+		{"?q=nothing", []string{}, []string{}},
+	}
+	for _, c := range cases {
+		w := doAs(ctx.HandleGroupedTasks, "alice", "GET", "/v1/tasks/grouped"+c.query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status %d but got %d: %s", c.query, http.StatusOK, w.Code, w.Body.String())
+		}
+		grouped := &struct {
+			Active   []*tasks.Task `json:"active"`
+			Complete []*tasks.Task `json:"complete"`
+		}{}
+		if err := json.NewDecoder(w.Body).Decode(grouped); err != nil {
+			t.Fatalf("%q: error decoding groups: %v", c.query, err)
+		}
+		if grouped.Active == nil || grouped.Complete == nil {
+			t.Errorf("%q: expected both groups to be lists", c.query)
+		}
+		for _, group := range []struct {
+			name     string
+			found    []*tasks.Task
+			expected []string
+		}{
+			{"active", grouped.Active, c.expectedActive},
+			{"complete", grouped.Complete, c.expectedComplete},
+		} {
+			titles := []string{}
+			for _, task := range group.found {
+				titles = append(titles, task.Title)
+			}
+			if !reflect.DeepEqual(titles, group.expected) {
+				t.Errorf("%q: expected %s tasks %v but got %v", c.query, group.name, group.expected, titles)
+			}
+		}
+	}
+
+	//grouping by complete and filtering by it don't mix
+	w := doAs(ctx.HandleGroupedTasks, "alice", "GET", "/v1/tasks/grouped?complete=true", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+	decodeError(t, w)
+}
+
+func TestGroupedTasksStoreError(t *testing.T) {
+	ctx, _ := newLoggedContext(t, failingStore{})
+	w := httptest.NewRecorder()
+	ctx.HandleGroupedTasks(w, httptest.NewRequest("GET", "/v1/tasks/grouped", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	decodeError(t, w)
+}
+
+func TestCompletedTasks(t *testing.T) {
+	ctx, seeded := newTestContext(t, "before", "first", "second", "incomplete", "uncompleted")
+	complete, incomplete := true, false
+	completeTask := func(task *tasks.Task, done *bool) {
+		if _, err := ctx.TasksStore.Update(task.ID, &tasks.TaskUpdates{Complete: done}); err != nil {
+			t.Fatalf("error updating task: %v", err)
+		}
+	}
+	completeTask(seeded[0], &complete)
+	since := time.Now()
+	for _, task := range []*tasks.Task{seeded[1], seeded[2], seeded[4]} {
+		completeTask(task, &complete)
+	}
+	completeTask(seeded[4], &incomplete)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx.HandleCompletedTasks(w, httptest.NewRequest("GET", "/v1/tasks/completed"+query, nil))
+		return w
+	}
+	w := get("?since=" + url.QueryEscape(since.Format(time.RFC3339Nano)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	found := []*tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(&found); err != nil {
+		t.Fatalf("error decoding tasks: %v", err)
+	}
+	titles := []string{}
+	for _, task := range found {
+		titles = append(titles, task.Title)
+		if task.CompletedAt == nil || !task.CompletedAt.After(since) {
+			t.Errorf("%s: expected completedAt after %v but got %v", task.Title, since, task.CompletedAt)
+		}
+	}
+	//most recently completed first
+	if expected := []string{"second", "first"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v but got %v", expected, titles)
+	}
+	if total := w.Header().Get(headerTotalCount); total != "2" {
+		t.Errorf("expected X-Total-Count 2 but got %q", total)
+	}
+
+	for _, query := range []string{"", "?since=yesterday", "?since=2017-05-01"} {
+		w := get(query)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		} else if apierr := decodeError(t, w); apierr.Code != codeInvalidQuery {
+			t.Errorf("%q: expected code %s but got %s", query, codeInvalidQuery, apierr.Code)
+		}
+	}
+}
+
+func TestPatchTaskBatch(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ids := []string{}
+	for _, title := range []string{"Learn Go", "Learn MongoDB", "Learn Docker"} {
+		task, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: title, Owner: "alice"})
+		ids = append(ids, task.ID.(bson.ObjectId).Hex())
+	}
+	bobs, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: "Bob's task", Owner: "bob"})
+	bobsID := bobs.ID.(bson.ObjectId).Hex()
+	missingID := bson.NewObjectId().Hex()
+	complete := true
+	ctx.TasksStore.Update(bson.ObjectIdHex(ids[2]), &tasks.TaskUpdates{Complete: &complete})
+
+	//the third is already complete, so it's matched but
+	//not modified, and bob's task isn't alice's to update
+	body := fmt.Sprintf(`{"ids": [%q, %q, %q, %q, %q, %q], "updates": {"complete": true}}`,
+		ids[0], missingID, ids[1], ids[2], bobsID, ids[0])
+	w := doAs(ctx.HandleTasks, "alice", "PATCH", "/v1/tasks", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	resp := &batchUpdateResponse{}
+	if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	expected := &batchUpdateResponse{Matched: 3, Modified: 2, NotFound: []string{missingID, bobsID}}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("expected %+v but got %+v", expected, resp)
+	}
+
+	for i, id := range ids {
+		task, _ := ctx.TasksStore.Get(bson.ObjectIdHex(id))
+		if !task.Complete {
+			t.Errorf("expected task %d to be complete", i)
+		}
+		//the one that was already complete wasn't touched
+		if expectedVersion := 2; task.Version != expectedVersion {
+			t.Errorf("task %d: expected version %d but got %d", i, expectedVersion, task.Version)
+		}
+	}
+	if task, _ := ctx.TasksStore.Get(bobs.ID); task.Complete || task.Version != 1 {
+		t.Errorf("expected bob's task to be unchanged, got %+v", task)
+	}
+
+	//nothing matched is still a success
+	w = doAs(ctx.HandleTasks, "carol", "PATCH", "/v1/tasks", fmt.Sprintf(`{"ids": [%q], "updates": {"title": "Mine now"}}`, ids[0]))
+	resp = &batchUpdateResponse{}
+	json.NewDecoder(w.Body).Decode(resp)
+	if w.Code != http.StatusOK || resp.Matched != 0 || resp.Modified != 0 || len(resp.NotFound) != 1 {
+		t.Errorf("expected nothing to match for carol, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestPatchTaskBatchRequireIfMatch(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	ctx.RequireIfMatch = true
+	id := seeded[0].ID.(bson.ObjectId).Hex()
+
+	w := doAs(ctx.HandleTasks, "", "PATCH", "/v1/tasks", fmt.Sprintf(`{"ids": [%q], "updates": {"complete": true}}`, id))
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusPreconditionRequired, w.Code, w.Body.String())
+	}
+	if apiErr := decodeError(t, w); apiErr.Code != codePreconditionRequired {
+		t.Errorf("expected code %q but got %q", codePreconditionRequired, apiErr.Code)
+	}
+	if task, _ := ctx.TasksStore.Get(seeded[0].ID); task.Complete {
+		t.Error("expected the task to be unchanged")
+	}
+}
+
+func TestPatchTaskBatchInvalid(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	id := seeded[0].ID.(bson.ObjectId).Hex()
+	tooMany := make([]string, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Quote(bson.NewObjectId().Hex())
+	}
+
+	cases := []struct {
+		name         string
+		body         string
+		expectedCode string
+	}{
+		{"no IDs", `{"ids": [], "updates": {"complete": true}}`, codeValidationFailed},
+		{"too many IDs", `{"ids": [` + strings.Join(tooMany, ",") + `], "updates": {"complete": true}}`, codeValidationFailed},
+		{"no updates", fmt.Sprintf(`{"ids": [%q]}`, id), codeValidationFailed},
+		{"empty updates", fmt.Sprintf(`{"ids": [%q], "updates": {}}`, id), codeValidationFailed},
+		{"invalid updates", fmt.Sprintf(`{"ids": [%q], "updates": {"title": ""}}`, id), codeValidationFailed},
+		{"invalid ID", fmt.Sprintf(`{"ids": [%q, "1234"], "updates": {"complete": true}}`, id), codeInvalidID},
+		//only the fields single PATCH allows can be updated
+		{"owner", fmt.Sprintf(`{"ids": [%q], "updates": {"owner": "mallory"}}`, id), codeInvalidJSON},
+		{"version", fmt.Sprintf(`{"ids": [%q], "updates": {"version": 7}}`, id), codeInvalidJSON},
+		{"archivedAt", fmt.Sprintf(`{"ids": [%q], "updates": {"archivedAt": "2017-05-01T00:00:00Z"}}`, id), codeInvalidJSON},
+	}
+	for _, c := range cases {
+		w := doAs(ctx.HandleTasks, "", "PATCH", "/v1/tasks", c.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d: %s", c.name, http.StatusBadRequest, w.Code, w.Body.String())
+			continue
+		}
+		apierr := decodeError(t, w)
+		if apierr.Code != c.expectedCode {
+			t.Errorf("%s: expected code %s but got %s", c.name, c.expectedCode, apierr.Code)
+		}
+		if c.name == "invalid ID" && (len(apierr.Invalid) != 1 || apierr.Invalid[0].Index != 1) {
+			t.Errorf("%s: expected index 1 to be listed, got %+v", c.name, apierr.Invalid)
+		}
+	}
+	if task, _ := ctx.TasksStore.Get(seeded[0].ID); task.Version != 1 {
+		t.Errorf("expected the task to be unchanged, got %+v", task)
+	}
+}
+
+//getTaskPage gets GET /v1/tasks with the query string
+//`query` as `user`, decoding the cursor page it returns
+func getTaskPage(t *testing.T, ctx *Context, user string, query string) *taskPage {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/tasks"+query, nil)
+	r.Header.Set(headerUser, user)
+	ctx.HandleTasks(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: expected status %d but got %d: %s", query, http.StatusOK, w.Code, w.Body.String())
+	}
+	page := &taskPage{}
+	if err := json.NewDecoder(w.Body).Decode(page); err != nil {
+		t.Fatalf("%s: error decoding page: %v", query, err)
+	}
+	return page
+}
+
+func TestGetAllTasksCursor(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	for i := 0; i < 7; i++ {
+		ctx.TasksStore.Insert(&tasks.NewTask{Title: fmt.Sprintf("task %d", i), Owner: "alice"})
+	}
+	ctx.TasksStore.Insert(&tasks.NewTask{Title: "bob's task", Owner: "bob"})
+
+	//walk to the end, creating a task after each page; the
+	//new ones come after the cursor, so they're walked too,
+	//without shifting the pages that came before them
+	seen := map[string]bool{}
+	titles := []string{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("still walking after %d pages: %v", pages, titles)
+		}
+		page := getTaskPage(t, ctx, "alice", "?limit=3&after="+cursor)
+		for _, task := range page.Tasks {
+			id := task.ID.(string)
+			if seen[id] {
+				t.Errorf("%s was on more than one page", task.Title)
+			}
+			seen[id] = true
+			titles = append(titles, task.Title)
+		}
+		if pages < 2 {
+			ctx.TasksStore.Insert(&tasks.NewTask{Title: fmt.Sprintf("new %d", pages), Owner: "alice"})
+		}
+		if len(page.NextCursor) == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	expected := []string{"task 0", "task 1", "task 2", "task 3", "task 4", "task 5", "task 6", "new 0", "new 1"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected to walk %v but got %v", expected, titles)
+	}
+
+	//filters apply, and the last page has no cursor
+	page := getTaskPage(t, ctx, "alice", "?complete=true&after=")
+	if len(page.Tasks) != 0 || len(page.NextCursor) > 0 {
+		t.Errorf("expected an empty last page, got %+v", page)
+	}
+	complete := true
+	alltasks, _ := ctx.TasksStore.GetAll()
+	ctx.TasksStore.Update(alltasks[5].ID, &tasks.TaskUpdates{Complete: &complete})
+	page = getTaskPage(t, ctx, "alice", "?complete=true&limit=1&after="+alltasks[2].ID.(bson.ObjectId).Hex())
+	if len(page.Tasks) != 1 || page.Tasks[0].Title != "task 5" || len(page.NextCursor) > 0 {
+		t.Errorf("expected only task 5, got %+v", page)
+	}
+}
+
+func TestGetAllTasksBadCursor(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	id := seeded[0].ID.(bson.ObjectId).Hex()
+	for _, query := range []string{"?after=1234", "?after=" + id + "&skip=1", "?after=" + id + "&sort=title"} {
+		w := httptest.NewRecorder()
+		ctx.HandleTasks(w, httptest.NewRequest("GET", "/v1/tasks"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		} else if apierr := decodeError(t, w); apierr.Code != codeInvalidQuery {
+			t.Errorf("%s: expected code %s but got %s", query, codeInvalidQuery, apierr.Code)
+		}
+	}
+}
+
+func TestGetTaskBatch(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ids := []string{}
+	for _, title := range []string{"Learn Go", "Learn MongoDB", "Learn Docker"} {
+		task, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: title, Owner: "alice"})
+		ids = append(ids, task.ID.(bson.ObjectId).Hex())
+	}
+	bobs, _ := ctx.TasksStore.Insert(&tasks.NewTask{Title: "Bob's task", Owner: "bob"})
+	bobsID := bobs.ID.(bson.ObjectId).Hex()
+	missingID := bson.NewObjectId().Hex()
+
+	//the tasks come back in the order they were asked for,
+	//not the order they were created, and listing one twice
+	//sends it once; bob's task is missing, as far as alice knows
+	query := strings.Join([]string{ids[2], missingID, ids[0], bobsID, ids[2]}, ",")
+	w := doAs(ctx.HandleTasks, "alice", "GET", "/v1/tasks?ids="+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	batch := &taskBatch{}
+	if err := json.NewDecoder(w.Body).Decode(batch); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	titles := []string{}
+	for _, task := range batch.Tasks {
+		titles = append(titles, task.Title)
+	}
+	if expected := []string{"Learn Docker", "Learn Go"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected tasks %v but got %v", expected, titles)
+	}
+	if expected := []string{missingID, bobsID}; !reflect.DeepEqual(batch.Missing, expected) {
+		t.Errorf("expected missing %v but got %v", expected, batch.Missing)
+	}
+
+	//the /v2 API sends them in its own shape
+	w = doAs(ctx.HandleTasks, "alice", "GET", "/v2/tasks?ids="+ids[1], "")
+	batchV2 := &taskBatchV2{}
+	if err := json.NewDecoder(w.Body).Decode(batchV2); err != nil || len(batchV2.Tasks) != 1 || batchV2.Tasks[0].ID != ids[1] || len(batchV2.Missing) != 0 {
+		t.Errorf("unexpected /v2 response %+v, %v", batchV2, err)
+	}
+}
+
+func TestGetTaskBatchInvalid(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	id := bson.NewObjectId().Hex()
+	tooMany := []string{}
+	for i := 0; i <= maxIDsParam; i++ {
+		tooMany = append(tooMany, bson.NewObjectId().Hex())
+	}
+	cases := []struct {
+		name         string
+		query        string
+		expectedCode string
+		mentions     string
+	}{
+		{"no IDs", "?ids=", codeInvalidQuery, paramIDs},
+		{"malformed ID", "?ids=" + id + ",not-an-id", codeInvalidID, "not-an-id"},
+		{"too many IDs", "?ids=" + strings.Join(tooMany, ","), codeInvalidQuery, strconv.Itoa(maxIDsParam)},
+		{"with a limit", "?ids=" + id + "&limit=10", codeInvalidQuery, paramLimit},
+		{"with a filter", "?complete=true&ids=" + id, codeInvalidQuery, paramComplete},
+		{"with a cursor", "?ids=" + id + "&after=", codeInvalidQuery, paramAfter},
+	}
+	for _, c := range cases {
+		w := doAs(ctx.HandleTasks, "", "GET", "/v1/tasks"+c.query, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", c.name, http.StatusBadRequest, w.Code)
+			continue
+		}
+		resp := &errorResponse{}
+		if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+			t.Fatalf("%s: error decoding response: %v", c.name, err)
+		}
+		if resp.Error.Code != c.expectedCode || !strings.Contains(resp.Error.Message, c.mentions) {
+			t.Errorf("%s: expected code %s mentioning %q, got %+v", c.name, c.expectedCode, c.mentions, resp.Error)
+		}
+	}
+}
+
+func TestDueSoon(t *testing.T) {
+	ctx, seeded := newTestContext(t, "in an hour", "tomorrow", "next week", "no due date", "overdue", "done")
+	now := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx.now = func() time.Time { return now }
+	for i, due := range []time.Duration{time.Hour, 23 * time.Hour, 48 * time.Hour, 0, -time.Hour, 2 * time.Hour} {
+		if due == 0 {
+			continue
+		}
+		dueDate := now.Add(due)
+		if _, err := ctx.TasksStore.Update(seeded[i].ID, &tasks.TaskUpdates{DueDate: &dueDate}); err != nil {
+			t.Fatalf("error updating task: %v", err)
+		}
+	}
+	complete := true
+	ctx.TasksStore.Update(seeded[5].ID, &tasks.TaskUpdates{Complete: &complete})
+
+	//soonest first, and never tasks without due dates
+	w := doAs(ctx.HandleDueSoon, "", "GET", "/v1/tasks/due-soon", "")
+	if titles := titlesIn(t, w); !reflect.DeepEqual(titles, []string{"in an hour", "tomorrow"}) {
+		t.Errorf("expected the tasks due in the next day, got %v", titles)
+	}
+	if total := w.Header().Get(headerTotalCount); total != "2" {
+		t.Errorf("expected X-Total-Count 2 but got %q", total)
+	}
+	if titles := titlesIn(t, doAs(ctx.HandleDueSoon, "", "GET", "/v1/tasks/due-soon?within=72h", "")); !reflect.DeepEqual(titles, []string{"in an hour", "tomorrow", "next week"}) {
+		t.Errorf("expected the tasks due in the next 3 days, got %v", titles)
+	}
+	if titles := titlesIn(t, doAs(ctx.HandleDueSoon, "mallory", "GET", "/v1/tasks/due-soon", "")); len(titles) != 0 {
+		t.Errorf("expected no tasks for another user, got %v", titles)
+	}
+
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/" + subReminded
+	if w := doAs(ctx.HandleSpecificTask, "mallory", "POST", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d reminding another user but got %d", http.StatusNotFound, w.Code)
+	}
+	w, task := doSubtaskRequest(t, ctx, "", "POST", path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if task.RemindedAt == nil || !task.RemindedAt.Equal(now) {
+		t.Errorf("expected remindedAt %v but got %v", now, task.RemindedAt)
+	}
+	//each reminder is only sent once...
+	if titles := titlesIn(t, doAs(ctx.HandleDueSoon, "", "GET", "/v1/tasks/due-soon", "")); !reflect.DeepEqual(titles, []string{"tomorrow"}) {
+		t.Errorf("expected the reminded task to be left out, got %v", titles)
+	}
+	//...within the window, so an old one doesn't count
+	remindedAt := now.Add(-25 * time.Hour)
+	ctx.TasksStore.Update(seeded[1].ID, &tasks.TaskUpdates{RemindedAt: &remindedAt})
+	if titles := titlesIn(t, doAs(ctx.HandleDueSoon, "", "GET", "/v1/tasks/due-soon", "")); !reflect.DeepEqual(titles, []string{"tomorrow"}) {
+		t.Errorf("expected a task reminded before the window to be included, got %v", titles)
+	}
+
+	completePath := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/" + subComplete
+	if _, task := doSubtaskRequest(t, ctx, "", "POST", completePath, ""); task.RemindedAt != nil {
+		t.Errorf("expected completing to clear remindedAt, got %v", task.RemindedAt)
+	}
+}
+
+func TestDueSoonInvalid(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Study")
+	for _, query := range []string{"?within=tomorrow", "?within=0", "?within=-1h", "?within=169h", "?complete=false", "?sort=nope"} {
+		w := doAs(ctx.HandleDueSoon, "", "GET", "/v1/tasks/due-soon"+query, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		} else if apierr := decodeError(t, w); apierr.Code != codeInvalidQuery {
+			t.Errorf("%q: expected code %s but got %s", query, codeInvalidQuery, apierr.Code)
+		}
+	}
+	path := specificTaskPath + seeded[0].ID.(bson.ObjectId).Hex() + "/" + subReminded
+	if w := doAs(ctx.HandleSpecificTask, "", "DELETE", path, ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestDuplicateTasks(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	w := doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": "Buy milk"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	stored, _ := ctx.TasksStore.Get(bson.ObjectIdHex(created.ID.(string)))
+	//just inside the window
+	ctx.now = func() time.Time { return stored.CreatedAt.Add(ctx.DuplicateWindow - time.Millisecond) }
+
+	w = doAs(ctx.HandleTasks, "alice", "POST", "/v1/tasks", `{"title": " buy MILK "}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	apierr := decodeError(t, w)
+	if existing, ok := apierr.Existing.(map[string]interface{}); apierr.Code != codeConflict || !ok || existing["id"] != created.ID {
+		t.Errorf("expected a conflict with the existing task, got %+v", apierr)
+	}
+
+	requests := []struct {
+		name           string
+		user           string
+		path           string
+		expectedStatus int
+	}{
+		{"another user", "bob", "/v1/tasks", http.StatusCreated},
+		{"invalid force", "alice", "/v1/tasks?force=maybe", http.StatusBadRequest},
+		{"force", "alice", "/v1/tasks?force=true", http.StatusCreated},
+		//the forced copy is a duplicate too
+		{"again", "alice", "/v1/tasks", http.StatusConflict},
+	}
+	for _, req := range requests {
+		if w := doAs(ctx.HandleTasks, req.user, "POST", req.path, `{"title": "Buy milk"}`); w.Code != req.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", req.name, req.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+	if found, _, _ := ctx.TasksStore.Find(&tasks.Query{Title: "buy milk"}); len(found) != 3 {
+		t.Errorf("expected 3 tasks to be created, got %d", len(found))
+	}
+}
+
+func TestDuplicateTasksWindow(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Buy milk")
+	post := func() int {
+		return doAs(ctx.HandleTasks, "", "POST", "/v1/tasks", `{"title": "Buy milk"}`).Code
+	}
+
+	//tasks created exactly DuplicateWindow ago are outside it
+	ctx.now = func() time.Time { return seeded[0].CreatedAt.Add(ctx.DuplicateWindow) }
+	if status := post(); status != http.StatusCreated {
+		t.Errorf("expected status %d at the end of the window but got %d", http.StatusCreated, status)
+	}
+
+	//deleted tasks don't count
+	ctx, seeded = newTestContext(t, "Buy milk")
+	doAs(ctx.HandleSpecificTask, "", "DELETE", specificTaskPath+seeded[0].ID.(bson.ObjectId).Hex(), "")
+	if status := post(); status != http.StatusCreated {
+		t.Errorf("expected status %d when the duplicate is deleted but got %d", http.StatusCreated, status)
+	}
+
+	//nor are tasks with a client key checked, which are
+	//created once, and found again by repeating the key
+	ctx, _ = newTestContext(t, "Buy milk")
+	if w := doAs(ctx.HandleTasks, "", "POST", "/v1/tasks", `{"title": "Buy milk", "clientKey": "k1"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected status %d with a client key but got %d", http.StatusCreated, w.Code)
+	}
+
+	ctx, _ = newTestContext(t, "Buy milk")
+	ctx.DuplicateWindow = 0
+	if status := post(); status != http.StatusCreated {
+		t.Errorf("expected status %d with no window but got %d", http.StatusCreated, status)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+)
+
+//HandleTrash will handle requests for the /v1/tasks/trash resource,
+//which holds the caller's deleted tasks until they're restored with
+//POST /v1/tasks/some-task-id/restore. GET lists them, most recently
+//deleted first, and takes the same parameters as GET /v1/tasks.
+//DELETE empties the trash, permanently deleting every task in it
+//(or just the ones matching the same filters as GET). Unlike
+//?permanent=true, anyone can do that, as it only removes tasks
+//that they've already deleted.
+func (ctx *Context) HandleTrash(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, allowTrash) {
+		return
+	}
+	q, ok := ctx.ownerQuery(w, r)
+	if !ok {
+		return
+	}
+	q.Archived = true
+
+	switch r.Method {
+	case "GET":
+		if len(r.URL.Query().Get(paramSort)) == 0 {
+			q.Sort = "-archivedAt"
+		}
+		found, total, err := ctx.TasksStore.Find(q)
+		if err != nil {
+			ctx.logf(r, "error getting deleted tasks: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error getting deleted tasks: "+err.Error())
+			return
+		}
+		//encode an empty trash as [] rather than null
+		if found == nil {
+			found = []*tasks.Task{}
+		}
+		w.Header().Set(headerTotalCount, strconv.Itoa(total))
+		respondTasks(w, r, http.StatusOK, found)
+
+	case "DELETE":
+		deleted, err := ctx.TasksStore.DeleteMany(q)
+		ctx.recordPurge()
+		if err != nil {
+			ctx.logf(r, "error emptying trash: %v", err)
+			respondError(w, http.StatusInternalServerError, codeStoreError, "error emptying trash: "+err.Error())
+			return
+		}
+		respond(w, http.StatusOK, &deleteResponse{Deleted: deleted})
+	}
+}
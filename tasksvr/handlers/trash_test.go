@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestTrash(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	//create returns the path of a new task owned by `user`
+	create := func(user string, title string) string {
+		w := doAs(ctx.HandleTasks, user, "POST", "/v1/tasks", `{"title": "`+title+`"}`)
+		created := &tasks.Task{}
+		if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+			t.Fatalf("error decoding task: %v", err)
+		}
+		return specificTaskPath + created.ID.(string)
+	}
+	create("alice", "keep")
+	old := create("alice", "old")
+	recent := create("alice", "new")
+	bobs := create("bob", "bob's")
+	for _, path := range []string{old, recent} {
+		if w := doAs(ctx.HandleSpecificTask, "alice", "DELETE", path, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d deleting but got %d", http.StatusNoContent, w.Code)
+		}
+	}
+	doAs(ctx.HandleSpecificTask, "bob", "DELETE", bobs, "")
+
+	//trashList lists `user`'s trash, checking its total
+	trashList := func(user string, query string, total string) []string {
+		w := doAs(ctx.HandleTrash, user, "GET", "/v1/tasks/trash"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if w.Header().Get(headerTotalCount) != total {
+			t.Errorf("%s%s: expected %s tasks in total but got %s", user, query, total, w.Header().Get(headerTotalCount))
+		}
+		return titlesIn(t, w)
+	}
+	//the most recently deleted come first,
+	//and nobody sees anyone else's trash
+	if titles := trashList("alice", "", "2"); !reflect.DeepEqual(titles, []string{"new", "old"}) {
+		t.Errorf("expected the trash to be [new old] but got %v", titles)
+	}
+	if titles := trashList("alice", "?limit=1&skip=1", "2"); !reflect.DeepEqual(titles, []string{"old"}) {
+		t.Errorf("expected the second page to be [old] but got %v", titles)
+	}
+	if titles := trashList("bob", "", "1"); !reflect.DeepEqual(titles, []string{"bob's"}) {
+		t.Errorf("expected bob's trash to be [bob's] but got %v", titles)
+	}
+
+	//restoring takes a task back out of the trash
+	w := doAs(ctx.HandleSpecificTask, "alice", "POST", old+"/restore", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d restoring but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if titles := trashList("alice", "", "1"); !reflect.DeepEqual(titles, []string{"new"}) {
+		t.Errorf("expected the trash to be [new] after restoring but got %v", titles)
+	}
+
+	//emptying the trash leaves the live tasks, and bob's trash
+	w = doAs(ctx.HandleTrash, "alice", "DELETE", "/v1/tasks/trash", "")
+	deleted := &deleteResponse{}
+	if err := json.NewDecoder(w.Body).Decode(deleted); err != nil || deleted.Deleted != 1 {
+		t.Errorf("expected 1 task to be deleted, got %+v, %v", deleted, err)
+	}
+	if titles := trashList("alice", "", "0"); len(titles) != 0 {
+		t.Errorf("expected the trash to be empty but got %v", titles)
+	}
+	live := titlesIn(t, doAs(ctx.HandleTasks, "alice", "GET", "/v1/tasks?sort=title", ""))
+	if !reflect.DeepEqual(live, []string{"keep", "old"}) {
+		t.Errorf("expected the live tasks to be [keep old] but got %v", live)
+	}
+	trashList("bob", "", "1")
+
+	//it's gone for good
+	if w := doAs(ctx.HandleSpecificTask, "alice", "POST", recent+"/restore", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d restoring an emptied task but got %d", http.StatusNotFound, w.Code)
+	}
+	if w := doAs(ctx.HandleTrash, "alice", "POST", "/v1/tasks/trash", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestArchivedAtUsesClock(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go", "Learn Rust")
+	now := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	ctx.now = func() time.Time { return now }
+
+	//one is deleted by itself, and the other along
+	//with the rest of the completed tasks
+	if w := doAs(ctx.HandleSpecificTask, "", "DELETE", specificTaskPath+seeded[0].ID.(bson.ObjectId).Hex(), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d", http.StatusNoContent, w.Code)
+	}
+	complete := true
+	ctx.TasksStore.Update(seeded[1].ID, &tasks.TaskUpdates{Complete: &complete})
+	if w := doAs(ctx.HandleTasks, "", "DELETE", "/v1/tasks?complete=true", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	for _, task := range seeded {
+		archived, _ := ctx.TasksStore.Get(task.ID)
+		if archived.ArchivedAt == nil || !archived.ArchivedAt.Equal(now) {
+			t.Errorf("%s: expected it to be archived at %v but got %v", task.Title, now, archived.ArchivedAt)
+		}
+	}
+}
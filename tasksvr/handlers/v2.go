@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//The /v2 API is served by the same handlers as /v1, but sends
+//tasks in a cleaner shape, without the Mongo details. Rather
+//than changing how tasks.Task marshals itself, which would
+//break /v1 clients, the handlers translate tasks into these
+//structs, so that each version can change on its own.
+
+//taskV2 is a task as it's sent by the /v2 API. The fields
+//are always in this order, and the optional ones are left
+//out when they're not set.
+type taskV2 struct {
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	Complete    bool            `json:"complete"`
+	Priority    string          `json:"priority"`
+	Tags        []string        `json:"tags,omitempty"`
+	Labels      []string        `json:"labels,omitempty"`
+	Subtasks    []*subtaskV2    `json:"subtasks,omitempty"`
+	Attachments []*attachmentV2 `json:"attachments,omitempty"`
+	DueDate     string          `json:"dueDate,omitempty"`
+	CompletedAt string          `json:"completedAt,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	CreatedAt   string          `json:"createdAt"`
+	ModifiedAt  string          `json:"modifiedAt"`
+	ArchivedAt  string          `json:"archivedAt,omitempty"`
+	RemindedAt  string          `json:"remindedAt,omitempty"`
+	Version     int             `json:"version"`
+}
+
+//subtaskV2 is a subtask as it's sent by the /v2 API
+type subtaskV2 struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Complete bool   `json:"complete"`
+}
+
+//attachmentV2 is an attachment as it's sent by the /v2 API
+type attachmentV2 struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+}
+
+//taskPageV2 is the /v2 shape of a taskPage
+type taskPageV2 struct {
+	Tasks      []*taskV2 `json:"tasks"`
+	NextCursor string    `json:"nextCursor"`
+}
+
+//taskBatchV2 is the /v2 shape of a taskBatch
+type taskBatchV2 struct {
+	Tasks   []*taskV2 `json:"tasks"`
+	Missing []string  `json:"missing"`
+}
+
+//groupedResponseV2 is the /v2 shape of a groupedResponse
+type groupedResponseV2 struct {
+	Active   []*taskV2 `json:"active"`
+	Complete []*taskV2 `json:"complete"`
+}
+
+//newTaskV2 translates `task` into its /v2 shape
+func newTaskV2(task *tasks.Task) *taskV2 {
+	id := fmt.Sprint(task.ID)
+	if oid, ok := task.ID.(bson.ObjectId); ok {
+		id = oid.Hex()
+	}
+	var subtasks []*subtaskV2
+	for _, st := range task.Subtasks {
+		subtasks = append(subtasks, &subtaskV2{ID: st.ID, Title: st.Title, Complete: st.Complete})
+	}
+	var attachments []*attachmentV2
+	for _, att := range task.Attachments {
+		attachments = append(attachments, &attachmentV2{ID: att.ID, Filename: att.Filename, Size: att.Size, ContentType: att.ContentType})
+	}
+	return &taskV2{
+		ID:          id,
+		Title:       task.Title,
+		Complete:    task.Complete,
+		Priority:    task.Priority.String(),
+		Tags:        task.Tags,
+		Labels:      task.Labels,
+		Subtasks:    subtasks,
+		Attachments: attachments,
+		DueDate:     formatTimeV2(task.DueDate),
+		CompletedAt: formatTimeV2(task.CompletedAt),
+		Owner:       task.Owner,
+		CreatedAt:   formatTimeV2(&task.CreatedAt),
+		ModifiedAt:  formatTimeV2(&task.ModifiedAt),
+		ArchivedAt:  formatTimeV2(task.ArchivedAt),
+		RemindedAt:  formatTimeV2(task.RemindedAt),
+		Version:     task.Version,
+	}
+}
+
+//newTasksV2 translates a list of tasks into their
+///v2 shape, which is [] rather than null if it's empty
+func newTasksV2(list []*tasks.Task) []*taskV2 {
+	translated := make([]*taskV2, 0, len(list))
+	for _, task := range list {
+		translated = append(translated, newTaskV2(task))
+	}
+	return translated
+}
+
+//formatTimeV2 formats `t` as an RFC3339 string in UTC,
+//or returns "" if it's nil, so it's left out
+func formatTimeV2(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+//isV2 returns true if `urlPath` is part of the /v2 API
+func isV2(urlPath string) bool {
+	return strings.HasPrefix(urlPath, "/v2/")
+}
+
+//specificTaskPrefix returns the path prefix of specific
+//tasks in the version of the API that `urlPath` is part of
+func specificTaskPrefix(urlPath string) string {
+	if isV2(urlPath) {
+		return specificTaskPathV2
+	}
+	return specificTaskPath
+}
+
+//respondTasks is like respond, but for responses containing
+//tasks, which it translates into the shape used by the
+//version of the API that `r` is for
+func respondTasks(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if isV2(r.URL.Path) {
+		switch tv := v.(type) {
+		case *tasks.Task:
+			v = newTaskV2(tv)
+		case []*tasks.Task:
+			v = newTasksV2(tv)
+		case *taskPage:
+			v = &taskPageV2{Tasks: newTasksV2(tv.Tasks), NextCursor: tv.NextCursor}
+		case *taskBatch:
+			v = &taskBatchV2{Tasks: newTasksV2(tv.Tasks), Missing: tv.Missing}
+		case *groupedResponse:
+			v = &groupedResponseV2{Active: newTasksV2(tv.Active), Complete: newTasksV2(tv.Complete)}
+		}
+	}
+	respond(w, status, v)
+}
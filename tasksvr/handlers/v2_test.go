@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestV1AndV2TaskShapes(t *testing.T) {
+	ctx, seeded := newTestContext(t, "Learn Go")
+	task := seeded[0]
+	idHex := task.ID.(bson.ObjectId).Hex()
+
+	//v1 is exactly what it's always been
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", "/v1/tasks/"+idHex, nil))
+	v1, _ := json.Marshal(task)
+	if w.Code != http.StatusOK || w.Body.String() != string(v1)+"\n" {
+		t.Errorf("expected the v1 task %s but got %d %s", v1, w.Code, w.Body.String())
+	}
+
+	//v2 has a plain id, RFC3339 times, and no empty optional fields
+	w = httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, httptest.NewRequest("GET", "/v2/tasks/"+idHex, nil))
+	expected := `{"id":"` + idHex + `","title":"Learn Go","complete":false,"priority":"normal",` +
+		`"createdAt":"` + task.CreatedAt.UTC().Format(time.RFC3339) + `",` +
+		`"modifiedAt":"` + task.ModifiedAt.UTC().Format(time.RFC3339) + `","version":1}` + "\n"
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Errorf("expected the v2 task %s but got %d %s", expected, w.Code, w.Body.String())
+	}
+}
+
+func TestV2Tasks(t *testing.T) {
+	ctx, _ := newTestContext(t)
+
+	//an empty list is still a list
+	w := httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("GET", "/v2/tasks", nil))
+	if w.Body.String() != "[]\n" {
+		t.Errorf("expected an empty list but got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	ctx.HandleTasks(w, httptest.NewRequest("POST", "/v2/tasks",
+		strings.NewReader(`{"title": "Learn Go", "tags": ["go"], "dueDate": "2030-05-01T17:00:00-07:00"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := &taskV2{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+	if created.DueDate != "2030-05-02T00:00:00Z" || len(created.Tags) != 1 || !bson.IsObjectIdHex(created.ID) {
+		t.Errorf("unexpected v2 task %+v", created)
+	}
+	if location := w.Header().Get(headerLocation); location != "/v2/tasks/"+created.ID {
+		t.Errorf("expected a /v2 Location but got %q", location)
+	}
+
+	//every response with tasks in it uses the v2 shape
+	requests := []struct {
+		handler func(http.ResponseWriter, *http.Request)
+		method  string
+		path    string
+		prefix  string
+	}{
+		{ctx.HandleTasks, "GET", "/v2/tasks", `[{"id":"` + created.ID + `"`},
+		{ctx.HandleGroupedTasks, "GET", "/v2/tasks/grouped", `{"active":[{"id":"` + created.ID + `"`},
+		{ctx.HandleSpecificTask, "POST", "/v2/tasks/" + created.ID + "/complete", `{"id":"` + created.ID + `"`},
+		{ctx.HandleSpecificTask, "PATCH", "/v2/tasks/" + created.ID, `{"id":"` + created.ID + `"`},
+		{ctx.HandleTasks, "POST", "/v2/tasks", `[{"id":"`},
+	}
+	bodies := map[string]string{
+		"PATCH": `{"title": "Learn Go well"}`,
+		"POST":  `[{"title": "Learn more Go"}]`,
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		req.handler(w, httptest.NewRequest(req.method, req.path, strings.NewReader(bodies[req.method])))
+		if !strings.HasPrefix(w.Body.String(), req.prefix) {
+			t.Errorf("%s %s: expected a body starting %s but got %d %s", req.method, req.path, req.prefix, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestV2Attachments(t *testing.T) {
+	ctx, seeded := newTestContext(t, "File taxes")
+	path := specificTaskPathV2 + seeded[0].ID.(bson.ObjectId).Hex()
+
+	//the task is sent with its attachments when one is added,
+	//and when it's read
+	w := httptest.NewRecorder()
+	ctx.HandleSpecificTask(w, newAttachmentRequest(t, "", path+"/attachments", "w2.txt", "text/plain", "hello"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	for _, body := range []string{w.Body.String(), doAs(ctx.HandleSpecificTask, "", "GET", path, "").Body.String()} {
+		task := &taskV2{}
+		if err := json.Unmarshal([]byte(body), task); err != nil || len(task.Attachments) != 1 {
+			t.Fatalf("expected a v2 task with one attachment, got %s, %v", body, err)
+		}
+		att := task.Attachments[0]
+		if len(att.ID) == 0 || att.Filename != "w2.txt" || att.ContentType != "text/plain" || att.Size != 5 {
+			t.Errorf("unexpected v2 attachment %+v", att)
+		}
+	}
+	if location := w.Header().Get(headerLocation); !strings.HasPrefix(location, path+"/attachments/") {
+		t.Fatalf("expected a /v2 Location but got %q", location)
+	}
+
+	//once it's deleted, there are no attachments to send
+	w = doAs(ctx.HandleSpecificTask, "", "DELETE", w.Header().Get(headerLocation), "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"attachments"`) {
+		t.Errorf("expected a v2 task without attachments but got %d %s", w.Code, w.Body.String())
+	}
+}
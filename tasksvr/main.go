@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
 	"github.com/info344-s17/info344-in-class/tasksvr/handlers"
 	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
 
@@ -14,6 +22,10 @@ import (
 
 const defaultPort = "80"
 
+//shutdownTimeout is how long in-flight requests
+//get to finish once the server is told to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	host := os.Getenv("HOST")
 	port := os.Getenv("PORT")
@@ -31,21 +43,238 @@ func main() {
 	}
 
 	//create TasksStore
-	tstore := &tasks.MongoStore{
-		Session:        mongoSession,
-		DatabaseName:   "tasksdemo",
-		CollectionName: "tasks",
+	tstore, err := tasks.NewMongoStore(mongoSession, "tasksdemo", "tasks")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//log to stdout, or to the destinations listed in
+	//LOGOUTPUTS or the LOGCONFIG file
+	logConfig, err := httpmw.LogConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
+	logOutput, err := httpmw.OpenLogOutput(logConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logOutput.ReopenOnSIGHUP()
+	logger := log.New(logOutput, "", log.LstdFlags)
 
 	//create handler context
-	hctx := &handlers.Context{
-		TasksStore: tstore,
+	hctx, err := handlers.NewContext(tstore, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	//set REQUIREIFMATCH to make clients send the task's
+	//ETag when changing it, so they can't lose updates
+	hctx.RequireIfMatch = len(os.Getenv("REQUIREIFMATCH")) > 0
+	//set REQUIRECONTENTTYPE to reject request bodies
+	//that don't say they're JSON
+	hctx.RequireContentType = len(os.Getenv("REQUIRECONTENTTYPE")) > 0
+	//set AUTOCOMPLETETASKS to mark tasks complete
+	//when all of their subtasks are
+	hctx.AutoCompleteTasks = len(os.Getenv("AUTOCOMPLETETASKS")) > 0
+	//the admin user can see everyone's tasks with ?all=true,
+	//once they've signed in with ADMINUSER and ADMINPASS
+	if admin := os.Getenv("ADMINUSER"); len(admin) > 0 {
+		hctx.Admins = []string{admin}
+	}
+	//set TASKQUOTA to the number of tasks each
+	//client can create per hour, like "500"
+	if quota := os.Getenv("TASKQUOTA"); len(quota) > 0 {
+		max, err := strconv.Atoi(quota)
+		if err != nil || max < 1 {
+			log.Fatalf("TASKQUOTA must be a positive number, but got %q", quota)
+		}
+		hctx.Quota = handlers.NewWriteQuota(max, time.Hour)
+	}
+	//set DUPLICATEWINDOW to how long creating a task with the
+	//same title as one just created is refused, like "1m",
+	//or to "0" to allow it at any time
+	if window := os.Getenv("DUPLICATEWINDOW"); len(window) > 0 {
+		d, err := time.ParseDuration(window)
+		if err != nil || d < 0 {
+			log.Fatalf("DUPLICATEWINDOW must be a duration like 1m, but got %q", window)
+		}
+		hctx.DuplicateWindow = d
+	}
+
+	//allow browser clients from the origins in CORSORIGINS,
+	//a comma-separated list like "https://example.com,http://localhost:3000"
+	corsOrigins := []string{}
+	for _, origin := range strings.Split(os.Getenv("CORSORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); len(origin) > 0 {
+			corsOrigins = append(corsOrigins, origin)
+		}
 	}
 
-	//add handlers
-	http.HandleFunc("/v1/tasks", hctx.HandleTasks)
-	http.HandleFunc("/v1/tasks/", hctx.HandleSpecificTask)
+	handler := newHandler(hctx, logger, corsOrigins)
 
+	//stop gracefully on Ctrl-C, or the SIGTERM
+	//sent by `docker stop`
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	//get the server timeouts, which can be overridden
+	//using environment variables like WRITETIMEOUT=30s
+	timeouts, err := httpmw.TimeoutsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := httpmw.NewServer(addr, handler, timeouts)
 	fmt.Printf("listening at %s...\n", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	if err := serveUntil(server, listener, stop, shutdownTimeout, logger); err != nil {
+		//some handlers may still be using the Mongo session,
+		//so leave it open; exiting closes it anyway
+		logger.Fatalf("error shutting down: %v", err)
+	}
+
+	//no handlers are running now, so nothing
+	//can be using these once they're closed
+	hctx.Notifier.Close()
+	logger.Println("closed WebSocket connections")
+	mongoSession.Close()
+	logger.Println("closed mongo session")
+}
+
+//serveUntil serves requests to `server` on `listener` until
+//a signal is received on `stop`. It then stops accepting new
+//requests, and waits up to `timeout` for the ones in flight
+//to finish, returning an error if they don't. WebSocket
+//connections aren't waited for, as they never finish.
+func serveUntil(server *http.Server, listener net.Listener, stop <-chan os.Signal, timeout time.Duration, logger *log.Logger) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case sig := <-stop:
+		logger.Printf("received %v, waiting for requests to finish...", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("waiting for requests to finish: %v", err)
+	}
+	//Serve returns http.ErrServerClosed as soon as Shutdown starts
+	<-served
+	logger.Println("finished in-flight requests")
+	return nil
+}
+
+//newHandler adds the routes to a new mux, and returns
+//it wrapped in the middleware every request goes through
+func newHandler(hctx *handlers.Context, logger *log.Logger, corsOrigins []string) http.Handler {
+	//all the task routes depend on Mongo, so they share
+	//a circuit breaker that fails fast while it's down
+	breaker := httpmw.NewCircuitBreaker(&httpmw.BreakerConfig{Name: "mongo"}, logger)
+
+	//add handlers, along with the methods each one
+	//supports, so that OPTIONS requests can be answered
+	mux := http.NewServeMux()
+	routes := httpmw.NewRoutes(mux)
+	//the /v2 API is served by the same handlers, which send
+	//tasks in a cleaner shape to requests for its paths
+	for _, version := range []string{"/v1", "/v2"} {
+		routes.Handle(version+"/tasks", breaker.Protect(http.HandlerFunc(hctx.HandleTasks)), "GET", "POST", "PATCH", "DELETE")
+		//the mux prefers these to the /tasks/ pattern below,
+		//so "stats", "grouped", "completed", "due-soon", "trash",
+		//"export" and "import" are never mistaken for task IDs
+		routes.Handle(version+"/tasks/stats", breaker.Protect(http.HandlerFunc(hctx.HandleTaskStats)), "GET")
+		routes.Handle(version+"/tasks/grouped", breaker.Protect(http.HandlerFunc(hctx.HandleGroupedTasks)), "GET")
+		routes.Handle(version+"/tasks/completed", breaker.Protect(http.HandlerFunc(hctx.HandleCompletedTasks)), "GET")
+		routes.Handle(version+"/tasks/due-soon", breaker.Protect(http.HandlerFunc(hctx.HandleDueSoon)), "GET")
+		routes.Handle(version+"/tasks/trash", breaker.Protect(http.HandlerFunc(hctx.HandleTrash)), "GET", "DELETE")
+		routes.Handle(version+"/tasks/export", breaker.Protect(http.HandlerFunc(hctx.HandleTaskExport)), "GET")
+		routes.Handle(version+"/tasks/import", breaker.Protect(http.HandlerFunc(hctx.HandleTaskImport)), "POST")
+		//this also handles the .../complete, .../restore, .../reminded,
+		//.../subtasks and .../attachments sub-resources of each task, so
+		//their methods are included, as the mux can't tell them apart
+		routes.Handle(version+"/tasks/", breaker.Protect(http.HandlerFunc(hctx.HandleSpecificTask)), "GET", "POST", "PUT", "PATCH", "DELETE")
+	}
+
+	//labels are shared by all of a user's tasks, so they have
+	//their own resource, which is only served by the /v1 API
+	routes.Handle("/v1/labels", breaker.Protect(http.HandlerFunc(hctx.HandleLabels)), "GET", "POST")
+	routes.Handle("/v1/labels/", breaker.Protect(http.HandlerFunc(hctx.HandleSpecificLabel)), "GET", "PATCH", "DELETE")
+
+	//WebSocket clients are told about changes to their tasks,
+	//rather than polling; this doesn't use Mongo, so it isn't
+	//behind the breaker
+	routes.Handle("/v1/ws", http.HandlerFunc(hctx.HandleWebSocket), "GET")
+	//the same changes are streamed as server-sent events, for
+	//clients that would rather use an EventSource; the mux prefers
+	//this to the /tasks/ pattern, so it's not taken for a task ID
+	routes.Handle("/v1/tasks/events", http.HandlerFunc(hctx.HandleTaskEvents), "GET")
+
+	//load balancers and Kubernetes check this to see whether
+	//Mongo is reachable, so it isn't behind the breaker, which
+	//would answer for Mongo without asking it, and it needs no X-User
+	routes.Handle("/v1/health", http.HandlerFunc(hctx.HandleHealth), "GET")
+
+	//during data migrations, POST {"enabled": true} to
+	///admin/maintenance (using the ADMINUSER and ADMINPASS
+	//credentials) to answer all other requests with a 503.
+	//Health checks still get through, so that the server
+	//isn't restarted for being in maintenance.
+	maintenance := httpmw.NewMaintenance("/health", "/v1/health", "/admin/maintenance")
+	routes.Handle("/admin/maintenance", httpmw.BasicAuth("admin", nil)(maintenance), "GET", "POST")
+
+	//log requests that don't match any route distinctly;
+	//set DEVMODE to suggest similar routes in the response
+	mux.Handle("/", httpmw.NotFound(logger, &httpmw.NotFoundConfig{
+		Patterns: routes.Patterns(),
+		Suggest:  len(os.Getenv("DEVMODE")) > 0,
+	}))
+
+	//log every request, and recover from panics
+	//so that one bad request can't crash the server.
+	//CORS comes before maintenance, so that browsers
+	//can read the 503 during maintenance.
+	//WebSocket and SSE clients stay connected for
+	//much longer than the server's WriteTimeout
+	return httpmw.Chain(mux,
+		httpmw.NoWriteTimeout("/v1/ws", "/v1/tasks/events"),
+		httpmw.AssignRequestIDs,
+		httpmw.LogRequests(logger),
+		httpmw.Recover(logger),
+		httpmw.CORS(&httpmw.CORSConfig{
+			AllowedOrigins: corsOrigins,
+			AllowedHeaders: []string{"Authorization", "Content-Type", "If-Match", "If-Modified-Since", "X-User", "Idempotency-Key", "Last-Event-ID"},
+			//clients need these to page through tasks,
+			//update them safely, and name exports
+			ExposedHeaders: []string{"X-Total-Count", "ETag", "Location", "Content-Disposition", "Retry-After", "X-RateLimit-Remaining"},
+			MaxAge:         time.Hour,
+			RouteMethods:   routes.Allowed,
+		}),
+		maintenance.Block,
+		routes.Options,
+		adminAuth,
+	)
+}
+
+//adminAuth authenticates requests that send the ADMINUSER
+//and ADMINPASS credentials with HTTP Basic authentication,
+//which admins need to use ?all=true and ?permanent=true.
+//Requests without an Authorization header are passed on as
+//they are, with the owner in X-User; ones with the wrong
+//credentials get a 401.
+func adminAuth(handler http.Handler) http.Handler {
+	authed := httpmw.BasicAuth("admin", nil)(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) == 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		authed.ServeHTTP(w, r)
+	})
 }
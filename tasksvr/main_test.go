@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
+	"github.com/info344-s17/info344-in-class/tasksvr/handlers"
+	"github.com/info344-s17/info344-in-class/tasksvr/models/tasks"
+	"golang.org/x/net/websocket"
+)
+
+const testOrigin = "https://frontend.example.com"
+
+//newTestHandler returns the real handler, using an in-memory
+//store, that allows requests from testOrigin
+func newTestHandler(t *testing.T) http.Handler {
+	logger := log.New(ioutil.Discard, "", 0)
+	hctx, err := handlers.NewContext(tasks.NewMemStore(), logger)
+	if err != nil {
+		t.Fatalf("error creating context: %v", err)
+	}
+	return newHandler(hctx, logger, []string{testOrigin})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := newTestHandler(t)
+	cases := []struct {
+		path   string
+		method string
+	}{
+		{"/v1/tasks", "POST"},
+		{"/v1/tasks", "DELETE"},
+		{"/v1/tasks/58f7d1b2e13823a1c0c1c0c1", "PATCH"},
+		{"/v1/tasks/58f7d1b2e13823a1c0c1c0c1", "DELETE"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("OPTIONS", c.path, nil)
+		r.Header.Set("Origin", testOrigin)
+		r.Header.Set("Access-Control-Request-Method", c.method)
+		r.Header.Set("Access-Control-Request-Headers", "Content-Type, If-Match")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s %s: expected status %d but got %d", c.method, c.path, http.StatusNoContent, w.Code)
+		}
+		if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != testOrigin {
+			t.Errorf("%s %s: expected Access-Control-Allow-Origin %s but got %q", c.method, c.path, testOrigin, origin)
+		}
+		if methods := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(methods, c.method) {
+			t.Errorf("%s %s: expected %s in Access-Control-Allow-Methods, got %q", c.method, c.path, c.method, methods)
+		}
+		if headers := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(headers, "Content-Type") {
+			t.Errorf("%s %s: expected Content-Type to be allowed, got %q", c.method, c.path, headers)
+		}
+	}
+
+	//other origins don't get CORS headers
+	r := httptest.NewRequest("OPTIONS", "/v1/tasks", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); len(origin) > 0 {
+		t.Errorf("expected no Access-Control-Allow-Origin for another origin, got %q", origin)
+	}
+}
+
+func TestCORSPost(t *testing.T) {
+	handler := newTestHandler(t)
+	r := httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn CORS"}`))
+	r.Header.Set("Origin", testOrigin)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != testOrigin {
+		t.Errorf("expected Access-Control-Allow-Origin %s but got %q", testOrigin, origin)
+	}
+	exposed := w.Header().Get("Access-Control-Expose-Headers")
+	for _, header := range []string{"X-Total-Count", "ETag", "Location"} {
+		if !strings.Contains(exposed, header) {
+			t.Errorf("expected %s to be exposed, got %q", header, exposed)
+		}
+	}
+
+	//the total count is readable when listing
+	r = httptest.NewRequest("GET", "/v1/tasks", nil)
+	r.Header.Set("Origin", testOrigin)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("X-Total-Count") != "1" || w.Header().Get("Access-Control-Allow-Origin") != testOrigin {
+		t.Errorf("expected a cross-origin listing with X-Total-Count 1, got headers %v", w.Header())
+	}
+}
+
+func TestTaskRoutes(t *testing.T) {
+	handler := newTestHandler(t)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/v1/tasks", strings.NewReader(`{"title": "Learn Go", "tags": ["go"]}`)))
+	created := &tasks.Task{}
+	if err := json.NewDecoder(w.Body).Decode(created); err != nil {
+		t.Fatalf("error decoding task: %v", err)
+	}
+
+	//stats goes to its own handler, rather than
+	//being treated as a malformed task ID
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	stats := &tasks.Stats{}
+	if err := json.NewDecoder(w.Body).Decode(stats); err != nil {
+		t.Fatalf("error decoding stats: %v", err)
+	}
+	if stats.Total != 1 || stats.Tags["go"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	//as does grouped
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks/grouped", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), `{"active":[{`) {
+		t.Errorf("expected the grouped tasks, got %d %s", w.Code, w.Body.String())
+	}
+
+	//and export
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks/export", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id,title,") {
+		t.Errorf("expected a CSV export, got %d %s", w.Code, w.Body.String())
+	}
+
+	//while task IDs still go to the specific task handler
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/tasks/"+created.ID.(string), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d getting the task but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	//and the /v2 API serves the same tasks
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v2/tasks/"+created.ID.(string), nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), `{"id":"`+created.ID.(string)+`"`) {
+		t.Errorf("expected the v2 task, got %d %s", w.Code, w.Body.String())
+	}
+
+	//labels have their own resources
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/v1/labels", strings.NewReader(`{"name": "school", "color": "#1e90ff"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d creating a label but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", location, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"school"`) {
+		t.Errorf("expected the label, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWebSocketRoute(t *testing.T) {
+	server := httptest.NewServer(newTestHandler(t))
+	defer server.Close()
+	//the middleware has to let the handler take over the connection
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", "", testOrigin)
+	if err != nil {
+		t.Fatalf("error dialing WebSocket: %v", err)
+	}
+	defer ws.Close()
+
+	resp, err := http.Post(server.URL+"/v1/tasks", "application/json", strings.NewReader(`{"title": "Learn WebSockets"}`))
+	if err != nil {
+		t.Fatalf("error posting task: %v", err)
+	}
+	resp.Body.Close()
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	e := &handlers.Event{}
+	if err := websocket.JSON.Receive(ws, e); err != nil {
+		t.Fatalf("error receiving event: %v", err)
+	}
+	if e.Type != handlers.EventCreated || e.Task.Title != "Learn WebSockets" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestStreamsOutliveWriteTimeout(t *testing.T) {
+	timeouts := httpmw.DefaultTimeouts
+	timeouts.Write = 100 * time.Millisecond
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = httpmw.NewServer("", newTestHandler(t), &timeouts)
+	server.Start()
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", "", testOrigin)
+	if err != nil {
+		t.Fatalf("error dialing WebSocket: %v", err)
+	}
+	defer ws.Close()
+	resp, err := http.Get(server.URL + "/v1/tasks/events")
+	if err != nil {
+		t.Fatalf("error getting events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	//both are still open once the timeout has passed
+	time.Sleep(3 * timeouts.Write)
+	resp2, err := http.Post(server.URL+"/v1/tasks", "application/json", strings.NewReader(`{"title": "Learn SSE"}`))
+	if err != nil {
+		t.Fatalf("error posting task: %v", err)
+	}
+	resp2.Body.Close()
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	e := &handlers.Event{}
+	if err := websocket.JSON.Receive(ws, e); err != nil || e.Task.Title != "Learn SSE" {
+		t.Errorf("expected the WebSocket to get the event, got %+v, %v", e, err)
+	}
+	events := bufio.NewScanner(resp.Body)
+	for events.Scan() {
+		if strings.HasPrefix(events.Text(), "data:") {
+			if !strings.Contains(events.Text(), "Learn SSE") {
+				t.Errorf("unexpected event %s", events.Text())
+			}
+			return
+		}
+	}
+	t.Errorf("expected the event stream to get the event, got %v", events.Err())
+}
+
+//slowStore is a MemStore whose Insert doesn't
+//return until the `release` channel is closed
+type slowStore struct {
+	*tasks.MemStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (ss *slowStore) Insert(newtask *tasks.NewTask) (*tasks.Task, error) {
+	close(ss.started)
+	<-ss.release
+	return ss.MemStore.Insert(newtask)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	store := &slowStore{tasks.NewMemStore(), make(chan struct{}), make(chan struct{})}
+	logger := log.New(ioutil.Discard, "", 0)
+	hctx, err := handlers.NewContext(store, logger)
+	if err != nil {
+		t.Fatalf("error creating context: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	url := "http://" + listener.Addr().String()
+	server := &http.Server{Handler: newHandler(hctx, logger, nil)}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntil(server, listener, stop, 5*time.Second, logger)
+	}()
+
+	posted := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(url+"/v1/tasks", "application/json", strings.NewReader(`{"title": "Learn Go"}`))
+		if err != nil {
+			t.Errorf("error posting task: %v", err)
+			posted <- 0
+			return
+		}
+		resp.Body.Close()
+		posted <- resp.StatusCode
+	}()
+
+	//shut down while the POST is in the store
+	select {
+	case <-store.started:
+	case <-time.After(time.Second):
+		t.Fatal("the POST never reached the store")
+	}
+	stop <- os.Interrupt
+
+	//new connections are refused from then on
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: time.Second}
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, err := client.Get(url + "/v1/tasks")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("still accepting requests after shutting down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	//but it waits for the POST
+	select {
+	case err := <-served:
+		t.Fatalf("returned before the POST finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(store.release)
+	if status := <-posted; status != http.StatusCreated {
+		t.Errorf("expected the in-flight POST to get status %d but got %d", http.StatusCreated, status)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("unexpected error shutting down: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("never finished shutting down")
+	}
+}
+
+func TestHealthRoute(t *testing.T) {
+	handler := newTestHandler(t)
+	w := httptest.NewRecorder()
+	//health checks don't say who they are
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/health", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"store":"ok"`) {
+		t.Errorf("expected a healthy store, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	os.Setenv("ADMINUSER", "admin")
+	os.Setenv("ADMINPASS", "secret")
+	defer os.Unsetenv("ADMINUSER")
+	defer os.Unsetenv("ADMINPASS")
+	logger := log.New(ioutil.Discard, "", 0)
+	hctx, err := handlers.NewContext(tasks.NewMemStore(), logger)
+	if err != nil {
+		t.Fatalf("error creating context: %v", err)
+	}
+	hctx.Admins = []string{"admin"}
+	handler := newHandler(hctx, logger, nil)
+
+	cases := []struct {
+		name           string
+		user           string
+		password       string
+		expectedStatus int
+	}{
+		{"signed in", "admin", "secret", http.StatusOK},
+		{"wrong password", "admin", "nope", http.StatusUnauthorized},
+		{"X-User", "", "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/tasks?all=true", nil)
+		if len(c.user) > 0 {
+			r.SetBasicAuth(c.user, c.password)
+		} else {
+			r.Header.Set("X-User", "admin")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", c.name, c.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
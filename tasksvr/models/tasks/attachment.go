@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//MaxAttachments is the most attachments a task can have
+const MaxAttachments = 10
+
+//maxFilenameLength is the longest filename an attachment can have
+const maxFilenameLength = 255
+
+//ErrAttachmentNotFound is returned by a Store when the task
+//exists, but has no attachment with the requested ID
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+//ErrTooManyAttachments is returned by a Store when adding an
+//attachment to a task that already has MaxAttachments of them
+var ErrTooManyAttachments = errors.New("task has too many attachments")
+
+//Attachment describes a file attached to a task, like a
+//photo or a PDF. Only this is stored in the task; the
+//file's bytes are stored separately, under the same ID.
+type Attachment struct {
+	//ID is unique within the task, and is a string
+	//for the same reason as a Subtask's ID
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+}
+
+//NewAttachment describes a file being attached to a task;
+//its size isn't known until its bytes have been stored
+type NewAttachment struct {
+	Filename    string
+	ContentType string
+}
+
+//Validate will validate the NewAttachment, returning
+//a *ValidationError if it's invalid. Which types
+//of files can be attached is up to the caller.
+func (na *NewAttachment) Validate() error {
+	ve := &ValidationError{}
+	if len(na.Filename) == 0 {
+		ve.add("filename", CodeRequired, "filename must be something")
+	} else if utf8.RuneCountInString(na.Filename) > maxFilenameLength {
+		ve.add("filename", CodeTooLong, "filename must be at most %d characters", maxFilenameLength)
+	}
+	if len(na.ContentType) == 0 {
+		ve.add("contentType", CodeRequired, "contentType must be something")
+	}
+	return ve.err()
+}
+
+//ToAttachment converts a NewAttachment to an Attachment
+//with a new ID, which is an ObjectId, so that it can also
+//be the ID of the file in GridFS
+func (na *NewAttachment) ToAttachment(size int64) *Attachment {
+	return &Attachment{
+		ID:          bson.NewObjectId().Hex(),
+		Filename:    na.Filename,
+		Size:        size,
+		ContentType: na.ContentType,
+	}
+}
@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//failingReader fails with `err` on the first read
+type failingReader struct {
+	err error
+}
+
+func (fr failingReader) Read(p []byte) (int, error) {
+	return 0, fr.err
+}
+
+func TestMemStoreAttachments(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Learn GridFS"})
+	newatt := &NewAttachment{Filename: "notes.txt", ContentType: "text/plain"}
+
+	updated, err := store.AddAttachment(task.ID, newatt, strings.NewReader("hello"))
+	if err != nil || len(updated.Attachments) != 1 || updated.Version != 2 {
+		t.Fatalf("expected one attachment and version 2, got %+v, %v", updated, err)
+	}
+	att := updated.Attachments[0]
+	if att.Filename != "notes.txt" || att.ContentType != "text/plain" || att.Size != 5 || !bson.IsObjectIdHex(att.ID) {
+		t.Errorf("unexpected attachment %+v", att)
+	}
+
+	opened, content, err := store.OpenAttachment(task.ID, att.ID)
+	if err != nil {
+		t.Fatalf("error opening attachment: %v", err)
+	}
+	data, _ := ioutil.ReadAll(content)
+	content.Close()
+	if string(data) != "hello" || *opened != *att {
+		t.Errorf("expected the attachment back, got %+v with %q", opened, data)
+	}
+	if _, _, err := store.OpenAttachment(task.ID, bson.NewObjectId().Hex()); err != ErrAttachmentNotFound {
+		t.Errorf("expected ErrAttachmentNotFound but got %v", err)
+	}
+	if _, _, err := store.OpenAttachment(bson.NewObjectId(), att.ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	//if the bytes can't be read, nothing is attached
+	readErr := errors.New("connection reset")
+	if _, err := store.AddAttachment(task.ID, newatt, failingReader{readErr}); err != readErr {
+		t.Errorf("expected the read error but got %v", err)
+	}
+	if got, _ := store.Get(task.ID); len(got.Attachments) != 1 {
+		t.Errorf("expected still one attachment, got %d", len(got.Attachments))
+	}
+
+	if updated, err = store.DeleteAttachment(task.ID, att.ID); err != nil || len(updated.Attachments) != 0 {
+		t.Errorf("expected no attachments after deleting it, got %+v, %v", updated, err)
+	}
+	if _, err := store.DeleteAttachment(task.ID, att.ID); err != ErrAttachmentNotFound {
+		t.Errorf("expected ErrAttachmentNotFound deleting it again but got %v", err)
+	}
+	if len(store.files) != 0 {
+		t.Errorf("expected the bytes to be deleted too, but %d files are left", len(store.files))
+	}
+}
+
+func TestMemStoreTooManyAttachments(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Learn GridFS"})
+	newatt := &NewAttachment{Filename: "photo.png", ContentType: "image/png"}
+	for i := 0; i < MaxAttachments; i++ {
+		if _, err := store.AddAttachment(task.ID, newatt, strings.NewReader("png")); err != nil {
+			t.Fatalf("error adding attachment %d: %v", i, err)
+		}
+	}
+	if _, err := store.AddAttachment(task.ID, newatt, strings.NewReader("png")); err != ErrTooManyAttachments {
+		t.Errorf("expected ErrTooManyAttachments but got %v", err)
+	}
+	if len(store.files) != MaxAttachments {
+		t.Errorf("expected %d files but got %d", MaxAttachments, len(store.files))
+	}
+}
+
+func TestMemStoreDeleteRemovesAttachments(t *testing.T) {
+	store := NewMemStore()
+	newatt := &NewAttachment{Filename: "notes.txt", ContentType: "text/plain"}
+	one, _ := store.Insert(&NewTask{Title: "one"})
+	two, _ := store.Insert(&NewTask{Title: "two", Tags: []string{"old"}})
+	kept, _ := store.Insert(&NewTask{Title: "kept"})
+	for _, task := range []*Task{one, two, kept} {
+		store.AddAttachment(task.ID, newatt, strings.NewReader(task.Title))
+	}
+
+	if err := store.Delete(one.ID); err != nil {
+		t.Fatalf("error deleting task: %v", err)
+	}
+	if len(store.files) != 2 {
+		t.Errorf("expected 2 files after deleting a task, got %d", len(store.files))
+	}
+	if n, err := store.DeleteMany(&Query{Tag: "old"}); err != nil || n != 1 {
+		t.Fatalf("expected 1 task to be deleted, got %d, %v", n, err)
+	}
+	if len(store.files) != 1 {
+		t.Errorf("expected 1 file after deleting another, got %d", len(store.files))
+	}
+
+	//the one that's left is untouched
+	got, _ := store.Get(kept.ID)
+	_, content, err := store.OpenAttachment(kept.ID, got.Attachments[0].ID)
+	if err != nil {
+		t.Fatalf("error opening attachment: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(content); string(data) != "kept" {
+		t.Errorf("expected the kept task's file, got %q", data)
+	}
+}
+
+func TestNewAttachmentValidate(t *testing.T) {
+	if err := (&NewAttachment{Filename: "notes.txt", ContentType: "text/plain"}).Validate(); err != nil {
+		t.Errorf("expected a valid attachment but got %v", err)
+	}
+	cases := []struct {
+		newatt   *NewAttachment
+		expected string
+	}{
+		{&NewAttachment{ContentType: "text/plain"}, "filename:" + CodeRequired},
+		{&NewAttachment{Filename: strings.Repeat("a", maxFilenameLength+1), ContentType: "text/plain"}, "filename:" + CodeTooLong},
+		{&NewAttachment{Filename: "notes.txt"}, "contentType:" + CodeRequired},
+	}
+	for _, c := range cases {
+		if codes := fieldCodes(t, c.newatt.Validate()); !reflect.DeepEqual(codes, []string{c.expected}) {
+			t.Errorf("%+v: expected %s but got %v", c.newatt, c.expected, codes)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestCompletedAtTransitions(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Learn Go"})
+	if task.CompletedAt != nil {
+		t.Fatalf("expected a new task not to be completed, got %v", task.CompletedAt)
+	}
+
+	complete, incomplete := true, false
+	before := time.Now()
+	task, _ = store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	if task.CompletedAt == nil || task.CompletedAt.Before(before) {
+		t.Fatalf("expected completedAt to be set when completing, got %v", task.CompletedAt)
+	}
+	completedAt := *task.CompletedAt
+
+	//completing it again, or changing something else, keeps the time
+	task, _ = store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	title := "Learn Go well"
+	task, _ = store.Update(task.ID, &TaskUpdates{Title: &title})
+	if task.CompletedAt == nil || !task.CompletedAt.Equal(completedAt) {
+		t.Errorf("expected completedAt to stay %v, got %v", completedAt, task.CompletedAt)
+	}
+
+	task, _ = store.Update(task.ID, &TaskUpdates{Complete: &incomplete})
+	if task.CompletedAt != nil {
+		t.Errorf("expected completedAt to be cleared when uncompleting, got %v", task.CompletedAt)
+	}
+
+	//imported tasks that are already complete were completed when created
+	imported := (&NewTask{Title: "Learn Go", Complete: true}).ToTask()
+	if imported.CompletedAt == nil || !imported.CompletedAt.Equal(imported.CreatedAt) {
+		t.Errorf("expected an imported complete task to have completedAt %v, got %v", imported.CreatedAt, imported.CompletedAt)
+	}
+}
+
+func TestCompletedAtMongoDocs(t *testing.T) {
+	complete, incomplete := true, false
+	update := updateDoc(&TaskUpdates{Complete: &complete})
+	min, ok := update["$min"].(bson.M)
+	if !ok || min["completedat"] != update["$set"].(bson.M)["modifiedat"] {
+		t.Errorf("expected completing to $min completedat, got %v", update)
+	}
+	update = updateDoc(&TaskUpdates{Complete: &incomplete})
+	if unset := update["$unset"]; !reflect.DeepEqual(unset, bson.M{"completedat": ""}) {
+		t.Errorf("expected uncompleting to $unset completedat, got %v", update)
+	}
+	if _, ok := update["$min"]; ok {
+		t.Errorf("expected no $min when uncompleting, got %v", update)
+	}
+
+	since := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	expected := bson.M{"completedat": bson.M{"$gt": since}, "archivedat": nil}
+	if filter := (&Query{CompletedAfter: &since}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+}
+
+func TestMemStoreCompletedAfter(t *testing.T) {
+	store := NewMemStore()
+	since := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	completedAt := map[string]time.Time{
+		"before":     since.Add(-time.Second),
+		"exactly":    since,
+		"just after": since.Add(time.Nanosecond),
+		"later":      since.Add(time.Hour),
+	}
+	for _, title := range []string{"later", "before", "just after", "exactly", "incomplete"} {
+		store.Insert(&NewTask{Title: title})
+	}
+	for _, task := range store.tasks {
+		if at, ok := completedAt[task.Title]; ok {
+			task.Complete = true
+			task.CompletedAt = &at
+		}
+	}
+
+	found, total, _ := store.Find(&Query{CompletedAfter: &since, Sort: "-completedAt"})
+	titles := []string{}
+	for _, task := range found {
+		titles = append(titles, task.Title)
+	}
+	expected := []string{"later", "just after"}
+	if total != 2 || !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v but got %v of %d", expected, titles, total)
+	}
+}
+
+func TestCompletingClearsRemindedAt(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Learn Go"})
+	remindedAt := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	task, _ = store.Update(task.ID, &TaskUpdates{RemindedAt: &remindedAt})
+	if task.RemindedAt == nil || !task.RemindedAt.Equal(remindedAt) {
+		t.Fatalf("expected remindedAt to be %v, got %v", remindedAt, task.RemindedAt)
+	}
+
+	complete := true
+	if !(&TaskUpdates{Complete: &complete}).Changes(task) {
+		t.Errorf("expected completing to change a task that's been reminded about")
+	}
+	task, _ = store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	if task.RemindedAt != nil {
+		t.Errorf("expected remindedAt to be cleared when completing, got %v", task.RemindedAt)
+	}
+	update := updateDoc(&TaskUpdates{Complete: &complete})
+	if unset := update["$unset"]; !reflect.DeepEqual(unset, bson.M{"remindedat": ""}) {
+		t.Errorf("expected completing to $unset remindedat, got %v", update)
+	}
+}
+
+func TestQueryNotRemindedSince(t *testing.T) {
+	since := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	//$not also matches tasks that have never been reminded
+	expected := bson.M{"remindedat": bson.M{"$not": bson.M{"$gt": since}}, "archivedat": nil}
+	if filter := (&Query{NotRemindedSince: &since}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+
+	before, after := since.Add(-time.Hour), since.Add(time.Hour)
+	q := &Query{NotRemindedSince: &since}
+	if !q.matches(&Task{}) || !q.matches(&Task{RemindedAt: &before}) || q.matches(&Task{RemindedAt: &after}) {
+		t.Errorf("expected only tasks not reminded since %v to match", since)
+	}
+}
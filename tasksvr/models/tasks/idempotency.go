@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+//MaxClientKeyLength is the longest key a client
+//can create a task with
+const MaxClientKeyLength = 255
+
+//ErrDuplicateClientKey is returned by a Store when inserting
+//a task with the same ClientKey as one the owner already has
+var ErrDuplicateClientKey = errors.New("a task was already created with that client key")
+
+//Idempotency records the key that a client created a task with,
+//so that retrying the create can find the task instead of
+//inserting a copy of it
+type Idempotency struct {
+	//Owner and Key are unique together; the MongoStore
+	//has a unique index on them, so that Mongo settles
+	//the race between two creates with the same key
+	Owner string
+	Key   string
+	//Fingerprint identifies the rest of the new task, so that
+	//reusing a key for a different task can be refused
+	Fingerprint string
+}
+
+//idempotency returns the Idempotency for the task created from
+//`nt`, or nil if it doesn't have a ClientKey. The fingerprint
+//covers the fields after Validate has normalized them, so that
+//"normal" and no priority at all are the same task.
+func (nt *NewTask) idempotency() *Idempotency {
+	if len(nt.ClientKey) == 0 {
+		return nil
+	}
+	var due *time.Time
+	if nt.DueDate != nil {
+		utc := nt.DueDate.UTC()
+		due = &utc
+	}
+	priority, err := ParsePriority(nt.Priority)
+	if err != nil {
+		priority = PriorityNormal
+	}
+	//marshaling a struct of strings, bools and times can't fail
+	j, _ := json.Marshal(struct {
+		Title    string
+		Tags     []string
+		DueDate  *time.Time
+		Priority string
+		Complete bool
+	}{nt.Title, nt.Tags, due, priority.String(), nt.Complete})
+	sum := sha256.Sum256(j)
+	return &Idempotency{
+		Owner:       nt.Owner,
+		Key:         nt.ClientKey,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+}
+
+//SameAs reports whether the task was created from a NewTask
+//with the same ClientKey and fields as `nt`, so that creating
+//`nt` is just a retry of creating the task
+func (t *Task) SameAs(nt *NewTask) bool {
+	idem := nt.idempotency()
+	return t.Idempotency != nil && idem != nil && *t.Idempotency == *idem
+}
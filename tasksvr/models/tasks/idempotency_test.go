@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSameAs(t *testing.T) {
+	due := time.Date(2017, 5, 1, 17, 0, 0, 0, time.FixedZone("PDT", -7*60*60))
+	nt := &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, ClientKey: "abc", Owner: "alice"}
+	task := nt.ToTask()
+
+	utc := due.UTC()
+	cases := []struct {
+		name     string
+		newtask  *NewTask
+		expected bool
+	}{
+		{"same", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, ClientKey: "abc", Owner: "alice"}, true},
+		{"same instant in UTC", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &utc, ClientKey: "abc", Owner: "alice", Priority: "normal"}, true},
+		{"different title", &NewTask{Title: "Learn Rust", Tags: []string{"go"}, DueDate: &due, ClientKey: "abc", Owner: "alice"}, false},
+		{"different priority", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, ClientKey: "abc", Owner: "alice", Priority: "high"}, false},
+		{"different key", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, ClientKey: "xyz", Owner: "alice"}, false},
+		{"different owner", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, ClientKey: "abc", Owner: "bob"}, false},
+		{"no key", &NewTask{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, Owner: "alice"}, false},
+	}
+	for _, c := range cases {
+		if got := task.SameAs(c.newtask); got != c.expected {
+			t.Errorf("%s: expected SameAs to be %t", c.name, c.expected)
+		}
+	}
+	if (&NewTask{Title: "Learn Go"}).ToTask().Idempotency != nil {
+		t.Errorf("expected no Idempotency without a client key")
+	}
+}
+
+func TestClientKeyValidate(t *testing.T) {
+	long := make([]byte, MaxClientKeyLength+1)
+	for i := range long {
+		long[i] = 'k'
+	}
+	err := (&NewTask{Title: "Learn Go", ClientKey: string(long)}).Validate()
+	if codes := fieldCodes(t, err); len(codes) != 1 || codes[0] != "clientKey:"+CodeTooLong {
+		t.Errorf("expected clientKey to be too long, got %v", codes)
+	}
+}
+
+func TestMemStoreClientKey(t *testing.T) {
+	store := NewMemStore()
+	alice := &NewTask{Title: "Learn Go", ClientKey: "abc", Owner: "alice"}
+	task, err := store.Insert(alice)
+	if err != nil {
+		t.Fatalf("error inserting: %v", err)
+	}
+	if _, err := store.Insert(alice); err != ErrDuplicateClientKey {
+		t.Errorf("expected ErrDuplicateClientKey but got %v", err)
+	}
+	//keys are per owner
+	if _, err := store.Insert(&NewTask{Title: "Learn Go", ClientKey: "abc", Owner: "bob"}); err != nil {
+		t.Errorf("expected bob to be able to use the same key but got %v", err)
+	}
+	//tasks without keys never clash
+	for i := 0; i < 2; i++ {
+		if _, err := store.Insert(&NewTask{Title: "Learn Go", Owner: "alice"}); err != nil {
+			t.Errorf("error inserting task without a key: %v", err)
+		}
+	}
+	if found, err := store.GetByClientKey("alice", "abc"); err != nil || found.ID != task.ID {
+		t.Errorf("expected to find alice's task, got %+v, %v", found, err)
+	}
+	if _, err := store.GetByClientKey("carol", "abc"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for another owner but got %v", err)
+	}
+	batch := []*NewTask{{Title: "a", ClientKey: "k"}, {Title: "b", ClientKey: "k"}}
+	if _, err := store.InsertMany(batch); err != ErrDuplicateClientKey {
+		t.Errorf("expected ErrDuplicateClientKey for a batch repeating a key but got %v", err)
+	}
+	if _, total, _ := store.Find(&Query{}); total != 4 {
+		t.Errorf("expected 4 tasks but got %d", total)
+	}
+}
+
+func TestMemStoreClientKeyRace(t *testing.T) {
+	store := NewMemStore()
+	const creators = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, creators)
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Insert(&NewTask{Title: "Learn Go", ClientKey: "abc"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	inserted := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			inserted++
+		case ErrDuplicateClientKey:
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if inserted != 1 {
+		t.Errorf("expected exactly one insert to win but %d did", inserted)
+	}
+}
@@ -0,0 +1,181 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//limits on the labels of a task, and on their names
+const (
+	maxLabels          = 10
+	maxLabelNameLength = 50
+)
+
+//ErrLabelNotFound is returned by a Store when
+//there's no label with the requested ID
+var ErrLabelNotFound = errors.New("label not found")
+
+//ErrDuplicateLabelName is returned by a Store when the
+//owner already has a label with the same name
+var ErrDuplicateLabelName = errors.New("label name is already used")
+
+//ErrLabelInUse is returned by a Store when deleting a label,
+//without removing it from tasks, that some tasks still have
+var ErrLabelInUse = errors.New("label is in use")
+
+//labelColor matches a color like "#1e90ff"
+var labelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+//Label is a named, colored label, like "school" in blue,
+//that its owner can put on any of their tasks. Unlike tags,
+//labels are stored on their own, so renaming or recoloring
+//one changes it on every task that has it.
+type Label struct {
+	ID         interface{} `json:"id" bson:"_id"`
+	Owner      string      `json:"owner"`
+	Name       string      `json:"name"`
+	Color      string      `json:"color"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	ModifiedAt time.Time   `json:"modifiedAt"`
+}
+
+//NewLabel represents a new label posted to the server
+type NewLabel struct {
+	Name string `json:"name"`
+	//Color is a hex color like "#1e90ff"
+	Color string `json:"color"`
+	//Owner is set by the server, not the client
+	Owner string `json:"-"`
+}
+
+//LabelUpdates represents updates to a label,
+//with pointers for the same reason as TaskUpdates
+type LabelUpdates struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+//LabelRef returns how tasks refer to the label with the
+//given ID: the hex of its ObjectId, like a Subtask's ID
+func LabelRef(ID interface{}) string {
+	if oid, ok := ID.(bson.ObjectId); ok {
+		return oid.Hex()
+	}
+	return fmt.Sprint(ID)
+}
+
+//Validate will validate the NewLabel, trimming its name and
+//lower-casing its color, returning a *ValidationError if it's
+//invalid. Whether the name is already used is up to the Store.
+func (nl *NewLabel) Validate() error {
+	ve := &ValidationError{}
+	nl.Name = validateLabelName(nl.Name, ve)
+	nl.Color = validateLabelColor(nl.Color, ve)
+	return ve.err()
+}
+
+//validateLabelName returns `name` with the spaces trimmed,
+//adding a problem to `ve` if it's empty or too long
+func validateLabelName(name string, ve *ValidationError) string {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		ve.add("name", CodeRequired, "name must be something")
+	} else if utf8.RuneCountInString(name) > maxLabelNameLength {
+		ve.add("name", CodeTooLong, "name must be at most %d characters", maxLabelNameLength)
+	}
+	return name
+}
+
+//validateLabelColor returns `color` in lower case,
+//adding a problem to `ve` if it isn't a hex color
+func validateLabelColor(color string, ve *ValidationError) string {
+	if len(color) == 0 {
+		ve.add("color", CodeRequired, "color must be something, like #1e90ff")
+	} else if !labelColor.MatchString(color) {
+		ve.add("color", CodeInvalid, "color must be a hex color like #1e90ff, but got %q", color)
+	}
+	return strings.ToLower(color)
+}
+
+//ToLabel converts a NewLabel to a Label
+func (nl *NewLabel) ToLabel() *Label {
+	return &Label{
+		Owner:      nl.Owner,
+		Name:       nl.Name,
+		Color:      nl.Color,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+	}
+}
+
+//Validate will validate the LabelUpdates like
+//NewLabel.Validate, returning a *ValidationError
+//if they're invalid
+func (lu *LabelUpdates) Validate() error {
+	ve := &ValidationError{}
+	if lu.Name == nil && lu.Color == nil {
+		ve.add("", CodeNoUpdates, "no updates: set name and/or color")
+		return ve
+	}
+	if lu.Name != nil {
+		name := validateLabelName(*lu.Name, ve)
+		lu.Name = &name
+	}
+	if lu.Color != nil {
+		color := validateLabelColor(*lu.Color, ve)
+		lu.Color = &color
+	}
+	return ve.err()
+}
+
+//Apply applies the updates to `l`
+func (lu *LabelUpdates) Apply(l *Label) {
+	if lu.Name != nil {
+		l.Name = *lu.Name
+	}
+	if lu.Color != nil {
+		l.Color = *lu.Color
+	}
+	l.ModifiedAt = time.Now()
+}
+
+//normalizeLabels returns `labels` with the spaces trimmed
+//from each one and the duplicates removed, like normalizeTags.
+//It adds a problem to `ve` for each one that isn't the ID
+//of a label, and if there are too many. Whether the labels
+//exist is up to the caller, which knows who owns the task.
+func normalizeLabels(labels []string, ve *ValidationError) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for i, label := range labels {
+		label = strings.TrimSpace(label)
+		if !bson.IsObjectIdHex(label) {
+			ve.add(fmt.Sprintf("labels[%d]", i), CodeInvalid, "labels must be label IDs, but %q isn't", label)
+			continue
+		}
+		if !seen[label] {
+			seen[label] = true
+			normalized = append(normalized, label)
+		}
+	}
+	if len(normalized) > maxLabels {
+		ve.add("labels", CodeTooMany, "tasks can have at most %d labels", maxLabels)
+	}
+	return normalized
+}
+
+//hasLabel returns true if `t` has the label
+func hasLabel(t *Task, label string) bool {
+	for _, l := range t.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNewLabelValidate(t *testing.T) {
+	nl := &NewLabel{Name: " school ", Color: "#1E90FF"}
+	if err := nl.Validate(); err != nil {
+		t.Fatalf("expected a valid label but got %v", err)
+	}
+	if nl.Name != "school" || nl.Color != "#1e90ff" {
+		t.Errorf("expected the name trimmed and the color lower-cased, got %+v", nl)
+	}
+	cases := []struct {
+		newlabel *NewLabel
+		expected []string
+	}{
+		{&NewLabel{Color: "#1e90ff"}, []string{"name:" + CodeRequired}},
+		{&NewLabel{Name: strings.Repeat("a", maxLabelNameLength+1), Color: "#1e90ff"}, []string{"name:" + CodeTooLong}},
+		{&NewLabel{Name: "school"}, []string{"color:" + CodeRequired}},
+		{&NewLabel{Name: "school", Color: "blue"}, []string{"color:" + CodeInvalid}},
+		{&NewLabel{Name: "school", Color: "#1e90f"}, []string{"color:" + CodeInvalid}},
+		{&NewLabel{Name: "school", Color: "#1e90fg"}, []string{"color:" + CodeInvalid}},
+		{&NewLabel{Name: "school", Color: "1e90ff"}, []string{"color:" + CodeInvalid}},
+		{&NewLabel{Name: " ", Color: "#fff"}, []string{"name:" + CodeRequired, "color:" + CodeInvalid}},
+	}
+	for _, c := range cases {
+		if codes := fieldCodes(t, c.newlabel.Validate()); !reflect.DeepEqual(codes, c.expected) {
+			t.Errorf("%+v: expected %v but got %v", c.newlabel, c.expected, codes)
+		}
+	}
+
+	if codes := fieldCodes(t, (&LabelUpdates{}).Validate()); !reflect.DeepEqual(codes, []string{":" + CodeNoUpdates}) {
+		t.Errorf("expected no updates but got %v", codes)
+	}
+	color := "red"
+	if codes := fieldCodes(t, (&LabelUpdates{Color: &color}).Validate()); !reflect.DeepEqual(codes, []string{"color:" + CodeInvalid}) {
+		t.Errorf("expected an invalid color but got %v", codes)
+	}
+}
+
+func TestTaskUpdatesLabels(t *testing.T) {
+	school, errand := bson.NewObjectId().Hex(), bson.NewObjectId().Hex()
+	labels := []string{school + " ", school, errand}
+	tu := &TaskUpdates{Labels: &labels}
+	if err := tu.Validate(); err != nil {
+		t.Fatalf("unexpected error validating updates: %v", err)
+	}
+	task := &Task{Title: "Study"}
+	if !tu.Changes(task) {
+		t.Errorf("expected new labels to change the task")
+	}
+	tu.Apply(task)
+	if expected := []string{school, errand}; !reflect.DeepEqual(task.Labels, expected) {
+		t.Errorf("expected labels %v but got %v", expected, task.Labels)
+	}
+	if tu.Changes(task) {
+		t.Errorf("expected the same labels not to change the task")
+	}
+
+	invalid := []string{"school"}
+	if codes := fieldCodes(t, (&TaskUpdates{Labels: &invalid}).Validate()); !reflect.DeepEqual(codes, []string{"labels[0]:" + CodeInvalid}) {
+		t.Errorf("expected an invalid label ID but got %v", codes)
+	}
+	tooMany := []string{}
+	for i := 0; i <= maxLabels; i++ {
+		tooMany = append(tooMany, bson.NewObjectId().Hex())
+	}
+	if codes := fieldCodes(t, (&TaskUpdates{Labels: &tooMany}).Validate()); !reflect.DeepEqual(codes, []string{"labels:" + CodeTooMany}) {
+		t.Errorf("expected too many labels but got %v", codes)
+	}
+}
+
+func TestMemStoreLabels(t *testing.T) {
+	store := NewMemStore()
+	school, err := store.InsertLabel(&NewLabel{Name: "school", Color: "#1e90ff", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("error inserting label: %v", err)
+	}
+	store.InsertLabel(&NewLabel{Name: "errand", Color: "#ff0000", Owner: "alice"})
+
+	//names are unique per owner
+	if _, err := store.InsertLabel(&NewLabel{Name: "school", Color: "#000000", Owner: "alice"}); err != ErrDuplicateLabelName {
+		t.Errorf("expected ErrDuplicateLabelName but got %v", err)
+	}
+	if _, err := store.InsertLabel(&NewLabel{Name: "school", Color: "#000000", Owner: "bob"}); err != nil {
+		t.Errorf("expected another owner to be able to use the name, got %v", err)
+	}
+
+	labels, err := store.FindLabels("alice")
+	if err != nil || len(labels) != 2 || labels[0].Name != "errand" || labels[1].Name != "school" {
+		t.Fatalf("expected alice's labels sorted by name, got %+v, %v", labels, err)
+	}
+
+	name, color := "errand", "#00ff00"
+	if _, err := store.UpdateLabel(school.ID, &LabelUpdates{Name: &name}); err != ErrDuplicateLabelName {
+		t.Errorf("expected ErrDuplicateLabelName renaming to another label's name, got %v", err)
+	}
+	//keeping its own name is fine
+	name = "school"
+	updated, err := store.UpdateLabel(school.ID, &LabelUpdates{Name: &name, Color: &color})
+	if err != nil || updated.Color != color || updated.Name != name {
+		t.Errorf("expected the color to change, got %+v, %v", updated, err)
+	}
+	if got, _ := store.GetLabel(school.ID); got.Color != color {
+		t.Errorf("expected the stored color to change, got %s", got.Color)
+	}
+	if _, err := store.GetLabel(bson.NewObjectId()); err != ErrLabelNotFound {
+		t.Errorf("expected ErrLabelNotFound but got %v", err)
+	}
+}
+
+func TestMemStoreDeleteLabel(t *testing.T) {
+	store := NewMemStore()
+	label, _ := store.InsertLabel(&NewLabel{Name: "school", Color: "#1e90ff"})
+	ref := LabelRef(label.ID)
+	task, _ := store.Insert(&NewTask{Title: "Study"})
+	labels := []string{ref}
+	store.Update(task.ID, &TaskUpdates{Labels: &labels})
+	other, _ := store.Insert(&NewTask{Title: "Buy milk"})
+
+	if found, _, _ := store.Find(&Query{Label: ref}); len(found) != 1 || found[0].ID != task.ID {
+		t.Errorf("expected only the labeled task to match, got %+v", found)
+	}
+
+	if err := store.DeleteLabel(label.ID, false); err != ErrLabelInUse {
+		t.Fatalf("expected ErrLabelInUse but got %v", err)
+	}
+	if _, err := store.GetLabel(label.ID); err != nil {
+		t.Errorf("expected the label to still be there, got %v", err)
+	}
+
+	if err := store.DeleteLabel(label.ID, true); err != nil {
+		t.Fatalf("error deleting label: %v", err)
+	}
+	if _, err := store.GetLabel(label.ID); err != ErrLabelNotFound {
+		t.Errorf("expected ErrLabelNotFound after deleting but got %v", err)
+	}
+	if got, _ := store.Get(task.ID); len(got.Labels) != 0 || got.Version != 3 {
+		t.Errorf("expected the label to be pulled from the task, bumping its version, got %+v", got)
+	}
+	if got, _ := store.Get(other.ID); got.Version != 1 {
+		t.Errorf("expected the other task to be left alone, got version %d", got.Version)
+	}
+	if err := store.DeleteLabel(label.ID, true); err != ErrLabelNotFound {
+		t.Errorf("expected ErrLabelNotFound deleting it again but got %v", err)
+	}
+
+	//unused labels can be deleted without cascading
+	unused, _ := store.InsertLabel(&NewLabel{Name: "unused", Color: "#1e90ff"})
+	if err := store.DeleteLabel(unused.ID, false); err != nil {
+		t.Errorf("error deleting unused label: %v", err)
+	}
+}
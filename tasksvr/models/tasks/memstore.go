@@ -0,0 +1,555 @@
+package tasks
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//MemStore is a Store that keeps tasks in memory,
+//for testing handlers without a Mongo server
+type MemStore struct {
+	mx    sync.RWMutex
+	tasks []*Task
+	//files holds the bytes of the attachments,
+	//by their IDs, as GridFS does for the MongoStore
+	files map[string][]byte
+	//labels are kept apart from the tasks, as they
+	//are in their own collection in the MongoStore
+	labels []*Label
+}
+
+//NewMemStore creates a new empty MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+//copyTask returns a copy of `t`, so that callers
+//can't change the tasks held by the store
+func copyTask(t *Task) *Task {
+	c := *t
+	c.Tags = append([]string(nil), t.Tags...)
+	c.Labels = append([]string(nil), t.Labels...)
+	if t.DueDate != nil {
+		due := *t.DueDate
+		c.DueDate = &due
+	}
+	if t.CompletedAt != nil {
+		completedAt := *t.CompletedAt
+		c.CompletedAt = &completedAt
+	}
+	if t.ArchivedAt != nil {
+		archivedAt := *t.ArchivedAt
+		c.ArchivedAt = &archivedAt
+	}
+	if t.RemindedAt != nil {
+		remindedAt := *t.RemindedAt
+		c.RemindedAt = &remindedAt
+	}
+	if t.Idempotency != nil {
+		idem := *t.Idempotency
+		c.Idempotency = &idem
+	}
+	if t.Subtasks != nil {
+		c.Subtasks = make([]*Subtask, 0, len(t.Subtasks))
+		for _, st := range t.Subtasks {
+			stc := *st
+			c.Subtasks = append(c.Subtasks, &stc)
+		}
+	}
+	if t.Attachments != nil {
+		c.Attachments = make([]*Attachment, 0, len(t.Attachments))
+		for _, att := range t.Attachments {
+			attc := *att
+			c.Attachments = append(c.Attachments, &attc)
+		}
+	}
+	return &c
+}
+
+func (ms *MemStore) Insert(newtask *NewTask) (*Task, error) {
+	t := newtask.ToTask()
+	t.ID = bson.NewObjectId()
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	//checking and inserting under the same lock is
+	//what Mongo's unique index does for the MongoStore
+	if ms.findClientKey(t.Idempotency) != nil {
+		return nil, ErrDuplicateClientKey
+	}
+	ms.tasks = append(ms.tasks, copyTask(t))
+	return t, nil
+}
+
+func (ms *MemStore) InsertMany(newtasks []*NewTask) ([]*Task, error) {
+	inserted := make([]*Task, 0, len(newtasks))
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, newtask := range newtasks {
+		t := newtask.ToTask()
+		t.ID = bson.NewObjectId()
+		inserted = append(inserted, t)
+	}
+	//insert none of them if any are duplicates, including of
+	//each other; Mongo keeps the ones before the duplicate,
+	//but the handlers don't allow client keys in batches
+	for i, t := range inserted {
+		if ms.findClientKey(t.Idempotency) != nil {
+			return nil, ErrDuplicateClientKey
+		}
+		for _, earlier := range inserted[:i] {
+			if sameClientKey(earlier.Idempotency, t.Idempotency) {
+				return nil, ErrDuplicateClientKey
+			}
+		}
+	}
+	for _, t := range inserted {
+		ms.tasks = append(ms.tasks, copyTask(t))
+	}
+	return inserted, nil
+}
+
+func (ms *MemStore) GetByClientKey(owner string, key string) (*Task, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	if t := ms.findClientKey(&Idempotency{Owner: owner, Key: key}); t != nil {
+		return copyTask(t), nil
+	}
+	return nil, ErrNotFound
+}
+
+//findClientKey returns the task with the same owner and
+//key as `idem`, or nil if there isn't one or `idem` is nil.
+//It must be called with the lock held.
+func (ms *MemStore) findClientKey(idem *Idempotency) *Task {
+	for _, t := range ms.tasks {
+		if sameClientKey(t.Idempotency, idem) {
+			return t
+		}
+	}
+	return nil
+}
+
+//sameClientKey reports whether both are set
+//and have the same owner and key
+func sameClientKey(a *Idempotency, b *Idempotency) bool {
+	return a != nil && b != nil && a.Owner == b.Owner && a.Key == b.Key
+}
+
+func (ms *MemStore) Get(ID interface{}) (*Task, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	for _, t := range ms.tasks {
+		if t.ID == ID {
+			return copyTask(t), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (ms *MemStore) GetAll() ([]*Task, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	tasks := make([]*Task, 0, len(ms.tasks))
+	for _, t := range ms.tasks {
+		tasks = append(tasks, copyTask(t))
+	}
+	return tasks, nil
+}
+
+func (ms *MemStore) Find(q *Query) ([]*Task, int, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	matched := []*Task{}
+	for _, t := range ms.tasks {
+		if q.matches(t) {
+			matched = append(matched, t)
+		}
+	}
+	q.sort(matched)
+	page := q.page(matched)
+	tasks := make([]*Task, 0, len(page))
+	for _, t := range page {
+		tasks = append(tasks, copyTask(t))
+	}
+	return tasks, len(matched), nil
+}
+
+func (ms *MemStore) Update(ID interface{}, updates *TaskUpdates) (*Task, error) {
+	return ms.update(ID, anyVersion, updates)
+}
+
+func (ms *MemStore) UpdateIfVersion(ID interface{}, version int, updates *TaskUpdates) (*Task, error) {
+	return ms.update(ID, version, updates)
+}
+
+//anyVersion tells update and delete not to check the version
+const anyVersion = -1
+
+//update applies the updates to the task with the given ID,
+//if its version matches `version`
+func (ms *MemStore) update(ID interface{}, version int, updates *TaskUpdates) (*Task, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, t := range ms.tasks {
+		if t.ID == ID {
+			if version != anyVersion && t.Version != version {
+				return nil, ErrVersionMismatch
+			}
+			updates.Apply(t)
+			return copyTask(t), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (ms *MemStore) Delete(ID interface{}) error {
+	return ms.delete(ID, anyVersion)
+}
+
+func (ms *MemStore) DeleteIfVersion(ID interface{}, version int) error {
+	return ms.delete(ID, version)
+}
+
+//delete deletes the task with the given ID,
+//if its version matches `version`
+func (ms *MemStore) delete(ID interface{}, version int) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for i, t := range ms.tasks {
+		if t.ID == ID {
+			if version != anyVersion && t.Version != version {
+				return ErrVersionMismatch
+			}
+			ms.deleteFiles(t)
+			ms.tasks = append(ms.tasks[:i], ms.tasks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (ms *MemStore) UpdateMany(q *Query, updates *TaskUpdates) (int, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	updated := 0
+	for _, t := range ms.tasks {
+		if q.matches(t) {
+			updates.Apply(t)
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (ms *MemStore) DeleteMany(q *Query) (int, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	kept := ms.tasks[:0]
+	for _, t := range ms.tasks {
+		if !q.matches(t) {
+			kept = append(kept, t)
+		} else {
+			ms.deleteFiles(t)
+		}
+	}
+	deleted := len(ms.tasks) - len(kept)
+	ms.tasks = kept
+	return deleted, nil
+}
+
+//changeTask calls `change` with the task with the given ID,
+//holding the lock, so that like Mongo's array operators, each
+//change to a task's subtasks or attachments happens all at once.
+//If `change` returns an error, the task isn't modified.
+func (ms *MemStore) changeTask(ID interface{}, change func(t *Task) error) (*Task, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, t := range ms.tasks {
+		if t.ID == ID {
+			if err := change(t); err != nil {
+				return nil, err
+			}
+			t.ModifiedAt = time.Now()
+			t.Version++
+			return copyTask(t), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+//subtaskIndex returns the index of the subtask with
+//ID `subID` in `t`, or -1 if there isn't one
+func subtaskIndex(t *Task, subID string) int {
+	for i, st := range t.Subtasks {
+		if st.ID == subID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ms *MemStore) AddSubtask(ID interface{}, newsub *NewSubtask) (*Task, error) {
+	return ms.changeTask(ID, func(t *Task) error {
+		if len(t.Subtasks) >= MaxSubtasks {
+			return ErrTooManySubtasks
+		}
+		t.Subtasks = append(t.Subtasks, newsub.ToSubtask())
+		return nil
+	})
+}
+
+func (ms *MemStore) UpdateSubtask(ID interface{}, subID string, updates *SubtaskUpdates) (*Task, error) {
+	return ms.changeTask(ID, func(t *Task) error {
+		i := subtaskIndex(t, subID)
+		if i < 0 {
+			return ErrSubtaskNotFound
+		}
+		updates.Apply(t.Subtasks[i])
+		return nil
+	})
+}
+
+func (ms *MemStore) DeleteSubtask(ID interface{}, subID string) (*Task, error) {
+	return ms.changeTask(ID, func(t *Task) error {
+		i := subtaskIndex(t, subID)
+		if i < 0 {
+			return ErrSubtaskNotFound
+		}
+		t.Subtasks = append(t.Subtasks[:i], t.Subtasks[i+1:]...)
+		return nil
+	})
+}
+
+//deleteFiles deletes the bytes of `t`'s attachments.
+//It must be called with the lock held.
+func (ms *MemStore) deleteFiles(t *Task) {
+	for _, att := range t.Attachments {
+		delete(ms.files, att.ID)
+	}
+}
+
+//attachmentIndex returns the index of the attachment
+//with ID `attID` in `t`, or -1 if there isn't one
+func attachmentIndex(t *Task, attID string) int {
+	for i, att := range t.Attachments {
+		if att.ID == attID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ms *MemStore) AddAttachment(ID interface{}, newatt *NewAttachment, content io.Reader) (*Task, error) {
+	//read it all before taking the lock, as
+	//it may be coming slowly from a client
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	return ms.changeTask(ID, func(t *Task) error {
+		if len(t.Attachments) >= MaxAttachments {
+			return ErrTooManyAttachments
+		}
+		att := newatt.ToAttachment(int64(len(data)))
+		if ms.files == nil {
+			ms.files = map[string][]byte{}
+		}
+		ms.files[att.ID] = data
+		t.Attachments = append(t.Attachments, att)
+		return nil
+	})
+}
+
+func (ms *MemStore) OpenAttachment(ID interface{}, attID string) (*Attachment, io.ReadCloser, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	for _, t := range ms.tasks {
+		if t.ID == ID {
+			i := attachmentIndex(t, attID)
+			if i < 0 {
+				return nil, nil, ErrAttachmentNotFound
+			}
+			att := *t.Attachments[i]
+			//the bytes are never changed,
+			//so they needn't be copied
+			return &att, ioutil.NopCloser(bytes.NewReader(ms.files[attID])), nil
+		}
+	}
+	return nil, nil, ErrNotFound
+}
+
+func (ms *MemStore) DeleteAttachment(ID interface{}, attID string) (*Task, error) {
+	return ms.changeTask(ID, func(t *Task) error {
+		i := attachmentIndex(t, attID)
+		if i < 0 {
+			return ErrAttachmentNotFound
+		}
+		delete(ms.files, attID)
+		t.Attachments = append(t.Attachments[:i], t.Attachments[i+1:]...)
+		return nil
+	})
+}
+
+//findLabelName returns the label owned by `owner` named
+//`name`, or nil if there isn't one. It must be called with
+//the lock held.
+func (ms *MemStore) findLabelName(owner string, name string) *Label {
+	for _, l := range ms.labels {
+		if l.Owner == owner && l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func (ms *MemStore) InsertLabel(newlabel *NewLabel) (*Label, error) {
+	l := newlabel.ToLabel()
+	l.ID = bson.NewObjectId()
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	//checking and inserting under the same lock is
+	//what Mongo's unique index does for the MongoStore
+	if ms.findLabelName(l.Owner, l.Name) != nil {
+		return nil, ErrDuplicateLabelName
+	}
+	lc := *l
+	ms.labels = append(ms.labels, &lc)
+	return l, nil
+}
+
+func (ms *MemStore) GetLabel(ID interface{}) (*Label, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	for _, l := range ms.labels {
+		if l.ID == ID {
+			lc := *l
+			return &lc, nil
+		}
+	}
+	return nil, ErrLabelNotFound
+}
+
+func (ms *MemStore) FindLabels(owner string) ([]*Label, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	labels := []*Label{}
+	for _, l := range ms.labels {
+		if l.Owner == owner {
+			lc := *l
+			labels = append(labels, &lc)
+		}
+	}
+	//they're in the order they were inserted,
+	//so ties stay in that order, like in Mongo
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels, nil
+}
+
+func (ms *MemStore) UpdateLabel(ID interface{}, updates *LabelUpdates) (*Label, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, l := range ms.labels {
+		if l.ID == ID {
+			if updates.Name != nil {
+				if other := ms.findLabelName(l.Owner, *updates.Name); other != nil && other != l {
+					return nil, ErrDuplicateLabelName
+				}
+			}
+			updates.Apply(l)
+			lc := *l
+			return &lc, nil
+		}
+	}
+	return nil, ErrLabelNotFound
+}
+
+func (ms *MemStore) DeleteLabel(ID interface{}, cascade bool) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for i, l := range ms.labels {
+		if l.ID != ID {
+			continue
+		}
+		//holding the lock, no task can get the label
+		//between checking the tasks and deleting it
+		ref := LabelRef(ID)
+		for _, t := range ms.tasks {
+			if hasLabel(t, ref) && !cascade {
+				return ErrLabelInUse
+			}
+		}
+		for _, t := range ms.tasks {
+			if hasLabel(t, ref) {
+				t.Labels = removeLabel(t.Labels, ref)
+				t.ModifiedAt = time.Now()
+				t.Version++
+			}
+		}
+		ms.labels = append(ms.labels[:i], ms.labels[i+1:]...)
+		return nil
+	}
+	return ErrLabelNotFound
+}
+
+//removeLabel returns `labels` without `label`
+func removeLabel(labels []string, label string) []string {
+	kept := []string{}
+	for _, l := range labels {
+		if l != label {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+func (ms *MemStore) Ping(timeout time.Duration) error {
+	//it's always there
+	return nil
+}
+
+func (ms *MemStore) MaxModified(owner *string) (time.Time, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	max := time.Time{}
+	for _, t := range ms.tasks {
+		if (owner == nil || t.Owner == *owner) && t.ModifiedAt.After(max) {
+			max = t.ModifiedAt
+		}
+	}
+	return max, nil
+}
+
+func (ms *MemStore) Stats(q *Query) (*Stats, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	dayAgo, weekAgo := statsWindows()
+	stats := &Stats{Tags: map[string]int{}}
+	for _, t := range ms.tasks {
+		if !q.matches(t) {
+			continue
+		}
+		stats.Total++
+		if t.Complete {
+			stats.Complete++
+		}
+		for _, tag := range t.Tags {
+			stats.Tags[tag]++
+		}
+		if !t.CreatedAt.Before(dayAgo) {
+			stats.CreatedLastDay++
+		}
+		if !t.CreatedAt.Before(weekAgo) {
+			stats.CreatedLastWeek++
+		}
+	}
+	stats.Incomplete = stats.Total - stats.Complete
+	return stats, nil
+}
@@ -0,0 +1,527 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemStore(t *testing.T) {
+	store := NewMemStore()
+	all, err := store.GetAll()
+	if err != nil || all == nil || len(all) != 0 {
+		t.Errorf("expected an empty slice but got %v, %v", all, err)
+	}
+
+	task, err := store.Insert(&NewTask{Title: "Learn Go", Tags: []string{"go"}})
+	if err != nil {
+		t.Fatalf("error inserting task: %v", err)
+	}
+	task2, err := store.Get(task.ID)
+	if err != nil {
+		t.Fatalf("error getting task: %v", err)
+	}
+	if task2.Title != task.Title {
+		t.Errorf("task title didn't match, expected %s but got %s", task.Title, task2.Title)
+	}
+
+	//changing a returned task doesn't change the stored one
+	task2.Tags[0] = "changed"
+	if task3, _ := store.Get(task.ID); task3.Tags[0] != "go" {
+		t.Errorf("expected the stored task to be unchanged, got %v", task3.Tags)
+	}
+
+	if _, err := store.Get("nope"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	complete := true
+	updated, err := store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	if err != nil {
+		t.Fatalf("error updating task: %v", err)
+	}
+	if !updated.Complete || updated.Title != task.Title {
+		t.Errorf("unexpected task after update: %+v", updated)
+	}
+	if _, err := store.Update("nope", &TaskUpdates{Complete: &complete}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	if err := store.Delete(task.ID); err != nil {
+		t.Errorf("error deleting task: %v", err)
+	}
+	if _, err := store.Get(task.ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after deleting but got %v", err)
+	}
+	if err := store.Delete(task.ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound deleting twice but got %v", err)
+	}
+}
+
+func TestMemStoreFind(t *testing.T) {
+	store := NewMemStore()
+	for _, title := range []string{"one", "two", "three", "four", "five"} {
+		if _, err := store.Insert(&NewTask{Title: title}); err != nil {
+			t.Fatalf("error inserting task: %v", err)
+		}
+	}
+
+	cases := []struct {
+		query  Query
+		titles []string
+	}{
+		{Query{Sort: "createdAt"}, []string{"one", "two", "three", "four", "five"}},
+		{Query{Sort: "createdAt", Limit: 2}, []string{"one", "two"}},
+		{Query{Sort: "createdAt", Skip: 2, Limit: 2}, []string{"three", "four"}},
+		{Query{Sort: "createdAt", Skip: 4, Limit: 2}, []string{"five"}},
+		{Query{Sort: "createdAt", Skip: 5, Limit: 2}, []string{}},
+		{Query{Sort: "createdAt", Skip: 10}, []string{}},
+		//newest first by default
+		{Query{Limit: 2}, []string{"five", "four"}},
+		{Query{Sort: "-title"}, []string{"two", "three", "one", "four", "five"}},
+	}
+	for _, c := range cases {
+		found, total, err := store.Find(&c.query)
+		if err != nil {
+			t.Fatalf("error finding tasks: %v", err)
+		}
+		if total != 5 {
+			t.Errorf("%+v: expected a total of 5 but got %d", c.query, total)
+		}
+		titles := []string{}
+		for _, task := range found {
+			titles = append(titles, task.Title)
+		}
+		if !reflect.DeepEqual(titles, c.titles) {
+			t.Errorf("%+v: expected %v but got %v", c.query, c.titles, titles)
+		}
+	}
+}
+
+func TestMemStoreFindComplete(t *testing.T) {
+	store := NewMemStore()
+	complete := true
+	for i, title := range []string{"one", "two", "three", "four"} {
+		task, err := store.Insert(&NewTask{Title: title})
+		if err != nil {
+			t.Fatalf("error inserting task: %v", err)
+		}
+		//complete the even ones
+		if i%2 == 0 {
+			store.Update(task.ID, &TaskUpdates{Complete: &complete})
+		}
+	}
+
+	found, total, err := store.Find(&Query{Complete: &complete, Sort: "createdAt", Limit: 1, Skip: 1})
+	if err != nil {
+		t.Fatalf("error finding tasks: %v", err)
+	}
+	if total != 2 || len(found) != 1 || found[0].Title != "three" {
+		t.Errorf("expected the second complete task of 2, got %d of %d", len(found), total)
+	}
+}
+
+func TestMemStoreFindSearch(t *testing.T) {
+	store := NewMemStore()
+	for _, title := range []string{"Buy groceries", "Learn C++", "GROCERIES again", "Walk dog"} {
+		if _, err := store.Insert(&NewTask{Title: title}); err != nil {
+			t.Fatalf("error inserting task: %v", err)
+		}
+	}
+
+	cases := []struct {
+		search string
+		titles []string
+	}{
+		{"groceries", []string{"Buy groceries", "GROCERIES again"}},
+		{"c++", []string{"Learn C++"}},
+		{".*", []string{}},
+		{"cat", []string{}},
+	}
+	for _, c := range cases {
+		found, total, err := store.Find(&Query{Search: c.search, Sort: "createdAt"})
+		if err != nil {
+			t.Fatalf("error finding tasks: %v", err)
+		}
+		titles := []string{}
+		for _, task := range found {
+			titles = append(titles, task.Title)
+		}
+		if total != len(c.titles) || !reflect.DeepEqual(titles, c.titles) {
+			t.Errorf("%q: expected %v but got %v of %d", c.search, c.titles, titles, total)
+		}
+	}
+}
+
+func TestMemStoreFindTag(t *testing.T) {
+	store := NewMemStore()
+	store.Insert(&NewTask{Title: "Buy milk", Tags: []string{"errand", "shopping"}})
+	store.Insert(&NewTask{Title: "Homework", Tags: []string{"school"}})
+	store.Insert(&NewTask{Title: "Post office", Tags: []string{"errand"}})
+
+	found, total, err := store.Find(&Query{Tag: "errand", Sort: "createdAt"})
+	if err != nil {
+		t.Fatalf("error finding tasks: %v", err)
+	}
+	if total != 2 || len(found) != 2 || found[0].Title != "Buy milk" || found[1].Title != "Post office" {
+		t.Errorf("expected the two errands, got %d of %d", len(found), total)
+	}
+	if _, total, _ := store.Find(&Query{Tag: "err"}); total != 0 {
+		t.Errorf("expected tags to match exactly, got %d tasks", total)
+	}
+}
+
+func TestMemStoreFindDue(t *testing.T) {
+	frozen := time.Date(2017, 4, 20, 12, 0, 0, 0, time.UTC)
+	defer freezeNow(frozen)()
+
+	store := NewMemStore()
+	insert := func(title string, due *time.Time) *Task {
+		task, err := store.Insert(&NewTask{Title: title, DueDate: due})
+		if err != nil {
+			t.Fatalf("error inserting task: %v", err)
+		}
+		return task
+	}
+	yesterday := frozen.AddDate(0, 0, -1)
+	tomorrow := frozen.AddDate(0, 0, 1)
+	nextWeek := frozen.AddDate(0, 0, 7)
+	insert("late", &yesterday)
+	done := insert("late but done", &yesterday)
+	insert("soon", &tomorrow)
+	insert("later", &nextWeek)
+	insert("whenever", nil)
+	complete := true
+	store.Update(done.ID, &TaskUpdates{Complete: &complete})
+
+	cases := []struct {
+		name   string
+		query  Query
+		titles []string
+	}{
+		{"overdue", Query{Overdue: true}, []string{"late"}},
+		{"due before", Query{DueBefore: &nextWeek}, []string{"late", "late but done", "soon"}},
+		{"overdue and due before", Query{Overdue: true, DueBefore: &nextWeek}, []string{"late"}},
+		{"overdue and complete", Query{Overdue: true, Complete: &complete}, []string{}},
+		{"due soon", Query{DueAfter: &frozen, DueBefore: &nextWeek}, []string{"soon"}},
+		{"due after", Query{DueAfter: &tomorrow}, []string{"soon", "later"}},
+	}
+	for _, c := range cases {
+		c.query.Sort = "createdAt"
+		found, _, err := store.Find(&c.query)
+		if err != nil {
+			t.Fatalf("error finding tasks: %v", err)
+		}
+		titles := []string{}
+		for _, task := range found {
+			titles = append(titles, task.Title)
+		}
+		if !reflect.DeepEqual(titles, c.titles) {
+			t.Errorf("%s: expected %v but got %v", c.name, c.titles, titles)
+		}
+	}
+}
+
+func TestMemStoreInsertMany(t *testing.T) {
+	store := NewMemStore()
+	inserted, err := store.InsertMany([]*NewTask{{Title: "one"}, {Title: "two"}})
+	if err != nil {
+		t.Fatalf("error inserting tasks: %v", err)
+	}
+	if len(inserted) != 2 || inserted[0].Title != "one" || inserted[1].Title != "two" || inserted[0].ID == inserted[1].ID {
+		t.Errorf("unexpected tasks inserted: %+v", inserted)
+	}
+	for _, task := range inserted {
+		if _, err := store.Get(task.ID); err != nil {
+			t.Errorf("error getting inserted task %q: %v", task.Title, err)
+		}
+	}
+}
+
+func TestMemStoreDeleteMany(t *testing.T) {
+	store := NewMemStore()
+	complete := true
+	for i, title := range []string{"one", "two", "three"} {
+		task, _ := store.Insert(&NewTask{Title: title})
+		if i > 0 {
+			store.Update(task.ID, &TaskUpdates{Complete: &complete})
+		}
+	}
+	deleted, err := store.DeleteMany(&Query{Complete: &complete})
+	if err != nil || deleted != 2 {
+		t.Errorf("expected 2 tasks to be deleted, got %d, %v", deleted, err)
+	}
+	remaining, total, _ := store.Find(&Query{})
+	if total != 1 || remaining[0].Title != "one" {
+		t.Errorf("expected only the incomplete task to remain, got %d tasks", total)
+	}
+}
+
+func TestMemStoreVersions(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Learn Go"})
+	if task.Version != 1 {
+		t.Errorf("expected a new task to be version 1, got %d", task.Version)
+	}
+
+	title := "Learn Go well"
+	updated, err := store.UpdateIfVersion(task.ID, 1, &TaskUpdates{Title: &title})
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("expected version 2 after updating, got %v, %v", updated, err)
+	}
+	title = "Learn Go fast"
+	if _, err := store.UpdateIfVersion(task.ID, 1, &TaskUpdates{Title: &title}); err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch updating a stale version, got %v", err)
+	}
+	if err := store.DeleteIfVersion(task.ID, 1); err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch deleting a stale version, got %v", err)
+	}
+	if _, err := store.UpdateIfVersion("nope", 1, &TaskUpdates{Title: &title}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+	if stored, _ := store.Get(task.ID); stored.Title != "Learn Go well" {
+		t.Errorf("expected the stale update to be ignored, got %q", stored.Title)
+	}
+	if err := store.DeleteIfVersion(task.ID, 2); err != nil {
+		t.Errorf("error deleting the current version: %v", err)
+	}
+}
+
+func TestMemStoreStats(t *testing.T) {
+	frozen := time.Date(2017, 4, 20, 12, 0, 0, 0, time.UTC)
+	defer freezeNow(frozen)()
+
+	store := NewMemStore()
+	complete := true
+	seeds := []struct {
+		title    string
+		tags     []string
+		created  time.Time
+		complete bool
+	}{
+		{"today", []string{"go", "school"}, frozen.Add(-time.Hour), true},
+		{"yesterday", []string{"go"}, frozen.Add(-30 * time.Hour), false},
+		{"last week", []string{"errand"}, frozen.AddDate(0, 0, -6), true},
+		{"last month", nil, frozen.AddDate(0, -1, 0), false},
+	}
+	for _, seed := range seeds {
+		task, _ := store.Insert(&NewTask{Title: seed.title, Tags: seed.tags})
+		if seed.complete {
+			store.Update(task.ID, &TaskUpdates{Complete: &complete})
+		}
+		store.tasks[len(store.tasks)-1].CreatedAt = seed.created
+	}
+
+	stats, err := store.Stats(&Query{})
+	if err != nil {
+		t.Fatalf("error getting stats: %v", err)
+	}
+	expected := &Stats{
+		Total:           4,
+		Complete:        2,
+		Incomplete:      2,
+		Tags:            map[string]int{"go": 2, "school": 1, "errand": 1},
+		CreatedLastDay:  1,
+		CreatedLastWeek: 3,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v but got %+v", expected, stats)
+	}
+
+	//the query's conditions apply
+	stats, _ = store.Stats(&Query{Tag: "go"})
+	expected = &Stats{
+		Total:           2,
+		Complete:        1,
+		Incomplete:      1,
+		Tags:            map[string]int{"go": 2, "school": 1},
+		CreatedLastDay:  1,
+		CreatedLastWeek: 2,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v but got %+v", expected, stats)
+	}
+
+	//no tasks still has an empty map of tags
+	stats, _ = NewMemStore().Stats(&Query{})
+	if stats.Total != 0 || stats.Tags == nil {
+		t.Errorf("unexpected stats for an empty store: %+v", stats)
+	}
+}
+
+func TestMemStoreArchive(t *testing.T) {
+	store := NewMemStore()
+	keep, _ := store.Insert(&NewTask{Title: "keep"})
+	archive, _ := store.Insert(&NewTask{Title: "archive"})
+
+	archivedAt := time.Now()
+	archived, err := store.Update(archive.ID, &TaskUpdates{ArchivedAt: &archivedAt})
+	if err != nil || archived.ArchivedAt == nil || archived.Version != 2 {
+		t.Fatalf("unexpected task after archiving: %+v, %v", archived, err)
+	}
+
+	//archived tasks are left out unless asked for
+	found, total, _ := store.Find(&Query{})
+	if total != 1 || found[0].ID != keep.ID {
+		t.Errorf("expected only the task that isn't archived, got %d tasks", total)
+	}
+	found, total, _ = store.Find(&Query{Archived: true})
+	if total != 1 || found[0].ID != archive.ID {
+		t.Errorf("expected only the archived task, got %d tasks", total)
+	}
+	if stats, _ := store.Stats(&Query{}); stats.Total != 1 {
+		t.Errorf("expected archived tasks to be left out of the stats, got %+v", stats)
+	}
+
+	restored, err := store.Update(archive.ID, &TaskUpdates{Unarchive: true})
+	if err != nil || restored.ArchivedAt != nil {
+		t.Fatalf("unexpected task after restoring: %+v, %v", restored, err)
+	}
+	if _, total, _ := store.Find(&Query{}); total != 2 {
+		t.Errorf("expected 2 tasks after restoring, got %d", total)
+	}
+}
+
+func TestMemStoreUpdateMany(t *testing.T) {
+	store := NewMemStore()
+	for _, title := range []string{"one", "two", "three"} {
+		store.Insert(&NewTask{Title: title})
+	}
+	complete := true
+	updated, err := store.UpdateMany(&Query{Search: "t"}, &TaskUpdates{Complete: &complete})
+	if err != nil || updated != 2 {
+		t.Errorf("expected 2 tasks to be updated, got %d, %v", updated, err)
+	}
+	if _, total, _ := store.Find(&Query{Complete: &complete}); total != 2 {
+		t.Errorf("expected 2 complete tasks, got %d", total)
+	}
+}
+
+func TestMemStoreSubtasks(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Plan trip"})
+
+	task, err := store.AddSubtask(task.ID, &NewSubtask{Title: "Book flights"})
+	if err != nil {
+		t.Fatalf("error adding subtask: %v", err)
+	}
+	task, err = store.AddSubtask(task.ID, &NewSubtask{Title: "Book hotel"})
+	if err != nil {
+		t.Fatalf("error adding subtask: %v", err)
+	}
+	if len(task.Subtasks) != 2 || task.Subtasks[1].Title != "Book hotel" || task.Version != 3 {
+		t.Fatalf("expected two subtasks at version 3, got %+v", task)
+	}
+	flights, hotel := task.Subtasks[0].ID, task.Subtasks[1].ID
+	if len(flights) == 0 || flights == hotel {
+		t.Errorf("expected distinct subtask IDs but got %q and %q", flights, hotel)
+	}
+
+	//only the one subtask changes, and only the fields set
+	complete := true
+	task, err = store.UpdateSubtask(task.ID, hotel, &SubtaskUpdates{Complete: &complete})
+	if err != nil {
+		t.Fatalf("error updating subtask: %v", err)
+	}
+	expected := []Subtask{{flights, "Book flights", false}, {hotel, "Book hotel", true}}
+	if got := []Subtask{*task.Subtasks[0], *task.Subtasks[1]}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected subtasks %+v but got %+v", expected, got)
+	}
+
+	task, err = store.DeleteSubtask(task.ID, flights)
+	if err != nil {
+		t.Fatalf("error deleting subtask: %v", err)
+	}
+	if len(task.Subtasks) != 1 || task.Subtasks[0].ID != hotel || task.Version != 5 {
+		t.Errorf("expected only the hotel at version 5, got %+v", task)
+	}
+	if !task.AllSubtasksComplete() {
+		t.Errorf("expected all the subtasks to be complete")
+	}
+
+	if _, err := store.UpdateSubtask(task.ID, flights, &SubtaskUpdates{Complete: &complete}); err != ErrSubtaskNotFound {
+		t.Errorf("expected ErrSubtaskNotFound updating a deleted subtask but got %v", err)
+	}
+	if _, err := store.DeleteSubtask(task.ID, flights); err != ErrSubtaskNotFound {
+		t.Errorf("expected ErrSubtaskNotFound deleting a deleted subtask but got %v", err)
+	}
+	if _, err := store.AddSubtask("nope", &NewSubtask{Title: "x"}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a missing task but got %v", err)
+	}
+
+	//a failed change doesn't bump the version
+	for i := len(task.Subtasks); i < MaxSubtasks; i++ {
+		task, _ = store.AddSubtask(task.ID, &NewSubtask{Title: "Pack"})
+	}
+	if _, err := store.AddSubtask(task.ID, &NewSubtask{Title: "One too many"}); err != ErrTooManySubtasks {
+		t.Errorf("expected ErrTooManySubtasks but got %v", err)
+	}
+	if got, _ := store.Get(task.ID); got.Version != task.Version || len(got.Subtasks) != MaxSubtasks {
+		t.Errorf("expected version %d with %d subtasks, got %d with %d", task.Version, MaxSubtasks, got.Version, len(got.Subtasks))
+	}
+}
+
+func TestMemStoreSubtasksConcurrent(t *testing.T) {
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Plan trip"})
+	for i := 0; i < 20; i++ {
+		task, _ = store.AddSubtask(task.ID, &NewSubtask{Title: "Pack"})
+	}
+
+	//completing them all at once mustn't lose any of the changes
+	complete := true
+	done := make(chan error)
+	for _, st := range task.Subtasks {
+		go func(subID string) {
+			_, err := store.UpdateSubtask(task.ID, subID, &SubtaskUpdates{Complete: &complete})
+			done <- err
+		}(st.ID)
+	}
+	for range task.Subtasks {
+		if err := <-done; err != nil {
+			t.Errorf("error updating subtask: %v", err)
+		}
+	}
+	got, _ := store.Get(task.ID)
+	if !got.AllSubtasksComplete() || got.Version != task.Version+len(task.Subtasks) {
+		t.Errorf("expected every subtask complete at version %d, got %+v", task.Version+len(task.Subtasks), got)
+	}
+}
+
+func TestMemStoreMaxModified(t *testing.T) {
+	store := NewMemStore()
+	alice, bob := "alice", "bob"
+	if max, err := store.MaxModified(nil); err != nil || !max.IsZero() {
+		t.Errorf("expected the zero time for an empty store, got %v, %v", max, err)
+	}
+
+	task, _ := store.Insert(&NewTask{Title: "Learn Go", Owner: alice})
+	created, _ := store.MaxModified(&alice)
+	if !created.Equal(task.ModifiedAt) {
+		t.Errorf("expected %v after inserting but got %v", task.ModifiedAt, created)
+	}
+
+	complete := true
+	store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	updated, _ := store.MaxModified(&alice)
+	if !updated.After(created) {
+		t.Errorf("expected updating to advance it past %v, got %v", created, updated)
+	}
+
+	//archived tasks still count, so deleting is a change
+	archivedAt := time.Now()
+	store.UpdateMany(&Query{Owner: &alice}, &TaskUpdates{ArchivedAt: &archivedAt})
+	archived, _ := store.MaxModified(&alice)
+	if !archived.After(updated) {
+		t.Errorf("expected archiving to advance it past %v, got %v", updated, archived)
+	}
+
+	//other owners' changes don't
+	store.Insert(&NewTask{Title: "Learn Go", Owner: bob})
+	if max, _ := store.MaxModified(&alice); !max.Equal(archived) {
+		t.Errorf("expected bob's task not to change alice's, got %v", max)
+	}
+	if max, _ := store.MaxModified(nil); !max.After(archived) {
+		t.Errorf("expected bob's task to count for everyone, got %v", max)
+	}
+}
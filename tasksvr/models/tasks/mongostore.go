@@ -1,25 +1,657 @@
 package tasks
 
 import (
+	"fmt"
+	"io"
+	"time"
+
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
+//MongoStore is a Store that keeps tasks in a Mongo collection,
+//and the bytes of their attachments in GridFS, in the files and
+//chunks collections whose names start with the collection's name.
+//Labels are kept in the LabelsCollectionName collection, which
+//NewMongoStore names after the tasks' collection too.
 type MongoStore struct {
-	Session        *mgo.Session
-	DatabaseName   string
-	CollectionName string
+	Session              *mgo.Session
+	DatabaseName         string
+	CollectionName       string
+	LabelsCollectionName string
+}
+
+//clientKeyIndex makes each owner's ClientKeys unique. It's
+//sparse, so tasks created without a key aren't in it at all.
+var clientKeyIndex = mgo.Index{
+	Key:    []string{"idempotency.owner", "idempotency.key"},
+	Unique: true,
+	Sparse: true,
+}
+
+//labelsIndex makes finding the tasks with a label quick,
+//both for ?label= and for checking if one is in use
+var labelsIndex = mgo.Index{
+	Key: []string{"labels"},
+}
+
+//titleIndex makes finding an owner's tasks with
+//the same title quick, for Query.Title
+var titleIndex = mgo.Index{
+	Key: []string{"owner", "normalizedtitle"},
+}
+
+//labelNameIndex makes each owner's label names unique
+var labelNameIndex = mgo.Index{
+	Key:    []string{"owner", "name"},
+	Unique: true,
+}
+
+//NewMongoStore creates a MongoStore for the tasks in the
+//given database and collection, and their labels in the
+//collection of the same name followed by ".labels", making
+//sure the collections have the indexes the store relies on
+func NewMongoStore(sess *mgo.Session, databaseName string, collectionName string) (*MongoStore, error) {
+	ms := &MongoStore{
+		Session:              sess,
+		DatabaseName:         databaseName,
+		CollectionName:       collectionName,
+		LabelsCollectionName: collectionName + ".labels",
+	}
+	if err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).EnsureIndex(clientKeyIndex); err != nil {
+		return nil, fmt.Errorf("error ensuring client key index: %v", err)
+	}
+	if err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).EnsureIndex(labelsIndex); err != nil {
+		return nil, fmt.Errorf("error ensuring labels index: %v", err)
+	}
+	if err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).EnsureIndex(titleIndex); err != nil {
+		return nil, fmt.Errorf("error ensuring title index: %v", err)
+	}
+	if err := ms.labelsC().EnsureIndex(labelNameIndex); err != nil {
+		return nil, fmt.Errorf("error ensuring label name index: %v", err)
+	}
+	return ms, nil
+}
+
+//labelsC returns the collection holding the labels
+func (ms *MongoStore) labelsC() *mgo.Collection {
+	return ms.Session.DB(ms.DatabaseName).C(ms.LabelsCollectionName)
 }
 
 func (ms *MongoStore) Insert(newtask *NewTask) (*Task, error) {
 	t := newtask.ToTask()
 	t.ID = bson.NewObjectId()
 	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Insert(t)
+	//the IDs are new, so only the client key can be a duplicate
+	if mgo.IsDup(err) {
+		return nil, ErrDuplicateClientKey
+	}
 	return t, err
 }
 
+func (ms *MongoStore) InsertMany(newtasks []*NewTask) ([]*Task, error) {
+	inserted := make([]*Task, 0, len(newtasks))
+	docs := make([]interface{}, 0, len(newtasks))
+	for _, newtask := range newtasks {
+		t := newtask.ToTask()
+		t.ID = bson.NewObjectId()
+		inserted = append(inserted, t)
+		docs = append(docs, t)
+	}
+	//insert them all in one round trip
+	bulk := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Bulk()
+	bulk.Insert(docs...)
+	if _, err := bulk.Run(); err != nil {
+		if mgo.IsDup(err) {
+			return nil, ErrDuplicateClientKey
+		}
+		return nil, err
+	}
+	return inserted, nil
+}
+
+func (ms *MongoStore) GetByClientKey(owner string, key string) (*Task, error) {
+	task := &Task{}
+	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(bson.M{"idempotency.owner": owner, "idempotency.key": key}).One(task)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return task, err
+}
+
 func (ms *MongoStore) Get(ID interface{}) (*Task, error) {
 	task := &Task{}
 	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).FindId(ID).One(task)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return task, err
+}
+
+func (ms *MongoStore) GetAll() ([]*Task, error) {
+	tasks := []*Task{}
+	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(nil).Sort("_id").All(&tasks)
+	return tasks, err
+}
+
+func (ms *MongoStore) Find(q *Query) ([]*Task, int, error) {
+	query := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(q.filter())
+	total, err := query.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+	tasks := []*Task{}
+	err = query.Sort(q.sortSpec()...).Skip(q.Skip).Limit(q.Limit).All(&tasks)
+	return tasks, total, err
+}
+
+func (ms *MongoStore) Update(ID interface{}, updates *TaskUpdates) (*Task, error) {
+	return ms.update(bson.M{"_id": ID}, updates)
+}
+
+func (ms *MongoStore) UpdateIfVersion(ID interface{}, version int, updates *TaskUpdates) (*Task, error) {
+	//matching on the version too makes the compare and
+	//the update one atomic operation
+	task, err := ms.update(bson.M{"_id": ID, "version": version}, updates)
+	if err == ErrNotFound {
+		return nil, ms.mismatchOrNotFound(ID)
+	}
 	return task, err
 }
+
+//updateDoc returns the Mongo update document for `updates`
+func updateDoc(updates *TaskUpdates) bson.M {
+	//only $set the fields that were updated, so
+	//that the others keep their current values
+	set := bson.M{"modifiedat": time.Now()}
+	if updates.Title != nil {
+		set["title"] = *updates.Title
+		set["normalizedtitle"] = normalizeTitle(*updates.Title)
+	}
+	if updates.Complete != nil {
+		set["complete"] = *updates.Complete
+	}
+	if updates.Tags != nil {
+		set["tags"] = *updates.Tags
+	}
+	if updates.Labels != nil {
+		set["labels"] = *updates.Labels
+	}
+	if updates.DueDate != nil {
+		set["duedate"] = *updates.DueDate
+	}
+	if updates.Priority != nil {
+		set["priority"], _ = ParsePriority(*updates.Priority)
+	}
+	if updates.ArchivedAt != nil {
+		set["archivedat"] = *updates.ArchivedAt
+	}
+	if updates.RemindedAt != nil {
+		set["remindedat"] = *updates.RemindedAt
+	}
+	unset := bson.M{}
+	//$min only sets completedat if it's missing, as any
+	//earlier time is less than now, which records the
+	//time that the task became complete in the same
+	//atomic update, without reading it first
+	min := bson.M{}
+	if updates.Complete != nil {
+		if *updates.Complete {
+			min["completedat"] = set["modifiedat"]
+			unset["remindedat"] = ""
+		} else {
+			unset["completedat"] = ""
+		}
+	}
+	if updates.ClearDueDate {
+		unset["duedate"] = ""
+	}
+	if updates.Unarchive {
+		unset["archivedat"] = ""
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(min) > 0 {
+		update["$min"] = min
+	}
+	return update
+}
+
+//update applies the updates to the task matching `selector`
+func (ms *MongoStore) update(selector bson.M, updates *TaskUpdates) (*Task, error) {
+	return ms.apply(selector, updateDoc(updates))
+}
+
+//apply applies the Mongo update document to the task matching
+//`selector`, and returns the updated task, or ErrNotFound
+//if nothing matches
+func (ms *MongoStore) apply(selector bson.M, update bson.M) (*Task, error) {
+	change := mgo.Change{
+		Update:    update,
+		ReturnNew: true,
+	}
+	task := &Task{}
+	_, err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(selector).Apply(change, task)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return task, err
+}
+
+func (ms *MongoStore) Delete(ID interface{}) error {
+	err := ms.remove(bson.M{"_id": ID})
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (ms *MongoStore) DeleteIfVersion(ID interface{}, version int) error {
+	err := ms.remove(bson.M{"_id": ID, "version": version})
+	if err == mgo.ErrNotFound {
+		return ms.mismatchOrNotFound(ID)
+	}
+	return err
+}
+
+//remove removes the task matching `selector`, and then the
+//files of its attachments, returning mgo.ErrNotFound if
+//nothing matches. Removing it with findAndModify returns
+//the removed task, so its attachments are known exactly.
+func (ms *MongoStore) remove(selector bson.M) error {
+	removed := &Task{}
+	_, err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(selector).
+		Apply(mgo.Change{Remove: true}, removed)
+	if err != nil {
+		return err
+	}
+	return ms.removeFiles(removed.Attachments)
+}
+
+//gridFS returns the GridFS holding the bytes of the attachments
+func (ms *MongoStore) gridFS() *mgo.GridFS {
+	return ms.Session.DB(ms.DatabaseName).GridFS(ms.CollectionName)
+}
+
+//removeFiles removes the files of `attachments` from GridFS.
+//Files that are already gone are skipped.
+func (ms *MongoStore) removeFiles(attachments []*Attachment) error {
+	gfs := ms.gridFS()
+	for _, att := range attachments {
+		if !bson.IsObjectIdHex(att.ID) {
+			continue
+		}
+		if err := gfs.RemoveId(bson.ObjectIdHex(att.ID)); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+//mismatchOrNotFound returns the error for a version-checked
+//operation that matched nothing: ErrVersionMismatch if the
+//task exists, or ErrNotFound if it doesn't
+func (ms *MongoStore) mismatchOrNotFound(ID interface{}) error {
+	return ms.existsOrNotFound(ID, ErrVersionMismatch)
+}
+
+//existsOrNotFound returns `err` if the task with the given
+//ID exists, or ErrNotFound if it doesn't
+func (ms *MongoStore) existsOrNotFound(ID interface{}, err error) error {
+	n, cerr := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).FindId(ID).Count()
+	if cerr != nil {
+		return cerr
+	}
+	if n > 0 {
+		return err
+	}
+	return ErrNotFound
+}
+
+//The subtask and attachment methods change just the one item,
+//using Mongo's array operators, rather than reading the whole
+//task, changing it, and writing it back, which would lose
+//concurrent changes to the task's other items.
+
+//arrayDoc returns the Mongo update document that applies
+//`update`, which changes the subtasks or attachments, to a
+//task, bumping its version and modification time like
+//updateDoc does
+func arrayDoc(update bson.M, set bson.M) bson.M {
+	if set == nil {
+		set = bson.M{}
+	}
+	set["modifiedat"] = time.Now()
+	update["$set"] = set
+	update["$inc"] = bson.M{"version": 1}
+	return update
+}
+
+//addSubtaskSelector matches the task with the given ID,
+//if it has fewer than MaxSubtasks subtasks, which makes
+//checking the limit part of adding one
+func addSubtaskSelector(ID interface{}) bson.M {
+	return bson.M{
+		"_id": ID,
+		fmt.Sprintf("subtasks.%d", MaxSubtasks-1): bson.M{"$exists": false},
+	}
+}
+
+//updateSubtaskDoc returns the Mongo update document for
+//`updates`, which uses the positional $ operator to $set
+//just the fields of the subtask the selector matched
+func updateSubtaskDoc(updates *SubtaskUpdates) bson.M {
+	set := bson.M{}
+	if updates.Title != nil {
+		set["subtasks.$.title"] = *updates.Title
+	}
+	if updates.Complete != nil {
+		set["subtasks.$.complete"] = *updates.Complete
+	}
+	return arrayDoc(bson.M{}, set)
+}
+
+func (ms *MongoStore) AddSubtask(ID interface{}, newsub *NewSubtask) (*Task, error) {
+	update := arrayDoc(bson.M{"$push": bson.M{"subtasks": newsub.ToSubtask()}}, nil)
+	task, err := ms.apply(addSubtaskSelector(ID), update)
+	if err == ErrNotFound {
+		return nil, ms.existsOrNotFound(ID, ErrTooManySubtasks)
+	}
+	return task, err
+}
+
+func (ms *MongoStore) UpdateSubtask(ID interface{}, subID string, updates *SubtaskUpdates) (*Task, error) {
+	task, err := ms.apply(bson.M{"_id": ID, "subtasks.id": subID}, updateSubtaskDoc(updates))
+	if err == ErrNotFound {
+		return nil, ms.existsOrNotFound(ID, ErrSubtaskNotFound)
+	}
+	return task, err
+}
+
+func (ms *MongoStore) DeleteSubtask(ID interface{}, subID string) (*Task, error) {
+	update := arrayDoc(bson.M{"$pull": bson.M{"subtasks": bson.M{"id": subID}}}, nil)
+	task, err := ms.apply(bson.M{"_id": ID, "subtasks.id": subID}, update)
+	if err == ErrNotFound {
+		return nil, ms.existsOrNotFound(ID, ErrSubtaskNotFound)
+	}
+	return task, err
+}
+
+//addAttachmentSelector matches the task with the given
+//ID, if it has fewer than MaxAttachments attachments,
+//like addSubtaskSelector
+func addAttachmentSelector(ID interface{}) bson.M {
+	return bson.M{
+		"_id": ID,
+		fmt.Sprintf("attachments.%d", MaxAttachments-1): bson.M{"$exists": false},
+	}
+}
+
+func (ms *MongoStore) AddAttachment(ID interface{}, newatt *NewAttachment, content io.Reader) (*Task, error) {
+	//the bytes are stored first, so that the task never
+	//describes a file that isn't there; if they can't be
+	//attached after all, they're removed again
+	att := newatt.ToAttachment(0)
+	file, err := ms.gridFS().Create(att.Filename)
+	if err != nil {
+		return nil, err
+	}
+	file.SetId(bson.ObjectIdHex(att.ID))
+	file.SetContentType(att.ContentType)
+	if att.Size, err = io.Copy(file, content); err != nil {
+		file.Abort()
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	update := arrayDoc(bson.M{"$push": bson.M{"attachments": att}}, nil)
+	task, err := ms.apply(addAttachmentSelector(ID), update)
+	if err == ErrNotFound {
+		err = ms.existsOrNotFound(ID, ErrTooManyAttachments)
+	}
+	if err != nil {
+		ms.removeFiles([]*Attachment{att})
+		return nil, err
+	}
+	return task, nil
+}
+
+func (ms *MongoStore) OpenAttachment(ID interface{}, attID string) (*Attachment, io.ReadCloser, error) {
+	//select just the matching attachment
+	task := &Task{}
+	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).
+		Find(bson.M{"_id": ID, "attachments.id": attID}).
+		Select(bson.M{"attachments.$": 1}).One(task)
+	if err == mgo.ErrNotFound {
+		return nil, nil, ms.existsOrNotFound(ID, ErrAttachmentNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	att := task.Attachments[0]
+	file, err := ms.gridFS().OpenId(bson.ObjectIdHex(att.ID))
+	if err == mgo.ErrNotFound {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return att, file, nil
+}
+
+func (ms *MongoStore) DeleteAttachment(ID interface{}, attID string) (*Task, error) {
+	update := arrayDoc(bson.M{"$pull": bson.M{"attachments": bson.M{"id": attID}}}, nil)
+	task, err := ms.apply(bson.M{"_id": ID, "attachments.id": attID}, update)
+	if err == ErrNotFound {
+		return nil, ms.existsOrNotFound(ID, ErrAttachmentNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	//the task no longer refers to the file, so if removing
+	//it fails, it's left behind, but never served
+	if err := ms.removeFiles([]*Attachment{{ID: attID}}); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (ms *MongoStore) InsertLabel(newlabel *NewLabel) (*Label, error) {
+	l := newlabel.ToLabel()
+	l.ID = bson.NewObjectId()
+	err := ms.labelsC().Insert(l)
+	//the IDs are new, so only the name can be a duplicate
+	if mgo.IsDup(err) {
+		return nil, ErrDuplicateLabelName
+	}
+	return l, err
+}
+
+func (ms *MongoStore) GetLabel(ID interface{}) (*Label, error) {
+	label := &Label{}
+	err := ms.labelsC().FindId(ID).One(label)
+	if err == mgo.ErrNotFound {
+		return nil, ErrLabelNotFound
+	}
+	return label, err
+}
+
+func (ms *MongoStore) FindLabels(owner string) ([]*Label, error) {
+	labels := []*Label{}
+	err := ms.labelsC().Find(bson.M{"owner": owner}).Sort("name", "_id").All(&labels)
+	return labels, err
+}
+
+func (ms *MongoStore) UpdateLabel(ID interface{}, updates *LabelUpdates) (*Label, error) {
+	set := bson.M{"modifiedat": time.Now()}
+	if updates.Name != nil {
+		set["name"] = *updates.Name
+	}
+	if updates.Color != nil {
+		set["color"] = *updates.Color
+	}
+	change := mgo.Change{
+		Update:    bson.M{"$set": set},
+		ReturnNew: true,
+	}
+	label := &Label{}
+	_, err := ms.labelsC().FindId(ID).Apply(change, label)
+	switch {
+	case err == mgo.ErrNotFound:
+		return nil, ErrLabelNotFound
+	case mgo.IsDup(err):
+		return nil, ErrDuplicateLabelName
+	}
+	return label, err
+}
+
+func (ms *MongoStore) DeleteLabel(ID interface{}, cascade bool) error {
+	c := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName)
+	ref := LabelRef(ID)
+	if !cascade {
+		n, err := c.Find(bson.M{"labels": ref}).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrLabelInUse
+		}
+	}
+	//the label is removed first, so that tasks can't be
+	//given it while it's pulled from them. A task given it
+	//just before, or after the check above, is left with
+	//the ID of a label that isn't there, which the handlers
+	//treat like any other label that doesn't exist.
+	if err := ms.labelsC().RemoveId(ID); err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrLabelNotFound
+		}
+		return err
+	}
+	if cascade {
+		_, err := c.UpdateAll(bson.M{"labels": ref}, bson.M{
+			"$pull": bson.M{"labels": ref},
+			"$set":  bson.M{"modifiedat": time.Now()},
+			"$inc":  bson.M{"version": 1},
+		})
+		return err
+	}
+	return nil
+}
+
+func (ms *MongoStore) Ping(timeout time.Duration) error {
+	//use a copy of the session, with its own connection and
+	//timeouts, so that it can't wait behind other requests,
+	//or for as long as they're allowed to
+	sess := ms.Session.Copy()
+	defer sess.Close()
+	sess.SetSyncTimeout(timeout)
+	sess.SetSocketTimeout(timeout)
+	return sess.Ping()
+}
+
+func (ms *MongoStore) UpdateMany(q *Query, updates *TaskUpdates) (int, error) {
+	info, err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).UpdateAll(q.filter(), updateDoc(updates))
+	if err != nil {
+		return 0, err
+	}
+	return info.Updated, nil
+}
+
+func (ms *MongoStore) DeleteMany(q *Query) (int, error) {
+	//find the attachments first, as RemoveAll doesn't return
+	//the removed tasks; one added in between is left behind
+	c := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName)
+	hasFiles := q.filter()
+	hasFiles["attachments.0"] = bson.M{"$exists": true}
+	withFiles := []*Task{}
+	if err := c.Find(hasFiles).Select(bson.M{"attachments": 1}).All(&withFiles); err != nil {
+		return 0, err
+	}
+	info, err := c.RemoveAll(q.filter())
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range withFiles {
+		if err := ms.removeFiles(t.Attachments); err != nil {
+			return info.Removed, err
+		}
+	}
+	return info.Removed, nil
+}
+
+func (ms *MongoStore) MaxModified(owner *string) (time.Time, error) {
+	newest := struct {
+		ModifiedAt time.Time `bson:"modifiedat"`
+	}{}
+	err := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName).Find(ownerFilter(owner)).
+		Sort("-modifiedat").Select(bson.M{"modifiedat": 1}).One(&newest)
+	if err == mgo.ErrNotFound {
+		return time.Time{}, nil
+	}
+	return newest.ModifiedAt, err
+}
+
+func (ms *MongoStore) Stats(q *Query) (*Stats, error) {
+	c := ms.Session.DB(ms.DatabaseName).C(ms.CollectionName)
+	dayAgo, weekAgo := statsWindows()
+	//countIf sums 1 for each task where `cond` is true
+	countIf := func(cond interface{}) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": []interface{}{cond, 1, 0}}}
+	}
+
+	//let Mongo do the counting, so that the
+	//tasks never have to be sent to us
+	counts := struct {
+		Total    int `bson:"total"`
+		Complete int `bson:"complete"`
+		LastDay  int `bson:"lastday"`
+		LastWeek int `bson:"lastweek"`
+	}{}
+	err := c.Pipe([]bson.M{
+		{"$match": q.filter()},
+		{"$group": bson.M{
+			"_id":      nil,
+			"total":    bson.M{"$sum": 1},
+			"complete": countIf(bson.M{"$eq": []interface{}{"$complete", true}}),
+			"lastday":  countIf(bson.M{"$gte": []interface{}{"$createdat", dayAgo}}),
+			"lastweek": countIf(bson.M{"$gte": []interface{}{"$createdat", weekAgo}}),
+		}},
+	}).One(&counts)
+	//with no matching tasks there's nothing to group
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+
+	//unwinding makes a copy of each task per tag
+	tagCounts := []struct {
+		Tag   string `bson:"_id"`
+		Count int    `bson:"count"`
+	}{}
+	err = c.Pipe([]bson.M{
+		{"$match": q.filter()},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+	}).All(&tagCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Total:           counts.Total,
+		Complete:        counts.Complete,
+		Incomplete:      counts.Total - counts.Complete,
+		Tags:            map[string]int{},
+		CreatedLastDay:  counts.LastDay,
+		CreatedLastWeek: counts.LastWeek,
+	}
+	for _, tc := range tagCounts {
+		stats.Tags[tc.Tag] = tc.Count
+	}
+	return stats, nil
+}
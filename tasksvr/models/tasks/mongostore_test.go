@@ -1,7 +1,10 @@
 package tasks
 
 import (
+	"io/ioutil"
+	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/mgo.v2"
 )
@@ -13,10 +16,13 @@ func TestCRUD(t *testing.T) {
 	}
 	defer sess.Close()
 
-	store := &MongoStore{
-		Session:        sess,
-		DatabaseName:   "test",
-		CollectionName: "tasks",
+	store, err := NewMongoStore(sess, "test", "tasks")
+	if err != nil {
+		t.Fatalf("error creating store: %v", err)
+	}
+
+	if err := store.Ping(time.Second); err != nil {
+		t.Errorf("error pinging: %v", err)
 	}
 
 	newtask := &NewTask{
@@ -36,5 +42,138 @@ func TestCRUD(t *testing.T) {
 		t.Errorf("task title didn't match, expected %s but got %s", task.Title, task2.Title)
 	}
 
+	found, total, err := store.Find(&Query{Limit: 10})
+	if err != nil {
+		t.Errorf("error finding tasks: %v", err)
+	}
+	if total != 1 || len(found) != 1 || found[0].Title != task.Title {
+		t.Errorf("expected to find only the new task, got %d of %d", len(found), total)
+	}
+
+	complete := true
+	task3, err := store.Update(task.ID, &TaskUpdates{Complete: &complete})
+	if err != nil {
+		t.Errorf("error updating task: %v", err)
+	}
+	if !task3.Complete || task3.Title != task.Title {
+		t.Errorf("expected only complete to be updated, got %+v", task3)
+	}
+	if max, err := store.MaxModified(nil); err != nil || !max.Equal(task3.ModifiedAt.Truncate(time.Millisecond)) {
+		t.Errorf("expected the newest modifiedAt to be %v, got %v, %v", task3.ModifiedAt, max, err)
+	}
+	if task3.CompletedAt == nil {
+		t.Errorf("expected completedAt to be set when completing")
+	}
+	if task3.Version != 2 {
+		t.Errorf("expected version 2 after updating, got %d", task3.Version)
+	}
+	if _, err := store.UpdateIfVersion(task.ID, 1, &TaskUpdates{Complete: &complete}); err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch updating a stale version, got %v", err)
+	}
+	if err := store.DeleteIfVersion(task.ID, 1); err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch deleting a stale version, got %v", err)
+	}
+
+	task4, err := store.AddSubtask(task.ID, &NewSubtask{Title: "Read the docs"})
+	if err != nil || len(task4.Subtasks) != 1 {
+		t.Fatalf("error adding subtask: %v", err)
+	}
+	subID := task4.Subtasks[0].ID
+	if task4, err = store.UpdateSubtask(task.ID, subID, &SubtaskUpdates{Complete: &complete}); err != nil || !task4.Subtasks[0].Complete {
+		t.Errorf("expected the subtask to be complete, got %+v, %v", task4, err)
+	}
+	if task4, err = store.DeleteSubtask(task.ID, subID); err != nil || len(task4.Subtasks) != 0 {
+		t.Errorf("expected no subtasks after deleting it, got %+v, %v", task4, err)
+	}
+	if _, err := store.DeleteSubtask(task.ID, subID); err != ErrSubtaskNotFound {
+		t.Errorf("expected ErrSubtaskNotFound deleting it again but got %v", err)
+	}
+
+	newatt := &NewAttachment{Filename: "notes.txt", ContentType: "text/plain"}
+	task4, err = store.AddAttachment(task.ID, newatt, strings.NewReader("hello"))
+	if err != nil || len(task4.Attachments) != 1 || task4.Attachments[0].Size != 5 {
+		t.Fatalf("error adding attachment: %+v, %v", task4, err)
+	}
+	attID := task4.Attachments[0].ID
+	if att, content, err := store.OpenAttachment(task.ID, attID); err != nil {
+		t.Errorf("error opening attachment: %v", err)
+	} else {
+		data, _ := ioutil.ReadAll(content)
+		content.Close()
+		if string(data) != "hello" || att.ContentType != "text/plain" {
+			t.Errorf("expected the attachment back, got %+v with %q", att, data)
+		}
+	}
+	if task4, err = store.DeleteAttachment(task.ID, attID); err != nil || len(task4.Attachments) != 0 {
+		t.Errorf("expected no attachments after deleting it, got %+v, %v", task4, err)
+	}
+	if _, _, err := store.OpenAttachment(task.ID, attID); err != ErrAttachmentNotFound {
+		t.Errorf("expected ErrAttachmentNotFound opening it again but got %v", err)
+	}
+	//deleting a task removes the files of its attachments
+	store.AddAttachment(task.ID, newatt, strings.NewReader("hello"))
+
+	label, err := store.InsertLabel(&NewLabel{Name: "school", Color: "#1e90ff"})
+	if err != nil {
+		t.Fatalf("error inserting label: %v", err)
+	}
+	if _, err := store.InsertLabel(&NewLabel{Name: "school", Color: "#000000"}); err != ErrDuplicateLabelName {
+		t.Errorf("expected ErrDuplicateLabelName inserting it again but got %v", err)
+	}
+	color := "#ff0000"
+	if updated, err := store.UpdateLabel(label.ID, &LabelUpdates{Color: &color}); err != nil || updated.Color != color {
+		t.Errorf("expected the label's color to change, got %+v, %v", updated, err)
+	}
+	if labels, err := store.FindLabels(""); err != nil || len(labels) != 1 || labels[0].ID != label.ID {
+		t.Errorf("expected to find the label, got %+v, %v", labels, err)
+	}
+	labels := []string{LabelRef(label.ID)}
+	store.Update(task.ID, &TaskUpdates{Labels: &labels})
+	if found, _, err := store.Find(&Query{Label: labels[0]}); err != nil || len(found) != 1 {
+		t.Errorf("expected to find the labeled task, got %d, %v", len(found), err)
+	}
+	if err := store.DeleteLabel(label.ID, false); err != ErrLabelInUse {
+		t.Errorf("expected ErrLabelInUse but got %v", err)
+	}
+	if err := store.DeleteLabel(label.ID, true); err != nil {
+		t.Errorf("error deleting label: %v", err)
+	}
+	if got, _ := store.Get(task.ID); len(got.Labels) != 0 {
+		t.Errorf("expected the label to be pulled from the task, got %v", got.Labels)
+	}
+
+	keyed := &NewTask{Title: "Learn idempotency", ClientKey: "abc"}
+	keyedTask, err := store.Insert(keyed)
+	if err != nil {
+		t.Fatalf("error inserting task with a client key: %v", err)
+	}
+	if _, err := store.Insert(keyed); err != ErrDuplicateClientKey {
+		t.Errorf("expected ErrDuplicateClientKey inserting it again but got %v", err)
+	}
+	if found, err := store.GetByClientKey("", "abc"); err != nil || found.ID != keyedTask.ID || !found.SameAs(keyed) {
+		t.Errorf("expected to find the task by its client key, got %+v, %v", found, err)
+	}
+	if err := store.Delete(keyedTask.ID); err != nil {
+		t.Errorf("error deleting task: %v", err)
+	}
+
+	stats, err := store.Stats(&Query{})
+	if err != nil {
+		t.Errorf("error getting stats: %v", err)
+	} else if stats.Total != 1 || stats.Complete != 1 || stats.CreatedLastDay != 1 || stats.Tags["mongo"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := store.Delete(task.ID); err != nil {
+		t.Errorf("error deleting task: %v", err)
+	}
+	if _, err := store.Get(task.ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after deleting but got %v", err)
+	}
+	if n, _ := store.gridFS().Find(nil).Count(); n != 0 {
+		t.Errorf("expected the attachment's file to be removed with the task, but %d are left", n)
+	}
+
 	sess.DB(store.DatabaseName).C(store.CollectionName).RemoveAll(nil)
+	store.labelsC().RemoveAll(nil)
 }
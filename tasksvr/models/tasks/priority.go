@@ -0,0 +1,76 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//Priority is how urgent a task is. It's stored as a number,
+//so that sorting by it puts the tasks in order of urgency,
+//rather than alphabetical order, but it's a name in JSON.
+type Priority int
+
+//the priorities a task can have
+const (
+	PriorityLow    Priority = 1
+	PriorityNormal Priority = 2
+	PriorityHigh   Priority = 3
+)
+
+//PriorityNames are the names of the priorities,
+//from least to most urgent
+var PriorityNames = []string{"low", "normal", "high"}
+
+//ParsePriority returns the Priority named `name`,
+//or an error if it isn't one of the PriorityNames
+func ParsePriority(name string) (Priority, error) {
+	for i, n := range PriorityNames {
+		if name == n {
+			return Priority(i + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("priority must be one of %s, but got %q", strings.Join(PriorityNames, ", "), name)
+}
+
+//orNormal returns `p`, or PriorityNormal if it isn't
+//set, as it isn't on tasks from before there were
+//priorities
+func (p Priority) orNormal() Priority {
+	if p < PriorityLow || p > PriorityHigh {
+		return PriorityNormal
+	}
+	return p
+}
+
+//String returns the priority's name
+func (p Priority) String() string {
+	return PriorityNames[p.orNormal()-1]
+}
+
+//MarshalJSON encodes the priority as its name
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+//UnmarshalJSON decodes a priority from its name
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	parsed, err := ParsePriority(name)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+//validatePriority adds a problem to `ve` if `name`
+//isn't one of the PriorityNames
+func validatePriority(name string, ve *ValidationError) {
+	if _, err := ParsePriority(name); err != nil {
+		ve.add("priority", CodeInvalid, "%s", err.Error())
+	}
+}
@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestPriorityJSON(t *testing.T) {
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("error encoding %v: %v", p, err)
+		}
+		var decoded Priority
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != p {
+			t.Errorf("expected %s to decode to %d but got %d, %v", data, p, decoded, err)
+		}
+	}
+	//tasks from before there were priorities are normal
+	if data, _ := json.Marshal(Priority(0)); string(data) != `"normal"` {
+		t.Errorf("expected an unset priority to be normal, got %s", data)
+	}
+	var p Priority
+	if err := json.Unmarshal([]byte(`"urgent"`), &p); err == nil {
+		t.Errorf("expected an error decoding an unknown priority")
+	}
+}
+
+func TestPriorityValidate(t *testing.T) {
+	nt := &NewTask{Title: "Buy milk"}
+	if err := nt.Validate(); err != nil || nt.Priority != "normal" || nt.ToTask().Priority != PriorityNormal {
+		t.Errorf("expected the priority to default to normal, got %q, %v", nt.Priority, err)
+	}
+	nt = &NewTask{Title: "Buy milk", Priority: "high"}
+	if err := nt.Validate(); err != nil || nt.ToTask().Priority != PriorityHigh {
+		t.Errorf("expected a high priority task, got %v", err)
+	}
+
+	err := (&NewTask{Title: "Buy milk", Priority: "HIGH"}).Validate()
+	if codes := fieldCodes(t, err); !reflect.DeepEqual(codes, []string{"priority:invalid"}) {
+		t.Errorf("expected an invalid priority but got %v", codes)
+	}
+	empty := ""
+	err = (&TaskUpdates{Priority: &empty}).Validate()
+	if codes := fieldCodes(t, err); !reflect.DeepEqual(codes, []string{"priority:invalid"}) {
+		t.Errorf("expected an empty priority to be invalid in updates, got %v", codes)
+	}
+
+	low := "low"
+	task := nt.ToTask()
+	(&TaskUpdates{Priority: &low}).Apply(task)
+	if task.Priority != PriorityLow {
+		t.Errorf("expected the priority to be updated to low, got %v", task.Priority)
+	}
+}
+
+func TestQueryPriorityFilter(t *testing.T) {
+	expected := bson.M{"priority": PriorityHigh, "archivedat": nil}
+	if filter := (&Query{Priority: PriorityHigh}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	//normal includes tasks without the field
+	expected = bson.M{"priority": bson.M{"$in": []interface{}{PriorityNormal, nil}}, "archivedat": nil}
+	if filter := (&Query{Priority: PriorityNormal}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+}
+
+func TestMemStorePriority(t *testing.T) {
+	store := NewMemStore()
+	for _, p := range []string{"normal", "high", "low", "high"} {
+		store.Insert(&NewTask{Title: p, Priority: p})
+	}
+	//an old task without a priority counts as normal
+	store.tasks[0].Priority = 0
+
+	titles := func(q *Query) []string {
+		found, _, _ := store.Find(q)
+		titles := []string{}
+		for _, task := range found {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+	//ordered by urgency, not alphabetically, keeping ties in order
+	if got, expected := titles(&Query{Sort: "priority"}), []string{"low", "normal", "high", "high"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+	if got := titles(&Query{Sort: "-priority"}); !reflect.DeepEqual(got, []string{"high", "high", "normal", "low"}) {
+		t.Errorf("expected the most urgent first but got %v", got)
+	}
+	for p, count := range map[Priority]int{PriorityLow: 1, PriorityNormal: 1, PriorityHigh: 2} {
+		if got := titles(&Query{Priority: p}); len(got) != count || got[0] != p.String() {
+			t.Errorf("?priority=%s: expected %d tasks but got %v", p, count, got)
+		}
+	}
+}
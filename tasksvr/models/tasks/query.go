@@ -0,0 +1,375 @@
+package tasks
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//SortFields are the task fields that tasks can be sorted by
+//Sorting by priority puts them in order of urgency, though
+//Mongo sorts tasks from before there were priorities, which
+//don't have the field, as less urgent than low ones.
+var SortFields = []string{"createdAt", "title", "complete", "priority", "completedAt", "archivedAt", "dueDate"}
+
+//DefaultSort sorts the newest tasks first
+const DefaultSort = "-createdAt"
+
+//IDSort sorts tasks by their IDs, which is the order they were
+//created in, as ObjectIds start with the time they were made.
+//It's what paging through tasks with After uses, so it isn't
+//one of the SortFields clients can choose.
+const IDSort = "_id"
+
+//Query selects which tasks a Store's Find method returns
+type Query struct {
+	//Owner, if set, only matches tasks owned by them
+	Owner *string
+	//IDs, if set, only matches the tasks with those IDs
+	IDs []interface{}
+	//After, if set, only matches the tasks whose IDs are
+	//greater than it, which with IDSort is the ones after
+	//it, so that a page can start where the last one ended
+	After interface{}
+	//Archived matches only archived tasks when true, and
+	//only tasks that aren't archived when false
+	Archived bool
+	//Complete, if set, only matches tasks
+	//whose Complete field has the same value
+	Complete *bool
+	//Search, if set, only matches tasks whose
+	//titles contain it, ignoring case
+	Search string
+	//Title, if set, only matches tasks with the same title,
+	//ignoring case and the spaces around it, unlike Search
+	Title string
+	//Tag, if set, only matches tasks with that tag
+	Tag string
+	//Label, if set, only matches tasks with the
+	//label that it refers to, like LabelRef
+	Label string
+	//Priority, if set, only matches tasks with that priority
+	Priority Priority
+	//DueBefore, if set, only matches tasks
+	//due before that time
+	DueBefore *time.Time
+	//DueAfter, if set, only matches tasks
+	//due at or after that time
+	DueAfter *time.Time
+	//Overdue, if true, only matches tasks that
+	//are past their due date and not complete
+	Overdue bool
+	//CreatedAfter, if set, only matches tasks
+	//that were created after that time
+	CreatedAfter *time.Time
+	//CompletedAfter, if set, only matches tasks
+	//that were completed after that time
+	CompletedAfter *time.Time
+	//NotRemindedSince, if set, only matches tasks whose
+	//owners haven't been reminded about them since that
+	//time, including tasks they've never been reminded about
+	NotRemindedSince *time.Time
+	//Sort is one of the SortFields to sort by, prefixed with
+	//"-" to sort in descending order. Tasks that sort the same
+	//are kept in the order they were inserted, or the reverse
+	//of that when descending. Defaults to DefaultSort.
+	Sort string
+	//Skip is the number of matching tasks to skip
+	Skip int
+	//Limit is the maximum number of tasks to return,
+	//or zero to return all of them
+	Limit int
+}
+
+//Filtered returns true if the query has any conditions,
+//other than the owner and whether tasks are archived, so
+//it doesn't match every one of the owner's tasks
+func (q *Query) Filtered() bool {
+	return len(q.filter()) > len(q.baseFilter())
+}
+
+//dueBefore returns the time that tasks must be due before
+//to match: the earlier of DueBefore, and now if Overdue is
+//set. It returns nil if tasks can be due at any time.
+func (q *Query) dueBefore() *time.Time {
+	dueBefore := q.DueBefore
+	if q.Overdue {
+		t := now()
+		if dueBefore == nil || t.Before(*dueBefore) {
+			dueBefore = &t
+		}
+	}
+	return dueBefore
+}
+
+//baseFilter returns the Mongo query document for the
+//conditions every query has: who owns the tasks, and whether
+//they're archived. Every filter starts from this one, so
+//archived tasks are left out unless they're asked for.
+func (q *Query) baseFilter() bson.M {
+	filter := ownerFilter(q.Owner)
+	//nil matches tasks without the field too
+	if q.Archived {
+		filter["archivedat"] = bson.M{"$ne": nil}
+	} else {
+		filter["archivedat"] = nil
+	}
+	return filter
+}
+
+//ownerFilter returns the Mongo query document for the
+//tasks owned by `owner`, or for every task if it's nil
+func ownerFilter(owner *string) bson.M {
+	filter := bson.M{}
+	if owner != nil {
+		if len(*owner) == 0 {
+			//tasks from before there were owners
+			//don't have the field at all
+			filter["owner"] = bson.M{"$in": []interface{}{"", nil}}
+		} else {
+			filter["owner"] = *owner
+		}
+	}
+	return filter
+}
+
+//filter returns the Mongo query document
+//for the query's conditions
+func (q *Query) filter() bson.M {
+	filter := q.baseFilter()
+	//both Complete and Overdue can add conditions on
+	//complete; if they contradict, nothing matches
+	complete := bson.M{}
+	if q.Complete != nil {
+		complete["$eq"] = *q.Complete
+	}
+	if q.Overdue {
+		complete["$ne"] = true
+	}
+	if len(complete) > 0 {
+		filter["complete"] = complete
+	}
+	//tasks without due dates never match $lt or $gte
+	due := bson.M{}
+	if dueBefore := q.dueBefore(); dueBefore != nil {
+		due["$lt"] = *dueBefore
+	}
+	if q.DueAfter != nil {
+		due["$gte"] = *q.DueAfter
+	}
+	if len(due) > 0 {
+		filter["duedate"] = due
+	}
+	id := bson.M{}
+	if q.IDs != nil {
+		id["$in"] = q.IDs
+	}
+	if q.After != nil {
+		id["$gt"] = q.After
+	}
+	if len(id) > 0 {
+		filter["_id"] = id
+	}
+	//incomplete tasks have no completedat, so never match
+	if q.CreatedAfter != nil {
+		filter["createdat"] = bson.M{"$gt": *q.CreatedAfter}
+	}
+	if q.CompletedAfter != nil {
+		filter["completedat"] = bson.M{"$gt": *q.CompletedAfter}
+	}
+	//$not matches tasks without the field too
+	if q.NotRemindedSince != nil {
+		filter["remindedat"] = bson.M{"$not": bson.M{"$gt": *q.NotRemindedSince}}
+	}
+	if len(q.Tag) > 0 {
+		//matches tasks whose tags array contains it
+		filter["tags"] = q.Tag
+	}
+	if len(q.Label) > 0 {
+		filter["labels"] = q.Label
+	}
+	if q.Priority == PriorityNormal {
+		//tasks from before there were priorities
+		//don't have the field, and count as normal
+		filter["priority"] = bson.M{"$in": []interface{}{PriorityNormal, nil}}
+	} else if q.Priority != 0 {
+		filter["priority"] = q.Priority
+	}
+	if len(q.Search) > 0 {
+		//quote the search, so that regular expression
+		//characters in it match themselves
+		filter["title"] = bson.RegEx{Pattern: regexp.QuoteMeta(q.Search), Options: "i"}
+	}
+	//compares the normalized titles, so it can use an index
+	if len(q.Title) > 0 {
+		filter["normalizedtitle"] = normalizeTitle(q.Title)
+	}
+	return filter
+}
+
+//matches returns true if `t` meets the query's conditions,
+//for stores that filter tasks in memory
+func (q *Query) matches(t *Task) bool {
+	if q.Owner != nil && t.Owner != *q.Owner {
+		return false
+	}
+	if q.Archived != (t.ArchivedAt != nil) {
+		return false
+	}
+	if q.IDs != nil && !hasID(q.IDs, t.ID) {
+		return false
+	}
+	if q.After != nil && !idLess(q.After, t.ID) {
+		return false
+	}
+	if q.Complete != nil && t.Complete != *q.Complete {
+		return false
+	}
+	if q.Overdue && t.Complete {
+		return false
+	}
+	if dueBefore := q.dueBefore(); dueBefore != nil && (t.DueDate == nil || !t.DueDate.Before(*dueBefore)) {
+		return false
+	}
+	if q.DueAfter != nil && (t.DueDate == nil || t.DueDate.Before(*q.DueAfter)) {
+		return false
+	}
+	if q.CreatedAfter != nil && !t.CreatedAt.After(*q.CreatedAfter) {
+		return false
+	}
+	if q.CompletedAfter != nil && (t.CompletedAt == nil || !t.CompletedAt.After(*q.CompletedAfter)) {
+		return false
+	}
+	if q.NotRemindedSince != nil && t.RemindedAt != nil && t.RemindedAt.After(*q.NotRemindedSince) {
+		return false
+	}
+	if len(q.Tag) > 0 && !hasTag(t, q.Tag) {
+		return false
+	}
+	if len(q.Label) > 0 && !hasLabel(t, q.Label) {
+		return false
+	}
+	if q.Priority != 0 && t.Priority.orNormal() != q.Priority {
+		return false
+	}
+	if len(q.Search) > 0 && !strings.Contains(strings.ToLower(t.Title), strings.ToLower(q.Search)) {
+		return false
+	}
+	if len(q.Title) > 0 && t.NormalizedTitle != normalizeTitle(q.Title) {
+		return false
+	}
+	return true
+}
+
+//hasID returns true if `ID` is one of `IDs`
+func hasID(IDs []interface{}, ID interface{}) bool {
+	for _, id := range IDs {
+		if id == ID {
+			return true
+		}
+	}
+	return false
+}
+
+//idLess returns true if `a` is less than `b`, comparing
+//ObjectIds by their bytes, as Mongo does. The MemStore
+//only makes ObjectIds, so other IDs are never less.
+func idLess(a interface{}, b interface{}) bool {
+	aid, aok := a.(bson.ObjectId)
+	bid, bok := b.(bson.ObjectId)
+	return aok && bok && aid < bid
+}
+
+//hasTag returns true if `t` has the tag
+func hasTag(t *Task, tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
+}
+
+//sortField returns the field to sort by,
+//and true if it's in descending order
+func (q *Query) sortField() (string, bool) {
+	sortBy := q.Sort
+	if len(sortBy) == 0 {
+		sortBy = DefaultSort
+	}
+	return strings.TrimPrefix(sortBy, "-"), strings.HasPrefix(sortBy, "-")
+}
+
+//sortSpec returns the Mongo sort spec for the query.
+//Mongo lower-cases field names by default, and
+//sorting by _id next keeps the order stable.
+func (q *Query) sortSpec() []string {
+	field, desc := q.sortField()
+	if field == IDSort {
+		if desc {
+			return []string{"-_id"}
+		}
+		return []string{"_id"}
+	}
+	if desc {
+		return []string{"-" + strings.ToLower(field), "-_id"}
+	}
+	return []string{strings.ToLower(field), "_id"}
+}
+
+//sort sorts `tasks`, which must be in the order they were
+//inserted, for stores that sort tasks in memory
+func (q *Query) sort(tasks []*Task) {
+	field, desc := q.sortField()
+	if desc {
+		//reverse first, so that ties stay in reverse order
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	}
+	less := func(a, b *Task) bool {
+		switch field {
+		case "title":
+			return a.Title < b.Title
+		case "complete":
+			return !a.Complete && b.Complete
+		case "priority":
+			return a.Priority.orNormal() < b.Priority.orNormal()
+		case IDSort:
+			return idLess(a.ID, b.ID)
+		case "completedAt":
+			//incomplete tasks sort first, as they do in Mongo
+			return b.CompletedAt != nil && (a.CompletedAt == nil || a.CompletedAt.Before(*b.CompletedAt))
+		case "archivedAt":
+			return b.ArchivedAt != nil && (a.ArchivedAt == nil || a.ArchivedAt.Before(*b.ArchivedAt))
+		case "dueDate":
+			//tasks without due dates sort first, as they do in Mongo
+			return b.DueDate != nil && (a.DueDate == nil || a.DueDate.Before(*b.DueDate))
+		default:
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if desc {
+			return less(tasks[j], tasks[i])
+		}
+		return less(tasks[i], tasks[j])
+	})
+}
+
+//page returns the part of `all` selected by the
+//query's Skip and Limit, for stores that filter
+//tasks in memory
+func (q *Query) page(all []*Task) []*Task {
+	if q.Skip >= len(all) {
+		return all[:0]
+	}
+	all = all[q.Skip:]
+	if q.Limit > 0 && q.Limit < len(all) {
+		all = all[:q.Limit]
+	}
+	return all
+}
@@ -0,0 +1,166 @@
+package tasks
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestQuerySearchFilter(t *testing.T) {
+	q := &Query{Search: "C++ (urgent)?"}
+	re, ok := q.filter()["title"].(bson.RegEx)
+	if !ok {
+		t.Fatalf("expected a regex title filter, got %#v", q.filter())
+	}
+	if re.Options != "i" {
+		t.Errorf("expected a case-insensitive regex, got options %q", re.Options)
+	}
+	//the special characters must match themselves
+	compiled := regexp.MustCompile("(?i)" + re.Pattern)
+	if !compiled.MatchString("learn c++ (URGENT)?") {
+		t.Errorf("expected %q to match the search literally", re.Pattern)
+	}
+	if compiled.MatchString("learn C urgent") {
+		t.Errorf("expected %q not to match as a regex", re.Pattern)
+	}
+}
+
+func TestQueryOverdueFilter(t *testing.T) {
+	frozen := time.Date(2017, 4, 20, 12, 0, 0, 0, time.UTC)
+	defer freezeNow(frozen)()
+
+	complete := true
+	later := frozen.AddDate(0, 0, 7)
+	q := &Query{Overdue: true, Complete: &complete, DueBefore: &later}
+	expected := bson.M{
+		//contradicts, so nothing matches
+		"complete": bson.M{"$eq": true, "$ne": true},
+		//now is earlier than DueBefore
+		"duedate":    bson.M{"$lt": frozen},
+		"archivedat": nil,
+	}
+	if filter := q.filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+}
+
+func TestQueryOwnerFilter(t *testing.T) {
+	alice, nobody := "alice", ""
+	if filter := (&Query{Owner: &alice}).filter(); !reflect.DeepEqual(filter, bson.M{"owner": "alice", "archivedat": nil}) {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	//tasks from before there were owners have no owner field
+	expected := bson.M{"owner": bson.M{"$in": []interface{}{"", nil}}, "archivedat": nil}
+	if filter := (&Query{Owner: &nobody}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	//the owner alone doesn't count as filtering
+	if (&Query{Owner: &alice}).Filtered() {
+		t.Errorf("expected a query with only an owner not to be filtered")
+	}
+	complete := true
+	if !(&Query{Owner: &alice, Complete: &complete}).Filtered() {
+		t.Errorf("expected a query with complete to be filtered")
+	}
+}
+
+func TestQueryArchivedFilter(t *testing.T) {
+	//archived tasks are left out by default,
+	//including from queries with other conditions
+	complete := true
+	expected := bson.M{"complete": bson.M{"$eq": true}, "archivedat": nil}
+	if filter := (&Query{Complete: &complete}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	expected = bson.M{"archivedat": bson.M{"$ne": nil}}
+	if filter := (&Query{Archived: true}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	//and it doesn't count as filtering
+	if (&Query{Archived: true}).Filtered() {
+		t.Errorf("expected a query for archived tasks not to be filtered")
+	}
+}
+
+func TestQueryIDsFilter(t *testing.T) {
+	ids := []interface{}{bson.NewObjectId(), bson.NewObjectId()}
+	expected := bson.M{"_id": bson.M{"$in": ids}, "archivedat": nil}
+	if filter := (&Query{IDs: ids}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	q := &Query{IDs: ids[:1]}
+	if !q.matches(&Task{ID: ids[0]}) || q.matches(&Task{ID: ids[1]}) {
+		t.Errorf("expected only the listed ID to match")
+	}
+}
+
+func TestQueryAfterFilter(t *testing.T) {
+	after := bson.NewObjectId()
+	expected := bson.M{"_id": bson.M{"$gt": after}, "archivedat": nil}
+	if filter := (&Query{After: after}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	//both conditions on the ID are kept
+	ids := []interface{}{bson.NewObjectId()}
+	expected = bson.M{"_id": bson.M{"$in": ids, "$gt": after}, "archivedat": nil}
+	if filter := (&Query{IDs: ids, After: after}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	if spec := (&Query{Sort: IDSort}).sortSpec(); !reflect.DeepEqual(spec, []string{"_id"}) {
+		t.Errorf("expected to sort by _id only, got %v", spec)
+	}
+	later := bson.NewObjectId()
+	q := &Query{After: after}
+	if q.matches(&Task{ID: after}) || !q.matches(&Task{ID: later}) {
+		t.Errorf("expected only IDs after the cursor to match")
+	}
+}
+
+func TestQueryLabelFilter(t *testing.T) {
+	label := bson.NewObjectId().Hex()
+	//matches tasks whose labels array contains it
+	expected := bson.M{"labels": label, "archivedat": nil}
+	if filter := (&Query{Label: label}).filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+	if !(&Query{Label: label}).Filtered() {
+		t.Errorf("expected a query with a label to be filtered")
+	}
+}
+
+func TestQueryTitleFilter(t *testing.T) {
+	since := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	q := &Query{Title: "  Buy MILK ", CreatedAfter: &since}
+	expected := bson.M{"normalizedtitle": "buy milk", "createdat": bson.M{"$gt": since}, "archivedat": nil}
+	if filter := q.filter(); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected filter %v but got %v", expected, filter)
+	}
+
+	store := NewMemStore()
+	task, _ := store.Insert(&NewTask{Title: "Buy milk"})
+	if task.NormalizedTitle != "buy milk" {
+		t.Errorf("expected the normalized title to be set on insert, got %q", task.NormalizedTitle)
+	}
+	if found, _, _ := store.Find(&Query{Title: "buy milk "}); len(found) != 1 {
+		t.Errorf("expected the task to match its normalized title, got %d tasks", len(found))
+	}
+	//it's the whole title, not a search
+	if found, _, _ := store.Find(&Query{Title: "milk"}); len(found) != 0 {
+		t.Errorf("expected part of the title not to match, got %d tasks", len(found))
+	}
+	if found, _, _ := store.Find(&Query{Title: "buy milk", CreatedAfter: &task.CreatedAt}); len(found) != 0 {
+		t.Errorf("expected a task created at CreatedAfter not to match, got %d tasks", len(found))
+	}
+
+	title := "Buy Bread"
+	store.Update(task.ID, &TaskUpdates{Title: &title})
+	if found, _, _ := store.Find(&Query{Title: "buy bread"}); len(found) != 1 || found[0].NormalizedTitle != "buy bread" {
+		t.Errorf("expected the normalized title to change with the title, got %+v", found)
+	}
+	if set := updateDoc(&TaskUpdates{Title: &title})["$set"].(bson.M); set["normalizedtitle"] != "buy bread" {
+		t.Errorf("expected the update to $set normalizedtitle, got %v", set)
+	}
+}
@@ -0,0 +1,29 @@
+package tasks
+
+import "time"
+
+//Stats summarizes a set of tasks, for dashboards
+//that need counts without fetching every task
+type Stats struct {
+	//Total is the number of tasks
+	Total int `json:"total"`
+	//Complete is the number of complete tasks
+	Complete int `json:"complete"`
+	//Incomplete is the number of incomplete tasks
+	Incomplete int `json:"incomplete"`
+	//Tags maps each tag to the number of tasks with it
+	Tags map[string]int `json:"tags"`
+	//CreatedLastDay is the number of tasks
+	//created in the last 24 hours
+	CreatedLastDay int `json:"createdLastDay"`
+	//CreatedLastWeek is the number of tasks
+	//created in the last 7 days
+	CreatedLastWeek int `json:"createdLastWeek"`
+}
+
+//statsWindows returns the start of the last
+//day and the last week, for Stats
+func statsWindows() (time.Time, time.Time) {
+	t := now()
+	return t.Add(-24 * time.Hour), t.AddDate(0, 0, -7)
+}
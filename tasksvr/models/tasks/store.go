@@ -1,9 +1,119 @@
 package tasks
 
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+//ErrNotFound is returned by a Store when
+//there's no task with the requested ID
+var ErrNotFound = errors.New("task not found")
+
+//ErrVersionMismatch is returned by a Store's IfVersion methods
+//when the task has been changed since the given version
+var ErrVersionMismatch = errors.New("task version doesn't match")
+
 //Store defines an abstract interface for a Task object store
 type Store interface {
-	//Insert inserts a NewTask and
-	//returns the fully-populated Task or an error
+	//Insert inserts a NewTask and returns the fully-populated
+	//Task or an error, which is ErrDuplicateClientKey if the
+	//owner already has a task with the same ClientKey
 	Insert(newtask *NewTask) (*Task, error)
+	//InsertMany inserts all of the NewTasks and returns
+	//the fully-populated Tasks in the same order, or an error
+	InsertMany(newtasks []*NewTask) ([]*Task, error)
+	//GetByClientKey returns the task that `owner` created
+	//with the ClientKey `key`, or ErrNotFound if there isn't one
+	GetByClientKey(owner string, key string) (*Task, error)
+	//Get returns the task with the given ID,
+	//or ErrNotFound if there isn't one
 	Get(ID interface{}) (*Task, error)
+	//GetAll returns all the tasks, in the order they were
+	//inserted, or an empty slice if there aren't any
+	GetAll() ([]*Task, error)
+	//Find returns the page of tasks matching the query,
+	//sorted as it asks, along with the total
+	//number of matching tasks, ignoring the query's Skip and Limit
+	Find(q *Query) ([]*Task, int, error)
+	//Update applies the updates to the task with the given ID
+	//and returns the updated task, or ErrNotFound if there isn't one
+	Update(ID interface{}, updates *TaskUpdates) (*Task, error)
+	//UpdateIfVersion is like Update, but only applies the updates
+	//if the task's version still matches `version`, returning
+	//ErrVersionMismatch if it doesn't
+	UpdateIfVersion(ID interface{}, version int, updates *TaskUpdates) (*Task, error)
+	//Delete deletes the task with the given ID, along with
+	//its attachments, or returns ErrNotFound if there isn't one
+	Delete(ID interface{}) error
+	//DeleteIfVersion is like Delete, but only deletes the task
+	//if its version still matches `version`, returning
+	//ErrVersionMismatch if it doesn't
+	DeleteIfVersion(ID interface{}, version int) error
+	//UpdateMany applies the updates to all the tasks matching
+	//the query, ignoring its Sort, Skip and Limit, and returns
+	//how many were updated
+	UpdateMany(q *Query, updates *TaskUpdates) (int, error)
+	//DeleteMany deletes all the tasks matching the query,
+	//along with their attachments, ignoring its Sort, Skip
+	//and Limit, and returns how many were deleted
+	DeleteMany(q *Query) (int, error)
+	//Stats summarizes the tasks matching the query,
+	//ignoring its Sort, Skip and Limit
+	Stats(q *Query) (*Stats, error)
+	//AddSubtask adds a subtask to the end of the task with the
+	//given ID, and returns the updated task, or ErrNotFound if
+	//there isn't one, or ErrTooManySubtasks if it's full
+	AddSubtask(ID interface{}, newsub *NewSubtask) (*Task, error)
+	//UpdateSubtask applies the updates to one subtask of the
+	//task with the given ID, and returns the updated task, or
+	//ErrNotFound or ErrSubtaskNotFound if either is missing
+	UpdateSubtask(ID interface{}, subID string, updates *SubtaskUpdates) (*Task, error)
+	//DeleteSubtask removes one subtask from the task with the
+	//given ID, and returns the updated task, or ErrNotFound or
+	//ErrSubtaskNotFound if either is missing
+	DeleteSubtask(ID interface{}, subID string) (*Task, error)
+	//AddAttachment stores the bytes read from `content` as a new
+	//attachment at the end of the task with the given ID, and
+	//returns the updated task, or ErrNotFound if there isn't one,
+	//or ErrTooManyAttachments if it's full. If reading `content`
+	//fails, nothing is attached, and that error is returned.
+	AddAttachment(ID interface{}, newatt *NewAttachment, content io.Reader) (*Task, error)
+	//OpenAttachment returns one attachment of the task with the
+	//given ID, along with its bytes, which the caller must close,
+	//or ErrNotFound or ErrAttachmentNotFound if either is missing
+	OpenAttachment(ID interface{}, attID string) (*Attachment, io.ReadCloser, error)
+	//DeleteAttachment removes one attachment, bytes and all, from
+	//the task with the given ID, and returns the updated task, or
+	//ErrNotFound or ErrAttachmentNotFound if either is missing
+	DeleteAttachment(ID interface{}, attID string) (*Task, error)
+	//InsertLabel inserts a NewLabel and returns the fully-populated
+	//Label or an error, which is ErrDuplicateLabelName if the
+	//owner already has a label with the same name
+	InsertLabel(newlabel *NewLabel) (*Label, error)
+	//GetLabel returns the label with the given ID,
+	//or ErrLabelNotFound if there isn't one
+	GetLabel(ID interface{}) (*Label, error)
+	//FindLabels returns the labels owned by `owner`, sorted by
+	//name, or an empty slice if they don't have any
+	FindLabels(owner string) ([]*Label, error)
+	//UpdateLabel applies the updates to the label with the given
+	//ID and returns the updated label, or ErrLabelNotFound if
+	//there isn't one, or ErrDuplicateLabelName if it's renamed
+	//to the name of another of the owner's labels
+	UpdateLabel(ID interface{}, updates *LabelUpdates) (*Label, error)
+	//DeleteLabel deletes the label with the given ID, or returns
+	//ErrLabelNotFound if there isn't one. If `cascade` is true, the
+	//label is removed from every task that has it, including archived
+	//ones, bumping their versions; otherwise it returns ErrLabelInUse
+	//if any task still has it, and the label is left alone.
+	DeleteLabel(ID interface{}, cascade bool) error
+	//MaxModified returns the newest ModifiedAt of the tasks owned
+	//by `owner`, or of every task if it's nil, including archived
+	//tasks, so that deleting one counts as a change. It returns
+	//the zero time if there aren't any.
+	MaxModified(owner *string) (time.Time, error)
+	//Ping returns an error if the store can't be reached,
+	//or doesn't answer within `timeout`
+	Ping(timeout time.Duration) error
 }
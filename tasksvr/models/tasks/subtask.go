@@ -0,0 +1,93 @@
+package tasks
+
+import (
+	"errors"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//MaxSubtasks is the most subtasks a task can have
+const MaxSubtasks = 50
+
+//ErrSubtaskNotFound is returned by a Store when the task
+//exists, but has no subtask with the requested ID
+var ErrSubtaskNotFound = errors.New("subtask not found")
+
+//ErrTooManySubtasks is returned by a Store when adding a
+//subtask to a task that already has MaxSubtasks of them
+var ErrTooManySubtasks = errors.New("task has too many subtasks")
+
+//Subtask is an item in a task's checklist, like
+//"book flights" for the task "plan trip"
+type Subtask struct {
+	//ID is unique within the task, and is
+	//a string so that it's easy to match in Mongo
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Complete bool   `json:"complete"`
+}
+
+//NewSubtask represents a new subtask posted to the server
+type NewSubtask struct {
+	Title string `json:"title"`
+}
+
+//SubtaskUpdates represents updates to a subtask,
+//with pointers for the same reason as TaskUpdates
+type SubtaskUpdates struct {
+	Title    *string `json:"title"`
+	Complete *bool   `json:"complete"`
+}
+
+//Validate will validate the NewSubtask, returning
+//a *ValidationError if it's invalid
+func (ns *NewSubtask) Validate() error {
+	ve := &ValidationError{}
+	if len(ns.Title) == 0 {
+		ve.add("title", CodeRequired, "title must be something")
+	}
+	return ve.err()
+}
+
+//ToSubtask converts a NewSubtask to a Subtask with a new ID
+func (ns *NewSubtask) ToSubtask() *Subtask {
+	return &Subtask{
+		ID:    bson.NewObjectId().Hex(),
+		Title: ns.Title,
+	}
+}
+
+//Validate will validate the SubtaskUpdates, returning
+//a *ValidationError if they're invalid
+func (su *SubtaskUpdates) Validate() error {
+	ve := &ValidationError{}
+	if su.Title == nil && su.Complete == nil {
+		ve.add("", CodeNoUpdates, "no updates: set title and/or complete")
+		return ve
+	}
+	if su.Title != nil && len(*su.Title) == 0 {
+		ve.add("title", CodeRequired, "title must be something")
+	}
+	return ve.err()
+}
+
+//Apply applies the updates to `st`
+func (su *SubtaskUpdates) Apply(st *Subtask) {
+	if su.Title != nil {
+		st.Title = *su.Title
+	}
+	if su.Complete != nil {
+		st.Complete = *su.Complete
+	}
+}
+
+//AllSubtasksComplete returns true if the task
+//has subtasks, and all of them are complete
+func (t *Task) AllSubtasksComplete() bool {
+	for _, st := range t.Subtasks {
+		if !st.Complete {
+			return false
+		}
+	}
+	return len(t.Subtasks) > 0
+}
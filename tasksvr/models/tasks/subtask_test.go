@@ -0,0 +1,60 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSubtaskValidate(t *testing.T) {
+	if err := (&NewSubtask{}).Validate(); err == nil {
+		t.Errorf("expected an error validating a subtask without a title")
+	}
+	title := ""
+	if err := (&SubtaskUpdates{Title: &title}).Validate(); err == nil {
+		t.Errorf("expected an error validating an empty title")
+	}
+	if err := (&SubtaskUpdates{}).Validate(); err == nil {
+		t.Errorf("expected an error validating no updates")
+	}
+	complete := true
+	if err := (&SubtaskUpdates{Complete: &complete}).Validate(); err != nil {
+		t.Errorf("unexpected error validating updates: %v", err)
+	}
+}
+
+func TestSubtaskMongoDocs(t *testing.T) {
+	//updates $set just the matched subtask's fields,
+	//using the positional operator
+	complete := true
+	update := updateSubtaskDoc(&SubtaskUpdates{Complete: &complete})
+	set := update["$set"].(bson.M)
+	if len(set) != 2 || set["subtasks.$.complete"] != true || set["modifiedat"] == nil {
+		t.Errorf("expected a positional $set of complete, got %v", set)
+	}
+	if inc := update["$inc"]; !reflect.DeepEqual(inc, bson.M{"version": 1}) {
+		t.Errorf("expected the version to be incremented, got %v", inc)
+	}
+
+	//adding only matches tasks that have room
+	expected := bson.M{"_id": "id", "subtasks.49": bson.M{"$exists": false}}
+	if selector := addSubtaskSelector("id"); !reflect.DeepEqual(selector, expected) {
+		t.Errorf("expected selector %v but got %v", expected, selector)
+	}
+}
+
+func TestAllSubtasksComplete(t *testing.T) {
+	task := &Task{}
+	if task.AllSubtasksComplete() {
+		t.Errorf("expected a task without subtasks not to count as all complete")
+	}
+	task.Subtasks = []*Subtask{{Complete: true}, {Complete: false}}
+	if task.AllSubtasksComplete() {
+		t.Errorf("expected an incomplete subtask to count")
+	}
+	task.Subtasks[1].Complete = true
+	if !task.AllSubtasksComplete() {
+		t.Errorf("expected all the subtasks to be complete")
+	}
+}
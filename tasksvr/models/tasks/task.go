@@ -2,40 +2,367 @@ package tasks
 
 import "time"
 import "fmt"
+import "strings"
+
+//limits on the tags of a task
+const (
+	maxTags      = 10
+	maxTagLength = 50
+)
+
+//maxDueYears is how many years in the future a task can be due
+const maxDueYears = 10
+
+//now returns the current time; tests replace it
+//to check due dates against a frozen clock
+var now = time.Now
 
 //NewTask represents a new task posted to the server
 type NewTask struct {
 	Title string   `json:"title"`
 	Tags  []string `json:"tags"`
+	//DueDate is optional, in RFC3339 format
+	//like "2017-05-01T17:00:00-07:00"
+	DueDate *time.Time `json:"dueDate"`
+	//Priority is one of the PriorityNames,
+	//and defaults to "normal"
+	Priority string `json:"priority"`
+	//Owner is set by the server, not the client
+	Owner string `json:"-"`
+	//Complete is only set by imports; tasks
+	//that clients create start incomplete
+	Complete bool `json:"-"`
+	//ClientKey is optional, and makes creating the task
+	//idempotent: creating another task with the same
+	//key finds this one instead of inserting a copy
+	ClientKey string `json:"clientKey"`
 }
 
 //Task represents a task stored in the database
 type Task struct {
 	ID         interface{} `json:"id" bson:"_id"`
+	Owner      string      `json:"owner"`
 	Title      string      `json:"title"`
 	Tags       []string    `json:"tags"`
 	CreatedAt  time.Time   `json:"createdAt"`
 	ModifiedAt time.Time   `json:"modifiedAt"`
 	Complete   bool        `json:"complete"`
+	Priority   Priority    `json:"priority"`
+	DueDate    *time.Time  `json:"dueDate,omitempty" bson:",omitempty"`
+	//CompletedAt is when the task was marked complete; it's
+	//cleared if the task is marked incomplete again
+	CompletedAt *time.Time `json:"completedAt,omitempty" bson:",omitempty"`
+	//ArchivedAt is when the task was deleted, if it has
+	//been; archived tasks can be restored until they're
+	//permanently deleted
+	ArchivedAt *time.Time `json:"archivedAt,omitempty" bson:",omitempty"`
+	//RemindedAt is when the owner was last reminded that
+	//the task is due soon; it's cleared when it's completed
+	RemindedAt *time.Time `json:"remindedAt,omitempty" bson:",omitempty"`
+	//Subtasks is the task's checklist, in the order
+	//they were added; it's left out when it's empty,
+	//so tasks look the same as they did before
+	Subtasks []*Subtask `json:"subtasks,omitempty" bson:",omitempty"`
+	//Attachments describes the files attached to the
+	//task, in the order they were attached, and is
+	//left out when it's empty, like Subtasks
+	Attachments []*Attachment `json:"attachments,omitempty" bson:",omitempty"`
+	//Labels are the IDs of the owner's labels that the task
+	//has, as LabelRef makes them, and are left out when
+	//there aren't any, like Subtasks
+	Labels []string `json:"labels,omitempty" bson:",omitempty"`
+	//NormalizedTitle is the title as normalizeTitle makes it,
+	//so that stores can find tasks with the same title; it's
+	//only for the store, not for clients. Tasks from before
+	//there were normalized titles don't have one.
+	NormalizedTitle string `json:"-" bson:",omitempty"`
+	//Idempotency is set if the task was created with a
+	//ClientKey; it's only for the store, not for clients
+	Idempotency *Idempotency `json:"-" bson:",omitempty"`
+	//Version starts at 1, and goes up by one every
+	//time the task is changed; it's used as the ETag
+	Version int `json:"version"`
+}
+
+//TaskUpdates represents updates to a task. The fields are
+//pointers so that we can tell the difference between a field
+//that wasn't in the request and one set to its zero value,
+//like "complete": false.
+type TaskUpdates struct {
+	Title    *string `json:"title"`
+	Complete *bool   `json:"complete"`
+	//Tags, if set, replaces all of the task's tags
+	Tags *[]string `json:"tags"`
+	//DueDate, if set, replaces the task's due date
+	DueDate *time.Time `json:"dueDate"`
+	//Priority, if set, is one of the PriorityNames
+	Priority *string `json:"priority"`
+	//Labels, if set, replaces all of the task's labels. Only
+	//PATCH sets them, as the handlers must check that the
+	//labels exist, so PUT leaves a task's labels alone.
+	Labels *[]string `json:"labels"`
+	//ClearDueDate removes the task's due date; it's only
+	//set by TaskReplacement, as "dueDate": null in JSON
+	//can't be told apart from leaving it out
+	ClearDueDate bool `json:"-"`
+	//ArchivedAt, if set, archives the task at that time,
+	//and Unarchive restores it. Clients archive and restore
+	//tasks with their own requests, so these aren't in JSON.
+	ArchivedAt *time.Time `json:"-"`
+	Unarchive  bool       `json:"-"`
+	//RemindedAt, if set, records that the owner was reminded
+	//about the task at that time; it's only set by the server
+	RemindedAt *time.Time `json:"-"`
+}
+
+//TaskReplacement represents a task sent with PUT, which
+//replaces all of the fields a client can change. Fields
+//that are left out are reset to their zero values.
+type TaskReplacement struct {
+	NewTask
+	Complete bool `json:"complete"`
 }
 
-//Validate will validate the NewTask
+//Validate will validate the NewTask,
+//trimming and de-duplicating its tags,
+//and checking that any due date is sensible.
+//If it's invalid, it returns a *ValidationError
+//listing every problem.
 func (nt *NewTask) Validate() error {
+	ve := &ValidationError{}
 	//Title field must be non-zero length
 	if len(nt.Title) == 0 {
-		return fmt.Errorf("title must be something")
+		ve.add("title", CodeRequired, "title must be something")
 	}
-	return nil
+	nt.Tags = normalizeTags(nt.Tags, ve)
+	if nt.DueDate != nil {
+		validateDueDate(*nt.DueDate, ve)
+	}
+	if len(nt.Priority) == 0 {
+		nt.Priority = PriorityNormal.String()
+	}
+	validatePriority(nt.Priority, ve)
+	if len(nt.ClientKey) > MaxClientKeyLength {
+		ve.add("clientKey", CodeTooLong, "clientKey must be at most %d characters", MaxClientKeyLength)
+	}
+	return ve.err()
+}
+
+//validateDueDate adds a problem to `ve` if `due` isn't
+//a sensible due date: it must be set, and not too far off
+func validateDueDate(due time.Time, ve *ValidationError) {
+	if due.IsZero() {
+		ve.add("dueDate", CodeInvalid, "dueDate must be a real date")
+	} else if due.After(now().AddDate(maxDueYears, 0, 0)) {
+		ve.add("dueDate", CodeOutOfRange, "dueDate must be within %d years", maxDueYears)
+	}
+}
+
+//normalizeTags returns `tags` with the spaces trimmed from
+//each one and the duplicates removed, keeping the first of
+//each. It adds a problem to `ve` for each one that's empty
+//or too long, and if there are too many.
+func normalizeTags(tags []string, ve *ValidationError) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		field := fmt.Sprintf("tags[%d]", i)
+		if len(tag) == 0 {
+			ve.add(field, CodeRequired, "tags must not be empty")
+			continue
+		}
+		if len(tag) > maxTagLength {
+			ve.add(field, CodeTooLong, "tags must be at most %d characters, but %q isn't", maxTagLength, tag)
+			continue
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		ve.add("tags", CodeTooMany, "tasks can have at most %d tags", maxTags)
+	}
+	return normalized
+}
+
+//normalizeTitle returns `title` with the spaces trimmed and
+//in lower case, so that titles that only differ by those,
+//like "Buy milk" and "buy milk ", are the same
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
 }
 
 //ToTask converts a NewTask to a Task
 func (nt *NewTask) ToTask() *Task {
+	//Validate has made sure it's valid, unless it wasn't
+	//called, in which case it's probably empty
+	priority, err := ParsePriority(nt.Priority)
+	if err != nil {
+		priority = PriorityNormal
+	}
 	t := &Task{
-		Title:      nt.Title,
-		Tags:       nt.Tags,
-		CreatedAt:  time.Now(),
-		ModifiedAt: time.Now(),
+		Title:           nt.Title,
+		NormalizedTitle: normalizeTitle(nt.Title),
+		Tags:            nt.Tags,
+		DueDate:         nt.DueDate,
+		Priority:        priority,
+		Owner:           nt.Owner,
+		Complete:        nt.Complete,
+		Version:         1,
+		CreatedAt:       time.Now(),
+		ModifiedAt:      time.Now(),
+	}
+	t.Idempotency = nt.idempotency()
+	//imported tasks can start out complete
+	if t.Complete {
+		completedAt := t.CreatedAt
+		t.CompletedAt = &completedAt
 	}
 
 	return t
 }
+
+//Validate will validate the TaskUpdates,
+//normalizing the tags like NewTask.Validate.
+//If they're invalid, it returns a *ValidationError
+//listing every problem.
+func (tu *TaskUpdates) Validate() error {
+	ve := &ValidationError{}
+	if tu.Title == nil && tu.Complete == nil && tu.Tags == nil && tu.DueDate == nil && !tu.ClearDueDate && tu.Priority == nil && tu.Labels == nil {
+		ve.add("", CodeNoUpdates, "no updates: set title, complete, tags, dueDate, priority and/or labels")
+		return ve
+	}
+	if tu.Title != nil && len(*tu.Title) == 0 {
+		ve.add("title", CodeRequired, "title must be something")
+	}
+	if tu.Tags != nil {
+		tags := normalizeTags(*tu.Tags, ve)
+		tu.Tags = &tags
+	}
+	if tu.DueDate != nil {
+		validateDueDate(*tu.DueDate, ve)
+	}
+	if tu.Priority != nil {
+		validatePriority(*tu.Priority, ve)
+	}
+	if tu.Labels != nil {
+		labels := normalizeLabels(*tu.Labels, ve)
+		tu.Labels = &labels
+	}
+	return ve.err()
+}
+
+//Validate will validate the TaskReplacement,
+//using the same rules as a NewTask
+func (tr *TaskReplacement) Validate() error {
+	return tr.NewTask.Validate()
+}
+
+//Updates returns TaskUpdates that replace every field
+//the client can change with the ones in `tr`
+func (tr *TaskReplacement) Updates() *TaskUpdates {
+	tags := tr.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &TaskUpdates{
+		Title:        &tr.Title,
+		Complete:     &tr.Complete,
+		Tags:         &tags,
+		DueDate:      tr.DueDate,
+		ClearDueDate: tr.DueDate == nil,
+		Priority:     &tr.Priority,
+	}
+}
+
+//Changes returns true if applying the updates to `t` would
+//change any of its fields, so that updating many tasks can
+//leave alone the ones that are already as they should be,
+//rather than bumping their versions
+func (tu *TaskUpdates) Changes(t *Task) bool {
+	if tu.Priority != nil {
+		//Validate has made sure it parses
+		if p, _ := ParsePriority(*tu.Priority); p != t.Priority.orNormal() {
+			return true
+		}
+	}
+	return tu.Title != nil && *tu.Title != t.Title ||
+		tu.Complete != nil && *tu.Complete != t.Complete ||
+		tu.Tags != nil && !sameTags(*tu.Tags, t.Tags) ||
+		tu.Labels != nil && !sameTags(*tu.Labels, t.Labels) ||
+		tu.DueDate != nil && (t.DueDate == nil || !tu.DueDate.Equal(*t.DueDate)) ||
+		tu.ClearDueDate && t.DueDate != nil ||
+		tu.ArchivedAt != nil && (t.ArchivedAt == nil || !tu.ArchivedAt.Equal(*t.ArchivedAt)) ||
+		tu.Unarchive && t.ArchivedAt != nil ||
+		tu.RemindedAt != nil && (t.RemindedAt == nil || !tu.RemindedAt.Equal(*t.RemindedAt)) ||
+		tu.Complete != nil && *tu.Complete && t.RemindedAt != nil
+}
+
+//sameTags returns true if `a` and `b` have
+//the same tags, in the same order
+func sameTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//Apply applies the updates to `t`, leaving fields
+//that weren't updated alone, and bumps its version
+func (tu *TaskUpdates) Apply(t *Task) {
+	if tu.Title != nil {
+		t.Title = *tu.Title
+		t.NormalizedTitle = normalizeTitle(t.Title)
+	}
+	if tu.Complete != nil {
+		//only record when it's completed, so that
+		//completing it again doesn't move the time
+		if *tu.Complete && !t.Complete {
+			completedAt := time.Now()
+			t.CompletedAt = &completedAt
+		} else if !*tu.Complete {
+			t.CompletedAt = nil
+		}
+		//there's nothing left to remind the owner about
+		if *tu.Complete {
+			t.RemindedAt = nil
+		}
+		t.Complete = *tu.Complete
+	}
+	if tu.Tags != nil {
+		t.Tags = append([]string{}, *tu.Tags...)
+	}
+	if tu.Labels != nil {
+		t.Labels = append([]string(nil), *tu.Labels...)
+	}
+	if tu.DueDate != nil {
+		due := *tu.DueDate
+		t.DueDate = &due
+	}
+	if tu.ClearDueDate {
+		t.DueDate = nil
+	}
+	if tu.Priority != nil {
+		t.Priority, _ = ParsePriority(*tu.Priority)
+	}
+	if tu.ArchivedAt != nil {
+		archivedAt := *tu.ArchivedAt
+		t.ArchivedAt = &archivedAt
+	}
+	if tu.Unarchive {
+		t.ArchivedAt = nil
+	}
+	if tu.RemindedAt != nil {
+		remindedAt := *tu.RemindedAt
+		t.RemindedAt = &remindedAt
+	}
+	t.ModifiedAt = time.Now()
+	t.Version++
+}
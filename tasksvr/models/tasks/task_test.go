@@ -0,0 +1,232 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTaskValidateTags(t *testing.T) {
+	nt := &NewTask{Title: "Buy milk", Tags: []string{" errand", "errand ", "shopping", "errand"}}
+	if err := nt.Validate(); err != nil {
+		t.Fatalf("unexpected error validating task: %v", err)
+	}
+	if expected := []string{"errand", "shopping"}; !reflect.DeepEqual(nt.Tags, expected) {
+		t.Errorf("expected tags %v but got %v", expected, nt.Tags)
+	}
+
+	//no tags becomes an empty list rather than null
+	nt = &NewTask{Title: "Buy milk"}
+	if err := nt.Validate(); err != nil || nt.Tags == nil || len(nt.Tags) != 0 {
+		t.Errorf("expected an empty list of tags but got %v, %v", nt.Tags, err)
+	}
+
+	//duplicates don't count towards the maximum
+	tags := []string{}
+	for i := 0; i < maxTags; i++ {
+		tags = append(tags, string(rune('a'+i)), string(rune('a'+i)))
+	}
+	if err := (&NewTask{Title: "Buy milk", Tags: tags}).Validate(); err != nil {
+		t.Errorf("unexpected error validating %d distinct tags: %v", maxTags, err)
+	}
+
+	invalid := [][]string{
+		{"errand", ""},
+		{"   "},
+		{strings.Repeat("x", maxTagLength+1)},
+		append(tags, "one too many"),
+	}
+	for _, tags := range invalid {
+		if err := (&NewTask{Title: "Buy milk", Tags: tags}).Validate(); err == nil {
+			t.Errorf("expected an error validating tags %q", tags)
+		}
+	}
+}
+
+//fieldCodes returns "field:code" for each problem in `err`,
+//which must be a *ValidationError
+func fieldCodes(t *testing.T, err error) []string {
+	ve, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError but got %#v", err)
+	}
+	codes := []string{}
+	for _, fe := range ve.Fields {
+		codes = append(codes, fe.Field+":"+fe.Code)
+	}
+	return codes
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	//every problem is reported, not just the first
+	longTag := strings.Repeat("x", maxTagLength+1)
+	nt := &NewTask{Tags: []string{"ok", "", longTag}, DueDate: &time.Time{}}
+	err := nt.Validate()
+	expected := []string{"title:required", "tags[1]:required", "tags[2]:too_long", "dueDate:invalid"}
+	if codes := fieldCodes(t, err); !reflect.DeepEqual(codes, expected) {
+		t.Errorf("expected problems %v but got %v", expected, codes)
+	}
+	if !strings.HasPrefix(err.Error(), "title must be something; ") {
+		t.Errorf("expected all the messages in the error, got %q", err.Error())
+	}
+
+	title := ""
+	far := time.Now().AddDate(maxDueYears+1, 0, 0)
+	err = (&TaskUpdates{Title: &title, DueDate: &far}).Validate()
+	expected = []string{"title:required", "dueDate:out_of_range"}
+	if codes := fieldCodes(t, err); !reflect.DeepEqual(codes, expected) {
+		t.Errorf("expected problems %v but got %v", expected, codes)
+	}
+
+	err = (&TaskUpdates{}).Validate()
+	if codes := fieldCodes(t, err); !reflect.DeepEqual(codes, []string{":" + CodeNoUpdates}) {
+		t.Errorf("expected only a no_updates problem but got %v", codes)
+	}
+
+	//valid tasks return a nil error, not a nil *ValidationError
+	if err := (&NewTask{Title: "Buy milk"}).Validate(); err != nil {
+		t.Errorf("expected a nil error for a valid task but got %#v", err)
+	}
+}
+
+func TestTaskUpdatesTags(t *testing.T) {
+	tags := []string{"school ", "school", "errand"}
+	tu := &TaskUpdates{Tags: &tags}
+	if err := tu.Validate(); err != nil {
+		t.Fatalf("unexpected error validating updates: %v", err)
+	}
+	task := &Task{Title: "Buy milk", Tags: []string{"old"}}
+	tu.Apply(task)
+	if expected := []string{"school", "errand"}; !reflect.DeepEqual(task.Tags, expected) {
+		t.Errorf("expected tags %v but got %v", expected, task.Tags)
+	}
+
+	empty := []string{""}
+	if err := (&TaskUpdates{Tags: &empty}).Validate(); err == nil {
+		t.Errorf("expected an error validating an empty tag")
+	}
+}
+
+//freezeNow makes now() return `t` until the returned
+//function is called to restore it
+func freezeNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
+
+func TestNewTaskValidateDueDate(t *testing.T) {
+	frozen := time.Date(2017, 4, 20, 12, 0, 0, 0, time.UTC)
+	defer freezeNow(frozen)()
+
+	valid := []time.Time{
+		frozen.AddDate(0, 0, 1),
+		//overdue when created is still a date
+		frozen.AddDate(0, 0, -1),
+		frozen.AddDate(maxDueYears, 0, 0),
+	}
+	for _, due := range valid {
+		due := due
+		if err := (&NewTask{Title: "Buy milk", DueDate: &due}).Validate(); err != nil {
+			t.Errorf("unexpected error validating due date %v: %v", due, err)
+		}
+	}
+
+	invalid := []time.Time{
+		{},
+		frozen.AddDate(maxDueYears, 0, 1),
+	}
+	for _, due := range invalid {
+		due := due
+		if err := (&NewTask{Title: "Buy milk", DueDate: &due}).Validate(); err == nil {
+			t.Errorf("expected an error validating due date %v", due)
+		}
+	}
+}
+
+func TestTaskDueDateJSON(t *testing.T) {
+	nt := &NewTask{}
+	if err := json.Unmarshal([]byte(`{"title": "Buy milk", "dueDate": "2017-05-01T17:00:00-07:00"}`), nt); err != nil {
+		t.Fatalf("error decoding new task: %v", err)
+	}
+	if nt.DueDate == nil || !nt.DueDate.Equal(time.Date(2017, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected due date %v", nt.DueDate)
+	}
+	if err := json.Unmarshal([]byte(`{"title": "Buy milk", "dueDate": "May 1st"}`), &NewTask{}); err == nil {
+		t.Errorf("expected an error decoding a due date that isn't RFC3339")
+	}
+
+	encoded, _ := json.Marshal(nt.ToTask())
+	if !strings.Contains(string(encoded), `"dueDate":"2017-05-01T17:00:00-07:00"`) {
+		t.Errorf("expected the due date in RFC3339 format, got %s", encoded)
+	}
+	//tasks without due dates leave the field out
+	encoded, _ = json.Marshal(&Task{Title: "Buy milk"})
+	if strings.Contains(string(encoded), "dueDate") {
+		t.Errorf("expected no due date, got %s", encoded)
+	}
+}
+
+func TestTaskReplacementUpdates(t *testing.T) {
+	due := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	task := &Task{Title: "Buy milk", Tags: []string{"errand"}, DueDate: &due, Complete: true, Version: 1}
+
+	tr := &TaskReplacement{NewTask: NewTask{Title: "Buy bread"}}
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("unexpected error validating replacement: %v", err)
+	}
+	tr.Updates().Apply(task)
+	//everything that wasn't in the replacement is reset
+	if task.Title != "Buy bread" || task.Complete || len(task.Tags) != 0 || task.DueDate != nil {
+		t.Errorf("unexpected task after replacing: %+v", task)
+	}
+	if task.Version != 2 {
+		t.Errorf("expected version 2 but got %d", task.Version)
+	}
+
+	if err := (&TaskReplacement{}).Validate(); err == nil {
+		t.Errorf("expected an error validating a replacement without a title")
+	}
+}
+
+func TestTaskUpdatesChanges(t *testing.T) {
+	due := time.Date(2017, 5, 1, 17, 0, 0, 0, time.UTC)
+	task := &Task{Title: "Learn Go", Tags: []string{"go"}, DueDate: &due, Priority: PriorityNormal}
+	title, other := "Learn Go", "Learn Rust"
+	complete, incomplete := true, false
+	tags, moreTags := []string{"go"}, []string{"go", "info344"}
+	sameDue := due.In(time.FixedZone("PDT", -7*60*60))
+	normal, high := "normal", "high"
+	archivedAt := time.Now()
+
+	cases := []struct {
+		name     string
+		updates  *TaskUpdates
+		expected bool
+	}{
+		{"same title", &TaskUpdates{Title: &title}, false},
+		{"new title", &TaskUpdates{Title: &other}, true},
+		{"already incomplete", &TaskUpdates{Complete: &incomplete}, false},
+		{"complete", &TaskUpdates{Complete: &complete}, true},
+		{"same tags", &TaskUpdates{Tags: &tags}, false},
+		{"more tags", &TaskUpdates{Tags: &moreTags}, true},
+		{"same due date in another zone", &TaskUpdates{DueDate: &sameDue}, false},
+		{"clear due date", &TaskUpdates{ClearDueDate: true}, true},
+		{"same priority", &TaskUpdates{Priority: &normal}, false},
+		{"new priority", &TaskUpdates{Priority: &high}, true},
+		{"archive", &TaskUpdates{ArchivedAt: &archivedAt}, true},
+		{"restore when not archived", &TaskUpdates{Unarchive: true}, false},
+		{"one of several", &TaskUpdates{Title: &title, Complete: &complete}, true},
+	}
+	for _, c := range cases {
+		if got := c.updates.Changes(task); got != c.expected {
+			t.Errorf("%s: expected Changes to be %t", c.name, c.expected)
+		}
+	}
+	//tasks from before there were priorities count as normal
+	task.Priority = 0
+	if (&TaskUpdates{Priority: &normal}).Changes(task) {
+		t.Errorf("expected normal not to change a task without a priority")
+	}
+}
@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+)
+
+//the codes for the problems in a FieldError
+const (
+	CodeRequired   = "required"
+	CodeTooLong    = "too_long"
+	CodeTooMany    = "too_many"
+	CodeInvalid    = "invalid"
+	CodeOutOfRange = "out_of_range"
+	CodeNoUpdates  = "no_updates"
+	CodeNotFound   = "not_found"
+)
+
+//FieldError describes a problem with one field of a task
+type FieldError struct {
+	//Field is the JSON name of the field, like "title",
+	//or "tags[2]" for one of the tags. It's empty for
+	//problems with the task as a whole.
+	Field string `json:"field"`
+	//Code is one of the codes above
+	Code string `json:"code"`
+	//Message explains the problem to a person
+	Message string `json:"message"`
+}
+
+//ValidationError lists all the problems found validating a
+//task, so that clients can fix them all at once. It's what
+//the Validate methods return, as an error, so callers that
+//only need to know whether a task is valid can treat it like
+//any other error.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+//Error returns all the problems' messages
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve.Fields))
+	for _, fe := range ve.Fields {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//add adds a problem with `field`
+func (ve *ValidationError) add(field string, code string, format string, args ...interface{}) {
+	ve.Fields = append(ve.Fields, &FieldError{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+//err returns `ve` if it has any problems, or nil if it
+//doesn't, so that a valid task returns a nil error rather
+//than a nil *ValidationError, which isn't equal to nil
+func (ve *ValidationError) err() error {
+	if len(ve.Fields) == 0 {
+		return nil
+	}
+	return ve
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+//minFragmentLength is the shortest fragment that
+//can be passed to /zips/city-contains/
+const minFragmentLength = 3
+
+//maxCitySearchResults is the maximum number of zips
+//returned from /zips/city-contains/
+const maxCitySearchResults = 500
+
+//citySearcher finds zips in cities whose names contain
+//a given fragment. The distinct city names are computed
+//once when the searcher is created, so each search is
+//a simple strings.Contains() scan over ~20k names.
+//See BenchmarkCitySearch for the measured cost.
+type citySearcher struct {
+	index  zipIndex
+	cities []string
+}
+
+//citySearchResult is the response for the /zips/city-contains/* resource
+type citySearchResult struct {
+	XMLName   xml.Name `json:"-" xml:"result"`
+	Fragment  string   `json:"fragment" xml:"fragment"`
+	Truncated bool     `json:"truncated" xml:"truncated"`
+	Zips      zipSlice `json:"zips" xml:"zips>zip"`
+}
+
+//newCitySearcher creates a new citySearcher for the
+//lower-cased city names in `zi`
+func newCitySearcher(zi zipIndex) *citySearcher {
+	cities := make([]string, 0, len(zi))
+	for city := range zi {
+		cities = append(cities, city)
+	}
+	//sort so that results come back in a stable order
+	sort.Strings(cities)
+	return &citySearcher{
+		index:  zi,
+		cities: cities,
+	}
+}
+
+//search returns up to `max` zips in cities whose names
+//contain `fragment`, and whether the results were truncated
+func (cs *citySearcher) search(fragment string, max int) (zipSlice, bool) {
+	lfragment := strings.ToLower(fragment)
+	zips := zipSlice{}
+	for _, city := range cs.cities {
+		if !strings.Contains(city, lfragment) {
+			continue
+		}
+		for _, z := range cs.index[city] {
+			if len(zips) == max {
+				return zips, true
+			}
+			zips = append(zips, z)
+		}
+	}
+	return zips, false
+}
+
+//cityContainsHandler handles requests for the /zips/city-contains/* resource
+func (cs *citySearcher) cityContainsHandler(w http.ResponseWriter, r *http.Request) {
+	// /zips/city-contains/harbor
+	_, fragment := path.Split(r.URL.Path)
+	fragment = strings.TrimSpace(fragment)
+	if len(fragment) < minFragmentLength {
+		respondError(w, r, http.StatusBadRequest,
+			fmt.Sprintf("fragment must be at least %d characters", minFragmentLength))
+		return
+	}
+
+	zips, truncated := cs.search(fragment, maxCitySearchResults)
+	respond(w, r, http.StatusOK, &citySearchResult{
+		Fragment:  fragment,
+		Truncated: truncated,
+		Zips:      zips,
+	})
+}
@@ -17,19 +17,25 @@ package main
 import (
 	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
 )
 
 type zip struct {
-	Zip   string `json:"zip"`
-	City  string `json:"city"`
-	State string `json:"state"`
+	XMLName    xml.Name `json:"-" xml:"zip"`
+	Zip        string   `json:"zip" xml:"zip"`
+	City       string   `json:"city" xml:"city"`
+	State      string   `json:"state" xml:"state"`
+	Population int      `json:"population" xml:"population"`
 }
 
 //zipSlice is a slice of pointers to zip structs (*zip)
@@ -40,7 +46,8 @@ type zipIndex map[string]zipSlice
 
 //loadZipsFromCSV loads zip records from a CSV file.
 //This expects that the zip code is in position 0,
-//city is in position 3, and state is in position 6.
+//city is in position 3, state is in position 6,
+//and estimated population is in position 14.
 func loadZipsFromCSV(filePath string) (zipSlice, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -89,6 +96,12 @@ func loadZipsFromCSV(filePath string) (zipSlice, error) {
 			State: record[6],
 		}
 
+		//the population column is sometimes empty,
+		//in which case we just leave it at zero
+		if pop, err := strconv.Atoi(record[14]); err == nil {
+			z.Population = pop
+		}
+
 		//append to the zipSlice
 		zips = append(zips, z)
 	}
@@ -161,14 +174,7 @@ func (zi zipIndex) zipsForCityHandler(w http.ResponseWriter, r *http.Request) {
 	// /zips/city/seattle
 	_, city := path.Split(r.URL.Path)
 	lcity := strings.ToLower(city)
-
-	w.Header().Add("Content-Type", "application/json; charset=utf-8")
-	w.Header().Add("Access-Control-Allow-Origin", "*")
-
-	encoder := json.NewEncoder(w)
-	if err := encoder.Encode(zi[lcity]); err != nil {
-		http.Error(w, "error encoding json: "+err.Error(), http.StatusInternalServerError)
-	}
+	respond(w, r, http.StatusOK, zi[lcity])
 }
 
 //main is the entry-point for all go programs
@@ -188,6 +194,13 @@ func main() {
 		log.Fatal("please set ADDR environment variable")
 	}
 
+	//get the server timeouts, which can be overridden
+	//using environment variables like WRITETIMEOUT=30s
+	timeouts, err := httpmw.TimeoutsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	//load the zip codes from either the JSON or CSV files
 	//comment/uncomment the following two lines to switch
 	//between them
@@ -212,6 +225,24 @@ func main() {
 
 	fmt.Printf("there are %d zips in Seattle\n", len(zi["seattle"]))
 
+	//precompute the distinct city names for
+	//the city-contains search
+	cs := newCitySearcher(zi)
+
+	//build a map of five-digit zip code to zip
+	zci := make(zipCodeIndex, len(zips))
+	for _, z := range zips {
+		zci[z.Zip] = z
+	}
+
+	//build a map of lower-cased state code
+	//to the zips in that state
+	zsi := make(zipStateIndex)
+	for _, z := range zips {
+		lower := strings.ToLower(z.State)
+		zsi[lower] = append(zsi[lower], z)
+	}
+
 	//Register our helloHandler as the handler for
 	//the `/hello` resource path. Whenever a request
 	//is made to this path, the Go web server will
@@ -224,6 +255,31 @@ func main() {
 	//with that path
 	http.HandleFunc("/zips/city/", zi.zipsForCityHandler)
 
+	//Register the cityContainsHandler for any request
+	//path that starts with `/zips/city-contains/`
+	http.HandleFunc("/zips/city-contains/", cs.cityContainsHandler)
+
+	//Register the zipForCodeHandler for any request
+	//path that starts with `/zips/code/`
+	http.HandleFunc("/zips/code/", zci.zipForCodeHandler)
+
+	//Register the zipsHandler for just the `/zips`
+	//path, which is driven by query string parameters
+	http.HandleFunc("/zips", zsi.zipsHandler)
+
+	//If the APIKEYSFILE environment variable is set, require
+	//an API key from a partner on every request except /hello,
+	//and rate limit each partner separately. The file maps
+	//each key to its owner; see httpmw.LoadKeyFile
+	var handler http.Handler = http.DefaultServeMux
+	if keysFile := os.Getenv("APIKEYSFILE"); len(keysFile) > 0 {
+		keys, err := httpmw.LoadKeyFile(keysFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler = requireAPIKeys(handler, keys)
+	}
+
 	//Let the client know what address the server is
 	//listening on. The `fmt` package lets you write
 	//messages to stdout. It can also format messages
@@ -233,12 +289,38 @@ func main() {
 	fmt.Printf("server is listening at %s...\n", addr)
 
 	//Start the web server on the address, and use the
-	//default router. The default router is what you
-	//configured above when you called http.HandleFunc().
-	//http.ListenAndServe() is a blocking function so
+	//handler from above, which wraps the default router.
+	//The default router is what you configured above
+	//when you called http.HandleFunc().
+	//We create an http.Server rather than calling
+	//http.ListenAndServe() so that we can set timeouts;
+	//otherwise a slow client could hold a connection open
+	//forever. srv.ListenAndServe() is a blocking function so
 	//it won't return until the web server is stopped,
 	//but if it can't actually start (e.g., can't bind)
 	//to the port number you gave it), it will return
 	//and error, which we will log using log.Fatal().
-	log.Fatal(http.ListenAndServe(addr, nil))
+	srv := httpmw.NewServer(addr, handler, timeouts)
+	log.Fatal(srv.ListenAndServe())
+}
+
+//requireAPIKeys wraps `handler` so that every request except
+//those for /hello needs one of the API `keys`, and each partner
+//is rate limited separately. Requests for /hello don't have an
+//owner, so they're limited by the client's IP address instead,
+//rather than all of them sharing the same limit.
+func requireAPIKeys(handler http.Handler, keys httpmw.KeyStore) http.Handler {
+	return httpmw.Chain(handler,
+		httpmw.RequireAPIKey(keys, "/hello"),
+		httpmw.RateLimit(&httpmw.RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             20,
+			KeyFunc: func(r *http.Request) string {
+				if owner := httpmw.APIKeyOwnerFromContext(r.Context()); len(owner) > 0 {
+					return "owner:" + owner
+				}
+				return "ip:" + httpmw.ClientIP(r)
+			},
+		}),
+	)
 }
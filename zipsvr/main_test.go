@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/info344-s17/info344-in-class/middleware/httpmw"
+)
+
+func TestHelloRateLimitedPerClient(t *testing.T) {
+	keys := httpmw.StaticKeys{
+		"partner1-key": &httpmw.APIKey{Owner: "partner1", Enabled: true},
+	}
+	handler := requireAPIKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}), keys)
+
+	get := func(remoteAddr string) int {
+		r := httptest.NewRequest("GET", "/hello", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	//use up the first client's burst
+	status := http.StatusOK
+	for i := 0; i < 100 && status == http.StatusOK; i++ {
+		status = get("10.0.0.1:1234")
+	}
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("expected the first client to be rate limited, but got %d", status)
+	}
+
+	//the second client has its own limit
+	if status := get("10.0.0.2:1234"); status != http.StatusOK {
+		t.Errorf("expected %d for a different client, but got %d", http.StatusOK, status)
+	}
+}
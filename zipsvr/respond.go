@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerAccept                   = "Accept"
+	headerContentType              = "Content-Type"
+	headerAccessControlAllowOrigin = "Access-Control-Allow-Origin"
+)
+
+const (
+	charsetUTF8     = "; charset=utf-8"
+	mediaTypeJSON   = "application/json"
+	mediaTypeXML    = "application/xml"
+	contentTypeJSON = mediaTypeJSON + charsetUTF8
+	contentTypeXML  = mediaTypeXML + charsetUTF8
+
+	contentTypeJavaScript = "application/javascript" + charsetUTF8
+)
+
+//maxCallbackLength is the maximum length of a JSONP callback name
+const maxCallbackLength = 64
+
+//callbackRegexp matches safe JSONP callback names: plain
+//JavaScript identifiers, optionally dotted (myApp.handleZips)
+var callbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+//errorResponse is the envelope used for all error responses
+type errorResponse struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Error   string   `json:"error" xml:"message"`
+}
+
+//zipsXML wraps a zipSlice in a <zips> root element,
+//as XML documents must have exactly one root
+type zipsXML struct {
+	XMLName xml.Name `xml:"zips"`
+	Zips    zipSlice `xml:"zip"`
+}
+
+//mediaTypeQuality returns the quality value the Accept
+//header assigns to the given media type, taking wildcards
+//into account. It returns 0 if the type isn't acceptable.
+func mediaTypeQuality(accept string, mediaType string) float64 {
+	best := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if accepted != mediaType && accepted != "*/*" &&
+			accepted != mediaType[:strings.IndexByte(mediaType, '/')]+"/*" {
+			continue
+		}
+		q := 1.0
+		if qs, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		//an exact match beats a wildcard at the same quality
+		if accepted == mediaType {
+			q += 0.0001
+		}
+		if q > best {
+			best = q
+		}
+	}
+	return best
+}
+
+//wantsXML returns true if the client asked for XML,
+//either via the `format` query string parameter
+//or by preferring application/xml in the Accept header
+func wantsXML(r *http.Request) bool {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "xml":
+		return true
+	case "json":
+		return false
+	}
+	accept := r.Header.Get(headerAccept)
+	if len(accept) == 0 {
+		return false
+	}
+	return mediaTypeQuality(accept, mediaTypeXML) > mediaTypeQuality(accept, mediaTypeJSON)
+}
+
+//writeXML writes `v` to `w` as an XML document
+func writeXML(w io.Writer, v interface{}) error {
+	if zips, ok := v.(zipSlice); ok {
+		v = &zipsXML{Zips: zips}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+//writeJSONP writes `v` to `w` as JSON wrapped in
+//a call to the JavaScript function `callback`
+func writeJSONP(w io.Writer, callback string, v interface{}) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);\n", callback, j)
+	return err
+}
+
+//jsonpCallback returns the `callback` query string parameter
+//if this is a JSONP request, or an empty string if it isn't.
+//It returns an error if the callback name isn't a safe
+//JavaScript identifier, or if it's combined with parameters
+//that change the output format.
+func jsonpCallback(r *http.Request) (string, error) {
+	if r.Method != "GET" {
+		return "", nil
+	}
+	query := r.URL.Query()
+	callback := query.Get("callback")
+	if len(callback) == 0 {
+		return "", nil
+	}
+	if len(callback) > maxCallbackLength || !callbackRegexp.MatchString(callback) {
+		return "", fmt.Errorf("invalid JSONP callback name")
+	}
+	if _, found := query["format"]; found {
+		return "", fmt.Errorf("the callback parameter can't be combined with format")
+	}
+	if _, found := query["pretty"]; found {
+		return "", fmt.Errorf("the callback parameter can't be combined with pretty")
+	}
+	return callback, nil
+}
+
+//respond writes `v` to the response with the given
+//status code, encoded as JSON, XML, or JSONP depending
+//on what the client asked for
+func respond(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set(headerAccessControlAllowOrigin, "*")
+
+	callback, err := jsonpCallback(r)
+	if err != nil {
+		//never echo an unsafe callback name back to the
+		//client, so report this as a plain JSON error
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&errorResponse{Error: err.Error()})
+		return
+	}
+
+	switch {
+	case len(callback) > 0:
+		//browsers won't run a script that comes back
+		//with an error status, so JSONP is always 200
+		//and errors are delivered through the callback
+		w.Header().Set(headerContentType, contentTypeJavaScript)
+		w.WriteHeader(http.StatusOK)
+		err = writeJSONP(w, callback, v)
+	case wantsXML(r):
+		w.Header().Set(headerContentType, contentTypeXML)
+		w.WriteHeader(status)
+		err = writeXML(w, v)
+	default:
+		w.Header().Set(headerContentType, contentTypeJSON)
+		w.WriteHeader(status)
+		err = json.NewEncoder(w).Encode(v)
+	}
+	if err != nil {
+		//headers are already gone at this point,
+		//so all we can do is log it
+		log.Printf("error encoding response: %v", err)
+	}
+}
+
+//respondError writes `msg` to the response
+//wrapped in the error envelope
+func respondError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	respond(w, r, status, &errorResponse{Error: msg})
+}
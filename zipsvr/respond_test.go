@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWantsXML(t *testing.T) {
+	cases := []struct {
+		query    string
+		accept   string
+		expected bool
+	}{
+		{"", "", false},
+		{"", "application/json", false},
+		{"", "application/xml", true},
+		{"", "text/html, application/xml;q=0.9, */*;q=0.8", true},
+		{"", "application/xml;q=0.5, application/json", false},
+		{"", "application/json;q=0.5, application/xml", true},
+		{"", "*/*", false},
+		{"format=xml", "", true},
+		{"format=XML", "application/json", true},
+		{"format=json", "application/xml", false},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/zips/city/seattle?"+c.query, nil)
+		if len(c.accept) > 0 {
+			r.Header.Set(headerAccept, c.accept)
+		}
+		if result := wantsXML(r); result != c.expected {
+			t.Errorf("query %q, Accept %q: expected %t but got %t", c.query, c.accept, c.expected, result)
+		}
+	}
+}
+
+//respondBoth writes `v` as both JSON and XML
+//and returns the two recorded responses
+func respondBoth(status int, v interface{}, isError bool) (*httptest.ResponseRecorder, *httptest.ResponseRecorder) {
+	jw := httptest.NewRecorder()
+	jr := httptest.NewRequest("GET", "/zips", nil)
+	xw := httptest.NewRecorder()
+	xr := httptest.NewRequest("GET", "/zips", nil)
+	xr.Header.Set(headerAccept, mediaTypeXML)
+
+	if isError {
+		respondError(jw, jr, status, v.(string))
+		respondError(xw, xr, status, v.(string))
+	} else {
+		respond(jw, jr, status, v)
+		respond(xw, xr, status, v)
+	}
+	return jw, xw
+}
+
+func TestRespondXMLRoundTrip(t *testing.T) {
+	zips := zipSlice{
+		&zip{Zip: "98103", City: "Seattle", State: "WA", Population: 45000},
+		&zip{Zip: "98105", City: "Seattle", State: "WA", Population: 44000},
+	}
+
+	jw, xw := respondBoth(http.StatusOK, zips, false)
+	if ctype := xw.Header().Get(headerContentType); ctype != contentTypeXML {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeXML, ctype)
+	}
+	if !strings.Contains(xw.Body.String(), "<zips><zip><zip>98103</zip>") {
+		t.Errorf("expected zips to be wrapped in a <zips> root element, got %s", xw.Body.String())
+	}
+
+	fromJSON := zipSlice{}
+	if err := json.Unmarshal(jw.Body.Bytes(), &fromJSON); err != nil {
+		t.Fatalf("error unmarshaling JSON: %v", err)
+	}
+	fromXML := &zipsXML{}
+	if err := xml.Unmarshal(xw.Body.Bytes(), fromXML); err != nil {
+		t.Fatalf("error unmarshaling XML: %v", err)
+	}
+	for _, z := range fromXML.Zips {
+		z.XMLName = xml.Name{}
+	}
+	if !reflect.DeepEqual(fromJSON, fromXML.Zips) {
+		t.Errorf("XML and JSON didn't match:\nJSON: %+v\nXML: %+v", fromJSON, fromXML.Zips)
+	}
+
+	result := &zipCodeResult{Code: "98103", Zip: zips[0]}
+	jw, xw = respondBoth(http.StatusOK, result, false)
+	resultFromJSON := &zipCodeResult{}
+	if err := json.Unmarshal(jw.Body.Bytes(), resultFromJSON); err != nil {
+		t.Fatalf("error unmarshaling JSON: %v", err)
+	}
+	resultFromXML := &zipCodeResult{}
+	if err := xml.Unmarshal(xw.Body.Bytes(), resultFromXML); err != nil {
+		t.Fatalf("error unmarshaling XML: %v", err)
+	}
+	resultFromXML.XMLName = xml.Name{}
+	resultFromXML.Zip.XMLName = xml.Name{}
+	if !reflect.DeepEqual(resultFromJSON, resultFromXML) {
+		t.Errorf("XML and JSON didn't match:\nJSON: %+v\nXML: %+v", resultFromJSON, resultFromXML)
+	}
+}
+
+func TestRespondErrorXML(t *testing.T) {
+	jw, xw := respondBoth(http.StatusBadRequest, "bad zip", true)
+	if xw.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d but got %d", http.StatusBadRequest, xw.Code)
+	}
+	if ctype := xw.Header().Get(headerContentType); ctype != contentTypeXML {
+		t.Errorf("expected Content-Type %s but got %s", contentTypeXML, ctype)
+	}
+
+	fromJSON := &errorResponse{}
+	if err := json.Unmarshal(jw.Body.Bytes(), fromJSON); err != nil {
+		t.Fatalf("error unmarshaling JSON: %v", err)
+	}
+	fromXML := &errorResponse{}
+	if err := xml.Unmarshal(xw.Body.Bytes(), fromXML); err != nil {
+		t.Fatalf("error unmarshaling XML: %v", err)
+	}
+	if fromXML.XMLName.Local != "error" {
+		t.Errorf("expected <error> root element but got <%s>", fromXML.XMLName.Local)
+	}
+	if fromXML.Error != fromJSON.Error || fromXML.Error != "bad zip" {
+		t.Errorf("expected error messages to match: JSON %q, XML %q", fromJSON.Error, fromXML.Error)
+	}
+}
+
+func TestRespondJSONP(t *testing.T) {
+	zci := zipCodeIndex{
+		"98103": &zip{Zip: "98103", City: "Seattle", State: "WA"},
+	}
+
+	cases := []struct {
+		path           string
+		expectedStatus int
+		expectedType   string
+		expectedPrefix string
+	}{
+		{"/zips/code/98103?callback=handleZip", http.StatusOK, contentTypeJavaScript, `handleZip({"code":"98103"`},
+		{"/zips/code/98103?callback=myApp.zips.handle", http.StatusOK, contentTypeJavaScript, `myApp.zips.handle({"code"`},
+		{"/zips/code/garbage?callback=handleZip", http.StatusOK, contentTypeJavaScript, `handleZip({"error":`},
+		{"/zips/code/98103?callback=alert(1)", http.StatusBadRequest, contentTypeJSON, `{"error":`},
+		{"/zips/code/98103?callback=%3Cscript%3E", http.StatusBadRequest, contentTypeJSON, `{"error":`},
+		{"/zips/code/98103?callback=1abc", http.StatusBadRequest, contentTypeJSON, `{"error":`},
+		{"/zips/code/98103?callback=a." + strings.Repeat("b", maxCallbackLength), http.StatusBadRequest, contentTypeJSON, `{"error":`},
+		{"/zips/code/98103?callback=handleZip&format=xml", http.StatusBadRequest, contentTypeJSON, `{"error":`},
+		{"/zips/code/98103?callback=handleZip&pretty=true", http.StatusBadRequest, contentTypeJSON, `{"error":`},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		zci.zipForCodeHandler(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", c.path, c.expectedStatus, w.Code)
+		}
+		if ctype := w.Header().Get(headerContentType); ctype != c.expectedType {
+			t.Errorf("%s: expected Content-Type %s but got %s", c.path, c.expectedType, ctype)
+		}
+		if body := w.Body.String(); !strings.HasPrefix(body, c.expectedPrefix) {
+			t.Errorf("%s: expected body to start with %s but got %s", c.path, c.expectedPrefix, body)
+		}
+		if c.expectedType == contentTypeJavaScript && !strings.HasSuffix(w.Body.String(), ");\n") {
+			t.Errorf("%s: expected body to end with the closing call, got %s", c.path, w.Body.String())
+		}
+	}
+}
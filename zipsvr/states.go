@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//maxStatesPerRequest is the maximum number of states
+//that can be requested in one call to /zips
+const maxStatesPerRequest = 10
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+//zipStateIndex is a map of lower-cased state code
+//to the zips in that state
+type zipStateIndex map[string]zipSlice
+
+//zipsResult is the response for the /zips resource
+type zipsResult struct {
+	XMLName         xml.Name `json:"-" xml:"result"`
+	Total           int      `json:"total" xml:"total"`
+	Offset          int      `json:"offset" xml:"offset"`
+	Limit           int      `json:"limit" xml:"limit"`
+	DuplicateStates []string `json:"duplicateStates,omitempty" xml:"duplicateStates>state,omitempty"`
+	UnknownStates   []string `json:"unknownStates,omitempty" xml:"unknownStates>state,omitempty"`
+	Zips            zipSlice `json:"zips" xml:"zips>zip"`
+}
+
+//intParam returns the named query string parameter
+//as an int, or def if the parameter wasn't supplied
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+//pageParams returns the offset and limit query string parameters
+func pageParams(r *http.Request) (offset int, limit int, err error) {
+	if offset, err = intParam(r, "offset", 0); err != nil {
+		return 0, 0, err
+	}
+	if limit, err = intParam(r, "limit", defaultPageLimit); err != nil {
+		return 0, 0, err
+	}
+	if limit == 0 || limit > maxPageLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return offset, limit, nil
+}
+
+//zipsHandler handles requests for the /zips resource
+func (zsi zipStateIndex) zipsHandler(w http.ResponseWriter, r *http.Request) {
+	// /zips?states=wa,or,id&minpop=1000&offset=0&limit=100
+	statesParam := r.URL.Query().Get("states")
+	if len(statesParam) == 0 {
+		respondError(w, r, http.StatusBadRequest, "please supply a `states` query string parameter")
+		return
+	}
+	states := strings.Split(statesParam, ",")
+	if len(states) > maxStatesPerRequest {
+		respondError(w, r, http.StatusBadRequest,
+			fmt.Sprintf("too many states: at most %d may be requested at once", maxStatesPerRequest))
+		return
+	}
+
+	minpop, err := intParam(r, "minpop", 0)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := &zipsResult{Offset: offset, Limit: limit}
+	matches := zipSlice{}
+	seen := make(map[string]bool, len(states))
+	for _, state := range states {
+		lstate := strings.ToLower(strings.TrimSpace(state))
+		if seen[lstate] {
+			result.DuplicateStates = append(result.DuplicateStates, state)
+			continue
+		}
+		seen[lstate] = true
+
+		zips, found := zsi[lstate]
+		if !found {
+			result.UnknownStates = append(result.UnknownStates, state)
+			continue
+		}
+		for _, z := range zips {
+			if z.Population >= minpop {
+				matches = append(matches, z)
+			}
+		}
+	}
+
+	//states don't overlap, so the union is just the
+	//concatenation; sort it so the order doesn't depend
+	//on the order the states were requested in
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Zip < matches[j].Zip
+	})
+
+	result.Total = len(matches)
+	result.Zips = zipSlice{}
+	if offset < len(matches) {
+		end := offset + limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		result.Zips = matches[offset:end]
+	}
+	respond(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testStateIndex() zipStateIndex {
+	return zipStateIndex{
+		"wa": zipSlice{
+			&zip{Zip: "98103", City: "Seattle", State: "WA", Population: 45000},
+			&zip{Zip: "98335", City: "Gig Harbor", State: "WA", Population: 30000},
+			&zip{Zip: "99362", City: "Walla Walla", State: "WA", Population: 500},
+		},
+		"or": zipSlice{
+			&zip{Zip: "97201", City: "Portland", State: "OR", Population: 15000},
+		},
+		"id": zipSlice{
+			&zip{Zip: "83702", City: "Boise", State: "ID", Population: 20000},
+		},
+	}
+}
+
+func getZips(t *testing.T, zsi zipStateIndex, query string) (*httptest.ResponseRecorder, *zipsResult) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/zips?"+query, nil)
+	zsi.zipsHandler(w, r)
+	if w.Code != http.StatusOK {
+		return w, nil
+	}
+	result := &zipsResult{}
+	if err := json.NewDecoder(w.Body).Decode(result); err != nil {
+		t.Fatalf("%s: error decoding response: %v", query, err)
+	}
+	return w, result
+}
+
+func zipCodes(zips zipSlice) string {
+	codes := make([]string, len(zips))
+	for i, z := range zips {
+		codes[i] = z.Zip
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestZipsHandlerStates(t *testing.T) {
+	zsi := testStateIndex()
+
+	cases := []struct {
+		query     string
+		expected  string
+		duplicate string
+		unknown   string
+	}{
+		{"states=wa", "98103,98335,99362", "", ""},
+		{"states=WA,or,Id", "83702,97201,98103,98335,99362", "", ""},
+		{"states=id,or,wa", "83702,97201,98103,98335,99362", "", ""},
+		{"states=wa,WA", "98103,98335,99362", "WA", ""},
+		{"states=or,zz", "97201", "", "zz"},
+		{"states=wa&minpop=20000", "98103,98335", "", ""},
+		{"states=wa,or,id&limit=2", "83702,97201", "", ""},
+		{"states=wa,or,id&limit=2&offset=2", "98103,98335", "", ""},
+		{"states=wa&offset=10", "", "", ""},
+	}
+
+	for _, c := range cases {
+		_, result := getZips(t, zsi, c.query)
+		if result == nil {
+			t.Errorf("%s: expected 200 response", c.query)
+			continue
+		}
+		if codes := zipCodes(result.Zips); codes != c.expected {
+			t.Errorf("%s: expected zips %s but got %s", c.query, c.expected, codes)
+		}
+		if dups := strings.Join(result.DuplicateStates, ","); dups != c.duplicate {
+			t.Errorf("%s: expected duplicates %q but got %q", c.query, c.duplicate, dups)
+		}
+		if unknown := strings.Join(result.UnknownStates, ","); unknown != c.unknown {
+			t.Errorf("%s: expected unknown %q but got %q", c.query, c.unknown, unknown)
+		}
+	}
+}
+
+func TestZipsHandlerErrors(t *testing.T) {
+	zsi := testStateIndex()
+	tooMany := strings.Repeat("wa,", maxStatesPerRequest) + "or"
+
+	cases := []string{
+		"",
+		"states=" + tooMany,
+		"states=wa&minpop=lots",
+		"states=wa&limit=0",
+		"states=wa&limit=100000",
+		"states=wa&offset=-1",
+	}
+
+	for _, query := range cases {
+		w, _ := getZips(t, zsi, query)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	w, _ := getZips(t, zsi, "states="+tooMany)
+	if !strings.Contains(w.Body.String(), fmt.Sprintf("at most %d", maxStatesPerRequest)) {
+		t.Errorf("expected the cap to be documented in the error, but got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+//zipCodeIndex is a map of five-digit zip code to zip
+type zipCodeIndex map[string]*zip
+
+//zipCodeResult is the response for the /zips/code/* resource.
+//Code is the normalized five-digit code that was matched,
+//which may differ from what the client sent.
+type zipCodeResult struct {
+	XMLName xml.Name `json:"-" xml:"result"`
+	Code    string   `json:"code" xml:"code"`
+	Zip     *zip     `json:"zip" xml:"zip"`
+}
+
+//isDigits returns true if s is non-empty
+//and contains only the digits 0-9
+func isDigits(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//normalizeZip converts a client-supplied zip code into
+//the five-digit form used by the index. ZIP+4 codes
+//(98103-1234) have their suffix removed, and four-digit
+//codes that lost their leading zero (2134) are padded.
+func normalizeZip(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if idx := strings.IndexByte(code, '-'); idx >= 0 {
+		plus4 := code[idx+1:]
+		if len(plus4) != 4 || !isDigits(plus4) {
+			return "", fmt.Errorf("invalid ZIP+4 suffix in %q", code)
+		}
+		code = code[:idx]
+	}
+	if !isDigits(code) {
+		return "", fmt.Errorf("%q is not a valid zip code", code)
+	}
+	switch len(code) {
+	case 5:
+		return code, nil
+	case 4:
+		return "0" + code, nil
+	default:
+		return "", fmt.Errorf("%q is not a valid zip code", code)
+	}
+}
+
+//zipForCodeHandler handles requests for the /zips/code/* resource
+func (zci zipCodeIndex) zipForCodeHandler(w http.ResponseWriter, r *http.Request) {
+	// /zips/code/98103-1234
+	_, code := path.Split(r.URL.Path)
+	normalized, err := normalizeZip(code)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	z, found := zci[normalized]
+	if !found {
+		respondError(w, r, http.StatusNotFound, "no zip found for code "+normalized)
+		return
+	}
+	respond(w, r, http.StatusOK, &zipCodeResult{Code: normalized, Zip: z})
+}
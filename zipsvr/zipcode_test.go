@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeZip(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"five digits", "98103", "98103", true},
+		{"plus 4", "98103-1234", "98103", true},
+		{"padded", "2134", "02134", true},
+		{"padded plus 4", "2134-0001", "02134", true},
+		{"surrounding spaces", " 98103 ", "98103", true},
+		{"empty", "", "", false},
+		{"too short", "981", "", false},
+		{"too long", "981031", "", false},
+		{"letters", "9810a", "", false},
+		{"short suffix", "98103-12", "", false},
+		{"letters in suffix", "98103-12ab", "", false},
+		{"empty suffix", "98103-", "", false},
+		{"nine digits no dash", "981031234", "", false},
+	}
+
+	for _, c := range cases {
+		code, err := normalizeZip(c.input)
+		if c.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: expected error for %q but got none", c.name, c.input)
+		}
+		if code != c.expected {
+			t.Errorf("%s: expected %q but got %q", c.name, c.expected, code)
+		}
+	}
+}
+
+func TestZipForCodeHandler(t *testing.T) {
+	zci := zipCodeIndex{
+		"98103": &zip{Zip: "98103", City: "Seattle", State: "WA"},
+		"02134": &zip{Zip: "02134", City: "Allston", State: "MA"},
+	}
+
+	cases := []struct {
+		path         string
+		expectedCode int
+		expectedZip  string
+	}{
+		{"/zips/code/98103", http.StatusOK, "98103"},
+		{"/zips/code/98103-1234", http.StatusOK, "98103"},
+		{"/zips/code/2134", http.StatusOK, "02134"},
+		{"/zips/code/99999", http.StatusNotFound, ""},
+		{"/zips/code/garbage", http.StatusBadRequest, ""},
+		{"/zips/code/98103-abcd", http.StatusBadRequest, ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", c.path, nil)
+		zci.zipForCodeHandler(w, r)
+
+		if w.Code != c.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", c.path, c.expectedCode, w.Code)
+			continue
+		}
+		if c.expectedCode != http.StatusOK {
+			errResp := &errorResponse{}
+			if err := json.NewDecoder(w.Body).Decode(errResp); err != nil {
+				t.Errorf("%s: error decoding error envelope: %v", c.path, err)
+			} else if len(errResp.Error) == 0 {
+				t.Errorf("%s: expected error message in envelope", c.path)
+			}
+			continue
+		}
+
+		result := &zipCodeResult{}
+		if err := json.NewDecoder(w.Body).Decode(result); err != nil {
+			t.Fatalf("%s: error decoding response: %v", c.path, err)
+		}
+		if result.Code != c.expectedZip || result.Zip == nil || result.Zip.Zip != c.expectedZip {
+			t.Errorf("%s: expected zip %s but got %+v", c.path, c.expectedZip, result)
+		}
+	}
+}